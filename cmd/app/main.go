@@ -1,8 +1,12 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"log"
+	"os"
+	"os/signal"
+	"syscall"
 
 	"github.com/gin-gonic/gin"
 	"github.com/vcnt72/go-boilerplate/internal/config"
@@ -12,19 +16,29 @@ import (
 	"github.com/vcnt72/go-boilerplate/internal/router"
 	"github.com/vcnt72/go-boilerplate/internal/service"
 	"github.com/vcnt72/go-boilerplate/internal/utils/logger"
+	"github.com/vcnt72/go-boilerplate/internal/worker"
 )
 
 func main() {
 	config.Load()
 	logger.Load()
-	db := database.NewPostgres()
+	db := database.New()
 	routerEngine := gin.Default()
 
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
 	repositories := repository.New(db)
 
 	services := service.New(repositories)
 
-	handlers := handler.New(services)
+	workers, err := worker.New(services)
+	if err != nil {
+		log.Fatalf("%v", err)
+	}
+	go workers.Run(ctx)
+
+	handlers := handler.New(services, workers, repositories.TxProvider)
 
 	router.New(routerEngine, handlers)
 
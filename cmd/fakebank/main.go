@@ -0,0 +1,42 @@
+// Command fakebank serves the in-process fake bank on its own port, so the API
+// can be run against it with PAYOUT_PROVIDER=http. Fault rates are fractions
+// between 0 and 1.
+package main
+
+import (
+	"flag"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/vcnt72/go-boilerplate/internal/fakebank"
+)
+
+func main() {
+	addr := flag.String("addr", ":8090", "listen address")
+	delay := flag.Duration("delay", 0, "delay before every answer")
+	fail := flag.Float64("fail", 0, "share of requests answered 503")
+	timeout := flag.Float64("timeout", 0, "share of payouts taken but never answered")
+	reject := flag.Float64("reject", 0, "share of payouts that fail")
+	callbackURL := flag.String("callback-url", "", "report outcomes asynchronously to this URL, e.g. http://localhost:8000/v1/payouts/callback")
+	callbackSecret := flag.String("callback-secret", "", "secret signing callbacks, as PAYOUT_CALLBACK_SECRET")
+	callbackDelay := flag.Duration("callback-delay", time.Second, "delay before a callback")
+	duplicate := flag.Float64("duplicate", 0, "share of callbacks delivered twice")
+	seed := flag.Uint64("seed", uint64(time.Now().UnixNano()), "seed of the injected faults")
+	flag.Parse()
+
+	bank := fakebank.New(fakebank.Options{
+		Delay:          *delay,
+		FailRate:       *fail,
+		TimeoutRate:    *timeout,
+		RejectRate:     *reject,
+		CallbackURL:    *callbackURL,
+		CallbackSecret: *callbackSecret,
+		CallbackDelay:  *callbackDelay,
+		DuplicateRate:  *duplicate,
+		Seed:           *seed,
+	})
+
+	log.Printf("fake bank listening on %s", *addr)
+	log.Fatal(http.ListenAndServe(*addr, bank))
+}
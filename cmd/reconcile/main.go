@@ -0,0 +1,95 @@
+// Command reconcile compares every wallet's stored balance with the balance
+// derived from its ledgers and journal postings. Drifting wallets are written
+// to stdout as JSON lines or CSV; the process exits with status 1 when any
+// drift is found and 2 when the check itself fails.
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/signal"
+	"strconv"
+
+	"github.com/vcnt72/go-boilerplate/internal/config"
+	"github.com/vcnt72/go-boilerplate/internal/database"
+	"github.com/vcnt72/go-boilerplate/internal/domain"
+	"github.com/vcnt72/go-boilerplate/internal/repository"
+	"github.com/vcnt72/go-boilerplate/internal/service"
+)
+
+func main() {
+	format := flag.String("format", "json", "report format: json or csv")
+	batchSize := flag.Int("batch", 500, "number of wallets checked per batch")
+	flag.Parse()
+
+	report, flush, err := newReporter(*format, os.Stdout)
+	if err != nil {
+		log.Println(err)
+		os.Exit(2)
+	}
+
+	config.Load()
+	db := database.New()
+	defer db.Close()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	services := service.New(repository.New(db))
+
+	summary, err := services.ReconcileService.Reconcile(ctx, service.ReconcileSpec{
+		BatchSize: *batchSize,
+		OnDrift:   report,
+	})
+	if ferr := flush(); ferr != nil && err == nil {
+		err = ferr
+	}
+	if err != nil {
+		log.Println(err)
+		os.Exit(2)
+	}
+
+	log.Printf("checked %d wallets, %d drifted", summary.Checked, summary.Drifted)
+	if summary.Drifted > 0 {
+		os.Exit(1)
+	}
+}
+
+func newReporter(format string, w io.Writer) (func(domain.WalletDrift) error, func() error, error) {
+	switch format {
+	case "json":
+		enc := json.NewEncoder(w)
+		return func(d domain.WalletDrift) error { return enc.Encode(d) },
+			func() error { return nil },
+			nil
+
+	case "csv":
+		cw := csv.NewWriter(w)
+		if err := cw.Write([]string{"wallet_id", "user_id", "balance", "ledger_balance", "postings_balance"}); err != nil {
+			return nil, nil, err
+		}
+		return func(d domain.WalletDrift) error {
+				return cw.Write([]string{
+					strconv.FormatInt(d.WalletID, 10),
+					strconv.FormatInt(d.UserID, 10),
+					strconv.FormatInt(d.Balance, 10),
+					strconv.FormatInt(d.LedgerBalance, 10),
+					strconv.FormatInt(d.PostingsBalance, 10),
+				})
+			},
+			func() error {
+				cw.Flush()
+				return cw.Error()
+			},
+			nil
+
+	default:
+		return nil, nil, fmt.Errorf("unknown format %q", format)
+	}
+}
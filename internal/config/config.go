@@ -3,13 +3,97 @@ package config
 
 import (
 	"os"
+	"strconv"
+	"time"
 
 	"github.com/joho/godotenv"
 )
 
 type env struct {
-	Port  string
-	DBUrl string
+	Port   string
+	DBUrl  string
+	AppEnv string
+
+	// DBDriver is "postgres" (default) or "sqlite", in which case DBUrl is
+	// the database file, such as "wallet.db".
+	DBDriver string
+
+	// AuthMode is "jwt" (default) or "header". Header mode trusts X-User-ID
+	// and is refused when AppEnv is "production".
+	AuthMode         string
+	JWTAlgorithm     string
+	JWTSecret        string
+	JWTPublicKeyFile string
+	JWTIssuer        string
+	JWTAudience      string
+
+	// Withdrawal limits used when neither the user nor its tier has a row in
+	// withdrawal_limits. Zero disables a limit.
+	WithdrawMaxPerTransaction int64
+	WithdrawMaxDailyAmount    int64
+	WithdrawMaxDailyCount     int64
+
+	// Withdrawal fee used when neither the user nor its tier has a row in
+	// fee_schedules. All zero charges no fee.
+	WithdrawFeeFlat       int64
+	WithdrawFeePercentBps int64
+	WithdrawFeeMin        int64
+	WithdrawFeeMax        int64
+
+	// WithdrawIsolation is the isolation level of the withdrawal
+	// transaction: "read_committed" (default), "repeatable_read" or
+	// "serializable".
+	WithdrawIsolation string
+	// TxMaxRetries caps how many times a withdrawal transaction is retried
+	// after a serialization failure or deadlock.
+	TxMaxRetries int
+
+	// DefaultCurrency is the currency of new users' first wallet and of
+	// requests that do not name a currency.
+	DefaultCurrency string
+
+	// HoldDefaultTTL is how long a hold lasts when the caller does not say.
+	HoldDefaultTTL time.Duration
+
+	// FXRatesFile, when set, is a JSON file of FX rates used instead of the
+	// admin-set fx_rates table.
+	FXRatesFile string
+	// FXQuoteTTL is how long a quoted rate and fee stay locked.
+	FXQuoteTTL time.Duration
+
+	// PayoutProvider is "simulator" (default) or "http", which sends payouts
+	// to the bank at PayoutProviderURL, such as cmd/fakebank.
+	PayoutProvider        string
+	PayoutProviderURL     string
+	PayoutProviderTimeout time.Duration
+	// PayoutSimulatorOutcome is what the simulated payout provider answers
+	// new payouts with: "succeed", "fail" or "pending".
+	PayoutSimulatorOutcome string
+	// PayoutCallbackSecret signs the provider's status callbacks. Unsigned
+	// callbacks are accepted only when it is empty outside production.
+	PayoutCallbackSecret string
+	// PayoutCallbackTolerance bounds the age of a signed callback.
+	PayoutCallbackTolerance time.Duration
+
+	SweeperInterval   time.Duration
+	SweeperStaleAfter time.Duration
+	SweeperBatchSize  int
+
+	// OutboxSinks is a comma separated list of "stdout", "webhook" and "file".
+	OutboxSinks         string
+	OutboxWebhookURL    string
+	OutboxFilePath      string
+	OutboxRelayInterval time.Duration
+	OutboxBatchSize     int
+	// OutboxLease is how long a relay keeps a claimed batch to itself.
+	OutboxLease time.Duration
+
+	WebhookDispatchInterval time.Duration
+	WebhookBatchSize        int
+	WebhookMaxAttempts      int
+	WebhookTimeout          time.Duration
+	// WebhookLease is how long a dispatcher keeps a claimed batch to itself.
+	WebhookLease time.Duration
 }
 
 var Env env
@@ -20,7 +104,110 @@ func Load() {
 	}
 
 	Env = env{
-		Port:  os.Getenv("PORT"),
-		DBUrl: os.Getenv("DB_URL"),
+		Port:   os.Getenv("PORT"),
+		DBUrl:  os.Getenv("DB_URL"),
+		AppEnv: getString("APP_ENV", "development"),
+
+		DBDriver: getString("DB_DRIVER", "postgres"),
+
+		AuthMode:         getString("AUTH_MODE", "jwt"),
+		JWTAlgorithm:     getString("JWT_ALGORITHM", "HS256"),
+		JWTSecret:        os.Getenv("JWT_SECRET"),
+		JWTPublicKeyFile: os.Getenv("JWT_PUBLIC_KEY_FILE"),
+		JWTIssuer:        os.Getenv("JWT_ISSUER"),
+		JWTAudience:      os.Getenv("JWT_AUDIENCE"),
+
+		WithdrawMaxPerTransaction: getInt64("WITHDRAW_MAX_PER_TRANSACTION", 0),
+		WithdrawMaxDailyAmount:    getInt64("WITHDRAW_MAX_DAILY_AMOUNT", 0),
+		WithdrawMaxDailyCount:     getInt64("WITHDRAW_MAX_DAILY_COUNT", 0),
+
+		WithdrawFeeFlat:       getInt64("WITHDRAW_FEE_FLAT", 0),
+		WithdrawFeePercentBps: getInt64("WITHDRAW_FEE_PERCENT_BPS", 0),
+		WithdrawFeeMin:        getInt64("WITHDRAW_FEE_MIN", 0),
+		WithdrawFeeMax:        getInt64("WITHDRAW_FEE_MAX", 0),
+
+		WithdrawIsolation: getString("WITHDRAW_ISOLATION", "read_committed"),
+		TxMaxRetries:      getInt("TX_MAX_RETRIES", 3),
+
+		DefaultCurrency: getString("DEFAULT_CURRENCY", "IDR"),
+
+		HoldDefaultTTL: getDuration("HOLD_DEFAULT_TTL", 7*24*time.Hour),
+
+		FXRatesFile: os.Getenv("FX_RATES_FILE"),
+		FXQuoteTTL:  getDuration("FX_QUOTE_TTL", 30*time.Second),
+
+		PayoutProvider:          getString("PAYOUT_PROVIDER", "simulator"),
+		PayoutProviderURL:       os.Getenv("PAYOUT_PROVIDER_URL"),
+		PayoutProviderTimeout:   getDuration("PAYOUT_PROVIDER_TIMEOUT", 10*time.Second),
+		PayoutSimulatorOutcome:  getString("PAYOUT_SIMULATOR_OUTCOME", "succeed"),
+		PayoutCallbackSecret:    os.Getenv("PAYOUT_CALLBACK_SECRET"),
+		PayoutCallbackTolerance: getDuration("PAYOUT_CALLBACK_TOLERANCE", 5*time.Minute),
+
+		SweeperInterval:   getDuration("SWEEPER_INTERVAL", time.Minute),
+		SweeperStaleAfter: getDuration("SWEEPER_STALE_AFTER", 5*time.Minute),
+		SweeperBatchSize:  getInt("SWEEPER_BATCH_SIZE", 100),
+
+		OutboxSinks:         getString("OUTBOX_SINKS", "stdout"),
+		OutboxWebhookURL:    os.Getenv("OUTBOX_WEBHOOK_URL"),
+		OutboxFilePath:      os.Getenv("OUTBOX_FILE_PATH"),
+		OutboxRelayInterval: getDuration("OUTBOX_RELAY_INTERVAL", time.Second),
+		OutboxBatchSize:     getInt("OUTBOX_BATCH_SIZE", 100),
+		OutboxLease:         getDuration("OUTBOX_LEASE", 5*time.Minute),
+
+		WebhookDispatchInterval: getDuration("WEBHOOK_DISPATCH_INTERVAL", time.Second),
+		WebhookBatchSize:        getInt("WEBHOOK_BATCH_SIZE", 50),
+		WebhookMaxAttempts:      getInt("WEBHOOK_MAX_ATTEMPTS", 8),
+		WebhookTimeout:          getDuration("WEBHOOK_TIMEOUT", 10*time.Second),
+		WebhookLease:            getDuration("WEBHOOK_LEASE", 15*time.Minute),
 	}
 }
+
+func getString(key string, fallback string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+
+	return fallback
+}
+
+func getDuration(key string, fallback time.Duration) time.Duration {
+	v, ok := os.LookupEnv(key)
+	if !ok || v == "" {
+		return fallback
+	}
+
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		panic(err)
+	}
+
+	return d
+}
+
+func getInt64(key string, fallback int64) int64 {
+	v, ok := os.LookupEnv(key)
+	if !ok || v == "" {
+		return fallback
+	}
+
+	i, err := strconv.ParseInt(v, 10, 64)
+	if err != nil {
+		panic(err)
+	}
+
+	return i
+}
+
+func getInt(key string, fallback int) int {
+	v, ok := os.LookupEnv(key)
+	if !ok || v == "" {
+		return fallback
+	}
+
+	i, err := strconv.Atoi(v)
+	if err != nil {
+		panic(err)
+	}
+
+	return i
+}
@@ -0,0 +1,22 @@
+// Package database opens the database selected by DB_DRIVER.
+package database
+
+import (
+	"log"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/vcnt72/go-boilerplate/internal/config"
+)
+
+// New opens the Postgres or SQLite database at DB_URL, as DB_DRIVER says.
+func New() *sqlx.DB {
+	switch config.Env.DBDriver {
+	case "postgres":
+		return NewPostgres()
+	case "sqlite":
+		return NewSQLite()
+	default:
+		log.Fatalf("unknown DB_DRIVER %q", config.Env.DBDriver)
+		return nil
+	}
+}
@@ -0,0 +1,38 @@
+package database
+
+import (
+	"log"
+	"strings"
+
+	"github.com/jmoiron/sqlx"
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/vcnt72/go-boilerplate/internal/config"
+)
+
+// sqliteOptions are added to DB_URL. Foreign keys are enforced, readers do not
+// wait for the writer, and every transaction takes the write lock when it
+// begins, waiting for it rather than failing, so transactions run one at a
+// time and the repositories need no row locks.
+const sqliteOptions = "_foreign_keys=on&_journal_mode=WAL&_busy_timeout=5000&_txlock=immediate"
+
+// NewSQLite opens the SQLite database file at DB_URL, for local demos and edge
+// deployments. Its schema is migrated from migrations/sqlite.
+func NewSQLite() *sqlx.DB {
+	db := sqlx.MustOpen("sqlite3", SQLiteDSN(config.Env.DBUrl))
+
+	err := db.Ping()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	return db
+}
+
+// SQLiteDSN adds sqliteOptions to a SQLite file name or URI.
+func SQLiteDSN(url string) string {
+	if strings.Contains(url, "?") {
+		return url + "&" + sqliteOptions
+	}
+
+	return url + "?" + sqliteOptions
+}
@@ -0,0 +1,46 @@
+package domain
+
+import (
+	"errors"
+	"strings"
+)
+
+var (
+	ErrUnsupportedCurrency = errors.New("error unsupported currency")
+	ErrCurrencyMismatch    = errors.New("error currency does not match any wallet of the user")
+)
+
+// Currency is an ISO 4217 currency. Amounts are stored in minor units, so an
+// amount of 12345 with Exponent 2 reads as 123.45.
+type Currency struct {
+	Code     string
+	Exponent int
+}
+
+// currencies lists the currencies wallets can be opened in.
+//
+// IDR is listed with exponent 0 although ISO 4217 gives it 2: sen are not in
+// circulation and this service has always stored whole rupiah.
+var currencies = map[string]Currency{
+	"IDR": {Code: "IDR", Exponent: 0},
+	"USD": {Code: "USD", Exponent: 2},
+	"EUR": {Code: "EUR", Exponent: 2},
+	"GBP": {Code: "GBP", Exponent: 2},
+	"SGD": {Code: "SGD", Exponent: 2},
+	"MYR": {Code: "MYR", Exponent: 2},
+	"AUD": {Code: "AUD", Exponent: 2},
+	"JPY": {Code: "JPY", Exponent: 0},
+	"KRW": {Code: "KRW", Exponent: 0},
+	"KWD": {Code: "KWD", Exponent: 3},
+	"BHD": {Code: "BHD", Exponent: 3},
+}
+
+// LookupCurrency accepts a currency code in any case.
+func LookupCurrency(code string) (Currency, error) {
+	c, ok := currencies[strings.ToUpper(strings.TrimSpace(code))]
+	if !ok {
+		return Currency{}, ErrUnsupportedCurrency
+	}
+
+	return c, nil
+}
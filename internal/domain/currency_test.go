@@ -0,0 +1,30 @@
+package domain
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLookupCurrency(t *testing.T) {
+	usd, err := LookupCurrency("USD")
+	require.NoError(t, err)
+	require.Equal(t, Currency{Code: "USD", Exponent: 2}, usd)
+
+	lower, err := LookupCurrency(" usd ")
+	require.NoError(t, err)
+	require.Equal(t, usd, lower)
+
+	jpy, err := LookupCurrency("JPY")
+	require.NoError(t, err)
+	require.Equal(t, 0, jpy.Exponent)
+
+	kwd, err := LookupCurrency("KWD")
+	require.NoError(t, err)
+	require.Equal(t, 3, kwd.Exponent)
+
+	for _, code := range []string{"", "XXX", "US", "USDD"} {
+		_, err := LookupCurrency(code)
+		require.ErrorIs(t, err, ErrUnsupportedCurrency, code)
+	}
+}
@@ -0,0 +1,49 @@
+package domain
+
+import (
+	"errors"
+	"math/big"
+	"time"
+)
+
+var ErrFeeScheduleNotFound = errors.New("error fee schedule not found")
+
+// FeeSchedule prices withdrawals for a single user or for every user of a
+// tier. The fee is Flat plus PercentBps basis points of the amount, then
+// raised to MinFee and capped at MaxFee; a zero MinFee or MaxFee is not
+// enforced. Amounts are in the minor units of the wallet withdrawn from.
+type FeeSchedule struct {
+	ID         int64     `db:"id"`
+	UserID     *int64    `db:"user_id"`
+	Tier       *string   `db:"tier"`
+	Currency   string    `db:"currency"`
+	Flat       int64     `db:"flat"`
+	PercentBps int64     `db:"percent_bps"`
+	MinFee     int64     `db:"min_fee"`
+	MaxFee     int64     `db:"max_fee"`
+	CreatedAt  time.Time `db:"created_at"`
+	UpdatedAt  time.Time `db:"updated_at"`
+}
+
+// Calculate returns the fee for withdrawing amount, in amount's currency.
+// The percentage part is rounded up to the minor unit.
+func (f FeeSchedule) Calculate(amount Money) (Money, error) {
+	fee := new(big.Int).Mul(big.NewInt(amount.Amount), big.NewInt(f.PercentBps))
+	fee.Add(fee, big.NewInt(9_999))
+	fee.Quo(fee, big.NewInt(10_000))
+	fee.Add(fee, big.NewInt(f.Flat))
+
+	if f.MinFee > 0 && fee.Cmp(big.NewInt(f.MinFee)) < 0 {
+		fee.SetInt64(f.MinFee)
+	}
+
+	if f.MaxFee > 0 && fee.Cmp(big.NewInt(f.MaxFee)) > 0 {
+		fee.SetInt64(f.MaxFee)
+	}
+
+	if !fee.IsInt64() {
+		return Money{}, ErrMoneyOverflow
+	}
+
+	return NewMoney(fee.Int64(), amount.Currency), nil
+}
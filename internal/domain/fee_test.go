@@ -0,0 +1,38 @@
+package domain
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestFeeSchedule_Calculate(t *testing.T) {
+	cases := []struct {
+		name     string
+		schedule FeeSchedule
+		amount   int64
+		want     int64
+	}{
+		{name: "none", schedule: FeeSchedule{}, amount: 100_000, want: 0},
+		{name: "flat", schedule: FeeSchedule{Flat: 2_500}, amount: 100_000, want: 2_500},
+		{name: "percentage rounds up", schedule: FeeSchedule{PercentBps: 25}, amount: 10_001, want: 26},
+		{name: "flat plus percentage", schedule: FeeSchedule{Flat: 1_000, PercentBps: 100}, amount: 50_000, want: 1_500},
+		{name: "min", schedule: FeeSchedule{PercentBps: 10, MinFee: 500}, amount: 10_000, want: 500},
+		{name: "max", schedule: FeeSchedule{PercentBps: 100, MaxFee: 5_000}, amount: 1_000_000, want: 5_000},
+		{name: "within min and max", schedule: FeeSchedule{PercentBps: 100, MinFee: 500, MaxFee: 5_000}, amount: 100_000, want: 1_000},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			fee, err := tc.schedule.Calculate(NewMoney(tc.amount, "IDR"))
+			require.NoError(t, err)
+			require.Equal(t, NewMoney(tc.want, "IDR"), fee)
+		})
+	}
+}
+
+func TestFeeSchedule_CalculateOverflow(t *testing.T) {
+	_, err := FeeSchedule{Flat: math.MaxInt64, PercentBps: 100}.Calculate(NewMoney(math.MaxInt64, "IDR"))
+	require.ErrorIs(t, err, ErrMoneyOverflow)
+}
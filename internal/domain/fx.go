@@ -0,0 +1,160 @@
+package domain
+
+import (
+	"errors"
+	"math/big"
+	"time"
+)
+
+var (
+	ErrRateNotFound  = errors.New("error fx rate not found")
+	ErrInvalidRate   = errors.New("error invalid fx rate")
+	ErrSameCurrency  = errors.New("error fx conversion into the same currency")
+	ErrQuoteNotFound = errors.New("error fx quote not found")
+	ErrQuoteExpired  = errors.New("error fx quote expired")
+	ErrQuoteNotOpen  = errors.New("error fx quote already executed")
+	ErrFXFailed      = errors.New("error fx conversion failed")
+)
+
+type FXQuoteStatus = string
+
+var (
+	FXQuoteStatusOpen     = "OPEN"
+	FXQuoteStatusExecuted = "EXECUTED"
+)
+
+// FXRate prices one unit of BaseCurrency in QuoteCurrency, e.g. USD→IDR
+// "16250.5". Rate is kept as a decimal string so that it never goes through
+// a float. FeeBps is charged on top of the amount converted, in basis points
+// of it and in BaseCurrency.
+type FXRate struct {
+	BaseCurrency  string    `db:"base_currency" json:"base"`
+	QuoteCurrency string    `db:"quote_currency" json:"quote"`
+	Rate          string    `db:"rate" json:"rate"`
+	FeeBps        int64     `db:"fee_bps" json:"feeBps"`
+	UpdatedAt     time.Time `db:"updated_at" json:"updatedAt"`
+}
+
+func (r FXRate) Validate() error {
+	base, err := LookupCurrency(r.BaseCurrency)
+	if err != nil {
+		return err
+	}
+
+	quote, err := LookupCurrency(r.QuoteCurrency)
+	if err != nil {
+		return err
+	}
+
+	if base.Code == quote.Code {
+		return ErrSameCurrency
+	}
+
+	if _, err := r.rat(); err != nil {
+		return err
+	}
+
+	if r.FeeBps < 0 || r.FeeBps >= 10_000 {
+		return ErrInvalidRate
+	}
+
+	return nil
+}
+
+func (r FXRate) rat() (*big.Rat, error) {
+	rate, ok := new(big.Rat).SetString(r.Rate)
+	if !ok || rate.Sign() <= 0 {
+		return nil, ErrInvalidRate
+	}
+
+	return rate, nil
+}
+
+// Convert prices amount, which must be in BaseCurrency, in QuoteCurrency. The
+// result is rounded down to the quote currency's minor unit.
+func (r FXRate) Convert(amount Money) (Money, error) {
+	if amount.Currency != r.BaseCurrency {
+		return Money{}, ErrCurrencyMismatch
+	}
+
+	base, err := LookupCurrency(r.BaseCurrency)
+	if err != nil {
+		return Money{}, err
+	}
+
+	quote, err := LookupCurrency(r.QuoteCurrency)
+	if err != nil {
+		return Money{}, err
+	}
+
+	rate, err := r.rat()
+	if err != nil {
+		return Money{}, err
+	}
+
+	// minor units of quote = minor units of base / 10^base.exp * rate * 10^quote.exp
+	v := new(big.Rat).SetInt64(amount.Amount)
+	v.Mul(v, rate)
+	v.Mul(v, new(big.Rat).SetInt(pow10(quote.Exponent)))
+	v.Quo(v, new(big.Rat).SetInt(pow10(base.Exponent)))
+
+	converted := new(big.Int).Quo(v.Num(), v.Denom())
+	if !converted.IsInt64() {
+		return Money{}, ErrMoneyOverflow
+	}
+
+	return NewMoney(converted.Int64(), quote.Code), nil
+}
+
+// Fee is FeeBps of amount, rounded up to amount's minor unit.
+func (r FXRate) Fee(amount Money) Money {
+	fee := new(big.Int).Mul(big.NewInt(amount.Amount), big.NewInt(r.FeeBps))
+	fee.Add(fee, big.NewInt(9_999))
+	fee.Quo(fee, big.NewInt(10_000))
+
+	// FeeBps is below 10000, so the fee never exceeds amount.
+	return NewMoney(fee.Int64(), amount.Currency)
+}
+
+func pow10(n int) *big.Int {
+	return new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(n)), nil)
+}
+
+// FXQuote locks a rate and fee for one conversion between two of a user's
+// wallets until ExpiresAt. Executing it debits FromAmount plus Fee and
+// credits ToAmount.
+type FXQuote struct {
+	ID           string        `db:"id"`
+	UserID       int64         `db:"user_id"`
+	FromCurrency string        `db:"from_currency"`
+	ToCurrency   string        `db:"to_currency"`
+	FromAmount   Money         `db:"from_amount"`
+	Fee          Money         `db:"fee"`
+	ToAmount     Money         `db:"to_amount"`
+	Rate         string        `db:"rate"`
+	Status       FXQuoteStatus `db:"status"`
+	ExpiresAt    time.Time     `db:"expires_at"`
+	ExecutedAt   *time.Time    `db:"executed_at"`
+	CreatedAt    time.Time     `db:"created_at"`
+	UpdatedAt    time.Time     `db:"updated_at"`
+}
+
+// BindCurrency sets the quote's currencies on its amounts after a scan.
+func (q *FXQuote) BindCurrency() {
+	q.FromAmount.Currency = q.FromCurrency
+	q.Fee.Currency = q.FromCurrency
+	q.ToAmount.Currency = q.ToCurrency
+}
+
+// CheckExecute reports why the quote cannot be executed at now.
+func (q FXQuote) CheckExecute(now time.Time) error {
+	if q.Status != FXQuoteStatusOpen {
+		return ErrQuoteNotOpen
+	}
+
+	if !now.Before(q.ExpiresAt) {
+		return ErrQuoteExpired
+	}
+
+	return nil
+}
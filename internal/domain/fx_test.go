@@ -0,0 +1,79 @@
+package domain
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestFXRate_Convert(t *testing.T) {
+	cases := map[string]struct {
+		rate FXRate
+		in   Money
+		want Money
+	}{
+		"usd to idr": {
+			rate: FXRate{BaseCurrency: "USD", QuoteCurrency: "IDR", Rate: "16250.5"},
+			in:   NewMoney(1_250, "USD"),
+			want: NewMoney(203_131, "IDR"),
+		},
+		"idr to usd rounds down": {
+			rate: FXRate{BaseCurrency: "IDR", QuoteCurrency: "USD", Rate: "0.0000615"},
+			in:   NewMoney(100_000, "IDR"),
+			want: NewMoney(615, "USD"),
+		},
+		"usd to kwd": {
+			rate: FXRate{BaseCurrency: "USD", QuoteCurrency: "KWD", Rate: "0.307"},
+			in:   NewMoney(10_000, "USD"),
+			want: NewMoney(30_700, "KWD"),
+		},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			got, err := c.rate.Convert(c.in)
+			require.NoError(t, err)
+			require.Equal(t, c.want, got)
+		})
+	}
+
+	rate := FXRate{BaseCurrency: "USD", QuoteCurrency: "IDR", Rate: "16250"}
+	_, err := rate.Convert(NewMoney(1, "EUR"))
+	require.ErrorIs(t, err, ErrCurrencyMismatch)
+
+	_, err = rate.Convert(NewMoney(math.MaxInt64, "USD"))
+	require.ErrorIs(t, err, ErrMoneyOverflow)
+}
+
+func TestFXRate_Fee(t *testing.T) {
+	rate := FXRate{BaseCurrency: "USD", QuoteCurrency: "IDR", Rate: "16250", FeeBps: 50}
+
+	require.Equal(t, NewMoney(50, "USD"), rate.Fee(NewMoney(10_000, "USD")))
+	require.Equal(t, NewMoney(1, "USD"), rate.Fee(NewMoney(1, "USD")))
+
+	rate.FeeBps = 0
+	require.Equal(t, NewMoney(0, "USD"), rate.Fee(NewMoney(10_000, "USD")))
+}
+
+func TestFXRate_Validate(t *testing.T) {
+	require.NoError(t, FXRate{BaseCurrency: "USD", QuoteCurrency: "IDR", Rate: "16250.5", FeeBps: 25}.Validate())
+
+	require.ErrorIs(t, FXRate{BaseCurrency: "USD", QuoteCurrency: "USD", Rate: "1"}.Validate(), ErrSameCurrency)
+	require.ErrorIs(t, FXRate{BaseCurrency: "USD", QuoteCurrency: "XXX", Rate: "1"}.Validate(), ErrUnsupportedCurrency)
+	require.ErrorIs(t, FXRate{BaseCurrency: "USD", QuoteCurrency: "IDR", Rate: "0"}.Validate(), ErrInvalidRate)
+	require.ErrorIs(t, FXRate{BaseCurrency: "USD", QuoteCurrency: "IDR", Rate: "abc"}.Validate(), ErrInvalidRate)
+	require.ErrorIs(t, FXRate{BaseCurrency: "USD", QuoteCurrency: "IDR", Rate: "1", FeeBps: 10_000}.Validate(), ErrInvalidRate)
+}
+
+func TestFXQuote_CheckExecute(t *testing.T) {
+	now := time.Now()
+
+	q := FXQuote{Status: FXQuoteStatusOpen, ExpiresAt: now.Add(time.Second)}
+	require.NoError(t, q.CheckExecute(now))
+	require.ErrorIs(t, q.CheckExecute(now.Add(time.Second)), ErrQuoteExpired)
+
+	q.Status = FXQuoteStatusExecuted
+	require.ErrorIs(t, q.CheckExecute(now), ErrQuoteNotOpen)
+}
@@ -0,0 +1,56 @@
+package domain
+
+import (
+	"errors"
+	"time"
+)
+
+var (
+	ErrHoldNotFound       = errors.New("error hold not found")
+	ErrHoldNotActive      = errors.New("error hold is no longer active")
+	ErrHoldExpired        = errors.New("error hold expired")
+	ErrCaptureExceedsHold = errors.New("error capture amount exceeds hold")
+	ErrHoldFailed         = errors.New("error hold failed")
+)
+
+type HoldStatus = string
+
+var (
+	HoldStatusActive   = "ACTIVE"
+	HoldStatusCaptured = "CAPTURED"
+	HoldStatusVoided   = "VOIDED"
+	HoldStatusExpired  = "EXPIRED"
+)
+
+// Hold reserves Amount of a wallet's balance until it is captured, voided or
+// expires. A capture is final: capturing less than Amount releases the rest.
+type Hold struct {
+	ID             int64      `db:"id"`
+	WalletID       int64      `db:"wallet_id"`
+	Currency       string     `db:"currency"`
+	Amount         Money      `db:"amount"`
+	CapturedAmount Money      `db:"captured_amount"`
+	Status         HoldStatus `db:"status"`
+	ExpiresAt      time.Time  `db:"expires_at"`
+	CreatedAt      time.Time  `db:"created_at"`
+	UpdatedAt      time.Time  `db:"updated_at"`
+}
+
+// BindCurrency sets the hold's currency on its amounts after a scan.
+func (h *Hold) BindCurrency() {
+	h.Amount.Currency = h.Currency
+	h.CapturedAmount.Currency = h.Currency
+}
+
+// CheckSettle reports why the hold cannot be captured or voided at now.
+func (h Hold) CheckSettle(now time.Time) error {
+	if h.Status != HoldStatusActive {
+		return ErrHoldNotActive
+	}
+
+	if !now.Before(h.ExpiresAt) {
+		return ErrHoldExpired
+	}
+
+	return nil
+}
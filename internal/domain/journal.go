@@ -0,0 +1,134 @@
+package domain
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+type AccountType = string
+
+var (
+	AccountTypeWallet = "WALLET"
+	AccountTypeSystem = "SYSTEM"
+)
+
+// System accounts are the counterparties of money entering or leaving the
+// wallets. Their balances are expected to go negative: cash-in is debited for
+// every deposit, so its balance mirrors the total ever paid in. Each exists
+// once per currency, see SystemAccountCode.
+var (
+	SystemAccountCashIn         = "system:cash-in"
+	SystemAccountPayoutClearing = "system:payout-clearing"
+	SystemAccountOpeningBalance = "system:opening-balance"
+	SystemAccountHoldSettlement = "system:hold-settlement"
+	// FX conversions pay into the settlement account of the currency sold
+	// and out of the one of the currency bought.
+	SystemAccountFXSettlement = "system:fx-settlement"
+	// Withdrawal and FX fees are the house's revenue.
+	SystemAccountFeeRevenue = "system:fee-revenue"
+)
+
+var (
+	JournalTypeOpening = "OPENING"
+	// JournalTypePayoutRestore gives back the funds of a failed payout.
+	JournalTypePayoutRestore = "PAYOUT_RESTORE"
+)
+
+var (
+	ErrAccountNotFound    = errors.New("error account not found")
+	ErrUnbalancedJournal  = errors.New("error journal postings do not sum to zero")
+	ErrInvalidJournalLine = errors.New("error invalid journal posting")
+)
+
+type Account struct {
+	ID        int64       `db:"id"`
+	Code      string      `db:"code"`
+	Type      AccountType `db:"type"`
+	WalletID  *int64      `db:"wallet_id"`
+	CreatedAt time.Time   `db:"created_at"`
+	UpdatedAt time.Time   `db:"updated_at"`
+}
+
+// SystemAccountCode names the system account for one currency, e.g.
+// "system:cash-in:IDR", so that amounts of different currencies never share
+// an account.
+func SystemAccountCode(account, currency string) string {
+	return account + ":" + currency
+}
+
+func WalletAccountCode(walletID int64) string {
+	return fmt.Sprintf("wallet:%d", walletID)
+}
+
+// Journal is one balanced accounting entry. Postings are signed: a positive
+// amount credits the account (its balance goes up), a negative amount debits
+// it. A wallet's balance is therefore the sum of its account's postings.
+type Journal struct {
+	ID        int64     `db:"id"`
+	LedgerID  *int64    `db:"ledger_id"`
+	Type      string    `db:"type"`
+	Postings  []Posting `db:"-"`
+	CreatedAt time.Time `db:"created_at"`
+}
+
+type Posting struct {
+	ID        int64     `db:"id"`
+	JournalID int64     `db:"journal_id"`
+	AccountID int64     `db:"account_id"`
+	Amount    int64     `db:"amount"`
+	CreatedAt time.Time `db:"created_at"`
+}
+
+// NewTransferJournal moves amount from one account to another.
+func NewTransferJournal(journalType string, ledgerID *int64, fromAccountID, toAccountID, amount int64) Journal {
+	return Journal{
+		LedgerID: ledgerID,
+		Type:     journalType,
+		Postings: []Posting{
+			{AccountID: fromAccountID, Amount: -amount},
+			{AccountID: toAccountID, Amount: amount},
+		},
+	}
+}
+
+// NewSplitJournal debits one account for the sum of the credits and credits
+// each of the others. Zero credits are left out, so an optional leg such as a
+// fee can be passed unconditionally.
+func NewSplitJournal(journalType string, ledgerID *int64, fromAccountID int64, credits []Posting) Journal {
+	journal := Journal{
+		LedgerID: ledgerID,
+		Type:     journalType,
+		Postings: []Posting{{AccountID: fromAccountID}},
+	}
+
+	for _, c := range credits {
+		if c.Amount == 0 {
+			continue
+		}
+		journal.Postings[0].Amount -= c.Amount
+		journal.Postings = append(journal.Postings, Posting{AccountID: c.AccountID, Amount: c.Amount})
+	}
+
+	return journal
+}
+
+func (j Journal) Validate() error {
+	if len(j.Postings) < 2 {
+		return ErrInvalidJournalLine
+	}
+
+	var sum int64
+	for _, p := range j.Postings {
+		if p.Amount == 0 || p.AccountID == 0 {
+			return ErrInvalidJournalLine
+		}
+		sum += p.Amount
+	}
+
+	if sum != 0 {
+		return ErrUnbalancedJournal
+	}
+
+	return nil
+}
@@ -0,0 +1,28 @@
+package domain
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewSplitJournal(t *testing.T) {
+	j := NewSplitJournal("FX_OUT", nil, 1, []Posting{
+		{AccountID: 2, Amount: 5_000},
+		{AccountID: 3, Amount: 50},
+	})
+	require.NoError(t, j.Validate())
+	require.Equal(t, []Posting{
+		{AccountID: 1, Amount: -5_050},
+		{AccountID: 2, Amount: 5_000},
+		{AccountID: 3, Amount: 50},
+	}, j.Postings)
+
+	// A zero fee adds no posting.
+	j = NewSplitJournal("FX_OUT", nil, 1, []Posting{
+		{AccountID: 2, Amount: 5_000},
+		{AccountID: 3, Amount: 0},
+	})
+	require.NoError(t, j.Validate())
+	require.Len(t, j.Postings, 2)
+}
@@ -12,13 +12,42 @@ var (
 	LedgerStatusSucceed    = "SUCCEED"
 	LedgerStatusFailed     = "FAILED"
 	LedgerStatusProcessing = "PROCESSING"
+	// LedgerStatusSent marks a withdrawal the payout provider accepted but
+	// has not settled yet.
+	LedgerStatusSent = "SENT"
+)
+
+// Error codes stored on FAILED ledgers so that replays can return the same
+// outcome as the original request.
+var (
+	LedgerErrorCodeInsufficientFund  = "INSUFFICIENT_FUND"
+	LedgerErrorCodeProcessingTimeout = "PROCESSING_TIMEOUT"
+	LedgerErrorCodeLimitExceeded     = "LIMIT_EXCEEDED"
+	LedgerErrorCodeHoldNotActive     = "HOLD_NOT_ACTIVE"
+	LedgerErrorCodeHoldExpired       = "HOLD_EXPIRED"
+	LedgerErrorCodeCaptureExceeds    = "CAPTURE_EXCEEDS_HOLD"
+	LedgerErrorCodeReversalExceeds   = "REVERSAL_EXCEEDS_ORIGINAL"
+	LedgerErrorCodeQuoteExpired      = "QUOTE_EXPIRED"
+	LedgerErrorCodeQuoteNotOpen      = "QUOTE_NOT_OPEN"
+	LedgerErrorCodePayoutFailed      = "PAYOUT_FAILED"
 )
 
 type LedgerType = string
 
 var (
-	LedgerTypeWithdraw = "WITHDRAW"
-	LedgerTypeInit     = "INIT"
+	LedgerTypeWithdraw    = "WITHDRAW"
+	LedgerTypeDeposit     = "DEPOSIT"
+	LedgerTypeTransferOut = "TRANSFER_OUT"
+	LedgerTypeTransferIn  = "TRANSFER_IN"
+	LedgerTypeInit        = "INIT"
+	LedgerTypeAuthorize   = "AUTHORIZE"
+	LedgerTypeCapture     = "CAPTURE"
+	LedgerTypeVoid        = "VOID"
+	LedgerTypeHoldExpire  = "HOLD_EXPIRE"
+	LedgerTypeReversal    = "REVERSAL"
+	LedgerTypeFXOut       = "FX_OUT"
+	LedgerTypeFXIn        = "FX_IN"
+	LedgerTypeFee         = "FEE"
 )
 
 var (
@@ -26,18 +55,57 @@ var (
 	ErrLedgerNotFound       = errors.New("error ledger not found")
 	ErrIdempotencyKeyReused = errors.New("error idempotency key reused")
 	ErrWithdrawFailed       = errors.New("error withdraw failed")
+	ErrDepositFailed        = errors.New("error deposit failed")
+	ErrTransferFailed       = errors.New("error transfer failed")
 	ErrRequestInProgress    = errors.New("error request in progress")
+	ErrInvalidCursor        = errors.New("error invalid cursor")
+	ErrNotReversible        = errors.New("error ledger is not a succeeded withdrawal")
+	ErrReversalExceeds      = errors.New("error reversal exceeds the original amount")
+	ErrReversalFailed       = errors.New("error reversal failed")
 )
 
+// LedgerBalanceSign tells how a succeeded ledger of the given type moves its
+// wallet's balance: +1 credits, -1 debits, 0 leaves it untouched.
+func LedgerBalanceSign(ledgerType LedgerType) int64 {
+	switch ledgerType {
+	case LedgerTypeInit, LedgerTypeDeposit, LedgerTypeTransferIn, LedgerTypeReversal, LedgerTypeFXIn:
+		return 1
+	case LedgerTypeWithdraw, LedgerTypeTransferOut, LedgerTypeCapture, LedgerTypeFXOut, LedgerTypeFee:
+		return -1
+	default:
+		return 0
+	}
+}
+
 type Ledger struct {
 	ID             int64        `db:"id"`
 	IdempotencyKey string       `db:"idempotency_key"`
 	Type           LedgerType   `db:"type"`
 	Status         LedgerStatus `db:"status"`
 	WalletID       int64        `db:"wallet_id"`
-	Amount         int64        `db:"amount"`
-	ResultBalance  *int64       `db:"result_balance"`
+	Currency       string       `db:"currency"`
+	Amount         Money        `db:"amount"`
+	ResultBalance  *Money       `db:"result_balance"`
 	ErrorCode      *string      `db:"error_code"`
-	CreatedAt      time.Time    `db:"created_at"`
-	UpdatedAt      time.Time    `db:"updated_at"`
+	// TransferID links the two legs of a transfer, or of an FX conversion
+	// where it is the quote ID.
+	TransferID *string `db:"transfer_id"`
+	HoldID     *int64  `db:"hold_id"`
+	// PayoutReference identifies a withdrawal and its fee at the payout
+	// provider. Funds of a PROCESSING or SENT ledger with a reference are
+	// already debited from the wallet.
+	PayoutReference *string `db:"payout_reference"`
+	// ParentLedgerID links a REVERSAL to the withdrawal it reverses, and a
+	// FEE to the withdrawal it was charged on.
+	ParentLedgerID *int64    `db:"parent_ledger_id"`
+	CreatedAt      time.Time `db:"created_at"`
+	UpdatedAt      time.Time `db:"updated_at"`
+}
+
+// BindCurrency sets the ledger's currency on its amounts after a scan.
+func (l *Ledger) BindCurrency() {
+	l.Amount.Currency = l.Currency
+	if l.ResultBalance != nil {
+		l.ResultBalance.Currency = l.Currency
+	}
 }
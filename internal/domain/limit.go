@@ -0,0 +1,83 @@
+package domain
+
+import (
+	"errors"
+	"time"
+)
+
+var (
+	ErrLimitExceeded = errors.New("error withdrawal limit exceeded")
+	ErrLimitNotFound = errors.New("error withdrawal limit not found")
+)
+
+var UserTierStandard = "STANDARD"
+
+// WithdrawalLimit caps withdrawals for a single user or for every user of a
+// tier. A zero value means the corresponding limit is not enforced. Daily
+// limits are evaluated over a rolling 24 hour window.
+type WithdrawalLimit struct {
+	ID                int64     `db:"id"`
+	UserID            *int64    `db:"user_id"`
+	Tier              *string   `db:"tier"`
+	Currency          string    `db:"currency"`
+	MaxPerTransaction int64     `db:"max_per_transaction"`
+	MaxDailyAmount    int64     `db:"max_daily_amount"`
+	MaxDailyCount     int64     `db:"max_daily_count"`
+	CreatedAt         time.Time `db:"created_at"`
+	UpdatedAt         time.Time `db:"updated_at"`
+}
+
+const WithdrawalLimitWindow = 24 * time.Hour
+
+// WithdrawalUsage is what a wallet already withdrew inside the limit window.
+type WithdrawalUsage struct {
+	Amount int64 `db:"amount"`
+	Count  int64 `db:"count"`
+}
+
+// Check reports ErrLimitExceeded when withdrawing amount on top of usage
+// would break any of the limits.
+func (l WithdrawalLimit) Check(amount int64, usage WithdrawalUsage) error {
+	if l.MaxPerTransaction > 0 && amount > l.MaxPerTransaction {
+		return ErrLimitExceeded
+	}
+
+	if l.MaxDailyAmount > 0 && usage.Amount+amount > l.MaxDailyAmount {
+		return ErrLimitExceeded
+	}
+
+	if l.MaxDailyCount > 0 && usage.Count+1 > l.MaxDailyCount {
+		return ErrLimitExceeded
+	}
+
+	return nil
+}
+
+// WithdrawalRemaining is what a limit still allows. A nil field is not
+// limited.
+type WithdrawalRemaining struct {
+	MaxPerTransaction *int64
+	DailyAmount       *int64
+	DailyCount        *int64
+}
+
+// Remaining reports what is left of the limits once usage is counted.
+func (l WithdrawalLimit) Remaining(usage WithdrawalUsage) WithdrawalRemaining {
+	var r WithdrawalRemaining
+
+	if l.MaxPerTransaction > 0 {
+		r.MaxPerTransaction = &l.MaxPerTransaction
+	}
+
+	if l.MaxDailyAmount > 0 {
+		amount := max(l.MaxDailyAmount-usage.Amount, 0)
+		r.DailyAmount = &amount
+	}
+
+	if l.MaxDailyCount > 0 {
+		count := max(l.MaxDailyCount-usage.Count, 0)
+		r.DailyCount = &count
+	}
+
+	return r
+}
@@ -0,0 +1,34 @@
+package domain
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestWithdrawalLimit_Check(t *testing.T) {
+	limit := WithdrawalLimit{MaxPerTransaction: 100, MaxDailyAmount: 250, MaxDailyCount: 3}
+
+	require.NoError(t, limit.Check(100, WithdrawalUsage{}))
+	require.ErrorIs(t, limit.Check(101, WithdrawalUsage{}), ErrLimitExceeded)
+	require.NoError(t, limit.Check(50, WithdrawalUsage{Amount: 200, Count: 2}))
+	require.ErrorIs(t, limit.Check(51, WithdrawalUsage{Amount: 200, Count: 1}), ErrLimitExceeded)
+	require.ErrorIs(t, limit.Check(1, WithdrawalUsage{Amount: 10, Count: 3}), ErrLimitExceeded)
+
+	require.NoError(t, WithdrawalLimit{}.Check(1_000_000, WithdrawalUsage{Amount: 1 << 40, Count: 1 << 20}))
+}
+
+func TestWithdrawalLimit_Remaining(t *testing.T) {
+	limit := WithdrawalLimit{MaxPerTransaction: 100, MaxDailyAmount: 250, MaxDailyCount: 3}
+
+	r := limit.Remaining(WithdrawalUsage{Amount: 200, Count: 2})
+	require.Equal(t, int64(100), *r.MaxPerTransaction)
+	require.Equal(t, int64(50), *r.DailyAmount)
+	require.Equal(t, int64(1), *r.DailyCount)
+
+	r = limit.Remaining(WithdrawalUsage{Amount: 300, Count: 5})
+	require.Equal(t, int64(0), *r.DailyAmount)
+	require.Equal(t, int64(0), *r.DailyCount)
+
+	require.Equal(t, WithdrawalRemaining{}, WithdrawalLimit{}.Remaining(WithdrawalUsage{Amount: 1, Count: 1}))
+}
@@ -0,0 +1,159 @@
+package domain
+
+import (
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+var (
+	ErrMoneyOverflow = errors.New("error money amount overflows")
+	ErrInvalidMoney  = errors.New("error invalid money amount")
+)
+
+// Money is an amount in minor units of an ISO 4217 currency. Arithmetic
+// refuses to mix currencies or to overflow int64.
+//
+// In the database only the minor units are stored; the currency lives on the
+// row's wallet, so Scan leaves Currency empty and repositories set it after
+// loading.
+type Money struct {
+	Amount   int64
+	Currency string
+}
+
+func NewMoney(amount int64, currency string) Money {
+	return Money{Amount: amount, Currency: currency}
+}
+
+// ParseMoney reads a decimal amount in major units, e.g. "12.50" USD is 1250
+// minor units. It rejects more fraction digits than the currency has.
+func ParseMoney(s, currency string) (Money, error) {
+	c, err := LookupCurrency(currency)
+	if err != nil {
+		return Money{}, err
+	}
+
+	s = strings.TrimSpace(s)
+	neg := strings.HasPrefix(s, "-")
+	s = strings.TrimPrefix(s, "-")
+
+	whole, frac, hasFrac := strings.Cut(s, ".")
+	if whole == "" || (hasFrac && frac == "") || len(frac) > c.Exponent {
+		return Money{}, ErrInvalidMoney
+	}
+	frac += strings.Repeat("0", c.Exponent-len(frac))
+
+	digits := whole + frac
+	for _, r := range digits {
+		if r < '0' || r > '9' {
+			return Money{}, ErrInvalidMoney
+		}
+	}
+
+	amount, err := strconv.ParseInt(digits, 10, 64)
+	if err != nil {
+		if errors.Is(err, strconv.ErrRange) {
+			return Money{}, ErrMoneyOverflow
+		}
+
+		return Money{}, ErrInvalidMoney
+	}
+
+	if neg {
+		amount = -amount
+	}
+
+	return Money{Amount: amount, Currency: c.Code}, nil
+}
+
+// Add returns m + o. Both must be in the same currency.
+func (m Money) Add(o Money) (Money, error) {
+	if m.Currency != o.Currency {
+		return Money{}, ErrCurrencyMismatch
+	}
+
+	if (o.Amount > 0 && m.Amount > math.MaxInt64-o.Amount) ||
+		(o.Amount < 0 && m.Amount < math.MinInt64-o.Amount) {
+		return Money{}, ErrMoneyOverflow
+	}
+
+	return Money{Amount: m.Amount + o.Amount, Currency: m.Currency}, nil
+}
+
+// Sub returns m - o. Both must be in the same currency.
+func (m Money) Sub(o Money) (Money, error) {
+	if o.Amount == math.MinInt64 {
+		return Money{}, ErrMoneyOverflow
+	}
+
+	return m.Add(Money{Amount: -o.Amount, Currency: o.Currency})
+}
+
+func (m Money) IsPositive() bool {
+	return m.Amount > 0
+}
+
+func (m Money) IsNegative() bool {
+	return m.Amount < 0
+}
+
+func (m Money) IsZero() bool {
+	return m.Amount == 0
+}
+
+// String formats m in major units with the currency's exponent, e.g.
+// "12.50". A money without a known currency is printed in minor units.
+func (m Money) String() string {
+	c, err := LookupCurrency(m.Currency)
+	if err != nil || c.Exponent == 0 {
+		return strconv.FormatInt(m.Amount, 10)
+	}
+
+	sign := ""
+	u := uint64(m.Amount)
+	if m.Amount < 0 {
+		sign = "-"
+		u = -u
+	}
+
+	digits := strconv.FormatUint(u, 10)
+	if len(digits) <= c.Exponent {
+		digits = strings.Repeat("0", c.Exponent-len(digits)+1) + digits
+	}
+
+	point := len(digits) - c.Exponent
+	return sign + digits[:point] + "." + digits[point:]
+}
+
+// MarshalJSON writes m as a decimal string so that clients never round it
+// through a float.
+func (m Money) MarshalJSON() ([]byte, error) {
+	return []byte(strconv.Quote(m.String())), nil
+}
+
+func (m Money) Value() (driver.Value, error) {
+	return m.Amount, nil
+}
+
+func (m *Money) Scan(src any) error {
+	switch v := src.(type) {
+	case int64:
+		m.Amount = v
+	case []byte:
+		return m.Scan(string(v))
+	case string:
+		amount, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return fmt.Errorf("cannot scan %q into Money: %w", v, err)
+		}
+		m.Amount = amount
+	default:
+		return fmt.Errorf("cannot scan %T into Money", src)
+	}
+
+	return nil
+}
@@ -0,0 +1,105 @@
+package domain
+
+import (
+	"encoding/json"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseMoney(t *testing.T) {
+	cases := map[string]struct {
+		in       string
+		currency string
+		want     int64
+	}{
+		"usd cents":         {in: "12.50", currency: "USD", want: 1250},
+		"usd short":         {in: "12.5", currency: "USD", want: 1250},
+		"usd whole":         {in: "12", currency: "USD", want: 1200},
+		"idr":               {in: "30000", currency: "IDR", want: 30000},
+		"kwd":               {in: "1.005", currency: "KWD", want: 1005},
+		"negative":          {in: "-0.01", currency: "USD", want: -1},
+		"lowercase code":    {in: "1.00", currency: "usd", want: 100},
+		"surrounding blank": {in: " 3 ", currency: "JPY", want: 3},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			m, err := ParseMoney(c.in, c.currency)
+			require.NoError(t, err)
+			require.Equal(t, c.want, m.Amount)
+		})
+	}
+
+	for _, in := range []string{"", ".5", "1.", "1.234", "1e3", "abc", "1,00", "--1"} {
+		_, err := ParseMoney(in, "USD")
+		require.ErrorIs(t, err, ErrInvalidMoney, in)
+	}
+
+	_, err := ParseMoney("1.5", "IDR")
+	require.ErrorIs(t, err, ErrInvalidMoney)
+
+	_, err = ParseMoney("99999999999999999999", "IDR")
+	require.ErrorIs(t, err, ErrMoneyOverflow)
+
+	_, err = ParseMoney("1", "XXX")
+	require.ErrorIs(t, err, ErrUnsupportedCurrency)
+}
+
+func TestMoney_Arithmetic(t *testing.T) {
+	a := NewMoney(1_000, "USD")
+
+	sum, err := a.Add(NewMoney(250, "USD"))
+	require.NoError(t, err)
+	require.Equal(t, NewMoney(1_250, "USD"), sum)
+
+	diff, err := a.Sub(NewMoney(1_500, "USD"))
+	require.NoError(t, err)
+	require.Equal(t, NewMoney(-500, "USD"), diff)
+	require.True(t, diff.IsNegative())
+
+	_, err = a.Add(NewMoney(1, "IDR"))
+	require.ErrorIs(t, err, ErrCurrencyMismatch)
+
+	_, err = NewMoney(math.MaxInt64, "USD").Add(NewMoney(1, "USD"))
+	require.ErrorIs(t, err, ErrMoneyOverflow)
+
+	_, err = NewMoney(math.MinInt64, "USD").Sub(NewMoney(1, "USD"))
+	require.ErrorIs(t, err, ErrMoneyOverflow)
+
+	_, err = NewMoney(0, "USD").Sub(NewMoney(math.MinInt64, "USD"))
+	require.ErrorIs(t, err, ErrMoneyOverflow)
+}
+
+func TestMoney_JSON(t *testing.T) {
+	cases := map[string]Money{
+		`"12.50"`:                 NewMoney(1250, "USD"),
+		`"0.05"`:                  NewMoney(5, "USD"),
+		`"-0.05"`:                 NewMoney(-5, "USD"),
+		`"30000"`:                 NewMoney(30000, "IDR"),
+		`"1.005"`:                 NewMoney(1005, "KWD"),
+		`"-92233720368547758.08"`: NewMoney(math.MinInt64, "USD"),
+	}
+
+	for want, m := range cases {
+		b, err := json.Marshal(m)
+		require.NoError(t, err)
+		require.Equal(t, want, string(b))
+	}
+}
+
+func TestMoney_SQL(t *testing.T) {
+	v, err := NewMoney(1250, "USD").Value()
+	require.NoError(t, err)
+	require.Equal(t, int64(1250), v)
+
+	var m Money
+	require.NoError(t, m.Scan(int64(42)))
+	require.Equal(t, int64(42), m.Amount)
+
+	require.NoError(t, m.Scan([]byte("7")))
+	require.Equal(t, int64(7), m.Amount)
+
+	require.Error(t, m.Scan(1.5))
+}
@@ -0,0 +1,137 @@
+package domain
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+type OutboxEventType = string
+
+var (
+	OutboxEventWithdrawSucceeded = "wallet.withdraw.succeeded"
+	OutboxEventWithdrawFailed    = "wallet.withdraw.failed"
+	OutboxEventWithdrawReversed  = "wallet.withdraw.reversed"
+	OutboxEventUserCreated       = "user.created"
+)
+
+// OutboxEvent is a message written in the same transaction as the state change
+// it describes and published later by the outbox relay. Events sharing a
+// PartitionKey are published in ID order.
+type OutboxEvent struct {
+	ID            int64           `db:"id" json:"id"`
+	Type          OutboxEventType `db:"event_type" json:"type"`
+	PartitionKey  string          `db:"partition_key" json:"partitionKey"`
+	Payload       json.RawMessage `db:"payload" json:"payload"`
+	Attempts      int             `db:"attempts" json:"-"`
+	LastError     *string         `db:"last_error" json:"-"`
+	NextAttemptAt time.Time       `db:"next_attempt_at" json:"-"`
+	PublishedAt   *time.Time      `db:"published_at" json:"-"`
+	CreatedAt     time.Time       `db:"created_at" json:"createdAt"`
+}
+
+func WalletPartitionKey(walletID int64) string {
+	return fmt.Sprintf("wallet:%d", walletID)
+}
+
+type WithdrawEventPayload struct {
+	LedgerID       int64   `json:"ledgerId"`
+	WalletID       int64   `json:"walletId"`
+	UserID         int64   `json:"userId"`
+	IdempotencyKey string  `json:"idempotencyKey"`
+	Currency       string  `json:"currency"`
+	Amount         Money   `json:"amount"`
+	Fee            *Money  `json:"fee,omitempty"`
+	Balance        *Money  `json:"balance"`
+	ErrorCode      *string `json:"errorCode,omitempty"`
+}
+
+// NewWithdrawEvent describes a withdraw ledger that reached a final status.
+// fee is the FEE ledger charged on it, if any; the balance reported is then
+// the one after the fee.
+func NewWithdrawEvent(userID int64, ledger Ledger, fee *Ledger) (OutboxEvent, error) {
+	eventType := OutboxEventWithdrawSucceeded
+	if ledger.Status != LedgerStatusSucceed {
+		eventType = OutboxEventWithdrawFailed
+	}
+
+	payload := WithdrawEventPayload{
+		LedgerID:       ledger.ID,
+		WalletID:       ledger.WalletID,
+		UserID:         userID,
+		IdempotencyKey: ledger.IdempotencyKey,
+		Currency:       ledger.Amount.Currency,
+		Amount:         ledger.Amount,
+		Balance:        ledger.ResultBalance,
+		ErrorCode:      ledger.ErrorCode,
+	}
+
+	if fee != nil {
+		payload.Fee = &fee.Amount
+		payload.Balance = fee.ResultBalance
+	}
+
+	return newOutboxEvent(eventType, WalletPartitionKey(ledger.WalletID), payload)
+}
+
+type ReversalEventPayload struct {
+	LedgerID       int64  `json:"ledgerId"`
+	ParentLedgerID int64  `json:"parentLedgerId"`
+	WalletID       int64  `json:"walletId"`
+	UserID         int64  `json:"userId"`
+	IdempotencyKey string `json:"idempotencyKey"`
+	WithdrawalKey  string `json:"withdrawalKey"`
+	Currency       string `json:"currency"`
+	Amount         Money  `json:"amount"`
+	Balance        *Money `json:"balance"`
+}
+
+// NewReversalEvent describes a succeeded reversal of the withdrawal parent.
+func NewReversalEvent(userID int64, parent, reversal Ledger) (OutboxEvent, error) {
+	return newOutboxEvent(OutboxEventWithdrawReversed, WalletPartitionKey(reversal.WalletID), ReversalEventPayload{
+		LedgerID:       reversal.ID,
+		ParentLedgerID: parent.ID,
+		WalletID:       reversal.WalletID,
+		UserID:         userID,
+		IdempotencyKey: reversal.IdempotencyKey,
+		WithdrawalKey:  parent.IdempotencyKey,
+		Currency:       reversal.Amount.Currency,
+		Amount:         reversal.Amount,
+		Balance:        reversal.ResultBalance,
+	})
+}
+
+type UserCreatedEventPayload struct {
+	UserID   int64  `json:"userId"`
+	Name     string `json:"name"`
+	Tier     string `json:"tier"`
+	WalletID int64  `json:"walletId"`
+	Currency string `json:"currency"`
+	Balance  Money  `json:"balance"`
+}
+
+// NewUserCreatedEvent is keyed by the new wallet so that it is published
+// before any event of that wallet.
+func NewUserCreatedEvent(user User, wallet Wallet) (OutboxEvent, error) {
+	return newOutboxEvent(OutboxEventUserCreated, WalletPartitionKey(wallet.ID), UserCreatedEventPayload{
+		UserID:   user.ID,
+		Name:     user.Name,
+		Tier:     user.Tier,
+		WalletID: wallet.ID,
+		Currency: wallet.Currency,
+		Balance:  wallet.Balance,
+	})
+}
+
+func newOutboxEvent(eventType OutboxEventType, partitionKey string, payload any) (OutboxEvent, error) {
+	b, err := json.Marshal(payload)
+	if err != nil {
+		return OutboxEvent{}, err
+	}
+
+	return OutboxEvent{
+		Type:         eventType,
+		PartitionKey: partitionKey,
+		Payload:      b,
+	}, nil
+}
@@ -0,0 +1,46 @@
+package domain
+
+import "errors"
+
+var (
+	ErrPayoutNotFound      = errors.New("error payout not found")
+	ErrPayoutFailed        = errors.New("error payout failed")
+	ErrPayoutConflict      = errors.New("error payout already settled with another status")
+	ErrInvalidPayoutStatus = errors.New("error invalid payout status")
+)
+
+// PayoutStatus is what a payout provider reports about a payout.
+type PayoutStatus = string
+
+var (
+	// PayoutStatusSent means the provider accepted the payout and will
+	// report its outcome later.
+	PayoutStatusSent      = "SENT"
+	PayoutStatusSucceeded = "SUCCEEDED"
+	PayoutStatusFailed    = "FAILED"
+)
+
+func ValidPayoutStatus(status string) bool {
+	switch status {
+	case PayoutStatusSent, PayoutStatusSucceeded, PayoutStatusFailed:
+		return true
+	default:
+		return false
+	}
+}
+
+// PayoutRequest asks a provider to pay Amount out of a wallet. Reference
+// identifies the payout on both sides; providers must treat a repeated
+// Reference as the same payout.
+type PayoutRequest struct {
+	Reference string `json:"reference"`
+	UserID    int64  `json:"userId"`
+	WalletID  int64  `json:"walletId"`
+	Amount    Money  `json:"amount"`
+}
+
+type PayoutResponse struct {
+	Status PayoutStatus `json:"status"`
+	// Reason explains a FAILED payout.
+	Reason string `json:"reason,omitempty"`
+}
@@ -4,9 +4,12 @@ import (
 	"time"
 )
 
+var UserRoleAdmin = "admin"
+
 type User struct {
 	ID        int64     `db:"id"`
 	Name      string    `db:"name"`
+	Tier      string    `db:"tier"`
 	CreatedAt time.Time `db:"created_at"`
 	UpdatedAt time.Time `db:"updated_at"`
 }
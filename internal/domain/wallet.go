@@ -9,12 +9,39 @@ var (
 	ErrWalletNotFound   = errors.New("error wallet not found")
 	ErrInsufficientFund = errors.New("error insuficient fund")
 	ErrInvalidAmount    = errors.New("error invalid amount")
+	ErrSameWallet       = errors.New("error transfer to same wallet")
+	ErrWalletExists     = errors.New("error wallet already exists for currency")
 )
 
+// Wallet.Balance is the money the wallet holds, in Currency. Part of it may
+// be reserved by active holds (HeldBalance); only AvailableBalance can be
+// spent. A user has at most one wallet per currency.
 type Wallet struct {
-	ID        int64     `db:"id"`
-	UserID    int64     `db:"user_id"`
-	Balance   int64     `db:"balance"`
-	CreatedAt time.Time `db:"created_at"`
-	UpdatedAt time.Time `db:"updated_at"`
+	ID               int64     `db:"id"`
+	UserID           int64     `db:"user_id"`
+	Currency         string    `db:"currency"`
+	Exponent         int       `db:"currency_exponent"`
+	Balance          Money     `db:"balance"`
+	HeldBalance      Money     `db:"held_balance"`
+	AvailableBalance Money     `db:"available_balance"`
+	CreatedAt        time.Time `db:"created_at"`
+	UpdatedAt        time.Time `db:"updated_at"`
+}
+
+// BindCurrency sets the wallet's currency on its amounts. Amounts are stored
+// as bare minor units, so repositories call it after scanning a wallet.
+func (w *Wallet) BindCurrency() {
+	w.Balance.Currency = w.Currency
+	w.HeldBalance.Currency = w.Currency
+	w.AvailableBalance.Currency = w.Currency
+}
+
+// WalletDrift is a wallet whose stored balance disagrees with the balance
+// derived from its ledgers or its journal postings.
+type WalletDrift struct {
+	WalletID        int64 `json:"walletId"`
+	UserID          int64 `json:"userId"`
+	Balance         int64 `json:"balance"`
+	LedgerBalance   int64 `json:"ledgerBalance"`
+	PostingsBalance int64 `json:"postingsBalance"`
 }
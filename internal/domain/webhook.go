@@ -0,0 +1,106 @@
+package domain
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"slices"
+	"time"
+)
+
+var (
+	ErrWebhookNotFound     = errors.New("error webhook subscription not found")
+	ErrInvalidWebhookURL   = errors.New("error invalid webhook url")
+	ErrInvalidWebhookEvent = errors.New("error invalid webhook event type")
+)
+
+type WebhookDeliveryStatus = string
+
+var (
+	WebhookDeliveryStatusPending   = "PENDING"
+	WebhookDeliveryStatusSucceeded = "SUCCEEDED"
+	WebhookDeliveryStatusDead      = "DEAD"
+)
+
+// WebhookEventTypes lists the outbox events partners can subscribe to.
+var WebhookEventTypes = []OutboxEventType{
+	OutboxEventWithdrawSucceeded,
+	OutboxEventWithdrawFailed,
+	OutboxEventWithdrawReversed,
+	OutboxEventUserCreated,
+}
+
+// EventTypes is stored as a JSON array.
+type EventTypes []string
+
+func (e EventTypes) Value() (driver.Value, error) {
+	b, err := json.Marshal([]string(e))
+	if err != nil {
+		return nil, err
+	}
+
+	return string(b), nil
+}
+
+func (e *EventTypes) Scan(src any) error {
+	switch v := src.(type) {
+	case []byte:
+		return json.Unmarshal(v, e)
+	case string:
+		return json.Unmarshal([]byte(v), e)
+	default:
+		return fmt.Errorf("cannot scan %T into EventTypes", src)
+	}
+}
+
+// WebhookSubscription receives every event of its EventTypes, signed with
+// Secret.
+type WebhookSubscription struct {
+	ID         int64      `db:"id"`
+	URL        string     `db:"url"`
+	EventTypes EventTypes `db:"event_types"`
+	Secret     string     `db:"secret"`
+	Active     bool       `db:"active"`
+	CreatedAt  time.Time  `db:"created_at"`
+	UpdatedAt  time.Time  `db:"updated_at"`
+}
+
+func (s WebhookSubscription) Validate() error {
+	u, err := url.Parse(s.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return ErrInvalidWebhookURL
+	}
+
+	if len(s.EventTypes) == 0 {
+		return ErrInvalidWebhookEvent
+	}
+
+	for _, t := range s.EventTypes {
+		if !slices.Contains(WebhookEventTypes, t) {
+			return ErrInvalidWebhookEvent
+		}
+	}
+
+	return nil
+}
+
+// WebhookDelivery is one event queued for one subscription. It is retried
+// with backoff while PENDING and ends SUCCEEDED or, once its attempts are
+// used up, DEAD.
+type WebhookDelivery struct {
+	ID             int64                 `db:"id"`
+	SubscriptionID int64                 `db:"subscription_id"`
+	EventID        int64                 `db:"event_id"`
+	EventType      OutboxEventType       `db:"event_type"`
+	Payload        json.RawMessage       `db:"payload"`
+	Status         WebhookDeliveryStatus `db:"status"`
+	Attempts       int                   `db:"attempts"`
+	NextAttemptAt  time.Time             `db:"next_attempt_at"`
+	LastStatusCode *int                  `db:"last_status_code"`
+	LastError      *string               `db:"last_error"`
+	DeliveredAt    *time.Time            `db:"delivered_at"`
+	CreatedAt      time.Time             `db:"created_at"`
+	UpdatedAt      time.Time             `db:"updated_at"`
+}
@@ -0,0 +1,311 @@
+// Package fakebank is an in-process bank for exercising payouts offline. It
+// speaks the protocol of payout.HTTPProvider and injects the faults a real
+// bank shows: slow answers, answers that never come, transient errors,
+// rejected payouts and callbacks delivered twice.
+package fakebank
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/rand/v2"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"time"
+
+	"github.com/vcnt72/go-boilerplate/internal/domain"
+	"github.com/vcnt72/go-boilerplate/internal/payout"
+	"github.com/vcnt72/go-boilerplate/internal/webhook"
+)
+
+// Options configures the faults. Rates are fractions between 0 and 1 of the
+// requests, or payouts, they apply to.
+type Options struct {
+	// Delay is waited before answering every request.
+	Delay time.Duration
+	// FailRate of requests are answered 503 without taking the payout.
+	FailRate float64
+	// TimeoutRate of new payouts are taken but never answered: the bank
+	// holds the request until the client gives up.
+	TimeoutRate float64
+	// RejectRate of new payouts end FAILED instead of SUCCEEDED.
+	RejectRate float64
+
+	// CallbackURL makes the bank asynchronous: new payouts are answered SENT
+	// and their outcome is posted to CallbackURL after CallbackDelay, signed
+	// with CallbackSecret. Without it payouts are answered with their outcome.
+	CallbackURL    string
+	CallbackSecret string
+	CallbackDelay  time.Duration
+	// DuplicateRate of callbacks are delivered twice.
+	DuplicateRate float64
+
+	// Seed makes the injected faults reproducible.
+	Seed uint64
+}
+
+// Payout is what the bank knows about one reference.
+type Payout struct {
+	Request payout.TransferRequest
+	// Status is the payout's final status, even while the client was only
+	// told SENT.
+	Status domain.PayoutStatus
+	// Requests counts how often the reference was sent.
+	Requests int
+}
+
+type Stats struct {
+	Requests  int `json:"requests"`
+	Failed    int `json:"failed"`
+	TimedOut  int `json:"timedOut"`
+	Replayed  int `json:"replayed"`
+	Callbacks int `json:"callbacks"`
+	// CallbackErrors counts callbacks the receiver did not answer 2xx.
+	CallbackErrors int `json:"callbackErrors"`
+}
+
+// Bank is an http.Handler serving POST /payouts.
+type Bank struct {
+	opts   Options
+	client *http.Client
+
+	mu      sync.Mutex
+	rand    *rand.Rand
+	payouts map[string]*Payout
+	stats   Stats
+
+	server    *httptest.Server
+	done      chan struct{}
+	closeOnce sync.Once
+	callbacks sync.WaitGroup
+}
+
+func (b *Bank) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost || r.URL.Path != "/payouts" {
+		http.NotFound(w, r)
+		return
+	}
+
+	var req payout.TransferRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Reference == "" {
+		http.Error(w, "invalid payout request", http.StatusBadRequest)
+		return
+	}
+
+	if !b.wait(r.Context(), b.options().Delay) {
+		return
+	}
+
+	res, replayed, hang, ok := b.take(req)
+	if !ok {
+		http.Error(w, "bank unavailable", http.StatusServiceUnavailable)
+		return
+	}
+
+	if hang {
+		// The payout is taken; only the answer is lost.
+		b.wait(r.Context(), time.Hour)
+		return
+	}
+
+	if replayed {
+		w.Header().Set("Idempotent-Replayed", "true")
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(res)
+}
+
+// take decides the request's fate under the lock. ok is false when the
+// request fails without taking the payout.
+func (b *Bank) take(req payout.TransferRequest) (res domain.PayoutResponse, replayed, hang, ok bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	opts := b.opts
+	b.stats.Requests++
+	if b.roll(opts.FailRate) {
+		b.stats.Failed++
+		return res, false, false, false
+	}
+
+	if p, found := b.payouts[req.Reference]; found {
+		p.Requests++
+		b.stats.Replayed++
+		return b.answer(p, false), true, false, true
+	}
+
+	p := &Payout{Request: req, Status: domain.PayoutStatusSucceeded, Requests: 1}
+	if b.roll(opts.RejectRate) {
+		p.Status = domain.PayoutStatusFailed
+	}
+	b.payouts[req.Reference] = p
+
+	if opts.CallbackURL != "" {
+		times := 1
+		if b.roll(opts.DuplicateRate) {
+			times = 2
+		}
+		b.callbacks.Add(1)
+		go b.callback(opts, p.Request.Reference, b.answer(p, false), times)
+	}
+
+	if b.roll(opts.TimeoutRate) {
+		b.stats.TimedOut++
+		return res, false, true, true
+	}
+
+	return b.answer(p, true), false, false, true
+}
+
+// answer is what the client is told. A new payout of an asynchronous bank is
+// only SENT; a repeated reference is told the outcome. The caller holds the
+// lock.
+func (b *Bank) answer(p *Payout, fresh bool) domain.PayoutResponse {
+	if fresh && b.opts.CallbackURL != "" {
+		return domain.PayoutResponse{Status: domain.PayoutStatusSent}
+	}
+
+	res := domain.PayoutResponse{Status: p.Status}
+	if p.Status == domain.PayoutStatusFailed {
+		res.Reason = "rejected by fake bank"
+	}
+
+	return res
+}
+
+func (b *Bank) callback(opts Options, reference string, res domain.PayoutResponse, times int) {
+	defer b.callbacks.Done()
+
+	if !b.wait(context.Background(), opts.CallbackDelay) {
+		return
+	}
+
+	body, err := json.Marshal(payout.Callback{Reference: reference, Status: res.Status, Reason: res.Reason})
+	if err != nil {
+		return
+	}
+
+	for range times {
+		err := b.post(opts, body)
+
+		b.mu.Lock()
+		b.stats.Callbacks++
+		if err != nil {
+			b.stats.CallbackErrors++
+		}
+		b.mu.Unlock()
+	}
+}
+
+func (b *Bank) post(opts Options, body []byte) error {
+	req, err := http.NewRequest(http.MethodPost, opts.CallbackURL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if opts.CallbackSecret != "" {
+		req.Header.Set(payout.CallbackSignatureHeader, webhook.Sign(opts.CallbackSecret, time.Now(), body))
+	}
+
+	res, err := b.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return fmt.Errorf("callback receiver responded %d", res.StatusCode)
+	}
+
+	return nil
+}
+
+// wait sleeps for d unless ctx ends or the bank closes first.
+func (b *Bank) wait(ctx context.Context, d time.Duration) bool {
+	if d <= 0 {
+		return true
+	}
+
+	t := time.NewTimer(d)
+	defer t.Stop()
+
+	select {
+	case <-t.C:
+		return true
+	case <-ctx.Done():
+		return false
+	case <-b.done:
+		return false
+	}
+}
+
+func (b *Bank) options() Options {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.opts
+}
+
+// Configure replaces the faults for requests from now on. Payouts already
+// taken keep their outcome.
+func (b *Bank) Configure(opts Options) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.opts = opts
+}
+
+func (b *Bank) roll(rate float64) bool {
+	return rate > 0 && b.rand.Float64() < rate
+}
+
+// Payouts returns a copy of every payout the bank took, by reference.
+func (b *Bank) Payouts() map[string]Payout {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	out := make(map[string]Payout, len(b.payouts))
+	for ref, p := range b.payouts {
+		out[ref] = *p
+	}
+
+	return out
+}
+
+func (b *Bank) Stats() Stats {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.stats
+}
+
+// Start serves the bank on a local port; URL returns its address.
+func (b *Bank) Start() {
+	b.server = httptest.NewServer(b)
+}
+
+func (b *Bank) URL() string {
+	return b.server.URL
+}
+
+// Close stops the server, releasing held requests, and waits for pending
+// callbacks.
+func (b *Bank) Close() {
+	b.closeOnce.Do(func() { close(b.done) })
+	if b.server != nil {
+		b.server.Close()
+	}
+	b.callbacks.Wait()
+}
+
+func New(opts Options) *Bank {
+	return &Bank{
+		opts:    opts,
+		client:  &http.Client{Timeout: 10 * time.Second},
+		rand:    rand.New(rand.NewPCG(opts.Seed, opts.Seed)),
+		payouts: map[string]*Payout{},
+		done:    make(chan struct{}),
+	}
+}
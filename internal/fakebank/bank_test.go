@@ -0,0 +1,149 @@
+package fakebank
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/vcnt72/go-boilerplate/internal/domain"
+	"github.com/vcnt72/go-boilerplate/internal/payout"
+	"github.com/vcnt72/go-boilerplate/internal/webhook"
+)
+
+func startBank(t *testing.T, opts Options) (*Bank, *payout.HTTPProvider) {
+	t.Helper()
+
+	bank := New(opts)
+	bank.Start()
+	t.Cleanup(bank.Close)
+
+	return bank, payout.NewHTTPProvider(nil, bank.URL(), 200*time.Millisecond)
+}
+
+func payoutRequest(reference string) domain.PayoutRequest {
+	return domain.PayoutRequest{Reference: reference, UserID: 1, WalletID: 1, Amount: domain.NewMoney(1_000, "IDR")}
+}
+
+func TestBank_Succeeds(t *testing.T) {
+	bank, provider := startBank(t, Options{})
+
+	res, err := provider.Send(context.Background(), payoutRequest("ref-1"))
+	require.NoError(t, err)
+	require.Equal(t, domain.PayoutStatusSucceeded, res.Status)
+
+	p := bank.Payouts()["ref-1"]
+	require.Equal(t, int64(1_000), p.Request.Amount)
+	require.Equal(t, "IDR", p.Request.Currency)
+}
+
+func TestBank_Rejects(t *testing.T) {
+	_, provider := startBank(t, Options{RejectRate: 1})
+
+	res, err := provider.Send(context.Background(), payoutRequest("ref-1"))
+	require.NoError(t, err)
+	require.Equal(t, domain.PayoutStatusFailed, res.Status)
+	require.NotEmpty(t, res.Reason)
+}
+
+func TestBank_FailsWithoutTakingPayout(t *testing.T) {
+	bank, provider := startBank(t, Options{FailRate: 1})
+
+	_, err := provider.Send(context.Background(), payoutRequest("ref-1"))
+	require.Error(t, err)
+	require.Empty(t, bank.Payouts())
+	require.Equal(t, 1, bank.Stats().Failed)
+}
+
+func TestBank_TimeoutTakesPayout(t *testing.T) {
+	bank, provider := startBank(t, Options{TimeoutRate: 1})
+
+	_, err := provider.Send(context.Background(), payoutRequest("ref-1"))
+	require.Error(t, err)
+
+	// The payout went through; resending the reference answers it.
+	res, err := provider.Send(context.Background(), payoutRequest("ref-1"))
+	require.NoError(t, err)
+	require.Equal(t, domain.PayoutStatusSucceeded, res.Status)
+	require.Equal(t, 2, bank.Payouts()["ref-1"].Requests)
+	require.Equal(t, 1, bank.Stats().TimedOut)
+}
+
+func TestBank_Delay(t *testing.T) {
+	_, provider := startBank(t, Options{Delay: time.Second})
+
+	_, err := provider.Send(context.Background(), payoutRequest("ref-1"))
+	require.Error(t, err)
+}
+
+func TestBank_CallbacksAndDuplicates(t *testing.T) {
+	var mu sync.Mutex
+	var callbacks []payout.Callback
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var cb payout.Callback
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&cb))
+		require.NotEmpty(t, r.Header.Get(payout.CallbackSignatureHeader))
+
+		mu.Lock()
+		callbacks = append(callbacks, cb)
+		mu.Unlock()
+	}))
+	defer receiver.Close()
+
+	bank, provider := startBank(t, Options{CallbackURL: receiver.URL, CallbackSecret: "s3cret", DuplicateRate: 1})
+
+	res, err := provider.Send(context.Background(), payoutRequest("ref-1"))
+	require.NoError(t, err)
+	require.Equal(t, domain.PayoutStatusSent, res.Status)
+
+	bank.Close()
+
+	require.Len(t, callbacks, 2)
+	for _, cb := range callbacks {
+		require.Equal(t, payout.Callback{Reference: "ref-1", Status: domain.PayoutStatusSucceeded}, cb)
+	}
+	require.Equal(t, 0, bank.Stats().CallbackErrors)
+}
+
+func TestBank_SignsCallbacks(t *testing.T) {
+	var verified error
+	done := make(chan struct{})
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer close(done)
+
+		var body json.RawMessage
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		verified = webhook.Verify("s3cret", r.Header.Get(payout.CallbackSignatureHeader), body, time.Minute, time.Now())
+	}))
+	defer receiver.Close()
+
+	_, provider := startBank(t, Options{CallbackURL: receiver.URL, CallbackSecret: "s3cret"})
+
+	_, err := provider.Send(context.Background(), payoutRequest("ref-1"))
+	require.NoError(t, err)
+
+	<-done
+	require.NoError(t, verified)
+}
+
+func TestBank_SeedIsReproducible(t *testing.T) {
+	outcomes := func() []domain.PayoutStatus {
+		_, provider := startBank(t, Options{RejectRate: 0.5, Seed: 42})
+
+		var statuses []domain.PayoutStatus
+		for _, ref := range []string{"a", "b", "c", "d", "e", "f", "g", "h"} {
+			res, err := provider.Send(context.Background(), payoutRequest(ref))
+			require.NoError(t, err)
+			statuses = append(statuses, res.Status)
+		}
+
+		return statuses
+	}
+
+	require.Equal(t, outcomes(), outcomes())
+}
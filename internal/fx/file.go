@@ -0,0 +1,72 @@
+// Package fx loads FX rates from outside the database.
+package fx
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"strings"
+
+	"github.com/vcnt72/go-boilerplate/internal/domain"
+)
+
+// FileSource reads rates from a JSON file holding a list of rates, e.g.
+//
+//	[{"base": "USD", "quote": "IDR", "rate": "16250.5", "feeBps": 50}]
+//
+// The file is read again on every lookup, so edits apply without a restart.
+type FileSource struct {
+	path string
+}
+
+func (f FileSource) GetRate(_ context.Context, base, quote string) (*domain.FXRate, error) {
+	rates, err := f.load()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, rate := range rates {
+		if strings.EqualFold(rate.BaseCurrency, base) && strings.EqualFold(rate.QuoteCurrency, quote) {
+			return &rate, nil
+		}
+	}
+
+	return nil, domain.ErrRateNotFound
+}
+
+func (f FileSource) load() ([]domain.FXRate, error) {
+	info, err := os.Stat(f.path)
+	if err != nil {
+		return nil, err
+	}
+
+	b, err := os.ReadFile(f.path)
+	if err != nil {
+		return nil, err
+	}
+
+	var rates []domain.FXRate
+	if err := json.Unmarshal(b, &rates); err != nil {
+		return nil, err
+	}
+
+	for i := range rates {
+		rates[i].BaseCurrency = strings.ToUpper(rates[i].BaseCurrency)
+		rates[i].QuoteCurrency = strings.ToUpper(rates[i].QuoteCurrency)
+		if err := rates[i].Validate(); err != nil {
+			return nil, err
+		}
+
+		if rates[i].UpdatedAt.IsZero() {
+			rates[i].UpdatedAt = info.ModTime()
+		}
+	}
+
+	return rates, nil
+}
+
+func NewFileSource(path string) *FileSource {
+	return &FileSource{
+		path: path,
+	}
+}
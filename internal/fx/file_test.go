@@ -0,0 +1,48 @@
+package fx
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/vcnt72/go-boilerplate/internal/domain"
+)
+
+func writeRates(t *testing.T, content string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "rates.json")
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o644))
+	return path
+}
+
+func TestFileSource_GetRate(t *testing.T) {
+	path := writeRates(t, `[{"base": "usd", "quote": "IDR", "rate": "16250.5", "feeBps": 50}]`)
+	source := NewFileSource(path)
+
+	rate, err := source.GetRate(context.Background(), "USD", "IDR")
+	require.NoError(t, err)
+	require.Equal(t, "USD", rate.BaseCurrency)
+	require.Equal(t, "16250.5", rate.Rate)
+	require.Equal(t, int64(50), rate.FeeBps)
+	require.False(t, rate.UpdatedAt.IsZero())
+
+	_, err = source.GetRate(context.Background(), "IDR", "USD")
+	require.ErrorIs(t, err, domain.ErrRateNotFound)
+
+	// Edits are picked up on the next lookup.
+	require.NoError(t, os.WriteFile(path, []byte(`[{"base": "USD", "quote": "IDR", "rate": "16300"}]`), 0o644))
+	rate, err = source.GetRate(context.Background(), "USD", "IDR")
+	require.NoError(t, err)
+	require.Equal(t, "16300", rate.Rate)
+}
+
+func TestFileSource_InvalidRate(t *testing.T) {
+	source := NewFileSource(writeRates(t, `[{"base": "USD", "quote": "IDR", "rate": "-1"}]`))
+
+	_, err := source.GetRate(context.Background(), "USD", "IDR")
+	require.ErrorIs(t, err, domain.ErrInvalidRate)
+}
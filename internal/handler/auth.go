@@ -0,0 +1,30 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/vcnt72/go-boilerplate/internal/utils/response"
+)
+
+// ContextUserIDKey is the gin context key under which the auth middleware
+// stores the authenticated user ID.
+const ContextUserIDKey = "auth.userID"
+
+// ContextUserRoleKey holds the role of the authenticated caller, empty for
+// regular users.
+const ContextUserRoleKey = "auth.userRole"
+
+// authUserID returns the authenticated caller. When no user is on the context
+// it writes a 401 response and reports false.
+func authUserID(ctx *gin.Context) (int64, bool) {
+	userID, ok := ctx.Get(ContextUserIDKey)
+	if ok {
+		if id, ok := userID.(int64); ok && id > 0 {
+			return id, true
+		}
+	}
+
+	ctx.JSON(http.StatusUnauthorized, response.Error(ctx, "UNAUTHORIZED", "missing or invalid credentials"))
+	return 0, false
+}
@@ -0,0 +1,248 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/vcnt72/go-boilerplate/internal/domain"
+	"github.com/vcnt72/go-boilerplate/internal/service"
+	"github.com/vcnt72/go-boilerplate/internal/utils/logger"
+	"github.com/vcnt72/go-boilerplate/internal/utils/response"
+	"go.uber.org/zap"
+)
+
+type FXHandler struct {
+	fxService *service.FXService
+}
+
+type CreateQuoteRequest struct {
+	// Amount is converted from FromCurrency; the fee is charged on top.
+	Amount       json.Number `json:"amount" binding:"required"`
+	FromCurrency string      `json:"fromCurrency" binding:"required"`
+	ToCurrency   string      `json:"toCurrency" binding:"required"`
+}
+
+func (f FXHandler) CreateQuote() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		var req CreateQuoteRequest
+
+		if err := ctx.ShouldBindJSON(&req); err != nil {
+			ctx.JSON(
+				http.StatusBadRequest,
+				response.Error(ctx, "VALIDATION_ERROR", "Salah validasi"),
+			)
+			return
+		}
+
+		userID, ok := authUserID(ctx)
+		if !ok {
+			return
+		}
+
+		amount, err := f.fxService.ParseAmount(req.Amount.String(), req.FromCurrency)
+		if err != nil {
+			f.fxReturnError(ctx, err)
+			return
+		}
+
+		quote, err := f.fxService.CreateQuote(ctx, service.CreateQuoteSpec{
+			UserID:     userID,
+			Amount:     amount,
+			ToCurrency: req.ToCurrency,
+		})
+		if err != nil {
+			f.fxReturnError(ctx, err)
+			return
+		}
+
+		ctx.JSON(http.StatusCreated, response.Success(ctx, response.JSON{
+			"id":           quote.ID,
+			"fromCurrency": quote.FromCurrency,
+			"toCurrency":   quote.ToCurrency,
+			"fromAmount":   quote.FromAmount,
+			"fee":          quote.Fee,
+			"toAmount":     quote.ToAmount,
+			"rate":         quote.Rate,
+			"status":       quote.Status,
+			"expiresAt":    quote.ExpiresAt,
+		}))
+	}
+}
+
+func (f FXHandler) ExecuteQuote() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		idempotencyKey := ctx.GetHeader("X-Idempotency-Key")
+
+		if idempotencyKey == "" {
+			ctx.JSON(http.StatusBadRequest, response.Error(ctx, "INVALID_IDEMPOTENCY_KEY", "idempotency should exist"))
+			return
+		}
+
+		userID, ok := authUserID(ctx)
+		if !ok {
+			return
+		}
+
+		res, err := f.fxService.ExecuteQuote(ctx, service.ExecuteQuoteSpec{
+			UserID:         userID,
+			QuoteID:        ctx.Param("id"),
+			IdempotencyKey: idempotencyKey,
+		})
+		if err != nil {
+			f.fxReturnError(ctx, err)
+			return
+		}
+
+		ctx.JSON(http.StatusOK, response.Success(ctx, response.JSON{
+			"quoteId":     res.QuoteID,
+			"userId":      res.UserID,
+			"debited":     res.Debited,
+			"fee":         res.Fee,
+			"credited":    res.Credited,
+			"fromBalance": res.FromBalance,
+			"toBalance":   res.ToBalance,
+		}))
+	}
+}
+
+type SetRateRequest struct {
+	BaseCurrency  string `json:"base" binding:"required"`
+	QuoteCurrency string `json:"quote" binding:"required"`
+	// Rate is a decimal string, the price of one base unit in the quote
+	// currency.
+	Rate   string `json:"rate" binding:"required"`
+	FeeBps int64  `json:"feeBps"`
+}
+
+// SetRate is admin-only: the route is guarded by RequireRole.
+func (f FXHandler) SetRate() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		var req SetRateRequest
+
+		if err := ctx.ShouldBindJSON(&req); err != nil {
+			ctx.JSON(
+				http.StatusBadRequest,
+				response.Error(ctx, "VALIDATION_ERROR", "Salah validasi"),
+			)
+			return
+		}
+
+		rate, err := f.fxService.SetRate(ctx, domain.FXRate{
+			BaseCurrency:  req.BaseCurrency,
+			QuoteCurrency: req.QuoteCurrency,
+			Rate:          req.Rate,
+			FeeBps:        req.FeeBps,
+		})
+		if err != nil {
+			f.fxReturnError(ctx, err)
+			return
+		}
+
+		ctx.JSON(http.StatusOK, response.Success(ctx, response.JSON{
+			"base":      rate.BaseCurrency,
+			"quote":     rate.QuoteCurrency,
+			"rate":      rate.Rate,
+			"feeBps":    rate.FeeBps,
+			"updatedAt": rate.UpdatedAt,
+		}))
+	}
+}
+
+func (f FXHandler) fxReturnError(ctx *gin.Context, err error) {
+	switch {
+	case errors.Is(err, domain.ErrInvalidAmount):
+		ctx.JSON(http.StatusBadRequest,
+			response.Error(ctx, "INVALID_AMOUNT", "amount must be greater than 0"))
+		return
+
+	case errors.Is(err, domain.ErrInvalidMoney):
+		ctx.JSON(http.StatusBadRequest,
+			response.Error(ctx, "INVALID_AMOUNT", "amount is not a valid decimal in this currency"))
+		return
+
+	case errors.Is(err, domain.ErrMoneyOverflow):
+		ctx.JSON(http.StatusUnprocessableEntity,
+			response.Error(ctx, "AMOUNT_OVERFLOW", "amount is too large"))
+		return
+
+	case errors.Is(err, domain.ErrUnsupportedCurrency):
+		ctx.JSON(http.StatusBadRequest,
+			response.Error(ctx, "UNSUPPORTED_CURRENCY", "currency is not supported"))
+		return
+
+	case errors.Is(err, domain.ErrSameCurrency):
+		ctx.JSON(http.StatusBadRequest,
+			response.Error(ctx, "SAME_CURRENCY", "cannot convert into the same currency"))
+		return
+
+	case errors.Is(err, domain.ErrInvalidRate):
+		ctx.JSON(http.StatusBadRequest,
+			response.Error(ctx, "INVALID_RATE", "rate must be a positive decimal and feeBps below 10000"))
+		return
+
+	case errors.Is(err, domain.ErrWalletNotFound):
+		ctx.JSON(http.StatusNotFound,
+			response.Error(ctx, "WALLET_NOT_FOUND", "wallet not found"))
+		return
+
+	case errors.Is(err, domain.ErrCurrencyMismatch):
+		ctx.JSON(http.StatusUnprocessableEntity,
+			response.Error(ctx, "CURRENCY_MISMATCH", "user has no wallet in this currency"))
+		return
+
+	case errors.Is(err, domain.ErrRateNotFound):
+		ctx.JSON(http.StatusNotFound,
+			response.Error(ctx, "RATE_NOT_FOUND", "no rate for this currency pair"))
+		return
+
+	case errors.Is(err, domain.ErrQuoteNotFound):
+		ctx.JSON(http.StatusNotFound,
+			response.Error(ctx, "QUOTE_NOT_FOUND", "quote not found"))
+		return
+
+	case errors.Is(err, domain.ErrQuoteExpired):
+		ctx.JSON(http.StatusConflict,
+			response.Error(ctx, "QUOTE_EXPIRED", "quote has expired, request a new one"))
+		return
+
+	case errors.Is(err, domain.ErrQuoteNotOpen):
+		ctx.JSON(http.StatusConflict,
+			response.Error(ctx, "QUOTE_NOT_OPEN", "quote was already executed"))
+		return
+
+	case errors.Is(err, domain.ErrInsufficientFund):
+		ctx.JSON(http.StatusConflict,
+			response.Error(ctx, "INSUFFICIENT_FUNDS", "insufficient balance"))
+		return
+
+	case errors.Is(err, domain.ErrIdempotencyKeyReused):
+		ctx.JSON(http.StatusConflict,
+			response.Error(ctx, "IDEMPOTENCY_KEY_REUSED", "idempotency key reused with different request"))
+		return
+
+	case errors.Is(err, domain.ErrRequestInProgress):
+		ctx.JSON(http.StatusConflict,
+			response.Error(ctx, "REQUEST_IN_PROGRESS", "request is being processed, please retry"))
+		return
+
+	case errors.Is(err, domain.ErrFXFailed):
+		logger.Log.Error("error on fx", zap.Error(err))
+		ctx.JSON(http.StatusInternalServerError,
+			response.Error(ctx, "FX_FAILED", "conversion failed"))
+		return
+
+	default:
+		logger.Log.Error("error on fx", zap.Error(err))
+		ctx.JSON(http.StatusInternalServerError,
+			response.Error(ctx, "UNKNOWN_ERROR", "internal server error"))
+		return
+	}
+}
+
+func NewFXHandler(fxService *service.FXService) *FXHandler {
+	return &FXHandler{
+		fxService: fxService,
+	}
+}
@@ -1,16 +1,30 @@
 // Package handler
 package handler
 
-import "github.com/vcnt72/go-boilerplate/internal/service"
+import (
+	"github.com/vcnt72/go-boilerplate/internal/repository"
+	"github.com/vcnt72/go-boilerplate/internal/service"
+	"github.com/vcnt72/go-boilerplate/internal/worker"
+)
 
 type Handlers struct {
-	UserHandler   *UserHandler
-	WalletHandler *WalletHandler
+	UserHandler    *UserHandler
+	WalletHandler  *WalletHandler
+	WorkerHandler  *WorkerHandler
+	WebhookHandler *WebhookHandler
+	FXHandler      *FXHandler
+	PayoutHandler  *PayoutHandler
+	TxHandler      *TxHandler
 }
 
-func New(services service.Services) Handlers {
+func New(services service.Services, workers worker.Workers, txProvider repository.TxProvider) Handlers {
 	return Handlers{
-		UserHandler:   NewUserHandler(services.UserService),
-		WalletHandler: NewWalletHandler(services.WalletService),
+		UserHandler:    NewUserHandler(services.UserService),
+		WalletHandler:  NewWalletHandler(services.WalletService),
+		WorkerHandler:  NewWorkerHandler(workers.Sweeper, workers.OutboxRelay, workers.WebhookDispatcher),
+		WebhookHandler: NewWebhookHandler(services.WebhookService),
+		FXHandler:      NewFXHandler(services.FXService),
+		PayoutHandler:  NewPayoutHandler(services.PayoutService),
+		TxHandler:      NewTxHandler(txProvider),
 	}
 }
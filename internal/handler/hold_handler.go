@@ -0,0 +1,275 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/vcnt72/go-boilerplate/internal/domain"
+	"github.com/vcnt72/go-boilerplate/internal/service"
+	"github.com/vcnt72/go-boilerplate/internal/utils/logger"
+	"github.com/vcnt72/go-boilerplate/internal/utils/response"
+	"go.uber.org/zap"
+)
+
+type AuthorizeRequest struct {
+	Amount json.Number `json:"amount" binding:"required"`
+	// ExpiresIn is in seconds, up to 30 days.
+	ExpiresIn int64 `json:"expiresIn" binding:"omitempty,min=1,max=2592000"`
+	// Currency defaults to the default currency.
+	Currency string `json:"currency"`
+}
+
+func (w WalletHandler) Authorize() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		var req AuthorizeRequest
+
+		if err := ctx.ShouldBindJSON(&req); err != nil {
+			ctx.JSON(
+				http.StatusBadRequest,
+				response.Error(ctx, "VALIDATION_ERROR", "Salah validasi"),
+			)
+			return
+		}
+
+		userID, ok := authUserID(ctx)
+		if !ok {
+			return
+		}
+
+		idempotencyKey := ctx.GetHeader("X-Idempotency-Key")
+
+		if idempotencyKey == "" {
+			ctx.JSON(http.StatusBadRequest, response.Error(ctx, "INVALID_IDEMPOTENCY_KEY", "idempotency should exist"))
+			return
+		}
+
+		amount, err := w.walletService.ParseAmount(req.Amount.String(), req.Currency)
+		if err != nil {
+			w.holdReturnError(ctx, err)
+			return
+		}
+
+		holdRes, err := w.walletService.Authorize(ctx, service.AuthorizeSpec{
+			UserID:         userID,
+			IdempotencyKey: idempotencyKey,
+			Amount:         amount,
+			ExpiresIn:      time.Duration(req.ExpiresIn) * time.Second,
+		})
+		if err != nil {
+			w.holdReturnError(ctx, err)
+			return
+		}
+
+		ctx.JSON(http.StatusOK, response.Success(ctx, holdJSON(holdRes)))
+	}
+}
+
+type CaptureRequest struct {
+	// Amount captures part of the hold; omitted captures all of it.
+	Amount json.Number `json:"amount"`
+	// Currency of Amount, defaulting to the default currency. It must be
+	// the hold's.
+	Currency string `json:"currency"`
+}
+
+func (w WalletHandler) Capture() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		var req CaptureRequest
+
+		if ctx.Request.ContentLength != 0 {
+			if err := ctx.ShouldBindJSON(&req); err != nil {
+				ctx.JSON(
+					http.StatusBadRequest,
+					response.Error(ctx, "VALIDATION_ERROR", "Salah validasi"),
+				)
+				return
+			}
+		}
+
+		userID, ok := authUserID(ctx)
+		if !ok {
+			return
+		}
+
+		holdID, ok := holdIDParam(ctx)
+		if !ok {
+			return
+		}
+
+		idempotencyKey := ctx.GetHeader("X-Idempotency-Key")
+
+		if idempotencyKey == "" {
+			ctx.JSON(http.StatusBadRequest, response.Error(ctx, "INVALID_IDEMPOTENCY_KEY", "idempotency should exist"))
+			return
+		}
+
+		var amount domain.Money
+		if req.Amount != "" {
+			var err error
+			amount, err = w.walletService.ParseAmount(req.Amount.String(), req.Currency)
+			if err != nil {
+				w.holdReturnError(ctx, err)
+				return
+			}
+
+			if !amount.IsPositive() {
+				w.holdReturnError(ctx, domain.ErrInvalidAmount)
+				return
+			}
+		}
+
+		holdRes, err := w.walletService.Capture(ctx, service.CaptureSpec{
+			UserID:         userID,
+			HoldID:         holdID,
+			IdempotencyKey: idempotencyKey,
+			Amount:         amount,
+		})
+		if err != nil {
+			w.holdReturnError(ctx, err)
+			return
+		}
+
+		ctx.JSON(http.StatusOK, response.Success(ctx, holdJSON(holdRes)))
+	}
+}
+
+func (w WalletHandler) Void() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		userID, ok := authUserID(ctx)
+		if !ok {
+			return
+		}
+
+		holdID, ok := holdIDParam(ctx)
+		if !ok {
+			return
+		}
+
+		idempotencyKey := ctx.GetHeader("X-Idempotency-Key")
+
+		if idempotencyKey == "" {
+			ctx.JSON(http.StatusBadRequest, response.Error(ctx, "INVALID_IDEMPOTENCY_KEY", "idempotency should exist"))
+			return
+		}
+
+		holdRes, err := w.walletService.Void(ctx, service.VoidSpec{
+			UserID:         userID,
+			HoldID:         holdID,
+			IdempotencyKey: idempotencyKey,
+		})
+		if err != nil {
+			w.holdReturnError(ctx, err)
+			return
+		}
+
+		ctx.JSON(http.StatusOK, response.Success(ctx, holdJSON(holdRes)))
+	}
+}
+
+func (w WalletHandler) holdReturnError(ctx *gin.Context, err error) {
+	switch {
+	case errors.Is(err, domain.ErrInvalidAmount):
+		ctx.JSON(http.StatusBadRequest,
+			response.Error(ctx, "INVALID_AMOUNT", "amount must be greater than 0"))
+		return
+
+	case errors.Is(err, domain.ErrWalletNotFound):
+		ctx.JSON(http.StatusNotFound,
+			response.Error(ctx, "WALLET_NOT_FOUND", "wallet not found"))
+		return
+
+	case errors.Is(err, domain.ErrInvalidMoney):
+		ctx.JSON(http.StatusBadRequest,
+			response.Error(ctx, "INVALID_AMOUNT", "amount is not a valid decimal in this currency"))
+		return
+
+	case errors.Is(err, domain.ErrMoneyOverflow):
+		ctx.JSON(http.StatusUnprocessableEntity,
+			response.Error(ctx, "AMOUNT_OVERFLOW", "amount is too large"))
+		return
+
+	case errors.Is(err, domain.ErrUnsupportedCurrency):
+		ctx.JSON(http.StatusBadRequest,
+			response.Error(ctx, "UNSUPPORTED_CURRENCY", "currency is not supported"))
+		return
+
+	case errors.Is(err, domain.ErrCurrencyMismatch):
+		ctx.JSON(http.StatusUnprocessableEntity,
+			response.Error(ctx, "CURRENCY_MISMATCH", "user has no wallet in this currency"))
+		return
+
+	case errors.Is(err, domain.ErrHoldNotFound):
+		ctx.JSON(http.StatusNotFound,
+			response.Error(ctx, "HOLD_NOT_FOUND", "hold not found"))
+		return
+
+	case errors.Is(err, domain.ErrInsufficientFund):
+		ctx.JSON(http.StatusConflict,
+			response.Error(ctx, "INSUFFICIENT_FUNDS", "insufficient balance"))
+		return
+
+	case errors.Is(err, domain.ErrHoldNotActive):
+		ctx.JSON(http.StatusConflict,
+			response.Error(ctx, "HOLD_NOT_ACTIVE", "hold was already captured, voided or expired"))
+		return
+
+	case errors.Is(err, domain.ErrHoldExpired):
+		ctx.JSON(http.StatusConflict,
+			response.Error(ctx, "HOLD_EXPIRED", "hold expired"))
+		return
+
+	case errors.Is(err, domain.ErrCaptureExceedsHold):
+		ctx.JSON(http.StatusUnprocessableEntity,
+			response.Error(ctx, "CAPTURE_EXCEEDS_HOLD", "capture amount exceeds the held amount"))
+		return
+
+	case errors.Is(err, domain.ErrIdempotencyKeyReused):
+		ctx.JSON(http.StatusConflict,
+			response.Error(ctx, "IDEMPOTENCY_KEY_REUSED", "idempotency key reused with different request"))
+		return
+
+	case errors.Is(err, domain.ErrRequestInProgress):
+		ctx.JSON(http.StatusConflict,
+			response.Error(ctx, "REQUEST_IN_PROGRESS", "request is being processed, please retry"))
+		return
+
+	case errors.Is(err, domain.ErrHoldFailed):
+		logger.Log.Error("error on hold", zap.Error(err))
+		ctx.JSON(http.StatusInternalServerError,
+			response.Error(ctx, "HOLD_FAILED", "hold failed"))
+		return
+
+	default:
+		logger.Log.Error("error on hold", zap.Error(err))
+		ctx.JSON(http.StatusInternalServerError,
+			response.Error(ctx, "UNKNOWN_ERROR", "internal server error"))
+		return
+	}
+}
+
+func holdIDParam(ctx *gin.Context) (int64, bool) {
+	id, err := strconv.ParseInt(ctx.Param("id"), 10, 64)
+	if err != nil || id <= 0 {
+		ctx.JSON(http.StatusNotFound, response.Error(ctx, "HOLD_NOT_FOUND", "hold not found"))
+		return 0, false
+	}
+
+	return id, true
+}
+
+func holdJSON(res *service.HoldResult) response.JSON {
+	return response.JSON{
+		"holdId":         res.Hold.ID,
+		"userId":         res.UserID,
+		"status":         res.Hold.Status,
+		"amount":         res.Hold.Amount,
+		"capturedAmount": res.Hold.CapturedAmount,
+		"expiresAt":      res.Hold.ExpiresAt,
+		"balance":        res.Balance,
+		"currency":       res.Hold.Currency,
+	}
+}
@@ -0,0 +1,90 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/vcnt72/go-boilerplate/internal/domain"
+	"github.com/vcnt72/go-boilerplate/internal/service"
+	"github.com/vcnt72/go-boilerplate/internal/utils/logger"
+	"github.com/vcnt72/go-boilerplate/internal/utils/response"
+	"go.uber.org/zap"
+)
+
+type PayoutHandler struct {
+	payoutService *service.PayoutService
+}
+
+type PayoutCallbackRequest struct {
+	Reference string `json:"reference" binding:"required"`
+	Status    string `json:"status" binding:"required"`
+	// Reason explains a FAILED payout. It is only logged.
+	Reason string `json:"reason"`
+}
+
+// Callback receives payout status updates from the payout provider. The route
+// verifies the provider's signature before this runs.
+func (p PayoutHandler) Callback() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		var req PayoutCallbackRequest
+
+		if err := ctx.ShouldBindJSON(&req); err != nil {
+			ctx.JSON(
+				http.StatusBadRequest,
+				response.Error(ctx, "VALIDATION_ERROR", "Salah validasi"),
+			)
+			return
+		}
+
+		if req.Status == domain.PayoutStatusFailed {
+			logger.Log.Info("payout failed at provider", zap.String("reference", req.Reference), zap.String("reason", req.Reason))
+		}
+
+		res, err := p.payoutService.Complete(ctx, service.CompletePayoutSpec{
+			Reference: req.Reference,
+			Status:    req.Status,
+		})
+		if err != nil {
+			p.payoutReturnError(ctx, err)
+			return
+		}
+
+		ctx.JSON(http.StatusOK, response.Success(ctx, response.JSON{
+			"reference": res.Reference,
+			"ledgerId":  res.Withdrawal.ID,
+			"status":    res.Withdrawal.Status,
+		}))
+	}
+}
+
+func (p PayoutHandler) payoutReturnError(ctx *gin.Context, err error) {
+	switch {
+	case errors.Is(err, domain.ErrInvalidPayoutStatus):
+		ctx.JSON(http.StatusBadRequest,
+			response.Error(ctx, "INVALID_PAYOUT_STATUS", "status must be SENT, SUCCEEDED or FAILED"))
+		return
+
+	case errors.Is(err, domain.ErrPayoutNotFound):
+		ctx.JSON(http.StatusNotFound,
+			response.Error(ctx, "PAYOUT_NOT_FOUND", "payout not found"))
+		return
+
+	case errors.Is(err, domain.ErrPayoutConflict):
+		ctx.JSON(http.StatusConflict,
+			response.Error(ctx, "PAYOUT_CONFLICT", "payout already settled with another status"))
+		return
+
+	default:
+		logger.Log.Error("error on payout callback", zap.Error(err))
+		ctx.JSON(http.StatusInternalServerError,
+			response.Error(ctx, "UNKNOWN_ERROR", "internal server error"))
+		return
+	}
+}
+
+func NewPayoutHandler(payoutService *service.PayoutService) *PayoutHandler {
+	return &PayoutHandler{
+		payoutService: payoutService,
+	}
+}
@@ -0,0 +1,155 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/vcnt72/go-boilerplate/internal/domain"
+	"github.com/vcnt72/go-boilerplate/internal/service"
+	"github.com/vcnt72/go-boilerplate/internal/utils/logger"
+	"github.com/vcnt72/go-boilerplate/internal/utils/response"
+	"go.uber.org/zap"
+)
+
+type ReverseWithdrawalRequest struct {
+	// UserID owns the withdrawal; idempotency keys are scoped per wallet.
+	UserID int64 `json:"userId" binding:"required,min=1"`
+	// Amount reverses part of the withdrawal; omitted reverses the rest of it.
+	Amount json.Number `json:"amount"`
+	// Currency picks the user's wallet, defaulting to the default currency.
+	Currency string `json:"currency"`
+}
+
+// ReverseWithdrawal is admin-only: the route is guarded by RequireRole.
+func (w WalletHandler) ReverseWithdrawal() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		var req ReverseWithdrawalRequest
+
+		if err := ctx.ShouldBindJSON(&req); err != nil {
+			ctx.JSON(
+				http.StatusBadRequest,
+				response.Error(ctx, "VALIDATION_ERROR", "Salah validasi"),
+			)
+			return
+		}
+
+		idempotencyKey := ctx.GetHeader("X-Idempotency-Key")
+
+		if idempotencyKey == "" {
+			ctx.JSON(http.StatusBadRequest, response.Error(ctx, "INVALID_IDEMPOTENCY_KEY", "idempotency should exist"))
+			return
+		}
+
+		// An omitted amount is parsed as zero so that it still names the
+		// wallet's currency.
+		rawAmount := req.Amount.String()
+		if rawAmount == "" {
+			rawAmount = "0"
+		}
+
+		amount, err := w.walletService.ParseAmount(rawAmount, req.Currency)
+		if err != nil {
+			w.reversalReturnError(ctx, err)
+			return
+		}
+
+		if req.Amount != "" && !amount.IsPositive() {
+			w.reversalReturnError(ctx, domain.ErrInvalidAmount)
+			return
+		}
+
+		reversalRes, err := w.walletService.ReverseWithdrawal(ctx, service.ReverseWithdrawalSpec{
+			UserID:         req.UserID,
+			WithdrawalKey:  ctx.Param("idempotencyKey"),
+			IdempotencyKey: idempotencyKey,
+			Amount:         amount,
+		})
+		if err != nil {
+			w.reversalReturnError(ctx, err)
+			return
+		}
+
+		ctx.JSON(http.StatusOK, response.Success(ctx, response.JSON{
+			"ledgerId":       reversalRes.LedgerID,
+			"userId":         reversalRes.UserID,
+			"withdrawalKey":  reversalRes.WithdrawalKey,
+			"amount":         reversalRes.Amount,
+			"reversedAmount": reversalRes.Reversed,
+			"balance":        reversalRes.Balance,
+			"currency":       reversalRes.Amount.Currency,
+		}))
+	}
+}
+
+func (w WalletHandler) reversalReturnError(ctx *gin.Context, err error) {
+	switch {
+	case errors.Is(err, domain.ErrInvalidAmount):
+		ctx.JSON(http.StatusBadRequest,
+			response.Error(ctx, "INVALID_AMOUNT", "amount must be greater than 0"))
+		return
+
+	case errors.Is(err, domain.ErrWalletNotFound):
+		ctx.JSON(http.StatusNotFound,
+			response.Error(ctx, "WALLET_NOT_FOUND", "wallet not found"))
+		return
+
+	case errors.Is(err, domain.ErrInvalidMoney):
+		ctx.JSON(http.StatusBadRequest,
+			response.Error(ctx, "INVALID_AMOUNT", "amount is not a valid decimal in this currency"))
+		return
+
+	case errors.Is(err, domain.ErrMoneyOverflow):
+		ctx.JSON(http.StatusUnprocessableEntity,
+			response.Error(ctx, "AMOUNT_OVERFLOW", "amount is too large"))
+		return
+
+	case errors.Is(err, domain.ErrUnsupportedCurrency):
+		ctx.JSON(http.StatusBadRequest,
+			response.Error(ctx, "UNSUPPORTED_CURRENCY", "currency is not supported"))
+		return
+
+	case errors.Is(err, domain.ErrCurrencyMismatch):
+		ctx.JSON(http.StatusUnprocessableEntity,
+			response.Error(ctx, "CURRENCY_MISMATCH", "user has no wallet in this currency"))
+		return
+
+	case errors.Is(err, domain.ErrLedgerNotFound):
+		ctx.JSON(http.StatusNotFound,
+			response.Error(ctx, "WITHDRAWAL_NOT_FOUND", "withdrawal not found"))
+		return
+
+	case errors.Is(err, domain.ErrNotReversible):
+		ctx.JSON(http.StatusConflict,
+			response.Error(ctx, "NOT_REVERSIBLE", "only succeeded withdrawals can be reversed"))
+		return
+
+	case errors.Is(err, domain.ErrReversalExceeds):
+		ctx.JSON(http.StatusUnprocessableEntity,
+			response.Error(ctx, "REVERSAL_EXCEEDS_ORIGINAL", "reversal exceeds the withdrawn amount"))
+		return
+
+	case errors.Is(err, domain.ErrIdempotencyKeyReused):
+		ctx.JSON(http.StatusConflict,
+			response.Error(ctx, "IDEMPOTENCY_KEY_REUSED", "idempotency key reused with different request"))
+		return
+
+	case errors.Is(err, domain.ErrRequestInProgress):
+		ctx.JSON(http.StatusConflict,
+			response.Error(ctx, "REQUEST_IN_PROGRESS", "request is being processed, please retry"))
+		return
+
+	case errors.Is(err, domain.ErrReversalFailed):
+		logger.Log.Error("error on reversal", zap.Error(err))
+		ctx.JSON(http.StatusInternalServerError,
+			response.Error(ctx, "REVERSAL_FAILED", "reversal failed"))
+		return
+
+	default:
+		logger.Log.Error("error on reversal", zap.Error(err))
+		ctx.JSON(http.StatusInternalServerError,
+			response.Error(ctx, "UNKNOWN_ERROR", "internal server error"))
+		return
+	}
+}
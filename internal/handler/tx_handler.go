@@ -0,0 +1,25 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/vcnt72/go-boilerplate/internal/repository"
+	"github.com/vcnt72/go-boilerplate/internal/utils/response"
+)
+
+type TxHandler struct {
+	txProvider repository.TxProvider
+}
+
+func (t TxHandler) Stats() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		ctx.JSON(http.StatusOK, response.Success(ctx, t.txProvider.Stats()))
+	}
+}
+
+func NewTxHandler(txProvider repository.TxProvider) *TxHandler {
+	return &TxHandler{
+		txProvider: txProvider,
+	}
+}
@@ -2,10 +2,13 @@
 package handler
 
 import (
+	"encoding/json"
+	"errors"
 	"log"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
+	"github.com/vcnt72/go-boilerplate/internal/domain"
 	"github.com/vcnt72/go-boilerplate/internal/service"
 	"github.com/vcnt72/go-boilerplate/internal/utils/response"
 )
@@ -15,8 +18,11 @@ type UserHandler struct {
 }
 
 type CreateUserRequest struct {
-	Name    string `json:"name" binding:"required"`
-	Balance int64  `json:"balance" binding:"required"`
+	Name string `json:"name" binding:"required"`
+	// Balance is a decimal in major units of Currency.
+	Balance json.Number `json:"balance" binding:"required"`
+	// Currency of the first wallet, defaulting to the default currency.
+	Currency string `json:"currency"`
 }
 
 func (t UserHandler) Create() gin.HandlerFunc {
@@ -31,13 +37,18 @@ func (t UserHandler) Create() gin.HandlerFunc {
 			return
 		}
 
+		balance, err := t.userService.ParseAmount(req.Balance.String(), req.Currency)
+		if err != nil {
+			t.createReturnError(ctx, err)
+			return
+		}
+
 		user, err := t.userService.Create(ctx, service.CreateUserSpec{
-			Balance: req.Balance,
+			Balance: balance,
 			Name:    req.Name,
 		})
 		if err != nil {
-			log.Println(err)
-			ctx.JSON(http.StatusInternalServerError, response.Error(ctx, "UNKNOWN_ERROR", "Unknown Error"))
+			t.createReturnError(ctx, err)
 			return
 		}
 
@@ -52,6 +63,23 @@ func (t UserHandler) Create() gin.HandlerFunc {
 	}
 }
 
+func (t UserHandler) createReturnError(ctx *gin.Context, err error) {
+	switch {
+	case errors.Is(err, domain.ErrUnsupportedCurrency):
+		ctx.JSON(http.StatusBadRequest, response.Error(ctx, "UNSUPPORTED_CURRENCY", "currency is not supported"))
+
+	case errors.Is(err, domain.ErrInvalidMoney):
+		ctx.JSON(http.StatusBadRequest, response.Error(ctx, "INVALID_AMOUNT", "amount is not a valid decimal in this currency"))
+
+	case errors.Is(err, domain.ErrMoneyOverflow):
+		ctx.JSON(http.StatusUnprocessableEntity, response.Error(ctx, "AMOUNT_OVERFLOW", "amount is too large"))
+
+	default:
+		log.Println(err)
+		ctx.JSON(http.StatusInternalServerError, response.Error(ctx, "UNKNOWN_ERROR", "Unknown Error"))
+	}
+}
+
 func NewUserHandler(userService *service.UserService) *UserHandler {
 	return &UserHandler{
 		userService: userService,
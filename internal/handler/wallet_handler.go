@@ -1,9 +1,10 @@
 package handler
 
 import (
+	"encoding/json"
 	"errors"
 	"net/http"
-	"strconv"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/vcnt72/go-boilerplate/internal/domain"
@@ -17,17 +18,16 @@ type WalletHandler struct {
 	walletService *service.WalletService
 }
 
+// GetBalance reads the wallet named by the currency query parameter, or the
+// default currency wallet.
 func (w WalletHandler) GetBalance() gin.HandlerFunc {
 	return func(ctx *gin.Context) {
-		userIDStr := ctx.GetHeader("X-User-ID")
-
-		userID, err := strconv.ParseInt(userIDStr, 10, 64)
-		if err != nil {
-			ctx.JSON(http.StatusBadRequest, response.Error(ctx, "INVALID_USER_ID", "user_id must be a positive integer"))
+		userID, ok := authUserID(ctx)
+		if !ok {
 			return
 		}
 
-		wallet, err := w.walletService.GetByUserID(ctx, userID)
+		wallet, err := w.walletService.GetByUserID(ctx, userID, ctx.Query("currency"))
 		if err != nil {
 
 			if errors.Is(err, domain.ErrWalletNotFound) {
@@ -35,20 +35,114 @@ func (w WalletHandler) GetBalance() gin.HandlerFunc {
 				return
 			}
 
+			if errors.Is(err, domain.ErrUnsupportedCurrency) {
+				ctx.JSON(http.StatusBadRequest, response.Error(ctx, "UNSUPPORTED_CURRENCY", "currency is not supported"))
+				return
+			}
+
+			if errors.Is(err, domain.ErrCurrencyMismatch) {
+				ctx.JSON(http.StatusUnprocessableEntity, response.Error(ctx, "CURRENCY_MISMATCH", "user has no wallet in this currency"))
+				return
+			}
+
 			logger.Log.Error("error on get user balance", zap.Error(err))
 
 			ctx.JSON(http.StatusInternalServerError, response.Error(ctx, "UNKNOWN_ERROR", "Unknown error"))
 			return
 		}
 
-		ctx.JSON(http.StatusOK, response.Success(ctx, response.JSON{
-			"balance": wallet.Balance,
-		}))
+		ctx.JSON(http.StatusOK, response.Success(ctx, walletJSON(*wallet)))
+	}
+}
+
+func (w WalletHandler) ListWallets() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		userID, ok := authUserID(ctx)
+		if !ok {
+			return
+		}
+
+		wallets, err := w.walletService.ListWallets(ctx, userID)
+		if err != nil {
+			logger.Log.Error("error on list wallets", zap.Error(err))
+			ctx.JSON(http.StatusInternalServerError, response.Error(ctx, "UNKNOWN_ERROR", "Unknown error"))
+			return
+		}
+
+		items := make([]response.JSON, 0, len(wallets))
+		for _, wallet := range wallets {
+			items = append(items, walletJSON(wallet))
+		}
+
+		ctx.JSON(http.StatusOK, response.Success(ctx, response.JSON{"items": items}))
+	}
+}
+
+type OpenWalletRequest struct {
+	Currency string `json:"currency" binding:"required"`
+}
+
+func (w WalletHandler) OpenWallet() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		var req OpenWalletRequest
+
+		if err := ctx.ShouldBindJSON(&req); err != nil {
+			ctx.JSON(
+				http.StatusBadRequest,
+				response.Error(ctx, "VALIDATION_ERROR", "Salah validasi"),
+			)
+			return
+		}
+
+		userID, ok := authUserID(ctx)
+		if !ok {
+			return
+		}
+
+		wallet, err := w.walletService.OpenWallet(ctx, service.OpenWalletSpec{
+			UserID:   userID,
+			Currency: req.Currency,
+		})
+		if err != nil {
+			switch {
+			case errors.Is(err, domain.ErrUnsupportedCurrency):
+				ctx.JSON(http.StatusBadRequest, response.Error(ctx, "UNSUPPORTED_CURRENCY", "currency is not supported"))
+				return
+
+			case errors.Is(err, domain.ErrWalletNotFound):
+				ctx.JSON(http.StatusNotFound, response.Error(ctx, "WALLET_NOT_FOUND", "wallet not found"))
+				return
+
+			case errors.Is(err, domain.ErrWalletExists):
+				ctx.JSON(http.StatusConflict, response.Error(ctx, "WALLET_EXISTS", "user already has a wallet in this currency"))
+				return
+
+			default:
+				logger.Log.Error("error on open wallet", zap.Error(err))
+				ctx.JSON(http.StatusInternalServerError, response.Error(ctx, "UNKNOWN_ERROR", "internal server error"))
+				return
+			}
+		}
+
+		ctx.JSON(http.StatusCreated, response.Success(ctx, walletJSON(*wallet)))
 	}
 }
 
+func walletJSON(wallet domain.Wallet) response.JSON {
+	return response.JSON{
+		"currency":         wallet.Currency,
+		"exponent":         wallet.Exponent,
+		"balance":          wallet.Balance,
+		"heldBalance":      wallet.HeldBalance,
+		"availableBalance": wallet.AvailableBalance,
+	}
+}
+
+// Amounts are decimals in major units of the currency, sent either as JSON
+// numbers or as strings, e.g. "12.50" USD.
 type WithdrawRequest struct {
-	Amount int64 `json:"amount" binding:"required"`
+	Amount   json.Number `json:"amount" binding:"required"`
+	Currency string      `json:"currency" binding:"required"`
 }
 
 func (w WalletHandler) Withdraw() gin.HandlerFunc {
@@ -63,11 +157,8 @@ func (w WalletHandler) Withdraw() gin.HandlerFunc {
 			return
 		}
 
-		userIDStr := ctx.GetHeader("X-User-ID")
-
-		userID, err := strconv.ParseInt(userIDStr, 10, 64)
-		if err != nil {
-			ctx.JSON(http.StatusBadRequest, response.Error(ctx, "INVALID_USER_ID", "user_id must be a positive integer"))
+		userID, ok := authUserID(ctx)
+		if !ok {
 			return
 		}
 
@@ -79,10 +170,68 @@ func (w WalletHandler) Withdraw() gin.HandlerFunc {
 
 		}
 
+		amount, err := w.walletService.ParseAmount(req.Amount.String(), req.Currency)
+		if err != nil {
+			w.withdrawReturnError(ctx, err)
+			return
+		}
+
 		withdrawalRes, err := w.walletService.Withdraw(ctx, service.WithdrawWalletSpec{
 			UserID:         userID,
 			IdempotencyKey: idempotencyKey,
-			Amount:         req.Amount,
+			Amount:         amount,
+		})
+		if err != nil {
+			w.withdrawReturnError(ctx, err)
+			return
+		}
+
+		// A payout still pending at the provider is accepted, not done.
+		status := http.StatusOK
+		if withdrawalRes.Status != domain.LedgerStatusSucceed {
+			status = http.StatusAccepted
+		}
+
+		ctx.JSON(status, response.Success(ctx, response.JSON{
+			"balance":         withdrawalRes.Balance,
+			"amount":          withdrawalRes.Amount,
+			"fee":             withdrawalRes.Fee,
+			"currency":        withdrawalRes.Amount.Currency,
+			"userId":          withdrawalRes.UserID,
+			"status":          withdrawalRes.Status,
+			"payoutReference": withdrawalRes.PayoutReference,
+		}))
+	}
+}
+
+// PreviewWithdraw answers what Withdraw would do with the same body, without
+// withdrawing. It needs no idempotency key since it changes nothing.
+func (w WalletHandler) PreviewWithdraw() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		var req WithdrawRequest
+
+		if err := ctx.ShouldBindJSON(&req); err != nil {
+			ctx.JSON(
+				http.StatusBadRequest,
+				response.Error(ctx, "VALIDATION_ERROR", "Salah validasi"),
+			)
+			return
+		}
+
+		userID, ok := authUserID(ctx)
+		if !ok {
+			return
+		}
+
+		amount, err := w.walletService.ParseAmount(req.Amount.String(), req.Currency)
+		if err != nil {
+			w.withdrawReturnError(ctx, err)
+			return
+		}
+
+		preview, err := w.walletService.PreviewWithdraw(ctx, service.PreviewWithdrawSpec{
+			UserID: userID,
+			Amount: amount,
 		})
 		if err != nil {
 			w.withdrawReturnError(ctx, err)
@@ -90,9 +239,18 @@ func (w WalletHandler) Withdraw() gin.HandlerFunc {
 		}
 
 		ctx.JSON(http.StatusOK, response.Success(ctx, response.JSON{
-			"balance": withdrawalRes.Balance,
-			"amount":  withdrawalRes.Amount,
-			"userId":  withdrawalRes.UserID,
+			"userId":                preview.UserID,
+			"currency":              preview.Amount.Currency,
+			"amount":                preview.Amount,
+			"fee":                   preview.Fee,
+			"totalDebit":            preview.Debit,
+			"balanceAfter":          preview.Balance,
+			"availableBalanceAfter": preview.AvailableBalance,
+			"limitsRemaining": response.JSON{
+				"maxPerTransaction": preview.MaxPerTransaction,
+				"dailyAmount":       preview.RemainingDailyAmount,
+				"dailyCount":        preview.RemainingDailyCount,
+			},
 		}))
 	}
 }
@@ -110,11 +268,36 @@ func (w WalletHandler) withdrawReturnError(ctx *gin.Context, err error) {
 			response.Error(ctx, "WALLET_NOT_FOUND", "wallet not found"))
 		return
 
+	case errors.Is(err, domain.ErrInvalidMoney):
+		ctx.JSON(http.StatusBadRequest,
+			response.Error(ctx, "INVALID_AMOUNT", "amount is not a valid decimal in this currency"))
+		return
+
+	case errors.Is(err, domain.ErrMoneyOverflow):
+		ctx.JSON(http.StatusUnprocessableEntity,
+			response.Error(ctx, "AMOUNT_OVERFLOW", "amount is too large"))
+		return
+
 	case errors.Is(err, domain.ErrInsufficientFund):
 		ctx.JSON(http.StatusConflict,
 			response.Error(ctx, "INSUFFICIENT_FUNDS", "insufficient balance"))
 		return
 
+	case errors.Is(err, domain.ErrUnsupportedCurrency):
+		ctx.JSON(http.StatusBadRequest,
+			response.Error(ctx, "UNSUPPORTED_CURRENCY", "currency is not supported"))
+		return
+
+	case errors.Is(err, domain.ErrCurrencyMismatch):
+		ctx.JSON(http.StatusUnprocessableEntity,
+			response.Error(ctx, "CURRENCY_MISMATCH", "user has no wallet in this currency"))
+		return
+
+	case errors.Is(err, domain.ErrLimitExceeded):
+		ctx.JSON(http.StatusUnprocessableEntity,
+			response.Error(ctx, "LIMIT_EXCEEDED", "withdrawal limit exceeded"))
+		return
+
 	case errors.Is(err, domain.ErrIdempotencyKeyReused):
 		ctx.JSON(http.StatusConflict,
 			response.Error(ctx, "IDEMPOTENCY_KEY_REUSED", "idempotency key reused with different request"))
@@ -125,6 +308,11 @@ func (w WalletHandler) withdrawReturnError(ctx *gin.Context, err error) {
 			response.Error(ctx, "REQUEST_IN_PROGRESS", "request is being processed, please retry"))
 		return
 
+	case errors.Is(err, domain.ErrPayoutFailed):
+		ctx.JSON(http.StatusUnprocessableEntity,
+			response.Error(ctx, "PAYOUT_FAILED", "payout was rejected, the funds were returned"))
+		return
+
 	case errors.Is(err, domain.ErrWithdrawFailed):
 		logger.Log.Error("error on withdraw balance", zap.Error(err))
 		ctx.JSON(http.StatusInternalServerError,
@@ -139,6 +327,330 @@ func (w WalletHandler) withdrawReturnError(ctx *gin.Context, err error) {
 	}
 }
 
+type DepositRequest struct {
+	Amount   json.Number `json:"amount" binding:"required"`
+	Currency string      `json:"currency" binding:"required"`
+}
+
+func (w WalletHandler) Deposit() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		var req DepositRequest
+
+		if err := ctx.ShouldBindJSON(&req); err != nil {
+			ctx.JSON(
+				http.StatusBadRequest,
+				response.Error(ctx, "VALIDATION_ERROR", "Salah validasi"),
+			)
+			return
+		}
+
+		userID, ok := authUserID(ctx)
+		if !ok {
+			return
+		}
+
+		idempotencyKey := ctx.GetHeader("X-Idempotency-Key")
+
+		if idempotencyKey == "" {
+			ctx.JSON(http.StatusBadRequest, response.Error(ctx, "INVALID_IDEMPOTENCY_KEY", "idempotency should exist"))
+			return
+		}
+
+		amount, err := w.walletService.ParseAmount(req.Amount.String(), req.Currency)
+		if err != nil {
+			w.depositReturnError(ctx, err)
+			return
+		}
+
+		depositRes, err := w.walletService.Deposit(ctx, service.DepositWalletSpec{
+			UserID:         userID,
+			IdempotencyKey: idempotencyKey,
+			Amount:         amount,
+		})
+		if err != nil {
+			w.depositReturnError(ctx, err)
+			return
+		}
+
+		ctx.JSON(http.StatusOK, response.Success(ctx, response.JSON{
+			"balance":  depositRes.Balance,
+			"amount":   depositRes.Amount,
+			"currency": depositRes.Amount.Currency,
+			"userId":   depositRes.UserID,
+		}))
+	}
+}
+
+func (w WalletHandler) depositReturnError(ctx *gin.Context, err error) {
+	switch {
+	case errors.Is(err, domain.ErrInvalidAmount):
+		ctx.JSON(http.StatusBadRequest,
+			response.Error(ctx, "INVALID_AMOUNT", "amount must be greater than 0"))
+		return
+
+	case errors.Is(err, domain.ErrWalletNotFound):
+		ctx.JSON(http.StatusNotFound,
+			response.Error(ctx, "WALLET_NOT_FOUND", "wallet not found"))
+		return
+
+	case errors.Is(err, domain.ErrInvalidMoney):
+		ctx.JSON(http.StatusBadRequest,
+			response.Error(ctx, "INVALID_AMOUNT", "amount is not a valid decimal in this currency"))
+		return
+
+	case errors.Is(err, domain.ErrMoneyOverflow):
+		ctx.JSON(http.StatusUnprocessableEntity,
+			response.Error(ctx, "AMOUNT_OVERFLOW", "amount is too large"))
+		return
+
+	case errors.Is(err, domain.ErrUnsupportedCurrency):
+		ctx.JSON(http.StatusBadRequest,
+			response.Error(ctx, "UNSUPPORTED_CURRENCY", "currency is not supported"))
+		return
+
+	case errors.Is(err, domain.ErrCurrencyMismatch):
+		ctx.JSON(http.StatusUnprocessableEntity,
+			response.Error(ctx, "CURRENCY_MISMATCH", "user has no wallet in this currency"))
+		return
+
+	case errors.Is(err, domain.ErrIdempotencyKeyReused):
+		ctx.JSON(http.StatusConflict,
+			response.Error(ctx, "IDEMPOTENCY_KEY_REUSED", "idempotency key reused with different request"))
+		return
+
+	case errors.Is(err, domain.ErrRequestInProgress):
+		ctx.JSON(http.StatusConflict,
+			response.Error(ctx, "REQUEST_IN_PROGRESS", "request is being processed, please retry"))
+		return
+
+	case errors.Is(err, domain.ErrDepositFailed):
+		logger.Log.Error("error on deposit balance", zap.Error(err))
+		ctx.JSON(http.StatusInternalServerError,
+			response.Error(ctx, "DEPOSIT_FAILED", "deposit failed"))
+		return
+
+	default:
+		logger.Log.Error("error on deposit balance", zap.Error(err))
+		ctx.JSON(http.StatusInternalServerError,
+			response.Error(ctx, "UNKNOWN_ERROR", "internal server error"))
+		return
+	}
+}
+
+type TransferRequest struct {
+	ToUserID int64       `json:"toUserId" binding:"required"`
+	Amount   json.Number `json:"amount" binding:"required"`
+	// Currency defaults to the default currency.
+	Currency string `json:"currency"`
+}
+
+func (w WalletHandler) Transfer() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		var req TransferRequest
+
+		if err := ctx.ShouldBindJSON(&req); err != nil {
+			ctx.JSON(
+				http.StatusBadRequest,
+				response.Error(ctx, "VALIDATION_ERROR", "Salah validasi"),
+			)
+			return
+		}
+
+		userID, ok := authUserID(ctx)
+		if !ok {
+			return
+		}
+
+		idempotencyKey := ctx.GetHeader("X-Idempotency-Key")
+
+		if idempotencyKey == "" {
+			ctx.JSON(http.StatusBadRequest, response.Error(ctx, "INVALID_IDEMPOTENCY_KEY", "idempotency should exist"))
+			return
+		}
+
+		amount, err := w.walletService.ParseAmount(req.Amount.String(), req.Currency)
+		if err != nil {
+			w.transferReturnError(ctx, err)
+			return
+		}
+
+		transferRes, err := w.walletService.Transfer(ctx, service.TransferWalletSpec{
+			FromUserID:     userID,
+			ToUserID:       req.ToUserID,
+			IdempotencyKey: idempotencyKey,
+			Amount:         amount,
+		})
+		if err != nil {
+			w.transferReturnError(ctx, err)
+			return
+		}
+
+		ctx.JSON(http.StatusOK, response.Success(ctx, response.JSON{
+			"transferId": transferRes.TransferID,
+			"balance":    transferRes.Balance,
+			"amount":     transferRes.Amount,
+			"currency":   transferRes.Amount.Currency,
+			"userId":     transferRes.FromUserID,
+			"toUserId":   transferRes.ToUserID,
+		}))
+	}
+}
+
+func (w WalletHandler) transferReturnError(ctx *gin.Context, err error) {
+	switch {
+	case errors.Is(err, domain.ErrInvalidAmount):
+		ctx.JSON(http.StatusBadRequest,
+			response.Error(ctx, "INVALID_AMOUNT", "amount must be greater than 0"))
+		return
+
+	case errors.Is(err, domain.ErrSameWallet):
+		ctx.JSON(http.StatusBadRequest,
+			response.Error(ctx, "INVALID_TRANSFER", "cannot transfer to the same wallet"))
+		return
+
+	case errors.Is(err, domain.ErrWalletNotFound):
+		ctx.JSON(http.StatusNotFound,
+			response.Error(ctx, "WALLET_NOT_FOUND", "wallet not found"))
+		return
+
+	case errors.Is(err, domain.ErrInvalidMoney):
+		ctx.JSON(http.StatusBadRequest,
+			response.Error(ctx, "INVALID_AMOUNT", "amount is not a valid decimal in this currency"))
+		return
+
+	case errors.Is(err, domain.ErrMoneyOverflow):
+		ctx.JSON(http.StatusUnprocessableEntity,
+			response.Error(ctx, "AMOUNT_OVERFLOW", "amount is too large"))
+		return
+
+	case errors.Is(err, domain.ErrUnsupportedCurrency):
+		ctx.JSON(http.StatusBadRequest,
+			response.Error(ctx, "UNSUPPORTED_CURRENCY", "currency is not supported"))
+		return
+
+	case errors.Is(err, domain.ErrCurrencyMismatch):
+		ctx.JSON(http.StatusUnprocessableEntity,
+			response.Error(ctx, "CURRENCY_MISMATCH", "user has no wallet in this currency"))
+		return
+
+	case errors.Is(err, domain.ErrInsufficientFund):
+		ctx.JSON(http.StatusConflict,
+			response.Error(ctx, "INSUFFICIENT_FUNDS", "insufficient balance"))
+		return
+
+	case errors.Is(err, domain.ErrIdempotencyKeyReused):
+		ctx.JSON(http.StatusConflict,
+			response.Error(ctx, "IDEMPOTENCY_KEY_REUSED", "idempotency key reused with different request"))
+		return
+
+	case errors.Is(err, domain.ErrRequestInProgress):
+		ctx.JSON(http.StatusConflict,
+			response.Error(ctx, "REQUEST_IN_PROGRESS", "request is being processed, please retry"))
+		return
+
+	case errors.Is(err, domain.ErrTransferFailed):
+		logger.Log.Error("error on transfer balance", zap.Error(err))
+		ctx.JSON(http.StatusInternalServerError,
+			response.Error(ctx, "TRANSFER_FAILED", "transfer failed"))
+		return
+
+	default:
+		logger.Log.Error("error on transfer balance", zap.Error(err))
+		ctx.JSON(http.StatusInternalServerError,
+			response.Error(ctx, "UNKNOWN_ERROR", "internal server error"))
+		return
+	}
+}
+
+type ListTransactionsRequest struct {
+	Currency string     `form:"currency"`
+	Type     string     `form:"type"`
+	Status   string     `form:"status"`
+	From     *time.Time `form:"from" time_format:"2006-01-02T15:04:05Z07:00"`
+	To       *time.Time `form:"to" time_format:"2006-01-02T15:04:05Z07:00"`
+	Limit    int        `form:"limit" binding:"omitempty,min=1,max=100"`
+	Cursor   string     `form:"cursor"`
+}
+
+func (w WalletHandler) ListTransactions() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		var req ListTransactionsRequest
+
+		if err := ctx.ShouldBindQuery(&req); err != nil {
+			ctx.JSON(
+				http.StatusBadRequest,
+				response.Error(ctx, "VALIDATION_ERROR", "Salah validasi"),
+			)
+			return
+		}
+
+		userID, ok := authUserID(ctx)
+		if !ok {
+			return
+		}
+
+		page, err := w.walletService.ListTransactions(ctx, service.ListTransactionsSpec{
+			UserID:   userID,
+			Currency: req.Currency,
+			Type:     req.Type,
+			Status:   req.Status,
+			From:     req.From,
+			To:       req.To,
+			Limit:    req.Limit,
+			Cursor:   req.Cursor,
+		})
+		if err != nil {
+			switch {
+			case errors.Is(err, domain.ErrInvalidCursor):
+				ctx.JSON(http.StatusBadRequest, response.Error(ctx, "INVALID_CURSOR", "cursor is invalid"))
+				return
+
+			case errors.Is(err, domain.ErrWalletNotFound):
+				ctx.JSON(http.StatusNotFound, response.Error(ctx, "WALLET_NOT_FOUND", "wallet not found"))
+				return
+
+			case errors.Is(err, domain.ErrUnsupportedCurrency):
+				ctx.JSON(http.StatusBadRequest, response.Error(ctx, "UNSUPPORTED_CURRENCY", "currency is not supported"))
+				return
+
+			case errors.Is(err, domain.ErrCurrencyMismatch):
+				ctx.JSON(http.StatusUnprocessableEntity, response.Error(ctx, "CURRENCY_MISMATCH", "user has no wallet in this currency"))
+				return
+
+			default:
+				logger.Log.Error("error on list transactions", zap.Error(err))
+				ctx.JSON(http.StatusInternalServerError, response.Error(ctx, "UNKNOWN_ERROR", "internal server error"))
+				return
+			}
+		}
+
+		items := make([]response.JSON, 0, len(page.Ledgers))
+		for _, l := range page.Ledgers {
+			items = append(items, response.JSON{
+				"id":            l.ID,
+				"type":          l.Type,
+				"status":        l.Status,
+				"currency":      l.Currency,
+				"amount":        l.Amount,
+				"resultBalance": l.ResultBalance,
+				"errorCode":     l.ErrorCode,
+				"transferId":    l.TransferID,
+				"createdAt":     l.CreatedAt,
+			})
+		}
+
+		var nextCursor *string
+		if page.NextCursor != "" {
+			nextCursor = &page.NextCursor
+		}
+
+		ctx.JSON(http.StatusOK, response.Success(ctx, response.JSON{
+			"items":      items,
+			"nextCursor": nextCursor,
+		}))
+	}
+}
+
 func NewWalletHandler(walletService *service.WalletService) *WalletHandler {
 	return &WalletHandler{
 		walletService: walletService,
@@ -0,0 +1,254 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/vcnt72/go-boilerplate/internal/domain"
+	"github.com/vcnt72/go-boilerplate/internal/service"
+	"github.com/vcnt72/go-boilerplate/internal/utils/logger"
+	"github.com/vcnt72/go-boilerplate/internal/utils/response"
+	"go.uber.org/zap"
+)
+
+type WebhookHandler struct {
+	webhookService *service.WebhookService
+}
+
+type CreateWebhookRequest struct {
+	URL        string   `json:"url" binding:"required"`
+	EventTypes []string `json:"eventTypes" binding:"required"`
+	Secret     string   `json:"secret"`
+}
+
+func (w WebhookHandler) Create() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		var req CreateWebhookRequest
+
+		if err := ctx.ShouldBindJSON(&req); err != nil {
+			ctx.JSON(
+				http.StatusBadRequest,
+				response.Error(ctx, "VALIDATION_ERROR", "Salah validasi"),
+			)
+			return
+		}
+
+		sub, err := w.webhookService.CreateSubscription(ctx, service.CreateWebhookSpec{
+			URL:        req.URL,
+			EventTypes: req.EventTypes,
+			Secret:     req.Secret,
+		})
+		if err != nil {
+			w.webhookReturnError(ctx, err)
+			return
+		}
+
+		// The secret is only ever returned on creation.
+		body := webhookJSON(*sub)
+		body["secret"] = sub.Secret
+
+		ctx.JSON(http.StatusCreated, response.Success(ctx, body))
+	}
+}
+
+func (w WebhookHandler) List() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		subs, err := w.webhookService.ListSubscriptions(ctx)
+		if err != nil {
+			w.webhookReturnError(ctx, err)
+			return
+		}
+
+		items := make([]response.JSON, 0, len(subs))
+		for _, sub := range subs {
+			items = append(items, webhookJSON(sub))
+		}
+
+		ctx.JSON(http.StatusOK, response.Success(ctx, response.JSON{
+			"items": items,
+		}))
+	}
+}
+
+func (w WebhookHandler) Get() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		id, ok := webhookID(ctx)
+		if !ok {
+			return
+		}
+
+		sub, err := w.webhookService.GetSubscription(ctx, id)
+		if err != nil {
+			w.webhookReturnError(ctx, err)
+			return
+		}
+
+		ctx.JSON(http.StatusOK, response.Success(ctx, webhookJSON(*sub)))
+	}
+}
+
+type UpdateWebhookRequest struct {
+	URL        *string  `json:"url"`
+	EventTypes []string `json:"eventTypes"`
+	Secret     *string  `json:"secret"`
+	Active     *bool    `json:"active"`
+}
+
+func (w WebhookHandler) Update() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		id, ok := webhookID(ctx)
+		if !ok {
+			return
+		}
+
+		var req UpdateWebhookRequest
+
+		if err := ctx.ShouldBindJSON(&req); err != nil {
+			ctx.JSON(
+				http.StatusBadRequest,
+				response.Error(ctx, "VALIDATION_ERROR", "Salah validasi"),
+			)
+			return
+		}
+
+		sub, err := w.webhookService.UpdateSubscription(ctx, service.UpdateWebhookSpec{
+			ID:         id,
+			URL:        req.URL,
+			EventTypes: req.EventTypes,
+			Secret:     req.Secret,
+			Active:     req.Active,
+		})
+		if err != nil {
+			w.webhookReturnError(ctx, err)
+			return
+		}
+
+		ctx.JSON(http.StatusOK, response.Success(ctx, webhookJSON(*sub)))
+	}
+}
+
+func (w WebhookHandler) Delete() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		id, ok := webhookID(ctx)
+		if !ok {
+			return
+		}
+
+		if err := w.webhookService.DeleteSubscription(ctx, id); err != nil {
+			w.webhookReturnError(ctx, err)
+			return
+		}
+
+		ctx.Status(http.StatusNoContent)
+	}
+}
+
+type ListDeliveriesRequest struct {
+	Status   string `form:"status" binding:"omitempty,oneof=PENDING SUCCEEDED DEAD"`
+	BeforeID int64  `form:"beforeId" binding:"omitempty,min=1"`
+	Limit    int    `form:"limit" binding:"omitempty,min=1,max=100"`
+}
+
+func (w WebhookHandler) ListDeliveries() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		id, ok := webhookID(ctx)
+		if !ok {
+			return
+		}
+
+		var req ListDeliveriesRequest
+
+		if err := ctx.ShouldBindQuery(&req); err != nil {
+			ctx.JSON(
+				http.StatusBadRequest,
+				response.Error(ctx, "VALIDATION_ERROR", "Salah validasi"),
+			)
+			return
+		}
+
+		deliveries, err := w.webhookService.ListDeliveries(ctx, service.ListDeliveriesSpec{
+			SubscriptionID: id,
+			Status:         req.Status,
+			BeforeID:       req.BeforeID,
+			Limit:          req.Limit,
+		})
+		if err != nil {
+			w.webhookReturnError(ctx, err)
+			return
+		}
+
+		items := make([]response.JSON, 0, len(deliveries))
+		for _, d := range deliveries {
+			items = append(items, response.JSON{
+				"id":             d.ID,
+				"eventId":        d.EventID,
+				"eventType":      d.EventType,
+				"status":         d.Status,
+				"attempts":       d.Attempts,
+				"nextAttemptAt":  d.NextAttemptAt,
+				"lastStatusCode": d.LastStatusCode,
+				"lastError":      d.LastError,
+				"deliveredAt":    d.DeliveredAt,
+				"createdAt":      d.CreatedAt,
+			})
+		}
+
+		ctx.JSON(http.StatusOK, response.Success(ctx, response.JSON{
+			"items": items,
+		}))
+	}
+}
+
+func (w WebhookHandler) webhookReturnError(ctx *gin.Context, err error) {
+	switch {
+	case errors.Is(err, domain.ErrWebhookNotFound):
+		ctx.JSON(http.StatusNotFound,
+			response.Error(ctx, "WEBHOOK_NOT_FOUND", "webhook subscription not found"))
+		return
+
+	case errors.Is(err, domain.ErrInvalidWebhookURL):
+		ctx.JSON(http.StatusBadRequest,
+			response.Error(ctx, "INVALID_WEBHOOK_URL", "url must be an absolute http or https url"))
+		return
+
+	case errors.Is(err, domain.ErrInvalidWebhookEvent):
+		ctx.JSON(http.StatusBadRequest,
+			response.Error(ctx, "INVALID_EVENT_TYPE", "eventTypes must list supported event types"))
+		return
+
+	default:
+		logger.Log.Error("error on webhook subscription", zap.Error(err))
+		ctx.JSON(http.StatusInternalServerError,
+			response.Error(ctx, "UNKNOWN_ERROR", "internal server error"))
+		return
+	}
+}
+
+func webhookID(ctx *gin.Context) (int64, bool) {
+	id, err := strconv.ParseInt(ctx.Param("id"), 10, 64)
+	if err != nil || id <= 0 {
+		ctx.JSON(http.StatusNotFound, response.Error(ctx, "WEBHOOK_NOT_FOUND", "webhook subscription not found"))
+		return 0, false
+	}
+
+	return id, true
+}
+
+func webhookJSON(sub domain.WebhookSubscription) response.JSON {
+	return response.JSON{
+		"id":         sub.ID,
+		"url":        sub.URL,
+		"eventTypes": sub.EventTypes,
+		"active":     sub.Active,
+		"createdAt":  sub.CreatedAt,
+		"updatedAt":  sub.UpdatedAt,
+	}
+}
+
+func NewWebhookHandler(webhookService *service.WebhookService) *WebhookHandler {
+	return &WebhookHandler{
+		webhookService: webhookService,
+	}
+}
@@ -0,0 +1,41 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/vcnt72/go-boilerplate/internal/utils/response"
+	"github.com/vcnt72/go-boilerplate/internal/worker"
+)
+
+type WorkerHandler struct {
+	sweeper           *worker.Sweeper
+	outboxRelay       *worker.OutboxRelay
+	webhookDispatcher *worker.WebhookDispatcher
+}
+
+func (w WorkerHandler) SweeperStats() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		ctx.JSON(http.StatusOK, response.Success(ctx, w.sweeper.Stats()))
+	}
+}
+
+func (w WorkerHandler) OutboxRelayStats() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		ctx.JSON(http.StatusOK, response.Success(ctx, w.outboxRelay.Stats()))
+	}
+}
+
+func (w WorkerHandler) WebhookDispatcherStats() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		ctx.JSON(http.StatusOK, response.Success(ctx, w.webhookDispatcher.Stats()))
+	}
+}
+
+func NewWorkerHandler(sweeper *worker.Sweeper, outboxRelay *worker.OutboxRelay, webhookDispatcher *worker.WebhookDispatcher) *WorkerHandler {
+	return &WorkerHandler{
+		sweeper:           sweeper,
+		outboxRelay:       outboxRelay,
+		webhookDispatcher: webhookDispatcher,
+	}
+}
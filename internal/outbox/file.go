@@ -0,0 +1,43 @@
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"sync"
+
+	"github.com/vcnt72/go-boilerplate/internal/domain"
+)
+
+// FileSink appends each event as one JSON line to a file, syncing after every
+// write so that a published event survives a crash.
+type FileSink struct {
+	mu   sync.Mutex
+	path string
+}
+
+func (s *FileSink) Publish(_ context.Context, event domain.OutboxEvent) error {
+	b, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	f, err := os.OpenFile(s.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if _, err := f.Write(append(b, '\n')); err != nil {
+		return err
+	}
+
+	return f.Sync()
+}
+
+func NewFileSink(path string) *FileSink {
+	return &FileSink{path: path}
+}
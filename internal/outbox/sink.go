@@ -0,0 +1,82 @@
+// Package outbox publishes outbox events to external sinks.
+package outbox
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/vcnt72/go-boilerplate/internal/domain"
+)
+
+const (
+	SinkStdout  = "stdout"
+	SinkWebhook = "webhook"
+	SinkFile    = "file"
+)
+
+// Sink delivers one event. Delivery is at-least-once: an event is retried
+// until Publish returns nil, so sinks and their consumers must tolerate
+// duplicates, using the event ID to deduplicate.
+type Sink interface {
+	Publish(ctx context.Context, event domain.OutboxEvent) error
+}
+
+type Options struct {
+	WebhookURL string
+	FilePath   string
+}
+
+// NewSink builds the sinks named in the comma separated list names. Several
+// sinks are combined with Multi.
+func NewSink(names string, opts Options) (Sink, error) {
+	var sinks []Sink
+	for name := range strings.SplitSeq(names, ",") {
+		switch strings.TrimSpace(name) {
+		case SinkStdout:
+			sinks = append(sinks, NewStdoutSink())
+
+		case SinkWebhook:
+			if opts.WebhookURL == "" {
+				return nil, errors.New("outbox webhook url is required for the webhook sink")
+			}
+			sinks = append(sinks, NewWebhookSink(opts.WebhookURL, nil))
+
+		case SinkFile:
+			if opts.FilePath == "" {
+				return nil, errors.New("outbox file path is required for the file sink")
+			}
+			sinks = append(sinks, NewFileSink(opts.FilePath))
+
+		case "":
+		default:
+			return nil, fmt.Errorf("unknown outbox sink %q", name)
+		}
+	}
+
+	if len(sinks) == 0 {
+		return nil, errors.New("no outbox sink configured")
+	}
+
+	if len(sinks) == 1 {
+		return sinks[0], nil
+	}
+
+	return Multi(sinks), nil
+}
+
+// Multi publishes every event to all of its sinks. If any of them fails the
+// event is retried on all of them.
+type Multi []Sink
+
+func (m Multi) Publish(ctx context.Context, event domain.OutboxEvent) error {
+	var errs []error
+	for _, s := range m {
+		if err := s.Publish(ctx, event); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
@@ -0,0 +1,94 @@
+package outbox
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/vcnt72/go-boilerplate/internal/domain"
+)
+
+func testEvent(id int64) domain.OutboxEvent {
+	return domain.OutboxEvent{
+		ID:           id,
+		Type:         domain.OutboxEventWithdrawSucceeded,
+		PartitionKey: domain.WalletPartitionKey(1),
+		Payload:      json.RawMessage(`{"amount":1000}`),
+	}
+}
+
+func TestWriterSink_WritesJSONLines(t *testing.T) {
+	var buf bytes.Buffer
+	sink := NewWriterSink(&buf)
+
+	require.NoError(t, sink.Publish(context.Background(), testEvent(1)))
+	require.NoError(t, sink.Publish(context.Background(), testEvent(2)))
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	require.Len(t, lines, 2)
+
+	var got map[string]any
+	require.NoError(t, json.Unmarshal([]byte(lines[1]), &got))
+	require.Equal(t, float64(2), got["id"])
+	require.Equal(t, domain.OutboxEventWithdrawSucceeded, got["type"])
+	require.Equal(t, map[string]any{"amount": float64(1000)}, got["payload"])
+}
+
+func TestFileSink_Appends(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events.jsonl")
+
+	require.NoError(t, NewFileSink(path).Publish(context.Background(), testEvent(1)))
+	require.NoError(t, NewFileSink(path).Publish(context.Background(), testEvent(2)))
+
+	b, err := os.ReadFile(path)
+	require.NoError(t, err)
+	require.Equal(t, 2, strings.Count(string(b), "\n"))
+}
+
+func TestWebhookSink(t *testing.T) {
+	var status = http.StatusOK
+	var gotHeader http.Header
+	var gotBody []byte
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotHeader = r.Header.Clone()
+		gotBody, _ = io.ReadAll(r.Body)
+		w.WriteHeader(status)
+	}))
+	defer srv.Close()
+
+	sink := NewWebhookSink(srv.URL, srv.Client())
+
+	require.NoError(t, sink.Publish(context.Background(), testEvent(7)))
+	require.Equal(t, "7", gotHeader.Get("X-Event-ID"))
+	require.Equal(t, domain.OutboxEventWithdrawSucceeded, gotHeader.Get("X-Event-Type"))
+	require.Contains(t, string(gotBody), `"payload":{"amount":1000}`)
+
+	status = http.StatusServiceUnavailable
+	require.Error(t, sink.Publish(context.Background(), testEvent(8)))
+}
+
+func TestNewSink(t *testing.T) {
+	_, err := NewSink("stdout", Options{})
+	require.NoError(t, err)
+
+	s, err := NewSink("stdout, file", Options{FilePath: filepath.Join(t.TempDir(), "e.jsonl")})
+	require.NoError(t, err)
+	require.IsType(t, Multi{}, s)
+
+	_, err = NewSink("webhook", Options{})
+	require.Error(t, err)
+
+	_, err = NewSink("kafka", Options{})
+	require.Error(t, err)
+
+	_, err = NewSink("", Options{})
+	require.Error(t, err)
+}
@@ -0,0 +1,39 @@
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"os"
+	"sync"
+
+	"github.com/vcnt72/go-boilerplate/internal/domain"
+)
+
+// WriterSink writes each event as one JSON line.
+type WriterSink struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func (s *WriterSink) Publish(_ context.Context, event domain.OutboxEvent) error {
+	b, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, err = s.w.Write(append(b, '\n'))
+
+	return err
+}
+
+func NewWriterSink(w io.Writer) *WriterSink {
+	return &WriterSink{w: w}
+}
+
+func NewStdoutSink() *WriterSink {
+	return NewWriterSink(os.Stdout)
+}
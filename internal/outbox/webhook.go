@@ -0,0 +1,60 @@
+package outbox
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/vcnt72/go-boilerplate/internal/domain"
+)
+
+// WebhookSink POSTs each event as JSON. Any non-2xx response is a failure.
+type WebhookSink struct {
+	url    string
+	client *http.Client
+}
+
+func (s WebhookSink) Publish(ctx context.Context, event domain.OutboxEvent) error {
+	b, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Event-ID", strconv.FormatInt(event.ID, 10))
+	req.Header.Set("X-Event-Type", event.Type)
+
+	res, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	_, _ = io.Copy(io.Discard, res.Body)
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return fmt.Errorf("outbox webhook responded %d", res.StatusCode)
+	}
+
+	return nil
+}
+
+// NewWebhookSink uses client, or a client with a 10s timeout when nil.
+func NewWebhookSink(url string, client *http.Client) *WebhookSink {
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+
+	return &WebhookSink{
+		url:    url,
+		client: client,
+	}
+}
@@ -0,0 +1,99 @@
+package payout
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/vcnt72/go-boilerplate/internal/domain"
+)
+
+// CallbackSignatureHeader carries the bank's signature on status callbacks,
+// in the webhook.Sign format.
+const CallbackSignatureHeader = "X-Payout-Signature"
+
+// TransferRequest is the body of POST <bank>/payouts. Amount is in minor
+// units of Currency.
+type TransferRequest struct {
+	Reference string `json:"reference"`
+	UserID    int64  `json:"userId"`
+	WalletID  int64  `json:"walletId"`
+	Amount    int64  `json:"amount"`
+	Currency  string `json:"currency"`
+}
+
+// Callback is the body a bank posts to report a payout's status.
+type Callback struct {
+	Reference string              `json:"reference"`
+	Status    domain.PayoutStatus `json:"status"`
+	Reason    string              `json:"reason,omitempty"`
+}
+
+// HTTPProvider sends payouts to a bank over HTTP. The bank answers with a
+// domain.PayoutResponse and must treat a repeated reference as the same
+// payout.
+type HTTPProvider struct {
+	client *http.Client
+	url    string
+}
+
+// Send returns an error unless the bank answered 2xx with a known status, in
+// which case the payout may or may not have been taken and must be sent
+// again later.
+func (h HTTPProvider) Send(ctx context.Context, req domain.PayoutRequest) (domain.PayoutResponse, error) {
+	b, err := json.Marshal(TransferRequest{
+		Reference: req.Reference,
+		UserID:    req.UserID,
+		WalletID:  req.WalletID,
+		Amount:    req.Amount.Amount,
+		Currency:  req.Amount.Currency,
+	})
+	if err != nil {
+		return domain.PayoutResponse{}, err
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, h.url+"/payouts", bytes.NewReader(b))
+	if err != nil {
+		return domain.PayoutResponse{}, err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+
+	res, err := h.client.Do(httpReq)
+	if err != nil {
+		return domain.PayoutResponse{}, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		_, _ = io.Copy(io.Discard, io.LimitReader(res.Body, 64<<10))
+		return domain.PayoutResponse{}, fmt.Errorf("payout provider responded %d", res.StatusCode)
+	}
+
+	var out domain.PayoutResponse
+	if err := json.NewDecoder(io.LimitReader(res.Body, 64<<10)).Decode(&out); err != nil {
+		return domain.PayoutResponse{}, fmt.Errorf("decode payout provider response: %w", err)
+	}
+
+	if !domain.ValidPayoutStatus(out.Status) {
+		return domain.PayoutResponse{}, fmt.Errorf("payout provider answered unknown status %q", out.Status)
+	}
+
+	return out, nil
+}
+
+// NewHTTPProvider uses client, or a client with the given timeout when nil.
+func NewHTTPProvider(client *http.Client, url string, timeout time.Duration) *HTTPProvider {
+	if client == nil {
+		client = &http.Client{Timeout: timeout}
+	}
+
+	return &HTTPProvider{
+		client: client,
+		url:    strings.TrimSuffix(url, "/"),
+	}
+}
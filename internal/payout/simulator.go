@@ -0,0 +1,71 @@
+// Package payout holds payout providers that send withdrawn funds out.
+package payout
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/vcnt72/go-boilerplate/internal/domain"
+)
+
+const (
+	OutcomeSucceed = "succeed"
+	OutcomeFail    = "fail"
+	// OutcomePending accepts payouts as SENT and leaves them there until
+	// Settle is called, like a provider that reports back by callback.
+	OutcomePending = "pending"
+)
+
+// Simulator is an in-process payout provider for development and tests. Every
+// new payout gets the configured outcome; a repeated reference returns what
+// the first request got.
+type Simulator struct {
+	outcome string
+
+	mu      sync.Mutex
+	payouts map[string]domain.PayoutResponse
+}
+
+func (s *Simulator) Send(_ context.Context, req domain.PayoutRequest) (domain.PayoutResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if res, ok := s.payouts[req.Reference]; ok {
+		return res, nil
+	}
+
+	var res domain.PayoutResponse
+	switch s.outcome {
+	case OutcomeFail:
+		res = domain.PayoutResponse{Status: domain.PayoutStatusFailed, Reason: "simulated failure"}
+	case OutcomePending:
+		res = domain.PayoutResponse{Status: domain.PayoutStatusSent}
+	default:
+		res = domain.PayoutResponse{Status: domain.PayoutStatusSucceeded}
+	}
+
+	s.payouts[req.Reference] = res
+	return res, nil
+}
+
+// Settle changes what the simulator reports for reference from now on.
+func (s *Simulator) Settle(reference string, res domain.PayoutResponse) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.payouts[reference] = res
+}
+
+func NewSimulator(outcome string) (*Simulator, error) {
+	switch outcome {
+	case OutcomeSucceed, OutcomeFail, OutcomePending:
+	default:
+		return nil, fmt.Errorf("unknown payout simulator outcome %q", outcome)
+	}
+
+	return &Simulator{
+		outcome: outcome,
+		payouts: map[string]domain.PayoutResponse{},
+	}, nil
+}
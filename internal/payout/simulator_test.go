@@ -0,0 +1,50 @@
+package payout
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/vcnt72/go-boilerplate/internal/domain"
+)
+
+func TestSimulator_Outcomes(t *testing.T) {
+	cases := map[string]domain.PayoutStatus{
+		OutcomeSucceed: domain.PayoutStatusSucceeded,
+		OutcomeFail:    domain.PayoutStatusFailed,
+		OutcomePending: domain.PayoutStatusSent,
+	}
+
+	for outcome, want := range cases {
+		sim, err := NewSimulator(outcome)
+		require.NoError(t, err)
+
+		res, err := sim.Send(context.Background(), domain.PayoutRequest{Reference: "ref-1", Amount: domain.NewMoney(1_000, "IDR")})
+		require.NoError(t, err)
+		require.Equal(t, want, res.Status, outcome)
+	}
+}
+
+func TestSimulator_RepeatedReference(t *testing.T) {
+	sim, err := NewSimulator(OutcomePending)
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	req := domain.PayoutRequest{Reference: "ref-1", Amount: domain.NewMoney(1_000, "IDR")}
+
+	res, err := sim.Send(ctx, req)
+	require.NoError(t, err)
+	require.Equal(t, domain.PayoutStatusSent, res.Status)
+
+	sim.Settle("ref-1", domain.PayoutResponse{Status: domain.PayoutStatusSucceeded})
+
+	res, err = sim.Send(ctx, req)
+	require.NoError(t, err)
+	require.Equal(t, domain.PayoutStatusSucceeded, res.Status)
+}
+
+func TestNewSimulator_UnknownOutcome(t *testing.T) {
+	_, err := NewSimulator("maybe")
+	require.Error(t, err)
+}
@@ -0,0 +1,67 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/vcnt72/go-boilerplate/internal/domain"
+)
+
+type accountRepository struct {
+	db *sqlx.DB
+}
+
+func (a accountRepository) CreateForWallet(ctx context.Context, walletID int64) (*domain.Account, error) {
+	account := domain.Account{
+		Code:     domain.WalletAccountCode(walletID),
+		Type:     domain.AccountTypeWallet,
+		WalletID: &walletID,
+	}
+
+	err := conn(ctx, a.db).QueryRowxContext(ctx, "INSERT INTO accounts(code, type, wallet_id) VALUES($1,$2,$3) RETURNING id", account.Code, account.Type, account.WalletID).
+		Scan(&account.ID)
+
+	return &account, err
+}
+
+func (a accountRepository) GetByWalletID(ctx context.Context, walletID int64) (*domain.Account, error) {
+	var account domain.Account
+
+	err := conn(ctx, a.db).QueryRowxContext(ctx, "SELECT id, code, type, wallet_id, created_at, updated_at FROM accounts WHERE wallet_id = $1", walletID).
+		StructScan(&account)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, domain.ErrAccountNotFound
+		}
+
+		return nil, err
+	}
+
+	return &account, nil
+}
+
+// GetOrCreateSystem returns the system account with the given code, creating
+// it on first use.
+func (a accountRepository) GetOrCreateSystem(ctx context.Context, code string) (*domain.Account, error) {
+	_, err := conn(ctx, a.db).ExecContext(ctx, "INSERT INTO accounts(code, type) VALUES($1,$2) ON CONFLICT (code) DO NOTHING", code, domain.AccountTypeSystem)
+	if err != nil {
+		return nil, err
+	}
+
+	var account domain.Account
+	err = conn(ctx, a.db).QueryRowxContext(ctx, "SELECT id, code, type, wallet_id, created_at, updated_at FROM accounts WHERE code = $1", code).
+		StructScan(&account)
+	if err != nil {
+		return nil, err
+	}
+
+	return &account, nil
+}
+
+func NewAccountRepository(db *sqlx.DB) AccountRepository {
+	return &accountRepository{
+		db: db,
+	}
+}
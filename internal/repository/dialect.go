@@ -0,0 +1,136 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// Statements are written for Postgres. On SQLite, sqliteConn rewrites what
+// only differs in spelling and the dialect functions below supply the clauses
+// SQLite has no counterpart for. RETURNING and ON CONFLICT ... DO NOTHING run
+// as written: SQLite has both since 3.35, and the driver bundles a newer one.
+
+// sqliteDriver is the database/sql driver name of SQLite.
+const sqliteDriver = "sqlite3"
+
+// sqliteTimeFormat is the strftime format of times computed by SQLite. It
+// yields the layout of sqliteTimeLayout, so that stored times compare as text
+// in time order.
+const sqliteTimeFormat = "'%Y-%m-%d %H:%M:%f000+00:00'"
+
+const sqliteNow = "strftime(" + sqliteTimeFormat + ", 'now')"
+
+// sqliteTimeLayout is how times are bound on SQLite: UTC, with a fixed number
+// of fraction digits.
+const sqliteTimeLayout = "2006-01-02 15:04:05.000000-07:00"
+
+var (
+	sqlitePlaceholder = regexp.MustCompile(`\$(\d+)`)
+	sqliteReplacer    = strings.NewReplacer("now()", sqliteNow, "::jsonb", "", "::text", "")
+)
+
+// sqliteConn runs statements written for Postgres on SQLite: $n placeholders
+// become ?n, now() becomes sqliteNow, ::jsonb and ::text casts are dropped,
+// and times are bound in sqliteTimeLayout.
+type sqliteConn struct {
+	sqlx.ExtContext
+}
+
+func (c sqliteConn) rewrite(query string, args []any) (string, []any) {
+	query = sqlitePlaceholder.ReplaceAllString(sqliteReplacer.Replace(query), "?$1")
+
+	bound := make([]any, len(args))
+	for i, arg := range args {
+		switch v := arg.(type) {
+		case time.Time:
+			bound[i] = v.UTC().Format(sqliteTimeLayout)
+		case *time.Time:
+			if v != nil {
+				bound[i] = v.UTC().Format(sqliteTimeLayout)
+			}
+		default:
+			bound[i] = arg
+		}
+	}
+
+	return query, bound
+}
+
+func (c sqliteConn) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	query, args = c.rewrite(query, args)
+	return c.ExtContext.ExecContext(ctx, query, args...)
+}
+
+func (c sqliteConn) QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+	query, args = c.rewrite(query, args)
+	return c.ExtContext.QueryContext(ctx, query, args...)
+}
+
+func (c sqliteConn) QueryxContext(ctx context.Context, query string, args ...any) (*sqlx.Rows, error) {
+	query, args = c.rewrite(query, args)
+	return c.ExtContext.QueryxContext(ctx, query, args...)
+}
+
+func (c sqliteConn) QueryRowxContext(ctx context.Context, query string, args ...any) *sqlx.Row {
+	query, args = c.rewrite(query, args)
+	return c.ExtContext.QueryRowxContext(ctx, query, args...)
+}
+
+// bind returns what a repository runs its statements on: db itself, or db
+// wrapped in a sqliteConn when it is a SQLite database or transaction.
+func bind(db sqlx.ExtContext) sqlx.ExtContext {
+	if db.DriverName() != sqliteDriver {
+		return db
+	}
+
+	return sqliteConn{db}
+}
+
+func isSQLite(db sqlx.ExtContext) bool {
+	return db.DriverName() == sqliteDriver
+}
+
+// rowLock is the locking clause of a SELECT, such as "FOR UPDATE". SQLite has
+// none and needs none: its transactions take the write lock when they begin,
+// see database.NewSQLite.
+func rowLock(db sqlx.ExtContext, clause string) string {
+	if isSQLite(db) {
+		return ""
+	}
+
+	return " " + clause
+}
+
+// nowPlusSeconds is the time the given number of seconds from now.
+func nowPlusSeconds(db sqlx.ExtContext, seconds string) string {
+	if isSQLite(db) {
+		return "strftime(" + sqliteTimeFormat + ", 'now', " + seconds + " || ' seconds')"
+	}
+
+	return "now() + make_interval(secs => " + seconds + ")"
+}
+
+// jsonColumn selects a JSON column so that it scans into a json.RawMessage,
+// which takes bytes but not a string.
+func jsonColumn(db sqlx.ExtContext, column string) string {
+	if isSQLite(db) {
+		return "CAST(" + column + " AS BLOB)"
+	}
+
+	return column + "::text"
+}
+
+// jsonArrayContains matches rows whose JSON array column holds the string
+// value.
+func jsonArrayContains(db sqlx.ExtContext, column, value string) string {
+	if isSQLite(db) {
+		return "EXISTS (SELECT 1 FROM json_each(" + column + ") WHERE json_each.value = " + value + ")"
+	}
+
+	return column + " @> jsonb_build_array(" + value + "::text)"
+}
@@ -0,0 +1,44 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/vcnt72/go-boilerplate/internal/domain"
+)
+
+type feeRepository struct {
+	db *sqlx.DB
+}
+
+// GetForUser returns the fee schedule that applies to the user's withdrawals
+// in currency: its own row if one exists, otherwise the row of its tier.
+func (f feeRepository) GetForUser(ctx context.Context, userID int64, currency string) (*domain.FeeSchedule, error) {
+	var schedule domain.FeeSchedule
+
+	err := conn(ctx, f.db).QueryRowxContext(ctx, `
+		SELECT f.id, f.user_id, f.tier, f.currency, f.flat, f.percent_bps, f.min_fee, f.max_fee, f.created_at, f.updated_at
+		FROM fee_schedules f
+		JOIN users u ON f.user_id = u.id OR f.tier = u.tier
+		WHERE u.id = $1 AND f.currency = $2
+		ORDER BY f.user_id IS NULL
+		LIMIT 1`, userID, currency).
+		StructScan(&schedule)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, domain.ErrFeeScheduleNotFound
+		}
+
+		return nil, err
+	}
+
+	return &schedule, nil
+}
+
+func NewFeeRepository(db *sqlx.DB) FeeRepository {
+	return &feeRepository{
+		db: db,
+	}
+}
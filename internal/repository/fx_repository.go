@@ -0,0 +1,138 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/vcnt72/go-boilerplate/internal/domain"
+)
+
+// fxRateRepository holds the admin-set FX rates.
+type fxRateRepository struct {
+	db *sqlx.DB
+}
+
+func (f fxRateRepository) GetRate(ctx context.Context, base, quote string) (*domain.FXRate, error) {
+	var rate domain.FXRate
+
+	err := conn(ctx, f.db).QueryRowxContext(ctx,
+		"SELECT base_currency, quote_currency, rate::text AS rate, fee_bps, updated_at FROM fx_rates WHERE base_currency = $1 AND quote_currency = $2",
+		base, quote).
+		StructScan(&rate)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, domain.ErrRateNotFound
+		}
+
+		return nil, err
+	}
+
+	rate.Rate = trimRate(rate.Rate)
+	return &rate, nil
+}
+
+// Upsert sets the rate of a currency pair, replacing any previous one.
+func (f fxRateRepository) Upsert(ctx context.Context, rate domain.FXRate) (*domain.FXRate, error) {
+	err := conn(ctx, f.db).QueryRowxContext(ctx, `
+		INSERT INTO fx_rates(base_currency, quote_currency, rate, fee_bps) VALUES($1,$2,$3,$4)
+		ON CONFLICT (base_currency, quote_currency) DO UPDATE SET rate = EXCLUDED.rate, fee_bps = EXCLUDED.fee_bps, updated_at = now()
+		RETURNING updated_at`,
+		rate.BaseCurrency, rate.QuoteCurrency, rate.Rate, rate.FeeBps).
+		Scan(&rate.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	return &rate, nil
+}
+
+func NewFXRateRepository(db *sqlx.DB) FXRateRepository {
+	return &fxRateRepository{
+		db: db,
+	}
+}
+
+type fxQuoteRepository struct {
+	db *sqlx.DB
+}
+
+const fxQuoteColumns = "id, user_id, from_currency, to_currency, from_amount, fee, to_amount, rate::text AS rate, status, expires_at, executed_at, created_at, updated_at"
+
+func (f fxQuoteRepository) Create(ctx context.Context, quote domain.FXQuote) (*domain.FXQuote, error) {
+	err := conn(ctx, f.db).QueryRowxContext(ctx,
+		"INSERT INTO fx_quotes(id, user_id, from_currency, to_currency, from_amount, fee, to_amount, rate, status, expires_at) VALUES($1,$2,$3,$4,$5,$6,$7,$8,$9,$10) RETURNING created_at, updated_at",
+		quote.ID, quote.UserID, quote.FromCurrency, quote.ToCurrency, quote.FromAmount, quote.Fee, quote.ToAmount, quote.Rate, quote.Status, quote.ExpiresAt).
+		Scan(&quote.CreatedAt, &quote.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	return &quote, nil
+}
+
+// LockByID returns the user's quote, locked until the surrounding
+// transaction ends. Another user's quote is reported as not found.
+func (f fxQuoteRepository) LockByID(ctx context.Context, userID int64, id string) (*domain.FXQuote, error) {
+	return f.get(ctx, "SELECT "+fxQuoteColumns+" FROM fx_quotes WHERE id = $1 AND user_id = $2"+rowLock(f.db, "FOR UPDATE"), id, userID)
+}
+
+func (f fxQuoteRepository) GetByID(ctx context.Context, userID int64, id string) (*domain.FXQuote, error) {
+	return f.get(ctx, "SELECT "+fxQuoteColumns+" FROM fx_quotes WHERE id = $1 AND user_id = $2", id, userID)
+}
+
+func (f fxQuoteRepository) get(ctx context.Context, query string, args ...any) (*domain.FXQuote, error) {
+	var quote domain.FXQuote
+
+	err := conn(ctx, f.db).QueryRowxContext(ctx, query, args...).StructScan(&quote)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, domain.ErrQuoteNotFound
+		}
+
+		return nil, err
+	}
+
+	quote.Rate = trimRate(quote.Rate)
+	quote.BindCurrency()
+	return &quote, nil
+}
+
+// MarkExecuted moves an open quote to EXECUTED.
+func (f fxQuoteRepository) MarkExecuted(ctx context.Context, id string) error {
+	res, err := conn(ctx, f.db).ExecContext(ctx,
+		"UPDATE fx_quotes SET status = $1, executed_at = now(), updated_at = now() WHERE id = $2 AND status = $3",
+		domain.FXQuoteStatusExecuted, id, domain.FXQuoteStatusOpen)
+	if err != nil {
+		return err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if n == 0 {
+		return domain.ErrQuoteNotOpen
+	}
+
+	return nil
+}
+
+func NewFXQuoteRepository(db *sqlx.DB) FXQuoteRepository {
+	return &fxQuoteRepository{
+		db: db,
+	}
+}
+
+// trimRate drops the trailing zeros numeric columns pad rates with, so that
+// "16000.000000000000" reads back as "16000".
+func trimRate(rate string) string {
+	if !strings.Contains(rate, ".") {
+		return rate
+	}
+
+	return strings.TrimSuffix(strings.TrimRight(rate, "0"), ".")
+}
@@ -0,0 +1,114 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/vcnt72/go-boilerplate/internal/domain"
+)
+
+type holdRepository struct {
+	db *sqlx.DB
+}
+
+const holdColumns = "id, wallet_id, currency, amount, captured_amount, status, expires_at, created_at, updated_at"
+
+func (h holdRepository) Create(ctx context.Context, hold domain.Hold) (*domain.Hold, error) {
+	err := conn(ctx, h.db).QueryRowxContext(ctx,
+		"INSERT INTO holds(wallet_id, currency, amount, status, expires_at) VALUES($1,$2,$3,$4,$5) RETURNING id, created_at, updated_at",
+		hold.WalletID, hold.Amount.Currency, hold.Amount, hold.Status, hold.ExpiresAt).
+		Scan(&hold.ID, &hold.CreatedAt, &hold.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	hold.Currency = hold.Amount.Currency
+	hold.CapturedAmount = domain.NewMoney(0, hold.Currency)
+	return &hold, nil
+}
+
+// GetByID looks a hold up without checking its owner; callers must compare
+// its wallet with the caller's.
+func (h holdRepository) GetByID(ctx context.Context, id int64) (*domain.Hold, error) {
+	var hold domain.Hold
+
+	err := conn(ctx, h.db).QueryRowxContext(ctx, "SELECT "+holdColumns+" FROM holds WHERE id = $1", id).
+		StructScan(&hold)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, domain.ErrHoldNotFound
+		}
+
+		return nil, err
+	}
+
+	hold.BindCurrency()
+	return &hold, nil
+}
+
+// GetByWalletID returns the hold only if it belongs to the wallet. Callers
+// changing it must hold the wallet lock.
+func (h holdRepository) GetByWalletID(ctx context.Context, walletID, id int64) (*domain.Hold, error) {
+	var hold domain.Hold
+
+	err := conn(ctx, h.db).QueryRowxContext(ctx, "SELECT "+holdColumns+" FROM holds WHERE id = $1 AND wallet_id = $2", id, walletID).
+		StructScan(&hold)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, domain.ErrHoldNotFound
+		}
+
+		return nil, err
+	}
+
+	hold.BindCurrency()
+	return &hold, nil
+}
+
+// ListExpired returns active holds that expired before now, oldest first.
+func (h holdRepository) ListExpired(ctx context.Context, now time.Time, limit int) ([]domain.Hold, error) {
+	holds := []domain.Hold{}
+
+	err := sqlx.SelectContext(ctx, conn(ctx, h.db), &holds,
+		"SELECT "+holdColumns+" FROM holds WHERE status = $1 AND expires_at <= $2 ORDER BY expires_at, id LIMIT $3",
+		domain.HoldStatusActive, now, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range holds {
+		holds[i].BindCurrency()
+	}
+
+	return holds, nil
+}
+
+// Settle moves an active hold to its final status.
+func (h holdRepository) Settle(ctx context.Context, id int64, status domain.HoldStatus, capturedAmount domain.Money) error {
+	res, err := conn(ctx, h.db).ExecContext(ctx,
+		"UPDATE holds SET status = $1, captured_amount = $2, updated_at = now() WHERE id = $3 AND status = $4",
+		status, capturedAmount, id, domain.HoldStatusActive)
+	if err != nil {
+		return err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if n == 0 {
+		return domain.ErrHoldNotActive
+	}
+
+	return nil
+}
+
+func NewHoldRepository(db *sqlx.DB) HoldRepository {
+	return &holdRepository{
+		db: db,
+	}
+}
@@ -0,0 +1,68 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/vcnt72/go-boilerplate/internal/domain"
+)
+
+type journalRepository struct {
+	db *sqlx.DB
+}
+
+// Create inserts the journal and its postings. The journal is validated up
+// front; the database re-checks that postings sum to zero when the
+// transaction commits.
+func (j journalRepository) Create(ctx context.Context, journal domain.Journal) (*domain.Journal, error) {
+	if err := journal.Validate(); err != nil {
+		return nil, err
+	}
+
+	err := conn(ctx, j.db).QueryRowxContext(ctx, "INSERT INTO journals(ledger_id, type) VALUES($1,$2) RETURNING id", journal.LedgerID, journal.Type).
+		Scan(&journal.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	postings := make([]domain.Posting, 0, len(journal.Postings))
+	for _, p := range journal.Postings {
+		p.JournalID = journal.ID
+		err := conn(ctx, j.db).QueryRowxContext(ctx, "INSERT INTO postings(journal_id, account_id, amount) VALUES($1,$2,$3) RETURNING id", p.JournalID, p.AccountID, p.Amount).
+			Scan(&p.ID)
+		if err != nil {
+			return nil, err
+		}
+
+		postings = append(postings, p)
+	}
+	journal.Postings = postings
+
+	return &journal, nil
+}
+
+type WalletPostingSum struct {
+	WalletID int64 `db:"wallet_id"`
+	Amount   int64 `db:"amount"`
+}
+
+// SumByWalletRange totals the postings of every wallet account with
+// fromID < wallet_id <= toID.
+func (j journalRepository) SumByWalletRange(ctx context.Context, fromID, toID int64) ([]WalletPostingSum, error) {
+	sums := []WalletPostingSum{}
+
+	err := sqlx.SelectContext(ctx, conn(ctx, j.db), &sums,
+		"SELECT a.wallet_id, SUM(p.amount) AS amount FROM postings p JOIN accounts a ON a.id = p.account_id WHERE a.wallet_id > $1 AND a.wallet_id <= $2 GROUP BY a.wallet_id",
+		fromID, toID)
+	if err != nil {
+		return nil, err
+	}
+
+	return sums, nil
+}
+
+func NewJournalRepository(db *sqlx.DB) JournalRepository {
+	return &journalRepository{
+		db: db,
+	}
+}
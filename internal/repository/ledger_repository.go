@@ -4,24 +4,36 @@ import (
 	"context"
 	"database/sql"
 	"errors"
+	"fmt"
+	"strings"
+	"time"
 
 	"github.com/jmoiron/sqlx"
 	"github.com/vcnt72/go-boilerplate/internal/domain"
 )
 
-type LedgerRepository struct {
-	db sqlx.ExtContext
+type ledgerRepository struct {
+	db *sqlx.DB
 }
 
-func (l LedgerRepository) Create(ctx context.Context, ledger domain.Ledger) (*domain.Ledger, error) {
+const ledgerColumns = "id, idempotency_key, wallet_id, type, status, currency, amount, result_balance, error_code, transfer_id, hold_id, payout_reference, parent_ledger_id, created_at, updated_at"
+
+// Create reports domain.ErrLedgerConflict when the insert hits a unique key:
+// ON CONFLICT DO NOTHING then returns no row, on Postgres and SQLite alike.
+func (l ledgerRepository) Create(ctx context.Context, ledger domain.Ledger) (*domain.Ledger, error) {
 	var id int64
 	var status string
-	err := l.db.QueryRowxContext(ctx, "INSERT INTO ledgers(idempotency_key, amount, type, status, wallet_id) VALUES($1,$2,$3,$4,$5) ON CONFLICT DO NOTHING RETURNING id, status",
+	err := conn(ctx, l.db).QueryRowxContext(ctx, "INSERT INTO ledgers(idempotency_key, currency, amount, type, status, wallet_id, transfer_id, hold_id, payout_reference, parent_ledger_id) VALUES($1,$2,$3,$4,$5,$6,$7,$8,$9,$10) ON CONFLICT DO NOTHING RETURNING id, status",
 		ledger.IdempotencyKey,
+		ledger.Amount.Currency,
 		ledger.Amount,
 		ledger.Type,
 		ledger.Status,
 		ledger.WalletID,
+		ledger.TransferID,
+		ledger.HoldID,
+		ledger.PayoutReference,
+		ledger.ParentLedgerID,
 	).Scan(&id, &status)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
@@ -32,26 +44,45 @@ func (l LedgerRepository) Create(ctx context.Context, ledger domain.Ledger) (*do
 	}
 
 	ledger.ID = id
+	ledger.Currency = ledger.Amount.Currency
 
 	return &ledger, nil
 }
 
-func (l LedgerRepository) Update(ctx context.Context, spec domain.Ledger) error {
-	_, err := l.db.ExecContext(ctx, "UPDATE ledgers SET status = $1, error_code = $2, result_balance = $3, updated_at = now() WHERE id = $4",
+func (l ledgerRepository) Update(ctx context.Context, spec domain.Ledger) error {
+	_, err := conn(ctx, l.db).ExecContext(ctx, "UPDATE ledgers SET status = $1, error_code = $2, result_balance = $3, hold_id = $4, updated_at = now() WHERE id = $5",
 
 		spec.Status,
 		spec.ErrorCode,
 		spec.ResultBalance,
+		spec.HoldID,
 		spec.ID,
 	)
 
 	return err
 }
 
-func (l LedgerRepository) GetByIdempotencyKey(ctx context.Context, idempotencyKey string) (*domain.Ledger, error) {
+func (l ledgerRepository) GetByIdempotencyKey(ctx context.Context, walletID int64, idempotencyKey string) (*domain.Ledger, error) {
+	var ledger domain.Ledger
+
+	err := conn(ctx, l.db).QueryRowxContext(ctx, "SELECT "+ledgerColumns+" FROM ledgers WHERE wallet_id = $1 AND idempotency_key = $2", walletID, idempotencyKey).
+		StructScan(&ledger)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, domain.ErrLedgerNotFound
+		}
+
+		return nil, err
+	}
+
+	ledger.BindCurrency()
+	return &ledger, nil
+}
+
+func (l ledgerRepository) GetByTransferID(ctx context.Context, transferID string, ledgerType domain.LedgerType) (*domain.Ledger, error) {
 	var ledger domain.Ledger
 
-	err := l.db.QueryRowxContext(ctx, "SELECT id, idempotency_key, wallet_id, type, status, amount, result_balance, error_code, created_at, updated_at FROM ledgers WHERE idempotency_key = $1", idempotencyKey).
+	err := conn(ctx, l.db).QueryRowxContext(ctx, "SELECT "+ledgerColumns+" FROM ledgers WHERE transfer_id = $1 AND type = $2", transferID, ledgerType).
 		StructScan(&ledger)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
@@ -61,17 +92,258 @@ func (l LedgerRepository) GetByIdempotencyKey(ctx context.Context, idempotencyKe
 		return nil, err
 	}
 
+	ledger.BindCurrency()
 	return &ledger, nil
 }
 
-func (l *LedgerRepository) WithTx(tx sqlx.ExtContext) *LedgerRepository {
-	return &LedgerRepository{
-		db: tx,
+// GetByParentID returns the ledger of ledgerType hanging off parentLedgerID,
+// such as the FEE charged on a withdrawal.
+func (l ledgerRepository) GetByParentID(ctx context.Context, parentLedgerID int64, ledgerType domain.LedgerType) (*domain.Ledger, error) {
+	var ledger domain.Ledger
+
+	err := conn(ctx, l.db).QueryRowxContext(ctx, "SELECT "+ledgerColumns+" FROM ledgers WHERE parent_ledger_id = $1 AND type = $2", parentLedgerID, ledgerType).
+		StructScan(&ledger)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, domain.ErrLedgerNotFound
+		}
+
+		return nil, err
 	}
+
+	ledger.BindCurrency()
+	return &ledger, nil
+}
+
+// GetByPayoutReference returns the withdrawal carrying the payout reference.
+func (l ledgerRepository) GetByPayoutReference(ctx context.Context, reference string) (*domain.Ledger, error) {
+	return l.getByPayoutReference(ctx, "SELECT "+ledgerColumns+" FROM ledgers WHERE payout_reference = $1 AND type = $2", reference)
+}
+
+// LockPayout is GetByPayoutReference taking a row lock.
+func (l ledgerRepository) LockPayout(ctx context.Context, reference string) (*domain.Ledger, error) {
+	return l.getByPayoutReference(ctx, "SELECT "+ledgerColumns+" FROM ledgers WHERE payout_reference = $1 AND type = $2"+rowLock(l.db, "FOR UPDATE"), reference)
+}
+
+func (l ledgerRepository) getByPayoutReference(ctx context.Context, query, reference string) (*domain.Ledger, error) {
+	var ledger domain.Ledger
+
+	err := conn(ctx, l.db).QueryRowxContext(ctx, query, reference, domain.LedgerTypeWithdraw).
+		StructScan(&ledger)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, domain.ErrLedgerNotFound
+		}
+
+		return nil, err
+	}
+
+	ledger.BindCurrency()
+	return &ledger, nil
+}
+
+// LedgerCursor points at the last ledger of a page in (created_at, id) order.
+type LedgerCursor struct {
+	CreatedAt time.Time
+	ID        int64
+}
+
+type LedgerFilter struct {
+	WalletID int64
+	Type     string
+	Status   string
+	From     *time.Time
+	To       *time.Time
+	After    *LedgerCursor
+	Limit    int
+}
+
+// List returns the wallet's ledgers newest first. When After is set only
+// ledgers strictly older than the cursor are returned.
+func (l ledgerRepository) List(ctx context.Context, filter LedgerFilter) ([]domain.Ledger, error) {
+	conds := []string{"wallet_id = $1"}
+	args := []any{filter.WalletID}
+
+	if filter.Type != "" {
+		args = append(args, filter.Type)
+		conds = append(conds, fmt.Sprintf("type = $%d", len(args)))
+	}
+
+	if filter.Status != "" {
+		args = append(args, filter.Status)
+		conds = append(conds, fmt.Sprintf("status = $%d", len(args)))
+	}
+
+	if filter.From != nil {
+		args = append(args, *filter.From)
+		conds = append(conds, fmt.Sprintf("created_at >= $%d", len(args)))
+	}
+
+	if filter.To != nil {
+		args = append(args, *filter.To)
+		conds = append(conds, fmt.Sprintf("created_at < $%d", len(args)))
+	}
+
+	if filter.After != nil {
+		args = append(args, filter.After.CreatedAt, filter.After.ID)
+		conds = append(conds, fmt.Sprintf("(created_at, id) < ($%d, $%d)", len(args)-1, len(args)))
+	}
+
+	args = append(args, filter.Limit)
+	query := fmt.Sprintf(
+		"SELECT "+ledgerColumns+" FROM ledgers WHERE %s ORDER BY created_at DESC, id DESC LIMIT $%d",
+		strings.Join(conds, " AND "),
+		len(args),
+	)
+
+	ledgers := []domain.Ledger{}
+	if err := sqlx.SelectContext(ctx, conn(ctx, l.db), &ledgers, query, args...); err != nil {
+		return nil, err
+	}
+
+	for i := range ledgers {
+		ledgers[i].BindCurrency()
+	}
+
+	return ledgers, nil
+}
+
+// ListStaleProcessing returns PROCESSING ledgers not touched since olderThan,
+// oldest first. Withdrawals handed to the payout provider are left out, see
+// ListStalePayouts.
+func (l ledgerRepository) ListStaleProcessing(ctx context.Context, olderThan time.Time, limit int) ([]domain.Ledger, error) {
+	ledgers := []domain.Ledger{}
+
+	err := sqlx.SelectContext(ctx, conn(ctx, l.db), &ledgers,
+		"SELECT "+ledgerColumns+" FROM ledgers WHERE status = $1 AND payout_reference IS NULL AND updated_at < $2 ORDER BY updated_at, id LIMIT $3",
+		domain.LedgerStatusProcessing, olderThan, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range ledgers {
+		ledgers[i].BindCurrency()
+	}
+
+	return ledgers, nil
+}
+
+// ListStalePayouts returns withdrawals still pending at the payout provider,
+// PROCESSING or SENT, and not touched since olderThan, oldest first.
+func (l ledgerRepository) ListStalePayouts(ctx context.Context, olderThan time.Time, limit int) ([]domain.Ledger, error) {
+	ledgers := []domain.Ledger{}
+
+	err := sqlx.SelectContext(ctx, conn(ctx, l.db), &ledgers,
+		"SELECT "+ledgerColumns+" FROM ledgers WHERE status IN ($1, $2) AND type = $3 AND payout_reference IS NOT NULL AND updated_at < $4 ORDER BY updated_at, id LIMIT $5",
+		domain.LedgerStatusProcessing, domain.LedgerStatusSent, domain.LedgerTypeWithdraw, olderThan, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range ledgers {
+		ledgers[i].BindCurrency()
+	}
+
+	return ledgers, nil
+}
+
+// TouchPayout stamps the pending ledgers of a payout as updated now, which
+// moves the payout to the back of ListStalePayouts.
+func (l ledgerRepository) TouchPayout(ctx context.Context, reference string) error {
+	_, err := conn(ctx, l.db).ExecContext(ctx, "UPDATE ledgers SET updated_at = now() WHERE payout_reference = $1 AND status IN ($2, $3)",
+		reference, domain.LedgerStatusProcessing, domain.LedgerStatusSent)
+
+	return err
+}
+
+// FailProcessing moves a ledger from PROCESSING to FAILED. It reports false
+// when the ledger had already left PROCESSING, so concurrent resolvers never
+// overwrite each other.
+func (l ledgerRepository) FailProcessing(ctx context.Context, id int64, errorCode string) (bool, error) {
+	res, err := conn(ctx, l.db).ExecContext(ctx, "UPDATE ledgers SET status = $1, error_code = $2, updated_at = now() WHERE id = $3 AND status = $4",
+		domain.LedgerStatusFailed, errorCode, id, domain.LedgerStatusProcessing)
+	if err != nil {
+		return false, err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return n == 1, nil
+}
+
+// bookedCondition matches ledgers whose amount is reflected in the wallet
+// balance: succeeded ones and payouts still in flight, whose funds were
+// debited when the withdrawal was accepted.
+const bookedCondition = "(status = 'SUCCEED' OR (status IN ('PROCESSING', 'SENT') AND payout_reference IS NOT NULL))"
+
+// SumWithdrawnSince totals the wallet's succeeded and in-flight withdrawals
+// created at or after since.
+func (l ledgerRepository) SumWithdrawnSince(ctx context.Context, walletID int64, since time.Time) (domain.WithdrawalUsage, error) {
+	var usage domain.WithdrawalUsage
+
+	err := conn(ctx, l.db).QueryRowxContext(ctx,
+		"SELECT COALESCE(SUM(amount), 0) AS amount, COUNT(1) AS count FROM ledgers WHERE wallet_id = $1 AND type = $2 AND "+bookedCondition+" AND created_at >= $3",
+		walletID, domain.LedgerTypeWithdraw, since).
+		StructScan(&usage)
+
+	return usage, err
+}
+
+// SumReversed totals the succeeded reversals of the given ledger.
+func (l ledgerRepository) SumReversed(ctx context.Context, parentLedgerID int64) (int64, error) {
+	var amount int64
+
+	err := conn(ctx, l.db).QueryRowxContext(ctx,
+		"SELECT COALESCE(SUM(amount), 0) FROM ledgers WHERE parent_ledger_id = $1 AND type = $2 AND status = $3",
+		parentLedgerID, domain.LedgerTypeReversal, domain.LedgerStatusSucceed).
+		Scan(&amount)
+
+	return amount, err
+}
+
+type LedgerTypeSum struct {
+	WalletID int64             `db:"wallet_id"`
+	Type     domain.LedgerType `db:"type"`
+	Amount   int64             `db:"amount"`
+}
+
+// SumBookedByWalletRange totals booked ledger amounts per wallet and type for
+// wallets with fromID < wallet_id <= toID. In-flight payouts count as booked.
+func (l ledgerRepository) SumBookedByWalletRange(ctx context.Context, fromID, toID int64) ([]LedgerTypeSum, error) {
+	sums := []LedgerTypeSum{}
+
+	err := sqlx.SelectContext(ctx, conn(ctx, l.db), &sums,
+		"SELECT wallet_id, type, SUM(amount) AS amount FROM ledgers WHERE wallet_id > $1 AND wallet_id <= $2 AND "+bookedCondition+" GROUP BY wallet_id, type",
+		fromID, toID)
+	if err != nil {
+		return nil, err
+	}
+
+	return sums, nil
+}
+
+// ListByPayoutReference returns the withdrawal and fee ledgers of a payout.
+func (l ledgerRepository) ListByPayoutReference(ctx context.Context, reference string) ([]domain.Ledger, error) {
+	ledgers := []domain.Ledger{}
+
+	err := sqlx.SelectContext(ctx, conn(ctx, l.db), &ledgers,
+		"SELECT "+ledgerColumns+" FROM ledgers WHERE payout_reference = $1 ORDER BY id", reference)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range ledgers {
+		ledgers[i].BindCurrency()
+	}
+
+	return ledgers, nil
 }
 
-func NewLedgerRepository(db sqlx.ExtContext) *LedgerRepository {
-	return &LedgerRepository{
+func NewLedgerRepository(db *sqlx.DB) LedgerRepository {
+	return &ledgerRepository{
 		db: db,
 	}
 }
@@ -0,0 +1,44 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/vcnt72/go-boilerplate/internal/domain"
+)
+
+type limitRepository struct {
+	db *sqlx.DB
+}
+
+// GetForUser returns the limit row that applies to the user's withdrawals in
+// currency: its own row if one exists, otherwise the row of its tier.
+func (l limitRepository) GetForUser(ctx context.Context, userID int64, currency string) (*domain.WithdrawalLimit, error) {
+	var limit domain.WithdrawalLimit
+
+	err := conn(ctx, l.db).QueryRowxContext(ctx, `
+		SELECT l.id, l.user_id, l.tier, l.currency, l.max_per_transaction, l.max_daily_amount, l.max_daily_count, l.created_at, l.updated_at
+		FROM withdrawal_limits l
+		JOIN users u ON l.user_id = u.id OR l.tier = u.tier
+		WHERE u.id = $1 AND l.currency = $2
+		ORDER BY l.user_id IS NULL
+		LIMIT 1`, userID, currency).
+		StructScan(&limit)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, domain.ErrLimitNotFound
+		}
+
+		return nil, err
+	}
+
+	return &limit, nil
+}
+
+func NewLimitRepository(db *sqlx.DB) LimitRepository {
+	return &limitRepository{
+		db: db,
+	}
+}
@@ -0,0 +1,83 @@
+package memory
+
+import (
+	"context"
+	"slices"
+
+	"github.com/vcnt72/go-boilerplate/internal/domain"
+)
+
+type accountRepository struct {
+	c conn
+}
+
+func (a accountRepository) CreateForWallet(ctx context.Context, walletID int64) (*domain.Account, error) {
+	account := domain.Account{
+		Code:     domain.WalletAccountCode(walletID),
+		Type:     domain.AccountTypeWallet,
+		WalletID: &walletID,
+	}
+
+	err := a.c.do(ctx, func(t *tables) error {
+		if t.wallet(walletID) < 0 {
+			return ErrForeignKeyViolation
+		}
+
+		if t.accountByCode(account.Code) >= 0 || slices.ContainsFunc(t.accounts, func(o domain.Account) bool { return o.WalletID != nil && *o.WalletID == walletID }) {
+			return ErrUniqueViolation
+		}
+
+		a.insert(t, &account)
+		return nil
+	})
+
+	return &account, err
+}
+
+func (a accountRepository) GetByWalletID(ctx context.Context, walletID int64) (*domain.Account, error) {
+	var account domain.Account
+	err := a.c.do(ctx, func(t *tables) error {
+		i := slices.IndexFunc(t.accounts, func(o domain.Account) bool { return o.WalletID != nil && *o.WalletID == walletID })
+		if i < 0 {
+			return domain.ErrAccountNotFound
+		}
+
+		account = t.accounts[i]
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &account, nil
+}
+
+func (a accountRepository) GetOrCreateSystem(ctx context.Context, code string) (*domain.Account, error) {
+	var account domain.Account
+	err := a.c.do(ctx, func(t *tables) error {
+		if i := t.accountByCode(code); i >= 0 {
+			account = t.accounts[i]
+			return nil
+		}
+
+		account = domain.Account{Code: code, Type: domain.AccountTypeSystem}
+		a.insert(t, &account)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &account, nil
+}
+
+func (a accountRepository) insert(t *tables, account *domain.Account) {
+	account.ID = next(&a.c.store.seq.accounts)
+	account.CreatedAt = now()
+	account.UpdatedAt = account.CreatedAt
+	t.accounts = append(t.accounts, *account)
+}
+
+func (t *tables) accountByCode(code string) int {
+	return slices.IndexFunc(t.accounts, func(a domain.Account) bool { return a.Code == code })
+}
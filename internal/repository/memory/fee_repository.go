@@ -0,0 +1,39 @@
+package memory
+
+import (
+	"context"
+	"slices"
+
+	"github.com/vcnt72/go-boilerplate/internal/domain"
+)
+
+type feeRepository struct {
+	c conn
+}
+
+// GetForUser returns the user's own fee schedule in currency if one exists,
+// otherwise the schedule of its tier. Schedules are set with
+// Store.SetFeeSchedule.
+func (f feeRepository) GetForUser(ctx context.Context, userID int64, currency string) (*domain.FeeSchedule, error) {
+	var schedule domain.FeeSchedule
+	err := f.c.do(ctx, func(t *tables) error {
+		user, ok := t.user(userID)
+		if !ok {
+			return domain.ErrFeeScheduleNotFound
+		}
+
+		fees := slices.DeleteFunc(slices.Clone(t.fees), func(o domain.FeeSchedule) bool { return o.Currency != currency })
+		i := scopeIndex(fees, user, func(o domain.FeeSchedule) (*int64, *string) { return o.UserID, o.Tier })
+		if i < 0 {
+			return domain.ErrFeeScheduleNotFound
+		}
+
+		schedule = fees[i]
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &schedule, nil
+}
@@ -0,0 +1,117 @@
+package memory
+
+import (
+	"context"
+
+	"github.com/vcnt72/go-boilerplate/internal/domain"
+)
+
+type fxRateRepository struct {
+	c conn
+}
+
+func rateKey(base, quote string) string {
+	return base + "/" + quote
+}
+
+func (f fxRateRepository) GetRate(ctx context.Context, base, quote string) (*domain.FXRate, error) {
+	var rate domain.FXRate
+	err := f.c.do(ctx, func(t *tables) error {
+		r, ok := t.rates[rateKey(base, quote)]
+		if !ok {
+			return domain.ErrRateNotFound
+		}
+
+		rate = r
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &rate, nil
+}
+
+func (f fxRateRepository) Upsert(ctx context.Context, rate domain.FXRate) (*domain.FXRate, error) {
+	err := f.c.do(ctx, func(t *tables) error {
+		rate.UpdatedAt = now()
+
+		stored := rate
+		stored.Rate = trimRate(rate.Rate)
+		t.rates[rateKey(rate.BaseCurrency, rate.QuoteCurrency)] = stored
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &rate, nil
+}
+
+type fxQuoteRepository struct {
+	c conn
+}
+
+func (f fxQuoteRepository) Create(ctx context.Context, quote domain.FXQuote) (*domain.FXQuote, error) {
+	err := f.c.do(ctx, func(t *tables) error {
+		if _, ok := t.user(quote.UserID); !ok {
+			return ErrForeignKeyViolation
+		}
+
+		if _, ok := t.quotes[quote.ID]; ok {
+			return ErrUniqueViolation
+		}
+
+		quote.CreatedAt = now()
+		quote.UpdatedAt = quote.CreatedAt
+
+		stored := quote
+		stored.Rate = trimRate(quote.Rate)
+		t.quotes[quote.ID] = stored
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &quote, nil
+}
+
+func (f fxQuoteRepository) LockByID(ctx context.Context, userID int64, id string) (*domain.FXQuote, error) {
+	return f.GetByID(ctx, userID, id)
+}
+
+func (f fxQuoteRepository) GetByID(ctx context.Context, userID int64, id string) (*domain.FXQuote, error) {
+	var quote domain.FXQuote
+	err := f.c.do(ctx, func(t *tables) error {
+		q, ok := t.quotes[id]
+		if !ok || q.UserID != userID {
+			return domain.ErrQuoteNotFound
+		}
+
+		quote = q
+		quote.BindCurrency()
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &quote, nil
+}
+
+func (f fxQuoteRepository) MarkExecuted(ctx context.Context, id string) error {
+	return f.c.do(ctx, func(t *tables) error {
+		q, ok := t.quotes[id]
+		if !ok || q.Status != domain.FXQuoteStatusOpen {
+			return domain.ErrQuoteNotOpen
+		}
+
+		ts := now()
+		q.Status = domain.FXQuoteStatusExecuted
+		q.ExecutedAt = &ts
+		q.UpdatedAt = ts
+		t.quotes[id] = q
+		return nil
+	})
+}
@@ -0,0 +1,109 @@
+package memory
+
+import (
+	"cmp"
+	"context"
+	"slices"
+	"time"
+
+	"github.com/vcnt72/go-boilerplate/internal/domain"
+)
+
+type holdRepository struct {
+	c conn
+}
+
+func (h holdRepository) Create(ctx context.Context, hold domain.Hold) (*domain.Hold, error) {
+	err := h.c.do(ctx, func(t *tables) error {
+		if t.wallet(hold.WalletID) < 0 {
+			return ErrForeignKeyViolation
+		}
+
+		if !hold.Amount.IsPositive() {
+			return ErrCheckViolation
+		}
+
+		hold.ID = next(&h.c.store.seq.holds)
+		hold.Currency = hold.Amount.Currency
+		hold.CapturedAmount = domain.NewMoney(0, hold.Currency)
+		hold.CreatedAt = now()
+		hold.UpdatedAt = hold.CreatedAt
+		t.holds = append(t.holds, hold)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &hold, nil
+}
+
+func (h holdRepository) GetByID(ctx context.Context, id int64) (*domain.Hold, error) {
+	return h.get(ctx, func(o domain.Hold) bool { return o.ID == id })
+}
+
+func (h holdRepository) GetByWalletID(ctx context.Context, walletID, id int64) (*domain.Hold, error) {
+	return h.get(ctx, func(o domain.Hold) bool { return o.ID == id && o.WalletID == walletID })
+}
+
+func (h holdRepository) get(ctx context.Context, match func(domain.Hold) bool) (*domain.Hold, error) {
+	var hold domain.Hold
+	err := h.c.do(ctx, func(t *tables) error {
+		i := slices.IndexFunc(t.holds, match)
+		if i < 0 {
+			return domain.ErrHoldNotFound
+		}
+
+		hold = t.holds[i]
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &hold, nil
+}
+
+func (h holdRepository) ListExpired(ctx context.Context, now time.Time, limit int) ([]domain.Hold, error) {
+	holds := []domain.Hold{}
+	err := h.c.do(ctx, func(t *tables) error {
+		for _, o := range t.holds {
+			if o.Status == domain.HoldStatusActive && !o.ExpiresAt.After(now) {
+				holds = append(holds, o)
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	slices.SortStableFunc(holds, func(a, b domain.Hold) int {
+		if c := a.ExpiresAt.Compare(b.ExpiresAt); c != 0 {
+			return c
+		}
+
+		return cmp.Compare(a.ID, b.ID)
+	})
+
+	return limitRows(holds, limit), nil
+}
+
+func (h holdRepository) Settle(ctx context.Context, id int64, status domain.HoldStatus, capturedAmount domain.Money) error {
+	return h.c.do(ctx, func(t *tables) error {
+		i := slices.IndexFunc(t.holds, func(o domain.Hold) bool { return o.ID == id })
+		if i < 0 || t.holds[i].Status != domain.HoldStatusActive {
+			return domain.ErrHoldNotActive
+		}
+
+		if capturedAmount.Amount < 0 || capturedAmount.Amount > t.holds[i].Amount.Amount {
+			return ErrCheckViolation
+		}
+
+		t.holds[i].Status = status
+		t.holds[i].CapturedAmount.Amount = capturedAmount.Amount
+		t.holds[i].UpdatedAt = now()
+		return nil
+	})
+}
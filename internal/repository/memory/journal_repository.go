@@ -0,0 +1,85 @@
+package memory
+
+import (
+	"context"
+	"slices"
+
+	"github.com/vcnt72/go-boilerplate/internal/domain"
+	"github.com/vcnt72/go-boilerplate/internal/repository"
+)
+
+type journalRepository struct {
+	c conn
+}
+
+func (j journalRepository) Create(ctx context.Context, journal domain.Journal) (*domain.Journal, error) {
+	if err := journal.Validate(); err != nil {
+		return nil, err
+	}
+
+	err := j.c.do(ctx, func(t *tables) error {
+		if journal.LedgerID != nil && t.ledger(*journal.LedgerID) < 0 {
+			return ErrForeignKeyViolation
+		}
+
+		for _, p := range journal.Postings {
+			if !slices.ContainsFunc(t.accounts, func(a domain.Account) bool { return a.ID == p.AccountID }) {
+				return ErrForeignKeyViolation
+			}
+		}
+
+		journal.ID = next(&j.c.store.seq.journals)
+		journal.CreatedAt = now()
+
+		postings := make([]domain.Posting, 0, len(journal.Postings))
+		for _, p := range journal.Postings {
+			p.ID = next(&j.c.store.seq.postings)
+			p.JournalID = journal.ID
+			p.CreatedAt = journal.CreatedAt
+			postings = append(postings, p)
+		}
+		journal.Postings = postings
+
+		t.journals = append(t.journals, journal)
+		t.postings = append(t.postings, postings...)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &journal, nil
+}
+
+func (j journalRepository) SumByWalletRange(ctx context.Context, fromID, toID int64) ([]repository.WalletPostingSum, error) {
+	sums := []repository.WalletPostingSum{}
+	err := j.c.do(ctx, func(t *tables) error {
+		wallets := map[int64]int64{}
+		for _, a := range t.accounts {
+			if a.WalletID != nil && *a.WalletID > fromID && *a.WalletID <= toID {
+				wallets[a.ID] = *a.WalletID
+			}
+		}
+
+		for _, p := range t.postings {
+			walletID, ok := wallets[p.AccountID]
+			if !ok {
+				continue
+			}
+
+			i := slices.IndexFunc(sums, func(s repository.WalletPostingSum) bool { return s.WalletID == walletID })
+			if i < 0 {
+				sums = append(sums, repository.WalletPostingSum{WalletID: walletID})
+				i = len(sums) - 1
+			}
+			sums[i].Amount += p.Amount
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return sums, nil
+}
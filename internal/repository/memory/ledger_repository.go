@@ -0,0 +1,292 @@
+package memory
+
+import (
+	"cmp"
+	"context"
+	"slices"
+	"time"
+
+	"github.com/vcnt72/go-boilerplate/internal/domain"
+	"github.com/vcnt72/go-boilerplate/internal/repository"
+)
+
+type ledgerRepository struct {
+	c conn
+}
+
+// Create reports domain.ErrLedgerConflict on the unique keys of the ledgers
+// table: the idempotency key per wallet and the payout reference of a
+// withdrawal.
+func (l ledgerRepository) Create(ctx context.Context, ledger domain.Ledger) (*domain.Ledger, error) {
+	err := l.c.do(ctx, func(t *tables) error {
+		if t.wallet(ledger.WalletID) < 0 {
+			return ErrForeignKeyViolation
+		}
+
+		if ledger.Amount.Amount < 0 {
+			return ErrCheckViolation
+		}
+
+		conflict := slices.ContainsFunc(t.ledgers, func(o domain.Ledger) bool {
+			if o.WalletID == ledger.WalletID && o.IdempotencyKey == ledger.IdempotencyKey {
+				return true
+			}
+
+			return ledger.Type == domain.LedgerTypeWithdraw && o.Type == domain.LedgerTypeWithdraw &&
+				ledger.PayoutReference != nil && o.PayoutReference != nil && *ledger.PayoutReference == *o.PayoutReference
+		})
+		if conflict {
+			return domain.ErrLedgerConflict
+		}
+
+		ledger.ID = next(&l.c.store.seq.ledgers)
+		ledger.Currency = ledger.Amount.Currency
+		ledger.CreatedAt = now()
+		ledger.UpdatedAt = ledger.CreatedAt
+		t.ledgers = append(t.ledgers, ledger)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &ledger, nil
+}
+
+func (l ledgerRepository) Update(ctx context.Context, spec domain.Ledger) error {
+	return l.c.do(ctx, func(t *tables) error {
+		i := t.ledger(spec.ID)
+		if i < 0 {
+			return nil
+		}
+
+		t.ledgers[i].Status = spec.Status
+		t.ledgers[i].ErrorCode = spec.ErrorCode
+		t.ledgers[i].ResultBalance = spec.ResultBalance
+		t.ledgers[i].HoldID = spec.HoldID
+		t.ledgers[i].UpdatedAt = now()
+		return nil
+	})
+}
+
+func (l ledgerRepository) GetByIdempotencyKey(ctx context.Context, walletID int64, idempotencyKey string) (*domain.Ledger, error) {
+	return l.get(ctx, func(o domain.Ledger) bool { return o.WalletID == walletID && o.IdempotencyKey == idempotencyKey })
+}
+
+func (l ledgerRepository) GetByTransferID(ctx context.Context, transferID string, ledgerType domain.LedgerType) (*domain.Ledger, error) {
+	return l.get(ctx, func(o domain.Ledger) bool {
+		return o.TransferID != nil && *o.TransferID == transferID && o.Type == ledgerType
+	})
+}
+
+func (l ledgerRepository) GetByParentID(ctx context.Context, parentLedgerID int64, ledgerType domain.LedgerType) (*domain.Ledger, error) {
+	return l.get(ctx, func(o domain.Ledger) bool {
+		return o.ParentLedgerID != nil && *o.ParentLedgerID == parentLedgerID && o.Type == ledgerType
+	})
+}
+
+func (l ledgerRepository) GetByPayoutReference(ctx context.Context, reference string) (*domain.Ledger, error) {
+	return l.get(ctx, func(o domain.Ledger) bool {
+		return o.PayoutReference != nil && *o.PayoutReference == reference && o.Type == domain.LedgerTypeWithdraw
+	})
+}
+
+func (l ledgerRepository) LockPayout(ctx context.Context, reference string) (*domain.Ledger, error) {
+	return l.GetByPayoutReference(ctx, reference)
+}
+
+func (l ledgerRepository) get(ctx context.Context, match func(domain.Ledger) bool) (*domain.Ledger, error) {
+	var ledger domain.Ledger
+	err := l.c.do(ctx, func(t *tables) error {
+		i := slices.IndexFunc(t.ledgers, match)
+		if i < 0 {
+			return domain.ErrLedgerNotFound
+		}
+
+		ledger = t.ledgers[i]
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &ledger, nil
+}
+
+// list returns the matching ledgers sorted by compare, or in ID order when it is
+// nil.
+func (l ledgerRepository) list(ctx context.Context, match func(domain.Ledger) bool, compare func(a, b domain.Ledger) int, limit int) ([]domain.Ledger, error) {
+	ledgers := []domain.Ledger{}
+	err := l.c.do(ctx, func(t *tables) error {
+		for _, o := range t.ledgers {
+			if match(o) {
+				ledgers = append(ledgers, o)
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if compare != nil {
+		slices.SortStableFunc(ledgers, compare)
+	}
+
+	return limitRows(ledgers, limit), nil
+}
+
+func (l ledgerRepository) List(ctx context.Context, filter repository.LedgerFilter) ([]domain.Ledger, error) {
+	match := func(o domain.Ledger) bool {
+		switch {
+		case o.WalletID != filter.WalletID,
+			filter.Type != "" && o.Type != filter.Type,
+			filter.Status != "" && o.Status != filter.Status,
+			filter.From != nil && o.CreatedAt.Before(*filter.From),
+			filter.To != nil && !o.CreatedAt.Before(*filter.To):
+			return false
+		case filter.After != nil:
+			return compareCreated(o, filter.After.CreatedAt, filter.After.ID) < 0
+		default:
+			return true
+		}
+	}
+
+	return l.list(ctx, match, func(a, b domain.Ledger) int { return -compareCreated(a, b.CreatedAt, b.ID) }, filter.Limit)
+}
+
+// compareCreated orders l against (createdAt, id).
+func compareCreated(l domain.Ledger, createdAt time.Time, id int64) int {
+	if c := l.CreatedAt.Compare(createdAt); c != 0 {
+		return c
+	}
+
+	return cmp.Compare(l.ID, id)
+}
+
+func byUpdated(a, b domain.Ledger) int {
+	if c := a.UpdatedAt.Compare(b.UpdatedAt); c != 0 {
+		return c
+	}
+
+	return cmp.Compare(a.ID, b.ID)
+}
+
+func (l ledgerRepository) ListStaleProcessing(ctx context.Context, olderThan time.Time, limit int) ([]domain.Ledger, error) {
+	return l.list(ctx, func(o domain.Ledger) bool {
+		return o.Status == domain.LedgerStatusProcessing && o.PayoutReference == nil && o.UpdatedAt.Before(olderThan)
+	}, byUpdated, limit)
+}
+
+func (l ledgerRepository) ListStalePayouts(ctx context.Context, olderThan time.Time, limit int) ([]domain.Ledger, error) {
+	return l.list(ctx, func(o domain.Ledger) bool {
+		return (o.Status == domain.LedgerStatusProcessing || o.Status == domain.LedgerStatusSent) &&
+			o.Type == domain.LedgerTypeWithdraw && o.PayoutReference != nil && o.UpdatedAt.Before(olderThan)
+	}, byUpdated, limit)
+}
+
+func (l ledgerRepository) TouchPayout(ctx context.Context, reference string) error {
+	return l.c.do(ctx, func(t *tables) error {
+		ts := now()
+		for i, o := range t.ledgers {
+			if o.PayoutReference != nil && *o.PayoutReference == reference &&
+				(o.Status == domain.LedgerStatusProcessing || o.Status == domain.LedgerStatusSent) {
+				t.ledgers[i].UpdatedAt = ts
+			}
+		}
+
+		return nil
+	})
+}
+
+func (l ledgerRepository) FailProcessing(ctx context.Context, id int64, errorCode string) (bool, error) {
+	var failed bool
+	err := l.c.do(ctx, func(t *tables) error {
+		i := t.ledger(id)
+		if i < 0 || t.ledgers[i].Status != domain.LedgerStatusProcessing {
+			return nil
+		}
+
+		t.ledgers[i].Status = domain.LedgerStatusFailed
+		t.ledgers[i].ErrorCode = &errorCode
+		t.ledgers[i].UpdatedAt = now()
+		failed = true
+		return nil
+	})
+
+	return failed, err
+}
+
+// booked reports whether the ledger's amount is reflected in the wallet
+// balance, as the bookedCondition of the SQL repository.
+func booked(l domain.Ledger) bool {
+	return l.Status == domain.LedgerStatusSucceed ||
+		((l.Status == domain.LedgerStatusProcessing || l.Status == domain.LedgerStatusSent) && l.PayoutReference != nil)
+}
+
+func (l ledgerRepository) SumWithdrawnSince(ctx context.Context, walletID int64, since time.Time) (domain.WithdrawalUsage, error) {
+	var usage domain.WithdrawalUsage
+	err := l.c.do(ctx, func(t *tables) error {
+		for _, o := range t.ledgers {
+			if o.WalletID == walletID && o.Type == domain.LedgerTypeWithdraw && booked(o) && !o.CreatedAt.Before(since) {
+				usage.Amount += o.Amount.Amount
+				usage.Count++
+			}
+		}
+
+		return nil
+	})
+
+	return usage, err
+}
+
+func (l ledgerRepository) SumReversed(ctx context.Context, parentLedgerID int64) (int64, error) {
+	var amount int64
+	err := l.c.do(ctx, func(t *tables) error {
+		for _, o := range t.ledgers {
+			if o.ParentLedgerID != nil && *o.ParentLedgerID == parentLedgerID && o.Type == domain.LedgerTypeReversal && o.Status == domain.LedgerStatusSucceed {
+				amount += o.Amount.Amount
+			}
+		}
+
+		return nil
+	})
+
+	return amount, err
+}
+
+func (l ledgerRepository) SumBookedByWalletRange(ctx context.Context, fromID, toID int64) ([]repository.LedgerTypeSum, error) {
+	sums := []repository.LedgerTypeSum{}
+	err := l.c.do(ctx, func(t *tables) error {
+		for _, o := range t.ledgers {
+			if o.WalletID <= fromID || o.WalletID > toID || !booked(o) {
+				continue
+			}
+
+			i := slices.IndexFunc(sums, func(s repository.LedgerTypeSum) bool { return s.WalletID == o.WalletID && s.Type == o.Type })
+			if i < 0 {
+				sums = append(sums, repository.LedgerTypeSum{WalletID: o.WalletID, Type: o.Type})
+				i = len(sums) - 1
+			}
+			sums[i].Amount += o.Amount.Amount
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return sums, nil
+}
+
+func (l ledgerRepository) ListByPayoutReference(ctx context.Context, reference string) ([]domain.Ledger, error) {
+	return l.list(ctx, func(o domain.Ledger) bool {
+		return o.PayoutReference != nil && *o.PayoutReference == reference
+	}, nil, -1)
+}
+
+func (t *tables) ledger(id int64) int {
+	return slices.IndexFunc(t.ledgers, func(l domain.Ledger) bool { return l.ID == id })
+}
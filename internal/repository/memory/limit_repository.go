@@ -0,0 +1,55 @@
+package memory
+
+import (
+	"context"
+	"slices"
+
+	"github.com/vcnt72/go-boilerplate/internal/domain"
+)
+
+type limitRepository struct {
+	c conn
+}
+
+// GetForUser returns the user's own limit row in currency if one exists,
+// otherwise the row of its tier. Rows are set with Store.SetWithdrawalLimit.
+func (l limitRepository) GetForUser(ctx context.Context, userID int64, currency string) (*domain.WithdrawalLimit, error) {
+	var limit domain.WithdrawalLimit
+	err := l.c.do(ctx, func(t *tables) error {
+		user, ok := t.user(userID)
+		if !ok {
+			return domain.ErrLimitNotFound
+		}
+
+		limits := slices.DeleteFunc(slices.Clone(t.limits), func(o domain.WithdrawalLimit) bool { return o.Currency != currency })
+		i := scopeIndex(limits, user, func(o domain.WithdrawalLimit) (*int64, *string) { return o.UserID, o.Tier })
+		if i < 0 {
+			return domain.ErrLimitNotFound
+		}
+
+		limit = limits[i]
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &limit, nil
+}
+
+// scopeIndex finds the row set for user, falling back to the row of its tier.
+func scopeIndex[T any](rows []T, user domain.User, scope func(T) (*int64, *string)) int {
+	tier := -1
+	for i, r := range rows {
+		userID, rowTier := scope(r)
+		if userID != nil && *userID == user.ID {
+			return i
+		}
+
+		if tier < 0 && rowTier != nil && *rowTier == user.Tier {
+			tier = i
+		}
+	}
+
+	return tier
+}
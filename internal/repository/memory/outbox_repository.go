@@ -0,0 +1,99 @@
+package memory
+
+import (
+	"context"
+	"slices"
+	"time"
+
+	"github.com/vcnt72/go-boilerplate/internal/domain"
+)
+
+type outboxRepository struct {
+	c conn
+}
+
+func (o outboxRepository) Create(ctx context.Context, event domain.OutboxEvent) (*domain.OutboxEvent, error) {
+	err := o.c.do(ctx, func(t *tables) error {
+		event.ID = next(&o.c.store.seq.outbox)
+		event.Payload = slices.Clone(event.Payload)
+		event.CreatedAt = now()
+		event.NextAttemptAt = event.CreatedAt
+		t.outbox = append(t.outbox, event)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &event, nil
+}
+
+// ClaimPending keeps the partition ordering of the SQL repository: an event
+// waits while an earlier event of its partition is claimed or waiting for a
+// retry.
+func (o outboxRepository) ClaimPending(ctx context.Context, limit int, lease time.Duration) ([]domain.OutboxEvent, error) {
+	events := []domain.OutboxEvent{}
+	err := o.c.do(ctx, func(t *tables) error {
+		ts := now()
+		for i, e := range t.outbox {
+			if e.PublishedAt != nil || e.NextAttemptAt.After(ts) {
+				continue
+			}
+
+			blocked := slices.ContainsFunc(t.outbox[:i], func(p domain.OutboxEvent) bool {
+				return p.PartitionKey == e.PartitionKey && p.PublishedAt == nil && p.NextAttemptAt.After(ts)
+			})
+			if !blocked {
+				events = append(events, e)
+			}
+		}
+
+		events = limitRows(events, limit)
+		for k, e := range events {
+			i := slices.IndexFunc(t.outbox, func(o domain.OutboxEvent) bool { return o.ID == e.ID })
+			t.outbox[i].NextAttemptAt = ts.Add(lease)
+			events[k] = t.outbox[i]
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return events, nil
+}
+
+func (o outboxRepository) Release(ctx context.Context, id int64) error {
+	return o.update(ctx, id, func(e *domain.OutboxEvent) {
+		e.NextAttemptAt = now()
+	})
+}
+
+func (o outboxRepository) MarkPublished(ctx context.Context, id int64) error {
+	return o.update(ctx, id, func(e *domain.OutboxEvent) {
+		ts := now()
+		e.PublishedAt = &ts
+		e.Attempts++
+		e.LastError = nil
+	})
+}
+
+func (o outboxRepository) MarkFailed(ctx context.Context, id int64, lastError string, retryAfter time.Duration) error {
+	return o.update(ctx, id, func(e *domain.OutboxEvent) {
+		e.Attempts++
+		e.LastError = &lastError
+		e.NextAttemptAt = now().Add(retryAfter)
+	})
+}
+
+func (o outboxRepository) update(ctx context.Context, id int64, fn func(e *domain.OutboxEvent)) error {
+	return o.c.do(ctx, func(t *tables) error {
+		i := slices.IndexFunc(t.outbox, func(e domain.OutboxEvent) bool { return e.ID == id })
+		if i >= 0 && t.outbox[i].PublishedAt == nil {
+			fn(&t.outbox[i])
+		}
+
+		return nil
+	})
+}
@@ -0,0 +1,313 @@
+// Package memory implements the repository interfaces in memory, for running
+// the service layer without a database.
+//
+// Transactions are serializable: TxProvider.Tx holds the store's lock until
+// the transaction ends, so row locks are implied, and restores a snapshot of
+// every table when the transaction fails. A savepoint restores the snapshot
+// taken when it was set. Statements outside a transaction each take the lock
+// on their own. The constraints the services rely on are
+// enforced as Postgres enforces them: unique keys, foreign keys and the
+// balance checks of the wallets table.
+package memory
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"maps"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/vcnt72/go-boilerplate/internal/domain"
+	"github.com/vcnt72/go-boilerplate/internal/repository"
+)
+
+var (
+	ErrCheckViolation      = errors.New("error check constraint violated")
+	ErrUniqueViolation     = errors.New("error unique constraint violated")
+	ErrForeignKeyViolation = errors.New("error foreign key constraint violated")
+)
+
+// tables holds the rows of every table, each slice in ID order.
+type tables struct {
+	users         []domain.User
+	wallets       []domain.Wallet
+	ledgers       []domain.Ledger
+	accounts      []domain.Account
+	journals      []domain.Journal
+	postings      []domain.Posting
+	limits        []domain.WithdrawalLimit
+	fees          []domain.FeeSchedule
+	outbox        []domain.OutboxEvent
+	subscriptions []domain.WebhookSubscription
+	deliveries    []domain.WebhookDelivery
+	holds         []domain.Hold
+	rates         map[string]domain.FXRate
+	quotes        map[string]domain.FXQuote
+}
+
+// clone copies every table. Rows are copied by value and never changed
+// through a shared pointer, so the copy is independent of t.
+func (t tables) clone() tables {
+	return tables{
+		users:         slices.Clone(t.users),
+		wallets:       slices.Clone(t.wallets),
+		ledgers:       slices.Clone(t.ledgers),
+		accounts:      slices.Clone(t.accounts),
+		journals:      slices.Clone(t.journals),
+		postings:      slices.Clone(t.postings),
+		limits:        slices.Clone(t.limits),
+		fees:          slices.Clone(t.fees),
+		outbox:        slices.Clone(t.outbox),
+		subscriptions: slices.Clone(t.subscriptions),
+		deliveries:    slices.Clone(t.deliveries),
+		holds:         slices.Clone(t.holds),
+		rates:         maps.Clone(t.rates),
+		quotes:        maps.Clone(t.quotes),
+	}
+}
+
+// sequences hand out IDs. Like Postgres sequences they are not rolled back.
+type sequences struct {
+	users, wallets, ledgers, accounts, journals, postings, limits, fees, outbox, subscriptions, deliveries, holds int64
+}
+
+type Store struct {
+	mu   sync.Mutex
+	data tables
+	seq  sequences
+
+	transactions atomic.Int64
+	savepoints   atomic.Int64
+}
+
+// conn is what a repository runs its statements on: the store, or the
+// transaction the context carries for it.
+type conn struct {
+	store *Store
+}
+
+// txKey is the context key of the transaction open on store.
+type txKey struct {
+	store *Store
+}
+
+type transaction struct {
+	done bool
+}
+
+// do runs fn on the tables. Outside a transaction fn is one statement and must
+// check everything it can fail on before changing a row.
+func (c conn) do(ctx context.Context, fn func(t *tables) error) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	if tx, ok := ctx.Value(txKey{c.store}).(*transaction); ok {
+		if tx.done {
+			return sql.ErrTxDone
+		}
+
+		return fn(&c.store.data)
+	}
+
+	c.store.mu.Lock()
+	defer c.store.mu.Unlock()
+
+	return fn(&c.store.data)
+}
+
+// next advances a sequence of the store. The caller holds the lock.
+func next(counter *int64) int64 {
+	*counter++
+	return *counter
+}
+
+type txProvider struct {
+	store *Store
+}
+
+func (t txProvider) Tx(ctx context.Context, txFunc func(ctx context.Context) error) error {
+	return t.TxWithOptions(ctx, repository.TxOptions{}, txFunc)
+}
+
+// TxWithOptions ignores opts: transactions are serializable already and are
+// never aborted in favour of another, so there is nothing to retry.
+func (t txProvider) TxWithOptions(ctx context.Context, opts repository.TxOptions, txFunc func(ctx context.Context) error) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	s := t.store
+	if tx, ok := ctx.Value(txKey{s}).(*transaction); ok {
+		s.savepoints.Add(1)
+		return tx.savepoint(ctx, s, txFunc)
+	}
+
+	s.transactions.Add(1)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	snapshot := s.data.clone()
+	tx := &transaction{}
+	committed := false
+	defer func() {
+		tx.done = true
+		if !committed {
+			s.data = snapshot
+		}
+	}()
+
+	if err := txFunc(context.WithValue(ctx, txKey{s}, tx)); err != nil {
+		return err
+	}
+
+	committed = true
+	return nil
+}
+
+// savepoint runs txFunc in tx, restoring the tables it found when txFunc
+// fails or panics. The caller's transaction holds the lock.
+func (tx *transaction) savepoint(ctx context.Context, s *Store, txFunc func(ctx context.Context) error) error {
+	if tx.done {
+		return sql.ErrTxDone
+	}
+
+	snapshot := s.data.clone()
+	released := false
+	defer func() {
+		if !released {
+			s.data = snapshot
+		}
+	}()
+
+	if err := txFunc(ctx); err != nil {
+		return err
+	}
+
+	released = true
+	return nil
+}
+
+func (t txProvider) Stats() repository.TxStats {
+	return repository.TxStats{
+		Transactions: t.store.transactions.Load(),
+		Savepoints:   t.store.savepoints.Load(),
+	}
+}
+
+// SetWithdrawalLimit inserts or replaces the limit row of limit.UserID, or of
+// limit.Tier when no user is set, in limit.Currency.
+func (s *Store) SetWithdrawalLimit(limit domain.WithdrawalLimit) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if (limit.UserID == nil) == (limit.Tier == nil) {
+		return ErrCheckViolation
+	}
+
+	ts := now()
+	limit.UpdatedAt = ts
+	for i, l := range s.data.limits {
+		if l.Currency == limit.Currency && sameScope(l.UserID, l.Tier, limit.UserID, limit.Tier) {
+			limit.ID, limit.CreatedAt = l.ID, l.CreatedAt
+			s.data.limits[i] = limit
+			return nil
+		}
+	}
+
+	limit.ID, limit.CreatedAt = next(&s.seq.limits), ts
+	s.data.limits = append(s.data.limits, limit)
+	return nil
+}
+
+// SetFeeSchedule inserts or replaces the fee schedule of schedule.UserID, or
+// of schedule.Tier when no user is set, in schedule.Currency.
+func (s *Store) SetFeeSchedule(schedule domain.FeeSchedule) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if (schedule.UserID == nil) == (schedule.Tier == nil) {
+		return ErrCheckViolation
+	}
+
+	ts := now()
+	schedule.UpdatedAt = ts
+	for i, f := range s.data.fees {
+		if f.Currency == schedule.Currency && sameScope(f.UserID, f.Tier, schedule.UserID, schedule.Tier) {
+			schedule.ID, schedule.CreatedAt = f.ID, f.CreatedAt
+			s.data.fees[i] = schedule
+			return nil
+		}
+	}
+
+	schedule.ID, schedule.CreatedAt = next(&s.seq.fees), ts
+	s.data.fees = append(s.data.fees, schedule)
+	return nil
+}
+
+func sameScope(userID *int64, tier *string, otherUserID *int64, otherTier *string) bool {
+	if userID != nil && otherUserID != nil {
+		return *userID == *otherUserID
+	}
+
+	return tier != nil && otherTier != nil && *tier == *otherTier
+}
+
+// Repositories returns every repository of the store.
+func (s *Store) Repositories() repository.Repositories {
+	c := conn{store: s}
+
+	return repository.Repositories{
+		UserRepository:    &userRepository{c},
+		WalletRepository:  &walletRepository{c},
+		LedgerRepository:  &ledgerRepository{c},
+		AccountRepository: &accountRepository{c},
+		JournalRepository: &journalRepository{c},
+		LimitRepository:   &limitRepository{c},
+		FeeRepository:     &feeRepository{c},
+		OutboxRepository:  &outboxRepository{c},
+		WebhookRepository: &webhookRepository{c},
+		HoldRepository:    &holdRepository{c},
+		FXRateRepository:  &fxRateRepository{c},
+		FXQuoteRepository: &fxQuoteRepository{c},
+		TxProvider:        &txProvider{s},
+	}
+}
+
+func New() *Store {
+	return &Store{
+		data: tables{
+			rates:  map[string]domain.FXRate{},
+			quotes: map[string]domain.FXQuote{},
+		},
+	}
+}
+
+// now is the time rows are stamped with, at the microsecond precision of a
+// Postgres timestamptz.
+func now() time.Time {
+	return time.Now().Truncate(time.Microsecond)
+}
+
+// limitRows applies a LIMIT clause to rows.
+func limitRows[T any](rows []T, limit int) []T {
+	if limit >= 0 && len(rows) > limit {
+		return rows[:limit]
+	}
+
+	return rows
+}
+
+// trimRate formats a rate the way a numeric column reads back, without
+// trailing zeros.
+func trimRate(rate string) string {
+	if !strings.Contains(rate, ".") {
+		return rate
+	}
+
+	return strings.TrimSuffix(strings.TrimRight(rate, "0"), ".")
+}
@@ -0,0 +1,160 @@
+package memory
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/vcnt72/go-boilerplate/internal/domain"
+	"github.com/vcnt72/go-boilerplate/internal/repository"
+)
+
+func seedWallet(t *testing.T, r repository.Repositories, balance int64) *domain.Wallet {
+	t.Helper()
+
+	ctx := context.Background()
+	user, err := r.UserRepository.Create(ctx, domain.User{Name: "test"})
+	require.NoError(t, err)
+
+	wallet, err := r.WalletRepository.Create(ctx, domain.Wallet{UserID: user.ID, Balance: domain.NewMoney(balance, "IDR"), Currency: "IDR"})
+	require.NoError(t, err)
+	return wallet
+}
+
+func TestTx_RollsBackOnError(t *testing.T) {
+	ctx := context.Background()
+	r := New().Repositories()
+	wallet := seedWallet(t, r, 1_000)
+
+	boom := errors.New("boom")
+	err := r.TxProvider.Tx(ctx, func(ctx context.Context) error {
+		if _, err := r.WalletRepository.DecreaseBalance(ctx, domain.NewMoney(400, "IDR"), wallet.ID); err != nil {
+			return err
+		}
+
+		if _, err := r.LedgerRepository.Create(ctx, domain.Ledger{IdempotencyKey: "k-1", WalletID: wallet.ID, Amount: domain.NewMoney(400, "IDR")}); err != nil {
+			return err
+		}
+
+		return boom
+	})
+	require.ErrorIs(t, err, boom)
+
+	got, err := r.WalletRepository.GetByID(ctx, wallet.ID)
+	require.NoError(t, err)
+	require.Equal(t, int64(1_000), got.Balance.Amount)
+
+	_, err = r.LedgerRepository.GetByIdempotencyKey(ctx, wallet.ID, "k-1")
+	require.ErrorIs(t, err, domain.ErrLedgerNotFound)
+}
+
+func TestTx_RollsBackOnPanic(t *testing.T) {
+	ctx := context.Background()
+	r := New().Repositories()
+	wallet := seedWallet(t, r, 1_000)
+
+	require.Panics(t, func() {
+		_ = r.TxProvider.Tx(ctx, func(ctx context.Context) error {
+			_, _ = r.WalletRepository.IncreaseBalance(ctx, domain.NewMoney(500, "IDR"), wallet.ID)
+			panic("boom")
+		})
+	})
+
+	got, err := r.WalletRepository.GetByID(ctx, wallet.ID)
+	require.NoError(t, err)
+	require.Equal(t, int64(1_000), got.Balance.Amount)
+}
+
+func TestTx_CommitsAndEndsTx(t *testing.T) {
+	ctx := context.Background()
+	r := New().Repositories()
+	wallet := seedWallet(t, r, 1_000)
+
+	var txCtx context.Context
+	err := r.TxProvider.Tx(ctx, func(ctx context.Context) error {
+		txCtx = ctx
+		_, err := r.WalletRepository.DecreaseBalance(ctx, domain.NewMoney(400, "IDR"), wallet.ID)
+		return err
+	})
+	require.NoError(t, err)
+
+	got, err := r.WalletRepository.GetByID(ctx, wallet.ID)
+	require.NoError(t, err)
+	require.Equal(t, int64(600), got.Balance.Amount)
+
+	_, err = r.WalletRepository.GetByID(txCtx, wallet.ID)
+	require.ErrorIs(t, err, sql.ErrTxDone)
+}
+
+func TestTx_NestedTxIsSavepoint(t *testing.T) {
+	ctx := context.Background()
+	r := New().Repositories()
+	wallet := seedWallet(t, r, 1_000)
+
+	boom := errors.New("boom")
+	err := r.TxProvider.Tx(ctx, func(ctx context.Context) error {
+		err := r.TxProvider.Tx(ctx, func(ctx context.Context) error {
+			_, err := r.WalletRepository.IncreaseBalance(ctx, domain.NewMoney(500, "IDR"), wallet.ID)
+			require.NoError(t, err)
+			return boom
+		})
+		require.ErrorIs(t, err, boom)
+
+		return r.TxProvider.Tx(ctx, func(ctx context.Context) error {
+			_, err := r.WalletRepository.DecreaseBalance(ctx, domain.NewMoney(400, "IDR"), wallet.ID)
+			return err
+		})
+	})
+	require.NoError(t, err)
+
+	got, err := r.WalletRepository.GetByID(ctx, wallet.ID)
+	require.NoError(t, err)
+	require.Equal(t, int64(600), got.Balance.Amount)
+	require.Equal(t, repository.TxStats{Transactions: 1, Savepoints: 2}, r.TxProvider.Stats())
+}
+
+func TestLedger_UniqueIdempotencyKeyPerWallet(t *testing.T) {
+	ctx := context.Background()
+	r := New().Repositories()
+	first := seedWallet(t, r, 0)
+	second := seedWallet(t, r, 0)
+
+	ledger := domain.Ledger{IdempotencyKey: "k-1", WalletID: first.ID, Amount: domain.NewMoney(100, "IDR")}
+	_, err := r.LedgerRepository.Create(ctx, ledger)
+	require.NoError(t, err)
+
+	_, err = r.LedgerRepository.Create(ctx, ledger)
+	require.ErrorIs(t, err, domain.ErrLedgerConflict)
+
+	ledger.WalletID = second.ID
+	_, err = r.LedgerRepository.Create(ctx, ledger)
+	require.NoError(t, err)
+}
+
+func TestWallet_BalanceChecks(t *testing.T) {
+	ctx := context.Background()
+	r := New().Repositories()
+	wallet := seedWallet(t, r, 1_000)
+
+	_, err := r.WalletRepository.Hold(ctx, wallet.ID, domain.NewMoney(700, "IDR"))
+	require.NoError(t, err)
+
+	// Held funds are not available.
+	_, err = r.WalletRepository.DecreaseBalance(ctx, domain.NewMoney(400, "IDR"), wallet.ID)
+	require.ErrorIs(t, err, domain.ErrInsufficientFund)
+
+	balance, err := r.WalletRepository.DecreaseBalance(ctx, domain.NewMoney(300, "IDR"), wallet.ID)
+	require.NoError(t, err)
+	require.Equal(t, int64(700), balance.Amount)
+
+	_, err = r.WalletRepository.SettleHold(ctx, wallet.ID, domain.NewMoney(0, "IDR"), domain.NewMoney(800, "IDR"))
+	require.ErrorIs(t, err, ErrCheckViolation)
+
+	got, err := r.WalletRepository.SettleHold(ctx, wallet.ID, domain.NewMoney(700, "IDR"), domain.NewMoney(700, "IDR"))
+	require.NoError(t, err)
+	require.Equal(t, int64(0), got.Balance.Amount)
+	require.Equal(t, int64(0), got.AvailableBalance.Amount)
+}
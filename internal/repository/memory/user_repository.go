@@ -0,0 +1,37 @@
+package memory
+
+import (
+	"context"
+	"slices"
+
+	"github.com/vcnt72/go-boilerplate/internal/domain"
+)
+
+type userRepository struct {
+	c conn
+}
+
+func (u userRepository) Create(ctx context.Context, spec domain.User) (*domain.User, error) {
+	err := u.c.do(ctx, func(t *tables) error {
+		spec.ID = next(&u.c.store.seq.users)
+		spec.Tier = domain.UserTierStandard
+		spec.CreatedAt = now()
+		spec.UpdatedAt = spec.CreatedAt
+		t.users = append(t.users, spec)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &spec, nil
+}
+
+func (t *tables) user(id int64) (domain.User, bool) {
+	i := slices.IndexFunc(t.users, func(u domain.User) bool { return u.ID == id })
+	if i < 0 {
+		return domain.User{}, false
+	}
+
+	return t.users[i], true
+}
@@ -0,0 +1,218 @@
+package memory
+
+import (
+	"context"
+	"slices"
+
+	"github.com/vcnt72/go-boilerplate/internal/domain"
+)
+
+type walletRepository struct {
+	c conn
+}
+
+// readWallet is the wallet as a query returns it, with its available balance.
+func readWallet(w domain.Wallet) domain.Wallet {
+	w.AvailableBalance = domain.NewMoney(w.Balance.Amount-w.HeldBalance.Amount, w.Currency)
+	w.BindCurrency()
+	return w
+}
+
+// checkWallet enforces the balance checks of the wallets table.
+func checkWallet(w domain.Wallet) error {
+	if w.Balance.Amount < 0 || w.HeldBalance.Amount < 0 || w.HeldBalance.Amount > w.Balance.Amount {
+		return ErrCheckViolation
+	}
+
+	return nil
+}
+
+func (t *tables) wallet(id int64) int {
+	return slices.IndexFunc(t.wallets, func(w domain.Wallet) bool { return w.ID == id })
+}
+
+func (w walletRepository) Create(ctx context.Context, spec domain.Wallet) (*domain.Wallet, error) {
+	err := w.c.do(ctx, func(t *tables) error {
+		if _, ok := t.user(spec.UserID); !ok {
+			return ErrForeignKeyViolation
+		}
+
+		if slices.ContainsFunc(t.wallets, func(o domain.Wallet) bool { return o.UserID == spec.UserID && o.Currency == spec.Currency }) {
+			return domain.ErrWalletExists
+		}
+
+		spec.HeldBalance = domain.NewMoney(0, spec.Currency)
+		spec.BindCurrency()
+		if err := checkWallet(spec); err != nil {
+			return err
+		}
+
+		spec.ID = next(&w.c.store.seq.wallets)
+		spec.CreatedAt = now()
+		spec.UpdatedAt = spec.CreatedAt
+		t.wallets = append(t.wallets, spec)
+		spec = readWallet(spec)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &spec, nil
+}
+
+func (w walletRepository) GetByUserID(ctx context.Context, userID int64, currency string) (*domain.Wallet, error) {
+	return w.get(ctx, func(o domain.Wallet) bool { return o.UserID == userID && o.Currency == currency })
+}
+
+func (w walletRepository) ListByUserID(ctx context.Context, userID int64) ([]domain.Wallet, error) {
+	return w.list(ctx, func(o domain.Wallet) bool { return o.UserID == userID }, -1)
+}
+
+func (w walletRepository) GetByID(ctx context.Context, id int64) (*domain.Wallet, error) {
+	return w.get(ctx, func(o domain.Wallet) bool { return o.ID == id })
+}
+
+func (w walletRepository) ListAfterID(ctx context.Context, afterID int64, limit int) ([]domain.Wallet, error) {
+	return w.list(ctx, func(o domain.Wallet) bool { return o.ID > afterID }, limit)
+}
+
+// LockByID is GetByID: the transaction already excludes every other.
+func (w walletRepository) LockByID(ctx context.Context, id int64) (*domain.Wallet, error) {
+	return w.GetByID(ctx, id)
+}
+
+func (w walletRepository) get(ctx context.Context, match func(domain.Wallet) bool) (*domain.Wallet, error) {
+	var wallet domain.Wallet
+	err := w.c.do(ctx, func(t *tables) error {
+		i := slices.IndexFunc(t.wallets, match)
+		if i < 0 {
+			return domain.ErrWalletNotFound
+		}
+
+		wallet = readWallet(t.wallets[i])
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &wallet, nil
+}
+
+func (w walletRepository) list(ctx context.Context, match func(domain.Wallet) bool, limit int) ([]domain.Wallet, error) {
+	wallets := []domain.Wallet{}
+	err := w.c.do(ctx, func(t *tables) error {
+		for _, o := range t.wallets {
+			if match(o) {
+				wallets = append(wallets, readWallet(o))
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return limitRows(wallets, limit), nil
+}
+
+func (w walletRepository) DecreaseBalance(ctx context.Context, amount domain.Money, walletID int64) (domain.Money, error) {
+	if !amount.IsPositive() {
+		return domain.Money{}, domain.ErrInvalidAmount
+	}
+
+	b := domain.Money{Currency: amount.Currency}
+	err := w.c.do(ctx, func(t *tables) error {
+		i := t.wallet(walletID)
+		if i < 0 || t.wallets[i].Balance.Amount-t.wallets[i].HeldBalance.Amount < amount.Amount {
+			return domain.ErrInsufficientFund
+		}
+
+		b.Amount = t.wallets[i].Balance.Amount - amount.Amount
+		t.wallets[i].Balance.Amount = b.Amount
+		t.wallets[i].UpdatedAt = now()
+		return nil
+	})
+	if err != nil {
+		return domain.Money{}, err
+	}
+
+	return b, nil
+}
+
+func (w walletRepository) IncreaseBalance(ctx context.Context, amount domain.Money, walletID int64) (domain.Money, error) {
+	if !amount.IsPositive() {
+		return domain.Money{}, domain.ErrInvalidAmount
+	}
+
+	b := domain.Money{Currency: amount.Currency}
+	err := w.c.do(ctx, func(t *tables) error {
+		i := t.wallet(walletID)
+		if i < 0 {
+			return domain.ErrWalletNotFound
+		}
+
+		b.Amount = t.wallets[i].Balance.Amount + amount.Amount
+		t.wallets[i].Balance.Amount = b.Amount
+		t.wallets[i].UpdatedAt = now()
+		return nil
+	})
+	if err != nil {
+		return domain.Money{}, err
+	}
+
+	return b, nil
+}
+
+func (w walletRepository) Hold(ctx context.Context, walletID int64, amount domain.Money) (*domain.Wallet, error) {
+	if !amount.IsPositive() {
+		return nil, domain.ErrInvalidAmount
+	}
+
+	var wallet domain.Wallet
+	err := w.c.do(ctx, func(t *tables) error {
+		i := t.wallet(walletID)
+		if i < 0 || t.wallets[i].Balance.Amount-t.wallets[i].HeldBalance.Amount < amount.Amount {
+			return domain.ErrInsufficientFund
+		}
+
+		t.wallets[i].HeldBalance.Amount += amount.Amount
+		t.wallets[i].UpdatedAt = now()
+		wallet = readWallet(t.wallets[i])
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &wallet, nil
+}
+
+func (w walletRepository) SettleHold(ctx context.Context, walletID int64, held, spent domain.Money) (*domain.Wallet, error) {
+	var wallet domain.Wallet
+	err := w.c.do(ctx, func(t *tables) error {
+		i := t.wallet(walletID)
+		if i < 0 {
+			return domain.ErrWalletNotFound
+		}
+
+		settled := t.wallets[i]
+		settled.HeldBalance.Amount -= held.Amount
+		settled.Balance.Amount -= spent.Amount
+		if err := checkWallet(settled); err != nil {
+			return err
+		}
+
+		settled.UpdatedAt = now()
+		t.wallets[i] = settled
+		wallet = readWallet(settled)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &wallet, nil
+}
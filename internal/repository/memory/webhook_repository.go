@@ -0,0 +1,252 @@
+package memory
+
+import (
+	"cmp"
+	"context"
+	"slices"
+	"time"
+
+	"github.com/vcnt72/go-boilerplate/internal/domain"
+	"github.com/vcnt72/go-boilerplate/internal/repository"
+)
+
+type webhookRepository struct {
+	c conn
+}
+
+func (w webhookRepository) CreateSubscription(ctx context.Context, spec domain.WebhookSubscription) (*domain.WebhookSubscription, error) {
+	err := w.c.do(ctx, func(t *tables) error {
+		spec.ID = next(&w.c.store.seq.subscriptions)
+		spec.EventTypes = slices.Clone(spec.EventTypes)
+		spec.CreatedAt = now()
+		spec.UpdatedAt = spec.CreatedAt
+		t.subscriptions = append(t.subscriptions, spec)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &spec, nil
+}
+
+func (w webhookRepository) GetSubscription(ctx context.Context, id int64) (*domain.WebhookSubscription, error) {
+	var sub domain.WebhookSubscription
+	err := w.c.do(ctx, func(t *tables) error {
+		i := t.subscription(id)
+		if i < 0 {
+			return domain.ErrWebhookNotFound
+		}
+
+		sub = t.subscriptions[i]
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &sub, nil
+}
+
+func (w webhookRepository) ListSubscriptions(ctx context.Context) ([]domain.WebhookSubscription, error) {
+	subs := []domain.WebhookSubscription{}
+	err := w.c.do(ctx, func(t *tables) error {
+		subs = append(subs, t.subscriptions...)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return subs, nil
+}
+
+func (w webhookRepository) UpdateSubscription(ctx context.Context, spec domain.WebhookSubscription) (*domain.WebhookSubscription, error) {
+	err := w.c.do(ctx, func(t *tables) error {
+		i := t.subscription(spec.ID)
+		if i < 0 {
+			return domain.ErrWebhookNotFound
+		}
+
+		sub := &t.subscriptions[i]
+		sub.URL = spec.URL
+		sub.EventTypes = slices.Clone(spec.EventTypes)
+		sub.Secret = spec.Secret
+		sub.Active = spec.Active
+		sub.UpdatedAt = now()
+		spec.UpdatedAt = sub.UpdatedAt
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &spec, nil
+}
+
+// DeleteSubscription removes the subscription together with its deliveries.
+func (w webhookRepository) DeleteSubscription(ctx context.Context, id int64) error {
+	return w.c.do(ctx, func(t *tables) error {
+		i := t.subscription(id)
+		if i < 0 {
+			return domain.ErrWebhookNotFound
+		}
+
+		t.subscriptions = slices.Delete(t.subscriptions, i, i+1)
+		t.deliveries = slices.DeleteFunc(t.deliveries, func(d domain.WebhookDelivery) bool { return d.SubscriptionID == id })
+		return nil
+	})
+}
+
+func (w webhookRepository) CreateDeliveries(ctx context.Context, event domain.OutboxEvent) (int64, error) {
+	var n int64
+	err := w.c.do(ctx, func(t *tables) error {
+		if !slices.ContainsFunc(t.outbox, func(e domain.OutboxEvent) bool { return e.ID == event.ID }) {
+			return ErrForeignKeyViolation
+		}
+
+		for _, sub := range t.subscriptions {
+			if !sub.Active || !slices.Contains(sub.EventTypes, event.Type) {
+				continue
+			}
+
+			exists := slices.ContainsFunc(t.deliveries, func(d domain.WebhookDelivery) bool {
+				return d.SubscriptionID == sub.ID && d.EventID == event.ID
+			})
+			if exists {
+				continue
+			}
+
+			ts := now()
+			t.deliveries = append(t.deliveries, domain.WebhookDelivery{
+				ID:             next(&w.c.store.seq.deliveries),
+				SubscriptionID: sub.ID,
+				EventID:        event.ID,
+				EventType:      event.Type,
+				Payload:        slices.Clone(event.Payload),
+				Status:         domain.WebhookDeliveryStatusPending,
+				NextAttemptAt:  ts,
+				CreatedAt:      ts,
+				UpdatedAt:      ts,
+			})
+			n++
+		}
+
+		return nil
+	})
+
+	return n, err
+}
+
+func (w webhookRepository) ListDeliveries(ctx context.Context, filter repository.WebhookDeliveryFilter) ([]domain.WebhookDelivery, error) {
+	deliveries := []domain.WebhookDelivery{}
+	err := w.c.do(ctx, func(t *tables) error {
+		for _, d := range slices.Backward(t.deliveries) {
+			if d.SubscriptionID != filter.SubscriptionID ||
+				(filter.Status != "" && d.Status != filter.Status) ||
+				(filter.BeforeID != 0 && d.ID >= filter.BeforeID) {
+				continue
+			}
+
+			deliveries = append(deliveries, d)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return limitRows(deliveries, filter.Limit), nil
+}
+
+func (w webhookRepository) ClaimDueDeliveries(ctx context.Context, limit int, lease time.Duration) ([]repository.DueWebhookDelivery, error) {
+	deliveries := []repository.DueWebhookDelivery{}
+	err := w.c.do(ctx, func(t *tables) error {
+		ts := now()
+		for _, d := range t.deliveries {
+			if d.Status != domain.WebhookDeliveryStatusPending || d.NextAttemptAt.After(ts) {
+				continue
+			}
+
+			i := t.subscription(d.SubscriptionID)
+			if i < 0 || !t.subscriptions[i].Active {
+				continue
+			}
+
+			deliveries = append(deliveries, repository.DueWebhookDelivery{
+				WebhookDelivery: d,
+				URL:             t.subscriptions[i].URL,
+				Secret:          t.subscriptions[i].Secret,
+			})
+		}
+
+		slices.SortStableFunc(deliveries, func(a, b repository.DueWebhookDelivery) int {
+			if c := a.NextAttemptAt.Compare(b.NextAttemptAt); c != 0 {
+				return c
+			}
+
+			return cmp.Compare(a.ID, b.ID)
+		})
+		deliveries = limitRows(deliveries, limit)
+
+		for k, d := range deliveries {
+			i := slices.IndexFunc(t.deliveries, func(o domain.WebhookDelivery) bool { return o.ID == d.ID })
+			t.deliveries[i].NextAttemptAt = ts.Add(lease)
+			t.deliveries[i].UpdatedAt = ts
+			deliveries[k].WebhookDelivery = t.deliveries[i]
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return deliveries, nil
+}
+
+func (w webhookRepository) MarkDelivered(ctx context.Context, id int64, statusCode int) error {
+	return w.update(ctx, id, func(d *domain.WebhookDelivery) {
+		ts := now()
+		d.Status = domain.WebhookDeliveryStatusSucceeded
+		d.Attempts++
+		d.LastStatusCode = &statusCode
+		d.LastError = nil
+		d.DeliveredAt = &ts
+	})
+}
+
+func (w webhookRepository) MarkRetry(ctx context.Context, id int64, statusCode *int, lastError string, retryAfter time.Duration) error {
+	return w.update(ctx, id, func(d *domain.WebhookDelivery) {
+		d.Attempts++
+		d.LastStatusCode = statusCode
+		d.LastError = &lastError
+		d.NextAttemptAt = now().Add(retryAfter)
+	})
+}
+
+func (w webhookRepository) MarkDead(ctx context.Context, id int64, statusCode *int, lastError string) error {
+	return w.update(ctx, id, func(d *domain.WebhookDelivery) {
+		d.Status = domain.WebhookDeliveryStatusDead
+		d.Attempts++
+		d.LastStatusCode = statusCode
+		d.LastError = &lastError
+	})
+}
+
+func (w webhookRepository) update(ctx context.Context, id int64, fn func(d *domain.WebhookDelivery)) error {
+	return w.c.do(ctx, func(t *tables) error {
+		i := slices.IndexFunc(t.deliveries, func(d domain.WebhookDelivery) bool { return d.ID == id })
+		if i >= 0 && t.deliveries[i].Status == domain.WebhookDeliveryStatusPending {
+			fn(&t.deliveries[i])
+			t.deliveries[i].UpdatedAt = now()
+		}
+
+		return nil
+	})
+}
+
+func (t *tables) subscription(id int64) int {
+	return slices.IndexFunc(t.subscriptions, func(s domain.WebhookSubscription) bool { return s.ID == id })
+}
@@ -0,0 +1,106 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/vcnt72/go-boilerplate/internal/domain"
+)
+
+type outboxRepository struct {
+	db *sqlx.DB
+}
+
+func (o outboxRepository) Create(ctx context.Context, event domain.OutboxEvent) (*domain.OutboxEvent, error) {
+	err := conn(ctx, o.db).QueryRowxContext(ctx,
+		"INSERT INTO outbox(event_type, partition_key, payload) VALUES($1,$2,$3::jsonb) RETURNING id, next_attempt_at, created_at",
+		event.Type, event.PartitionKey, string(event.Payload)).
+		Scan(&event.ID, &event.NextAttemptAt, &event.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	return &event, nil
+}
+
+// outboxClaimLock is the Postgres advisory lock that makes relays claim one
+// at a time, so that every claim sees the leases of the ones before it.
+const outboxClaimLock = 0x6f7574626f78
+
+// ClaimPending returns up to limit unpublished events that are due, oldest
+// first, and postpones them by lease, so that other relays leave them alone
+// once the surrounding transaction commits. An event is left out while an
+// earlier event of its partition is claimed or waiting for a retry, so a
+// partition is never published out of order.
+func (o outboxRepository) ClaimPending(ctx context.Context, limit int, lease time.Duration) ([]domain.OutboxEvent, error) {
+	if !isSQLite(o.db) {
+		if _, err := conn(ctx, o.db).ExecContext(ctx, "SELECT pg_advisory_xact_lock($1)", outboxClaimLock); err != nil {
+			return nil, err
+		}
+	}
+
+	events := []domain.OutboxEvent{}
+
+	err := sqlx.SelectContext(ctx, conn(ctx, o.db), &events, `
+		SELECT o.id, o.event_type, o.partition_key, `+jsonColumn(o.db, "o.payload")+` AS payload, o.attempts, o.last_error, o.next_attempt_at, o.published_at, o.created_at
+		FROM outbox o
+		WHERE o.published_at IS NULL
+		AND o.next_attempt_at <= now()
+		AND NOT EXISTS (
+			SELECT 1 FROM outbox p
+			WHERE p.partition_key = o.partition_key
+			AND p.published_at IS NULL
+			AND p.id < o.id
+			AND p.next_attempt_at > now()
+		)
+		ORDER BY o.id
+		LIMIT $1`+rowLock(o.db, "FOR UPDATE OF o"), limit)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, e := range events {
+		err := conn(ctx, o.db).QueryRowxContext(ctx,
+			"UPDATE outbox SET next_attempt_at = "+nowPlusSeconds(o.db, "$1")+" WHERE id = $2 RETURNING next_attempt_at",
+			lease.Seconds(), e.ID).
+			Scan(&events[i].NextAttemptAt)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return events, nil
+}
+
+// Release hands a claimed event back before its lease ends, so that it is
+// due again as soon as the rest of its partition allows.
+func (o outboxRepository) Release(ctx context.Context, id int64) error {
+	_, err := conn(ctx, o.db).ExecContext(ctx, "UPDATE outbox SET next_attempt_at = now() WHERE id = $1 AND published_at IS NULL", id)
+
+	return err
+}
+
+// MarkPublished records that the sink accepted the event. Like MarkFailed, it
+// leaves an event that another relay published after the lease of this one
+// ran out alone.
+func (o outboxRepository) MarkPublished(ctx context.Context, id int64) error {
+	_, err := conn(ctx, o.db).ExecContext(ctx, "UPDATE outbox SET published_at = now(), attempts = attempts + 1, last_error = NULL WHERE id = $1 AND published_at IS NULL", id)
+
+	return err
+}
+
+// MarkFailed records a failed publish and postpones the event by retryAfter.
+func (o outboxRepository) MarkFailed(ctx context.Context, id int64, lastError string, retryAfter time.Duration) error {
+	_, err := conn(ctx, o.db).ExecContext(ctx,
+		"UPDATE outbox SET attempts = attempts + 1, last_error = $1, next_attempt_at = "+nowPlusSeconds(o.db, "$2")+" WHERE id = $3 AND published_at IS NULL",
+		lastError, retryAfter.Seconds(), id)
+
+	return err
+}
+
+func NewOutboxRepository(db *sqlx.DB) OutboxRepository {
+	return &outboxRepository{
+		db: db,
+	}
+}
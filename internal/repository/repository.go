@@ -1,20 +1,220 @@
 // Package repository
 package repository
 
-import "github.com/jmoiron/sqlx"
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/vcnt72/go-boilerplate/internal/domain"
+)
+
+// ErrCommitUnknown is returned by a TxProvider when a commit failed without
+// telling whether the transaction committed. It did if its effects are there.
+var ErrCommitUnknown = errors.New("error commit outcome unknown")
+
+// TxProvider runs txFunc in a transaction, committing when it returns nil and
+// rolling back otherwise. Tx runs it with the zero TxOptions. A failed
+// rollback is joined with the error of txFunc, and a panic in txFunc rolls
+// the transaction back before it goes on. A failed commit is ErrCommitUnknown
+// unless the transaction is known to have rolled back.
+//
+// The transaction travels in the context passed to txFunc: the repositories of
+// the same implementation run every statement given that context in it. A Tx
+// in such a context does not begin a transaction but sets a savepoint in the
+// one already open, rolling back to it when txFunc fails, so that services
+// compose without passing transactions around. A context must not be shared
+// by goroutines while it carries a transaction.
+type TxProvider interface {
+	Tx(ctx context.Context, txFunc func(ctx context.Context) error) error
+	TxWithOptions(ctx context.Context, opts TxOptions, txFunc func(ctx context.Context) error) error
+	Stats() TxStats
+}
+
+// IsolationLevel is the isolation level of a transaction.
+type IsolationLevel int
+
+const (
+	// IsolationDefault is the database's default, read committed on Postgres.
+	IsolationDefault IsolationLevel = iota
+	IsolationReadCommitted
+	IsolationRepeatableRead
+	IsolationSerializable
+)
+
+// ParseIsolationLevel reads "read_committed", "repeatable_read" or
+// "serializable". The empty string is IsolationDefault.
+func ParseIsolationLevel(s string) (IsolationLevel, error) {
+	switch s {
+	case "":
+		return IsolationDefault, nil
+	case "read_committed":
+		return IsolationReadCommitted, nil
+	case "repeatable_read":
+		return IsolationRepeatableRead, nil
+	case "serializable":
+		return IsolationSerializable, nil
+	default:
+		return IsolationDefault, fmt.Errorf("unknown isolation level %q", s)
+	}
+}
+
+// TxOptions tune a transaction. The zero value is a read-write transaction at
+// the default isolation level that is not retried. A savepoint takes the
+// options of the transaction it is set in and ignores its own.
+type TxOptions struct {
+	Isolation IsolationLevel
+	ReadOnly  bool
+	// MaxRetries is how many more times txFunc runs, in a new transaction,
+	// when the database aborts the transaction with a serialization failure
+	// or a deadlock. A retried txFunc must have no effect outside the
+	// transaction and must reset whatever it sets.
+	MaxRetries int
+}
+
+// TxStats counts the transactions of a TxProvider and why they were retried.
+type TxStats struct {
+	Transactions          int64 `json:"transactions"`
+	Savepoints            int64 `json:"savepoints"`
+	SerializationFailures int64 `json:"serializationFailures"`
+	Deadlocks             int64 `json:"deadlocks"`
+	Retries               int64 `json:"retries"`
+	// RetriesExhausted counts transactions that still failed after their
+	// last retry.
+	RetriesExhausted int64 `json:"retriesExhausted"`
+}
+
+type UserRepository interface {
+	Create(ctx context.Context, spec domain.User) (*domain.User, error)
+}
+
+type WalletRepository interface {
+	Create(ctx context.Context, spec domain.Wallet) (*domain.Wallet, error)
+	GetByUserID(ctx context.Context, userID int64, currency string) (*domain.Wallet, error)
+	ListByUserID(ctx context.Context, userID int64) ([]domain.Wallet, error)
+	GetByID(ctx context.Context, id int64) (*domain.Wallet, error)
+	ListAfterID(ctx context.Context, afterID int64, limit int) ([]domain.Wallet, error)
+	LockByID(ctx context.Context, id int64) (*domain.Wallet, error)
+	DecreaseBalance(ctx context.Context, amount domain.Money, walletID int64) (domain.Money, error)
+	IncreaseBalance(ctx context.Context, amount domain.Money, walletID int64) (domain.Money, error)
+	Hold(ctx context.Context, walletID int64, amount domain.Money) (*domain.Wallet, error)
+	SettleHold(ctx context.Context, walletID int64, held, spent domain.Money) (*domain.Wallet, error)
+}
+
+// LedgerRepository.Create reports domain.ErrLedgerConflict when the wallet
+// already has a ledger with the same idempotency key.
+type LedgerRepository interface {
+	Create(ctx context.Context, ledger domain.Ledger) (*domain.Ledger, error)
+	Update(ctx context.Context, spec domain.Ledger) error
+	GetByIdempotencyKey(ctx context.Context, walletID int64, idempotencyKey string) (*domain.Ledger, error)
+	GetByTransferID(ctx context.Context, transferID string, ledgerType domain.LedgerType) (*domain.Ledger, error)
+	GetByParentID(ctx context.Context, parentLedgerID int64, ledgerType domain.LedgerType) (*domain.Ledger, error)
+	GetByPayoutReference(ctx context.Context, reference string) (*domain.Ledger, error)
+	LockPayout(ctx context.Context, reference string) (*domain.Ledger, error)
+	List(ctx context.Context, filter LedgerFilter) ([]domain.Ledger, error)
+	ListStaleProcessing(ctx context.Context, olderThan time.Time, limit int) ([]domain.Ledger, error)
+	ListStalePayouts(ctx context.Context, olderThan time.Time, limit int) ([]domain.Ledger, error)
+	TouchPayout(ctx context.Context, reference string) error
+	FailProcessing(ctx context.Context, id int64, errorCode string) (bool, error)
+	SumWithdrawnSince(ctx context.Context, walletID int64, since time.Time) (domain.WithdrawalUsage, error)
+	SumReversed(ctx context.Context, parentLedgerID int64) (int64, error)
+	SumBookedByWalletRange(ctx context.Context, fromID, toID int64) ([]LedgerTypeSum, error)
+	ListByPayoutReference(ctx context.Context, reference string) ([]domain.Ledger, error)
+}
+
+type AccountRepository interface {
+	CreateForWallet(ctx context.Context, walletID int64) (*domain.Account, error)
+	GetByWalletID(ctx context.Context, walletID int64) (*domain.Account, error)
+	GetOrCreateSystem(ctx context.Context, code string) (*domain.Account, error)
+}
+
+type JournalRepository interface {
+	Create(ctx context.Context, journal domain.Journal) (*domain.Journal, error)
+	SumByWalletRange(ctx context.Context, fromID, toID int64) ([]WalletPostingSum, error)
+}
+
+type LimitRepository interface {
+	GetForUser(ctx context.Context, userID int64, currency string) (*domain.WithdrawalLimit, error)
+}
+
+type FeeRepository interface {
+	GetForUser(ctx context.Context, userID int64, currency string) (*domain.FeeSchedule, error)
+}
+
+type OutboxRepository interface {
+	Create(ctx context.Context, event domain.OutboxEvent) (*domain.OutboxEvent, error)
+	ClaimPending(ctx context.Context, limit int, lease time.Duration) ([]domain.OutboxEvent, error)
+	Release(ctx context.Context, id int64) error
+	MarkPublished(ctx context.Context, id int64) error
+	MarkFailed(ctx context.Context, id int64, lastError string, retryAfter time.Duration) error
+}
+
+type WebhookRepository interface {
+	CreateSubscription(ctx context.Context, spec domain.WebhookSubscription) (*domain.WebhookSubscription, error)
+	GetSubscription(ctx context.Context, id int64) (*domain.WebhookSubscription, error)
+	ListSubscriptions(ctx context.Context) ([]domain.WebhookSubscription, error)
+	UpdateSubscription(ctx context.Context, spec domain.WebhookSubscription) (*domain.WebhookSubscription, error)
+	DeleteSubscription(ctx context.Context, id int64) error
+	CreateDeliveries(ctx context.Context, event domain.OutboxEvent) (int64, error)
+	ListDeliveries(ctx context.Context, filter WebhookDeliveryFilter) ([]domain.WebhookDelivery, error)
+	ClaimDueDeliveries(ctx context.Context, limit int, lease time.Duration) ([]DueWebhookDelivery, error)
+	MarkDelivered(ctx context.Context, id int64, statusCode int) error
+	MarkRetry(ctx context.Context, id int64, statusCode *int, lastError string, retryAfter time.Duration) error
+	MarkDead(ctx context.Context, id int64, statusCode *int, lastError string) error
+}
+
+type HoldRepository interface {
+	Create(ctx context.Context, hold domain.Hold) (*domain.Hold, error)
+	GetByID(ctx context.Context, id int64) (*domain.Hold, error)
+	GetByWalletID(ctx context.Context, walletID, id int64) (*domain.Hold, error)
+	ListExpired(ctx context.Context, now time.Time, limit int) ([]domain.Hold, error)
+	Settle(ctx context.Context, id int64, status domain.HoldStatus, capturedAmount domain.Money) error
+}
+
+type FXRateRepository interface {
+	GetRate(ctx context.Context, base, quote string) (*domain.FXRate, error)
+	Upsert(ctx context.Context, rate domain.FXRate) (*domain.FXRate, error)
+}
+
+type FXQuoteRepository interface {
+	Create(ctx context.Context, quote domain.FXQuote) (*domain.FXQuote, error)
+	LockByID(ctx context.Context, userID int64, id string) (*domain.FXQuote, error)
+	GetByID(ctx context.Context, userID int64, id string) (*domain.FXQuote, error)
+	MarkExecuted(ctx context.Context, id string) error
+}
 
 type Repositories struct {
-	UserRepository   *UserRepository
-	WalletRepository *WalletRepository
-	LedgerRepository *LedgerRepository
-	TxProvider       *TxProvider
+	UserRepository    UserRepository
+	WalletRepository  WalletRepository
+	LedgerRepository  LedgerRepository
+	AccountRepository AccountRepository
+	JournalRepository JournalRepository
+	LimitRepository   LimitRepository
+	FeeRepository     FeeRepository
+	OutboxRepository  OutboxRepository
+	WebhookRepository WebhookRepository
+	HoldRepository    HoldRepository
+	FXRateRepository  FXRateRepository
+	FXQuoteRepository FXQuoteRepository
+	TxProvider        TxProvider
 }
 
 func New(db *sqlx.DB) Repositories {
 	return Repositories{
-		UserRepository:   NewUserRepository(db),
-		WalletRepository: NewWalletRepository(db),
-		LedgerRepository: NewLedgerRepository(db),
-		TxProvider:       NewTxProvider(db),
+		UserRepository:    NewUserRepository(db),
+		WalletRepository:  NewWalletRepository(db),
+		LedgerRepository:  NewLedgerRepository(db),
+		AccountRepository: NewAccountRepository(db),
+		JournalRepository: NewJournalRepository(db),
+		LimitRepository:   NewLimitRepository(db),
+		FeeRepository:     NewFeeRepository(db),
+		OutboxRepository:  NewOutboxRepository(db),
+		WebhookRepository: NewWebhookRepository(db),
+		HoldRepository:    NewHoldRepository(db),
+		FXRateRepository:  NewFXRateRepository(db),
+		FXQuoteRepository: NewFXQuoteRepository(db),
+		TxProvider:        NewTxProvider(db),
 	}
 }
@@ -0,0 +1,227 @@
+package repository_test
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/pressly/goose/v3"
+	"github.com/stretchr/testify/require"
+
+	"github.com/vcnt72/go-boilerplate/internal/database"
+	"github.com/vcnt72/go-boilerplate/internal/domain"
+	"github.com/vcnt72/go-boilerplate/internal/repository"
+)
+
+// newSQLite opens a migrated SQLite database in a temporary directory.
+func newSQLite(t *testing.T) repository.Repositories {
+	t.Helper()
+
+	db, err := sqlx.Open("sqlite3", database.SQLiteDSN(filepath.Join(t.TempDir(), "wallet.db")))
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+
+	provider, err := goose.NewProvider(goose.DialectSQLite3, db.DB, os.DirFS("../../migrations/sqlite"))
+	require.NoError(t, err)
+	_, err = provider.Up(context.Background())
+	require.NoError(t, err)
+
+	return repository.New(db)
+}
+
+func seedWallet(t *testing.T, r repository.Repositories, balance int64) *domain.Wallet {
+	t.Helper()
+
+	ctx := context.Background()
+	user, err := r.UserRepository.Create(ctx, domain.User{Name: "test"})
+	require.NoError(t, err)
+
+	wallet, err := r.WalletRepository.Create(ctx, domain.Wallet{UserID: user.ID, Balance: domain.NewMoney(balance, "IDR"), Currency: "IDR"})
+	require.NoError(t, err)
+	return wallet
+}
+
+func TestSQLite_LedgerCreate_ReportsConflict(t *testing.T) {
+	ctx := context.Background()
+	r := newSQLite(t)
+	wallet := seedWallet(t, r, 0)
+
+	ledger := domain.Ledger{WalletID: wallet.ID, IdempotencyKey: "k-1", Type: domain.LedgerTypeDeposit, Status: domain.LedgerStatusSucceed, Amount: domain.NewMoney(100, "IDR")}
+	_, err := r.LedgerRepository.Create(ctx, ledger)
+	require.NoError(t, err)
+
+	_, err = r.LedgerRepository.Create(ctx, ledger)
+	require.ErrorIs(t, err, domain.ErrLedgerConflict)
+}
+
+func TestSQLite_DecreaseBalance_SpendsOnlyAvailable(t *testing.T) {
+	ctx := context.Background()
+	r := newSQLite(t)
+	wallet := seedWallet(t, r, 1_000)
+
+	_, err := r.WalletRepository.Hold(ctx, wallet.ID, domain.NewMoney(600, "IDR"))
+	require.NoError(t, err)
+
+	_, err = r.WalletRepository.DecreaseBalance(ctx, domain.NewMoney(500, "IDR"), wallet.ID)
+	require.ErrorIs(t, err, domain.ErrInsufficientFund)
+
+	balance, err := r.WalletRepository.DecreaseBalance(ctx, domain.NewMoney(400, "IDR"), wallet.ID)
+	require.NoError(t, err)
+	require.Equal(t, int64(600), balance.Amount)
+}
+
+func TestSQLite_LedgerList_PagesByCursor(t *testing.T) {
+	ctx := context.Background()
+	r := newSQLite(t)
+	wallet := seedWallet(t, r, 0)
+
+	for _, key := range []string{"k-1", "k-2", "k-3"} {
+		_, err := r.LedgerRepository.Create(ctx, domain.Ledger{WalletID: wallet.ID, IdempotencyKey: key, Type: domain.LedgerTypeDeposit, Status: domain.LedgerStatusSucceed, Amount: domain.NewMoney(100, "IDR")})
+		require.NoError(t, err)
+	}
+
+	page, err := r.LedgerRepository.List(ctx, repository.LedgerFilter{WalletID: wallet.ID, Limit: 2})
+	require.NoError(t, err)
+	require.Len(t, page, 2)
+	require.Equal(t, "k-3", page[0].IdempotencyKey)
+
+	last := page[len(page)-1]
+	page, err = r.LedgerRepository.List(ctx, repository.LedgerFilter{WalletID: wallet.ID, After: &repository.LedgerCursor{CreatedAt: last.CreatedAt, ID: last.ID}, Limit: 2})
+	require.NoError(t, err)
+	require.Len(t, page, 1)
+	require.Equal(t, "k-1", page[0].IdempotencyKey)
+
+	from := last.CreatedAt
+	page, err = r.LedgerRepository.List(ctx, repository.LedgerFilter{WalletID: wallet.ID, From: &from, Limit: 10})
+	require.NoError(t, err)
+	require.NotEmpty(t, page)
+}
+
+func TestSQLite_Outbox_HoldsBackPartitionOnRetry(t *testing.T) {
+	ctx := context.Background()
+	r := newSQLite(t)
+
+	payload := json.RawMessage(`{"walletId":1}`)
+	first, err := r.OutboxRepository.Create(ctx, domain.OutboxEvent{Type: domain.OutboxEventWithdrawSucceeded, PartitionKey: "wallet:1", Payload: payload})
+	require.NoError(t, err)
+	_, err = r.OutboxRepository.Create(ctx, domain.OutboxEvent{Type: domain.OutboxEventWithdrawSucceeded, PartitionKey: "wallet:1", Payload: payload})
+	require.NoError(t, err)
+
+	err = r.TxProvider.Tx(ctx, func(ctx context.Context) error {
+		events, err := r.OutboxRepository.ClaimPending(ctx, 10, 0)
+		require.NoError(t, err)
+		require.Len(t, events, 2)
+		require.JSONEq(t, string(payload), string(events[0].Payload))
+
+		return r.OutboxRepository.MarkFailed(ctx, first.ID, "unavailable", time.Hour)
+	})
+	require.NoError(t, err)
+
+	events, err := r.OutboxRepository.ClaimPending(ctx, 10, 0)
+	require.NoError(t, err)
+	require.Empty(t, events)
+}
+
+func TestSQLite_Outbox_ClaimHoldsBackPartition(t *testing.T) {
+	ctx := context.Background()
+	r := newSQLite(t)
+
+	payload := json.RawMessage(`{"walletId":1}`)
+	first, err := r.OutboxRepository.Create(ctx, domain.OutboxEvent{Type: domain.OutboxEventWithdrawSucceeded, PartitionKey: "wallet:1", Payload: payload})
+	require.NoError(t, err)
+	second, err := r.OutboxRepository.Create(ctx, domain.OutboxEvent{Type: domain.OutboxEventWithdrawSucceeded, PartitionKey: "wallet:1", Payload: payload})
+	require.NoError(t, err)
+
+	events, err := r.OutboxRepository.ClaimPending(ctx, 1, time.Hour)
+	require.NoError(t, err)
+	require.Len(t, events, 1)
+	require.Equal(t, first.ID, events[0].ID)
+
+	// The claimed event holds back the rest of its partition.
+	events, err = r.OutboxRepository.ClaimPending(ctx, 10, time.Hour)
+	require.NoError(t, err)
+	require.Empty(t, events)
+
+	require.NoError(t, r.OutboxRepository.MarkPublished(ctx, first.ID))
+	events, err = r.OutboxRepository.ClaimPending(ctx, 10, time.Hour)
+	require.NoError(t, err)
+	require.Len(t, events, 1)
+	require.Equal(t, second.ID, events[0].ID)
+
+	require.NoError(t, r.OutboxRepository.Release(ctx, second.ID))
+	events, err = r.OutboxRepository.ClaimPending(ctx, 10, time.Hour)
+	require.NoError(t, err)
+	require.Len(t, events, 1)
+}
+
+func TestSQLite_Webhook_QueuesSubscribedEvents(t *testing.T) {
+	ctx := context.Background()
+	r := newSQLite(t)
+
+	sub, err := r.WebhookRepository.CreateSubscription(ctx, domain.WebhookSubscription{URL: "https://example.com/hook", EventTypes: domain.EventTypes{domain.OutboxEventWithdrawSucceeded}, Secret: "s", Active: true})
+	require.NoError(t, err)
+
+	succeeded, err := r.OutboxRepository.Create(ctx, domain.OutboxEvent{Type: domain.OutboxEventWithdrawSucceeded, PartitionKey: "wallet:1", Payload: json.RawMessage(`{}`)})
+	require.NoError(t, err)
+	failed, err := r.OutboxRepository.Create(ctx, domain.OutboxEvent{Type: domain.OutboxEventWithdrawFailed, PartitionKey: "wallet:1", Payload: json.RawMessage(`{}`)})
+	require.NoError(t, err)
+
+	n, err := r.WebhookRepository.CreateDeliveries(ctx, *succeeded)
+	require.NoError(t, err)
+	require.Equal(t, int64(1), n)
+
+	n, err = r.WebhookRepository.CreateDeliveries(ctx, *failed)
+	require.NoError(t, err)
+	require.Equal(t, int64(0), n)
+
+	due, err := r.WebhookRepository.ClaimDueDeliveries(ctx, 10, 0)
+	require.NoError(t, err)
+	require.Len(t, due, 1)
+	require.Equal(t, sub.URL, due[0].URL)
+
+	require.NoError(t, r.WebhookRepository.MarkRetry(ctx, due[0].ID, nil, "timeout", time.Hour))
+
+	due, err = r.WebhookRepository.ClaimDueDeliveries(ctx, 10, 0)
+	require.NoError(t, err)
+	require.Empty(t, due)
+
+	got, err := r.WebhookRepository.GetSubscription(ctx, sub.ID)
+	require.NoError(t, err)
+	require.Equal(t, sub.EventTypes, got.EventTypes)
+	require.True(t, got.Active)
+}
+
+func TestSQLite_FXRate_KeepsDigits(t *testing.T) {
+	ctx := context.Background()
+	r := newSQLite(t)
+
+	_, err := r.FXRateRepository.Upsert(ctx, domain.FXRate{BaseCurrency: "USD", QuoteCurrency: "IDR", Rate: "16000.123456789012"})
+	require.NoError(t, err)
+	_, err = r.FXRateRepository.Upsert(ctx, domain.FXRate{BaseCurrency: "USD", QuoteCurrency: "IDR", Rate: "16250.50", FeeBps: 25})
+	require.NoError(t, err)
+
+	rate, err := r.FXRateRepository.GetRate(ctx, "USD", "IDR")
+	require.NoError(t, err)
+	require.Equal(t, "16250.5", rate.Rate)
+	require.Equal(t, int64(25), rate.FeeBps)
+}
+
+func TestSQLite_Hold_ListExpired(t *testing.T) {
+	ctx := context.Background()
+	r := newSQLite(t)
+	wallet := seedWallet(t, r, 1_000)
+
+	expired, err := r.HoldRepository.Create(ctx, domain.Hold{WalletID: wallet.ID, Amount: domain.NewMoney(100, "IDR"), Status: domain.HoldStatusActive, ExpiresAt: time.Now().Add(-time.Minute)})
+	require.NoError(t, err)
+	_, err = r.HoldRepository.Create(ctx, domain.Hold{WalletID: wallet.ID, Amount: domain.NewMoney(100, "IDR"), Status: domain.HoldStatusActive, ExpiresAt: time.Now().Add(time.Hour)})
+	require.NoError(t, err)
+
+	holds, err := r.HoldRepository.ListExpired(ctx, time.Now(), 10)
+	require.NoError(t, err)
+	require.Len(t, holds, 1)
+	require.Equal(t, expired.ID, holds[0].ID)
+}
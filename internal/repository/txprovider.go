@@ -2,32 +2,251 @@ package repository
 
 import (
 	"context"
+	"database/sql"
+	"errors"
+	"math/rand/v2"
+	"strconv"
+	"sync"
+	"time"
 
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jmoiron/sqlx"
 )
 
-type TxProvider struct {
+// Postgres error codes of a transaction aborted so that another can proceed.
+// Running it again may succeed.
+const (
+	serializationFailure = "40001"
+	deadlockDetected     = "40P01"
+)
+
+// txRetryBase and txRetryMax bound the wait before a retry, which doubles with
+// every attempt. The wait itself is random up to the bound, so that the
+// transactions that collided do not collide again.
+const (
+	txRetryBase = 10 * time.Millisecond
+	txRetryMax  = 500 * time.Millisecond
+)
+
+// txKey is the context key of the transaction open on db.
+type txKey struct {
 	db *sqlx.DB
 }
 
-func (t TxProvider) Tx(ctx context.Context, txFunc func(sqlx.ExtContext) error) error {
-	tx, err := t.db.BeginTxx(ctx, nil)
+// activeTx is the transaction a context carries.
+type activeTx struct {
+	tx *sqlx.Tx
+	// savepoints counts the savepoints set so far, to name the next one.
+	savepoints int
+}
+
+// conn is what a repository runs its statements on: the transaction ctx
+// carries for db, or db itself.
+func conn(ctx context.Context, db *sqlx.DB) sqlx.ExtContext {
+	if active, ok := ctx.Value(txKey{db}).(*activeTx); ok {
+		return bind(active.tx)
+	}
+
+	return bind(db)
+}
+
+type txProvider struct {
+	db *sqlx.DB
+
+	mu    sync.Mutex
+	stats TxStats
+}
+
+func (t *txProvider) Tx(ctx context.Context, txFunc func(ctx context.Context) error) error {
+	return t.TxWithOptions(ctx, TxOptions{}, txFunc)
+}
+
+// TxWithOptions runs txFunc again, after a random wait, while the transaction
+// is aborted with a serialization failure or deadlock and opts.MaxRetries
+// allows. A savepoint is never retried on its own: the abort fails the
+// transaction it is set in, which is retried as a whole if its options allow.
+func (t *txProvider) TxWithOptions(ctx context.Context, opts TxOptions, txFunc func(ctx context.Context) error) error {
+	if active, ok := ctx.Value(txKey{t.db}).(*activeTx); ok {
+		t.count(func(s *TxStats) { s.Savepoints++ })
+		return active.savepoint(ctx, txFunc)
+	}
+
+	t.count(func(s *TxStats) { s.Transactions++ })
+
+	for attempt := 0; ; attempt++ {
+		err := t.run(ctx, opts, txFunc)
+
+		code := abortCode(err)
+		if code == "" {
+			return err
+		}
+
+		retry := attempt < opts.MaxRetries
+		t.count(func(s *TxStats) {
+			if code == deadlockDetected {
+				s.Deadlocks++
+			} else {
+				s.SerializationFailures++
+			}
+
+			switch {
+			case retry:
+				s.Retries++
+			case opts.MaxRetries > 0:
+				s.RetriesExhausted++
+			}
+		})
+		if !retry {
+			return err
+		}
+
+		timer := time.NewTimer(txRetryDelay(attempt))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return errors.Join(err, ctx.Err())
+		case <-timer.C:
+		}
+	}
+}
+
+// run rolls the transaction back when txFunc fails or panics, in which case
+// the panic goes on once the transaction is closed.
+func (t *txProvider) run(ctx context.Context, opts TxOptions, txFunc func(ctx context.Context) error) error {
+	tx, err := t.db.BeginTxx(ctx, t.txOptions(opts))
 	if err != nil {
 		return err
 	}
 
-	err = txFunc(tx)
+	defer func() {
+		if p := recover(); p != nil {
+			_ = tx.Rollback()
+			panic(p)
+		}
+	}()
+
+	err = txFunc(context.WithValue(ctx, txKey{t.db}, &activeTx{tx: tx}))
 	if err != nil {
 		if rollbackErr := tx.Rollback(); rollbackErr != nil {
-			return rollbackErr
+			return errors.Join(err, rollbackErr)
+		}
+
+		return err
+	}
+
+	ctxDone := ctx.Err() != nil
+	return commitError(t.db.DriverName(), tx.Commit(), ctxDone)
+}
+
+// commitError reports err, returned by a commit, as ErrCommitUnknown when the
+// transaction may have committed all the same: the commit was sent but
+// Postgres did not answer, so its outcome never arrived. An error from
+// Postgres, or sql.ErrTxDone for a commit never sent, means it rolled back.
+// So does a context error when ctxDone reports that the context ended before
+// the commit was called; one raised while the commit was in flight does not.
+// SQLite commits in process and always knows.
+func commitError(driver string, err error, ctxDone bool) error {
+	if err == nil || driver == sqliteDriver {
+		return err
+	}
+
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) || errors.Is(err, sql.ErrTxDone) {
+		return err
+	}
+
+	if ctxDone && (errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded)) {
+		return err
+	}
+
+	return errors.Join(ErrCommitUnknown, err)
+}
+
+// savepoint runs txFunc after a savepoint, rolling back to it when txFunc
+// fails or panics and releasing it otherwise.
+func (a *activeTx) savepoint(ctx context.Context, txFunc func(ctx context.Context) error) error {
+	a.savepoints++
+	name := "sp_" + strconv.Itoa(a.savepoints)
+
+	if _, err := a.tx.ExecContext(ctx, "SAVEPOINT "+name); err != nil {
+		return err
+	}
+
+	defer func() {
+		if p := recover(); p != nil {
+			_, _ = a.tx.ExecContext(ctx, "ROLLBACK TO SAVEPOINT "+name)
+			panic(p)
+		}
+	}()
+
+	err := txFunc(ctx)
+	if err != nil {
+		if _, rollbackErr := a.tx.ExecContext(ctx, "ROLLBACK TO SAVEPOINT "+name); rollbackErr != nil {
+			return errors.Join(err, rollbackErr)
 		}
 
 		return err
 	}
 
-	return tx.Commit()
+	_, err = a.tx.ExecContext(ctx, "RELEASE SAVEPOINT "+name)
+	return err
+}
+
+func (t *txProvider) Stats() TxStats {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return t.stats
+}
+
+func (t *txProvider) count(fn func(s *TxStats)) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	fn(&t.stats)
+}
+
+// abortCode returns the Postgres error code of err when it aborted the
+// transaction in favour of another one, or "" otherwise.
+func abortCode(err error) string {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && (pgErr.Code == serializationFailure || pgErr.Code == deadlockDetected) {
+		return pgErr.Code
+	}
+
+	return ""
+}
+
+// txRetryDelay is the random wait before retrying after the given attempt.
+func txRetryDelay(attempt int) time.Duration {
+	bound := txRetryMax
+	if attempt < 30 {
+		bound = min(txRetryBase<<attempt, txRetryMax)
+	}
+
+	return rand.N(bound) + 1
+}
+
+// txOptions translates opts for database/sql. SQLite takes none: its
+// transactions run one at a time anyway, see database.NewSQLite.
+func (t *txProvider) txOptions(opts TxOptions) *sql.TxOptions {
+	if t.db.DriverName() == sqliteDriver {
+		return nil
+	}
+
+	isolation := sql.LevelDefault
+	switch opts.Isolation {
+	case IsolationReadCommitted:
+		isolation = sql.LevelReadCommitted
+	case IsolationRepeatableRead:
+		isolation = sql.LevelRepeatableRead
+	case IsolationSerializable:
+		isolation = sql.LevelSerializable
+	}
+
+	return &sql.TxOptions{Isolation: isolation, ReadOnly: opts.ReadOnly}
 }
 
-func NewTxProvider(db *sqlx.DB) *TxProvider {
-	return &TxProvider{db: db}
+func NewTxProvider(db *sqlx.DB) TxProvider {
+	return &txProvider{db: db}
 }
@@ -0,0 +1,128 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"path/filepath"
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jmoiron/sqlx"
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCommitError(t *testing.T) {
+	lost := io.ErrUnexpectedEOF
+	require.NoError(t, commitError("pgx", nil, false))
+	require.ErrorIs(t, commitError("pgx", lost, false), ErrCommitUnknown)
+	require.ErrorIs(t, commitError("pgx", lost, false), lost)
+	require.NotErrorIs(t, commitError("pgx", &pgconn.PgError{Code: "23514"}, false), ErrCommitUnknown)
+	require.NotErrorIs(t, commitError("pgx", sql.ErrTxDone, false), ErrCommitUnknown)
+	require.NotErrorIs(t, commitError("pgx", context.Canceled, true), ErrCommitUnknown)
+	require.ErrorIs(t, commitError("pgx", context.Canceled, false), ErrCommitUnknown)
+	require.ErrorIs(t, commitError("pgx", context.DeadlineExceeded, false), ErrCommitUnknown)
+	require.NotErrorIs(t, commitError(sqliteDriver, lost, false), ErrCommitUnknown)
+}
+
+// slowCommitConnector connects to a database whose commits wait for the
+// context the transaction began with to end and then fail with its error, as
+// pgx does when the context ends while the commit is in flight.
+type slowCommitConnector struct {
+	committing chan struct{}
+}
+
+func (c slowCommitConnector) Connect(context.Context) (driver.Conn, error) {
+	return slowCommitConn(c), nil
+}
+
+func (c slowCommitConnector) Driver() driver.Driver {
+	return nil
+}
+
+type slowCommitConn slowCommitConnector
+
+func (c slowCommitConn) Prepare(string) (driver.Stmt, error) {
+	return nil, errors.New("not supported")
+}
+
+func (c slowCommitConn) Close() error {
+	return nil
+}
+
+func (c slowCommitConn) Begin() (driver.Tx, error) {
+	return c.BeginTx(context.Background(), driver.TxOptions{})
+}
+
+func (c slowCommitConn) BeginTx(ctx context.Context, _ driver.TxOptions) (driver.Tx, error) {
+	return slowCommitTx{ctx: ctx, committing: c.committing}, nil
+}
+
+type slowCommitTx struct {
+	ctx        context.Context
+	committing chan struct{}
+}
+
+func (t slowCommitTx) Commit() error {
+	close(t.committing)
+	<-t.ctx.Done()
+	return t.ctx.Err()
+}
+
+func (t slowCommitTx) Rollback() error {
+	return nil
+}
+
+func TestTx_CanceledDuringCommitIsUnknown(t *testing.T) {
+	committing := make(chan struct{})
+	db := sqlx.NewDb(sql.OpenDB(slowCommitConnector{committing: committing}), "pgx")
+	t.Cleanup(func() { db.Close() })
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		<-committing
+		cancel()
+	}()
+
+	err := NewTxProvider(db).Tx(ctx, func(ctx context.Context) error { return nil })
+	require.ErrorIs(t, err, ErrCommitUnknown)
+	require.ErrorIs(t, err, context.Canceled)
+}
+
+func TestTx_CanceledBeforeCommitRollsBack(t *testing.T) {
+	committing := make(chan struct{})
+	db := sqlx.NewDb(sql.OpenDB(slowCommitConnector{committing: committing}), "pgx")
+	t.Cleanup(func() { db.Close() })
+
+	ctx, cancel := context.WithCancel(context.Background())
+	err := NewTxProvider(db).Tx(ctx, func(ctx context.Context) error {
+		cancel()
+		return nil
+	})
+	require.ErrorIs(t, err, context.Canceled)
+	require.NotErrorIs(t, err, ErrCommitUnknown)
+
+	select {
+	case <-committing:
+		t.Fatal("commit was sent")
+	default:
+	}
+}
+
+func TestTx_JoinsRollbackError(t *testing.T) {
+	db, err := sqlx.Open(sqliteDriver, filepath.Join(t.TempDir(), "tx.db"))
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+
+	boom := errors.New("boom")
+	err = NewTxProvider(db).Tx(context.Background(), func(ctx context.Context) error {
+		// Ending the transaction early makes the rollback fail.
+		require.NoError(t, ctx.Value(txKey{db}).(*activeTx).tx.Rollback())
+		return boom
+	})
+	require.ErrorIs(t, err, boom)
+	require.ErrorIs(t, err, sql.ErrTxDone)
+}
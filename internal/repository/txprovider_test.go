@@ -0,0 +1,154 @@
+package repository_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/stretchr/testify/require"
+
+	"github.com/vcnt72/go-boilerplate/internal/domain"
+	"github.com/vcnt72/go-boilerplate/internal/repository"
+)
+
+func TestTxWithOptions_RetriesAbortedTransactions(t *testing.T) {
+	ctx := context.Background()
+	r := newSQLite(t)
+
+	codes := []string{"40001", "40P01"}
+	attempts := 0
+	err := r.TxProvider.TxWithOptions(ctx, repository.TxOptions{Isolation: repository.IsolationSerializable, MaxRetries: 3}, func(ctx context.Context) error {
+		attempts++
+		if attempts <= len(codes) {
+			return &pgconn.PgError{Code: codes[attempts-1]}
+		}
+		return nil
+	})
+	require.NoError(t, err)
+	require.Equal(t, 3, attempts)
+	require.Equal(t, repository.TxStats{Transactions: 1, SerializationFailures: 1, Deadlocks: 1, Retries: 2}, r.TxProvider.Stats())
+}
+
+func TestTxWithOptions_StopsAtMaxRetries(t *testing.T) {
+	ctx := context.Background()
+	r := newSQLite(t)
+
+	attempts := 0
+	err := r.TxProvider.TxWithOptions(ctx, repository.TxOptions{MaxRetries: 2}, func(ctx context.Context) error {
+		attempts++
+		return &pgconn.PgError{Code: "40001"}
+	})
+
+	var pgErr *pgconn.PgError
+	require.ErrorAs(t, err, &pgErr)
+	require.Equal(t, 3, attempts)
+	require.Equal(t, repository.TxStats{Transactions: 1, SerializationFailures: 3, Retries: 2, RetriesExhausted: 1}, r.TxProvider.Stats())
+}
+
+func TestTx_DoesNotRetryByDefault(t *testing.T) {
+	ctx := context.Background()
+	r := newSQLite(t)
+
+	attempts := 0
+	err := r.TxProvider.Tx(ctx, func(ctx context.Context) error {
+		attempts++
+		return &pgconn.PgError{Code: "40001"}
+	})
+	require.Error(t, err)
+	require.Equal(t, 1, attempts)
+	require.Equal(t, repository.TxStats{Transactions: 1, SerializationFailures: 1}, r.TxProvider.Stats())
+}
+
+func TestTxWithOptions_DoesNotRetryOtherErrors(t *testing.T) {
+	ctx := context.Background()
+	r := newSQLite(t)
+
+	failure := errors.New("failure")
+	attempts := 0
+	err := r.TxProvider.TxWithOptions(ctx, repository.TxOptions{MaxRetries: 3}, func(ctx context.Context) error {
+		attempts++
+		return failure
+	})
+	require.ErrorIs(t, err, failure)
+	require.Equal(t, 1, attempts)
+}
+
+func TestParseIsolationLevel(t *testing.T) {
+	level, err := repository.ParseIsolationLevel("serializable")
+	require.NoError(t, err)
+	require.Equal(t, repository.IsolationSerializable, level)
+
+	_, err = repository.ParseIsolationLevel("snapshot")
+	require.Error(t, err)
+}
+
+func TestTx_NestedTxIsSavepoint(t *testing.T) {
+	ctx := context.Background()
+	r := newSQLite(t)
+	wallet := seedWallet(t, r, 1_000)
+
+	boom := errors.New("boom")
+	err := r.TxProvider.Tx(ctx, func(ctx context.Context) error {
+		err := r.TxProvider.Tx(ctx, func(ctx context.Context) error {
+			_, err := r.WalletRepository.IncreaseBalance(ctx, domain.NewMoney(500, "IDR"), wallet.ID)
+			require.NoError(t, err)
+			return boom
+		})
+		require.ErrorIs(t, err, boom)
+
+		return r.TxProvider.Tx(ctx, func(ctx context.Context) error {
+			_, err := r.WalletRepository.DecreaseBalance(ctx, domain.NewMoney(400, "IDR"), wallet.ID)
+			return err
+		})
+	})
+	require.NoError(t, err)
+
+	got, err := r.WalletRepository.GetByID(ctx, wallet.ID)
+	require.NoError(t, err)
+	require.Equal(t, int64(600), got.Balance.Amount)
+	require.Equal(t, repository.TxStats{Transactions: 1, Savepoints: 2}, r.TxProvider.Stats())
+}
+
+func TestTx_RollsBackReleasedSavepoints(t *testing.T) {
+	ctx := context.Background()
+	r := newSQLite(t)
+	wallet := seedWallet(t, r, 1_000)
+
+	boom := errors.New("boom")
+	err := r.TxProvider.Tx(ctx, func(ctx context.Context) error {
+		err := r.TxProvider.Tx(ctx, func(ctx context.Context) error {
+			_, err := r.WalletRepository.IncreaseBalance(ctx, domain.NewMoney(500, "IDR"), wallet.ID)
+			return err
+		})
+		require.NoError(t, err)
+		return boom
+	})
+	require.ErrorIs(t, err, boom)
+
+	got, err := r.WalletRepository.GetByID(ctx, wallet.ID)
+	require.NoError(t, err)
+	require.Equal(t, int64(1_000), got.Balance.Amount)
+}
+
+func TestTx_RollsBackOnPanic(t *testing.T) {
+	ctx := context.Background()
+	r := newSQLite(t)
+	wallet := seedWallet(t, r, 1_000)
+
+	require.PanicsWithValue(t, "boom", func() {
+		_ = r.TxProvider.Tx(ctx, func(ctx context.Context) error {
+			_, err := r.WalletRepository.IncreaseBalance(ctx, domain.NewMoney(500, "IDR"), wallet.ID)
+			require.NoError(t, err)
+			panic("boom")
+		})
+	})
+
+	// The transaction no longer holds the write lock.
+	_, err := r.WalletRepository.DecreaseBalance(ctx, domain.NewMoney(400, "IDR"), wallet.ID)
+	require.NoError(t, err)
+
+	got, err := r.WalletRepository.GetByID(ctx, wallet.ID)
+	require.NoError(t, err)
+	require.Equal(t, int64(600), got.Balance.Amount)
+}
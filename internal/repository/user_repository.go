@@ -7,26 +7,18 @@ import (
 	"github.com/vcnt72/go-boilerplate/internal/domain"
 )
 
-type UserRepository struct {
-	db sqlx.ExtContext
+type userRepository struct {
+	db *sqlx.DB
 }
 
-func (t UserRepository) Create(ctx context.Context, spec domain.User) (*domain.User, error) {
-	var id int64
-	err := t.db.QueryRowxContext(ctx, "INSERT INTO users(name) VALUES($1) RETURNING id", spec.Name).Scan(&id)
-	spec.ID = id
+func (t userRepository) Create(ctx context.Context, spec domain.User) (*domain.User, error) {
+	err := conn(ctx, t.db).QueryRowxContext(ctx, "INSERT INTO users(name) VALUES($1) RETURNING id, tier", spec.Name).Scan(&spec.ID, &spec.Tier)
 
 	return &spec, err
 }
 
-func (t *UserRepository) WithTx(tx sqlx.ExtContext) *UserRepository {
-	return &UserRepository{
-		db: tx,
-	}
-}
-
-func NewUserRepository(db *sqlx.DB) *UserRepository {
-	return &UserRepository{
+func NewUserRepository(db *sqlx.DB) UserRepository {
+	return &userRepository{
 		db,
 	}
 }
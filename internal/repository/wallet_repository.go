@@ -9,21 +9,107 @@ import (
 	"github.com/vcnt72/go-boilerplate/internal/domain"
 )
 
-type WalletRepository struct {
-	db sqlx.ExtContext
+type walletRepository struct {
+	db *sqlx.DB
 }
 
-func (w WalletRepository) Create(ctx context.Context, spec domain.Wallet) (*domain.Wallet, error) {
+const walletColumns = "id, balance, held_balance, balance - held_balance AS available_balance, user_id, currency, currency_exponent, created_at, updated_at"
+
+// Create returns domain.ErrWalletExists when the user already has a wallet in
+// spec.Currency.
+func (w walletRepository) Create(ctx context.Context, spec domain.Wallet) (*domain.Wallet, error) {
 	var id int64
-	err := w.db.QueryRowxContext(ctx, "INSERT INTO wallets(user_id, balance) VALUES($1,$2) RETURNING id", spec.UserID, spec.Balance).Scan(&id)
+	err := conn(ctx, w.db).QueryRowxContext(ctx,
+		"INSERT INTO wallets(user_id, balance, currency, currency_exponent) VALUES($1,$2,$3,$4) ON CONFLICT DO NOTHING RETURNING id",
+		spec.UserID, spec.Balance, spec.Currency, spec.Exponent).Scan(&id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, domain.ErrWalletExists
+		}
+
+		return nil, err
+	}
+
 	spec.ID = id
-	return &spec, err
+	spec.HeldBalance = domain.NewMoney(0, spec.Currency)
+	spec.AvailableBalance = spec.Balance
+	spec.BindCurrency()
+	return &spec, nil
+}
+
+func (w walletRepository) GetByUserID(ctx context.Context, userID int64, currency string) (*domain.Wallet, error) {
+	var wallet domain.Wallet
+
+	err := conn(ctx, w.db).QueryRowxContext(ctx, "SELECT "+walletColumns+" FROM wallets WHERE user_id = $1 AND currency = $2", userID, currency).
+		StructScan(&wallet)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, domain.ErrWalletNotFound
+		}
+
+		return nil, err
+	}
+
+	wallet.BindCurrency()
+	return &wallet, nil
+}
+
+// ListByUserID returns the user's wallets, one per currency, in ID order.
+func (w walletRepository) ListByUserID(ctx context.Context, userID int64) ([]domain.Wallet, error) {
+	wallets := []domain.Wallet{}
+
+	err := sqlx.SelectContext(ctx, conn(ctx, w.db), &wallets, "SELECT "+walletColumns+" FROM wallets WHERE user_id = $1 ORDER BY id", userID)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range wallets {
+		wallets[i].BindCurrency()
+	}
+
+	return wallets, nil
+}
+
+func (w walletRepository) GetByID(ctx context.Context, id int64) (*domain.Wallet, error) {
+	var wallet domain.Wallet
+
+	err := conn(ctx, w.db).QueryRowxContext(ctx, "SELECT "+walletColumns+" FROM wallets WHERE id = $1", id).
+		StructScan(&wallet)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, domain.ErrWalletNotFound
+		}
+
+		return nil, err
+	}
+
+	wallet.BindCurrency()
+	return &wallet, nil
 }
 
-func (w WalletRepository) GetByUserID(ctx context.Context, userID int64) (*domain.Wallet, error) {
+// ListAfterID pages through wallets in ID order, returning at most limit
+// wallets whose ID is greater than afterID.
+func (w walletRepository) ListAfterID(ctx context.Context, afterID int64, limit int) ([]domain.Wallet, error) {
+	wallets := []domain.Wallet{}
+
+	err := sqlx.SelectContext(ctx, conn(ctx, w.db), &wallets, "SELECT "+walletColumns+" FROM wallets WHERE id > $1 ORDER BY id LIMIT $2", afterID, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range wallets {
+		wallets[i].BindCurrency()
+	}
+
+	return wallets, nil
+}
+
+// LockByID takes a row lock on the wallet until the surrounding transaction
+// ends. Callers locking several wallets must do so in ascending ID order.
+func (w walletRepository) LockByID(ctx context.Context, id int64) (*domain.Wallet, error) {
 	var wallet domain.Wallet
 
-	err := w.db.QueryRowxContext(ctx, "SELECT id, balance, user_id, created_at, updated_at FROM wallets WHERE user_id = $1", userID).
+	err := conn(ctx, w.db).QueryRowxContext(ctx, "SELECT "+walletColumns+" FROM wallets WHERE id = $1"+rowLock(w.db, "FOR UPDATE"), id).
 		StructScan(&wallet)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
@@ -33,37 +119,99 @@ func (w WalletRepository) GetByUserID(ctx context.Context, userID int64) (*domai
 		return nil, err
 	}
 
+	wallet.BindCurrency()
 	return &wallet, nil
 }
 
-func (w WalletRepository) DecreaseBalance(ctx context.Context, amount, userID int64) (int64, error) {
-	if amount <= 0 {
-		return 0, domain.ErrInvalidAmount
+// DecreaseBalance only spends the available balance, so held funds stay
+// reserved for their capture. amount must be in the wallet's currency; the
+// new balance is returned in it.
+func (w walletRepository) DecreaseBalance(ctx context.Context, amount domain.Money, walletID int64) (domain.Money, error) {
+	if !amount.IsPositive() {
+		return domain.Money{}, domain.ErrInvalidAmount
 	}
 
-	var b int64
-	err := w.db.QueryRowxContext(ctx,
-		"UPDATE wallets SET balance = balance - $1, updated_at = now() WHERE user_id = $2 AND balance >= $1 RETURNING balance", amount, userID).
+	b := domain.Money{Currency: amount.Currency}
+	err := conn(ctx, w.db).QueryRowxContext(ctx,
+		"UPDATE wallets SET balance = balance - $1, updated_at = now() WHERE id = $2 AND balance - held_balance >= $1 RETURNING balance", amount, walletID).
 		Scan(&b)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
-			return 0, domain.ErrInsufficientFund
+			return domain.Money{}, domain.ErrInsufficientFund
 		}
 
-		return 0, err
+		return domain.Money{}, err
 	}
 
 	return b, nil
 }
 
-func (w WalletRepository) WithTx(tx sqlx.ExtContext) *WalletRepository {
-	return &WalletRepository{
-		db: tx,
+// IncreaseBalance credits amount, which must be in the wallet's currency.
+func (w walletRepository) IncreaseBalance(ctx context.Context, amount domain.Money, walletID int64) (domain.Money, error) {
+	if !amount.IsPositive() {
+		return domain.Money{}, domain.ErrInvalidAmount
 	}
+
+	b := domain.Money{Currency: amount.Currency}
+	err := conn(ctx, w.db).QueryRowxContext(ctx,
+		"UPDATE wallets SET balance = balance + $1, updated_at = now() WHERE id = $2 RETURNING balance", amount, walletID).
+		Scan(&b)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return domain.Money{}, domain.ErrWalletNotFound
+		}
+
+		return domain.Money{}, err
+	}
+
+	return b, nil
+}
+
+// Hold reserves amount of the available balance. It returns the wallet after
+// the update, or domain.ErrInsufficientFund when not enough is available.
+func (w walletRepository) Hold(ctx context.Context, walletID int64, amount domain.Money) (*domain.Wallet, error) {
+	if !amount.IsPositive() {
+		return nil, domain.ErrInvalidAmount
+	}
+
+	var wallet domain.Wallet
+	err := conn(ctx, w.db).QueryRowxContext(ctx,
+		"UPDATE wallets SET held_balance = held_balance + $1, updated_at = now() WHERE id = $2 AND balance - held_balance >= $1 RETURNING "+walletColumns, amount, walletID).
+		StructScan(&wallet)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, domain.ErrInsufficientFund
+		}
+
+		return nil, err
+	}
+
+	wallet.BindCurrency()
+	return &wallet, nil
+}
+
+// SettleHold releases held from the wallet's held balance and debits spent
+// from its balance. spent may be zero when the hold is released without a
+// capture.
+func (w walletRepository) SettleHold(ctx context.Context, walletID int64, held, spent domain.Money) (*domain.Wallet, error) {
+	var wallet domain.Wallet
+	err := conn(ctx, w.db).QueryRowxContext(ctx,
+		"UPDATE wallets SET held_balance = held_balance - $1, balance = balance - $2, updated_at = now() WHERE id = $3 RETURNING "+walletColumns, held, spent, walletID).
+		StructScan(&wallet)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, domain.ErrWalletNotFound
+		}
+
+		return nil, err
+	}
+
+	wallet.BindCurrency()
+	return &wallet, nil
 }
 
-func NewWalletRepository(db *sqlx.DB) *WalletRepository {
-	return &WalletRepository{
+func NewWalletRepository(db *sqlx.DB) WalletRepository {
+	return &walletRepository{
 		db: db,
 	}
 }
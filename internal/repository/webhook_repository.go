@@ -0,0 +1,215 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/vcnt72/go-boilerplate/internal/domain"
+)
+
+type webhookRepository struct {
+	db *sqlx.DB
+}
+
+const webhookSubscriptionColumns = "id, url, event_types, secret, active, created_at, updated_at"
+
+func (w webhookRepository) deliveryColumns() string {
+	return "d.id, d.subscription_id, d.event_id, d.event_type, " + jsonColumn(w.db, "d.payload") + " AS payload, d.status, d.attempts, d.next_attempt_at, d.last_status_code, d.last_error, d.delivered_at, d.created_at, d.updated_at"
+}
+
+func (w webhookRepository) CreateSubscription(ctx context.Context, spec domain.WebhookSubscription) (*domain.WebhookSubscription, error) {
+	err := conn(ctx, w.db).QueryRowxContext(ctx,
+		"INSERT INTO webhook_subscriptions(url, event_types, secret, active) VALUES($1,$2::jsonb,$3,$4) RETURNING id, created_at, updated_at",
+		spec.URL, spec.EventTypes, spec.Secret, spec.Active).
+		Scan(&spec.ID, &spec.CreatedAt, &spec.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	return &spec, nil
+}
+
+func (w webhookRepository) GetSubscription(ctx context.Context, id int64) (*domain.WebhookSubscription, error) {
+	var sub domain.WebhookSubscription
+
+	err := conn(ctx, w.db).QueryRowxContext(ctx, "SELECT "+webhookSubscriptionColumns+" FROM webhook_subscriptions WHERE id = $1", id).
+		StructScan(&sub)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, domain.ErrWebhookNotFound
+		}
+
+		return nil, err
+	}
+
+	return &sub, nil
+}
+
+func (w webhookRepository) ListSubscriptions(ctx context.Context) ([]domain.WebhookSubscription, error) {
+	subs := []domain.WebhookSubscription{}
+
+	err := sqlx.SelectContext(ctx, conn(ctx, w.db), &subs, "SELECT "+webhookSubscriptionColumns+" FROM webhook_subscriptions ORDER BY id")
+	if err != nil {
+		return nil, err
+	}
+
+	return subs, nil
+}
+
+func (w webhookRepository) UpdateSubscription(ctx context.Context, spec domain.WebhookSubscription) (*domain.WebhookSubscription, error) {
+	err := conn(ctx, w.db).QueryRowxContext(ctx,
+		"UPDATE webhook_subscriptions SET url = $1, event_types = $2::jsonb, secret = $3, active = $4, updated_at = now() WHERE id = $5 RETURNING updated_at",
+		spec.URL, spec.EventTypes, spec.Secret, spec.Active, spec.ID).
+		Scan(&spec.UpdatedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, domain.ErrWebhookNotFound
+		}
+
+		return nil, err
+	}
+
+	return &spec, nil
+}
+
+// DeleteSubscription removes the subscription together with its deliveries.
+func (w webhookRepository) DeleteSubscription(ctx context.Context, id int64) error {
+	res, err := conn(ctx, w.db).ExecContext(ctx, "DELETE FROM webhook_subscriptions WHERE id = $1", id)
+	if err != nil {
+		return err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if n == 0 {
+		return domain.ErrWebhookNotFound
+	}
+
+	return nil
+}
+
+// CreateDeliveries queues the event for every active subscription to its
+// type. It returns the number of deliveries created.
+func (w webhookRepository) CreateDeliveries(ctx context.Context, event domain.OutboxEvent) (int64, error) {
+	res, err := conn(ctx, w.db).ExecContext(ctx, `
+		INSERT INTO webhook_deliveries(subscription_id, event_id, event_type, payload)
+		SELECT id, $1, $2, $3::jsonb
+		FROM webhook_subscriptions
+		WHERE active AND `+jsonArrayContains(w.db, "event_types", "$2")+`
+		ON CONFLICT (subscription_id, event_id) DO NOTHING`,
+		event.ID, event.Type, string(event.Payload))
+	if err != nil {
+		return 0, err
+	}
+
+	return res.RowsAffected()
+}
+
+type WebhookDeliveryFilter struct {
+	SubscriptionID int64
+	Status         string
+	BeforeID       int64
+	Limit          int
+}
+
+// ListDeliveries returns the deliveries of a subscription, newest first.
+func (w webhookRepository) ListDeliveries(ctx context.Context, filter WebhookDeliveryFilter) ([]domain.WebhookDelivery, error) {
+	deliveries := []domain.WebhookDelivery{}
+
+	err := sqlx.SelectContext(ctx, conn(ctx, w.db), &deliveries, `
+		SELECT `+w.deliveryColumns()+`
+		FROM webhook_deliveries d
+		WHERE d.subscription_id = $1
+		AND ($2 = '' OR d.status = $2)
+		AND ($3 = 0 OR d.id < $3)
+		ORDER BY d.id DESC
+		LIMIT $4`,
+		filter.SubscriptionID, filter.Status, filter.BeforeID, filter.Limit)
+	if err != nil {
+		return nil, err
+	}
+
+	return deliveries, nil
+}
+
+// DueWebhookDelivery is a pending delivery together with where and how to
+// send it.
+type DueWebhookDelivery struct {
+	domain.WebhookDelivery
+	URL    string `db:"url"`
+	Secret string `db:"secret"`
+}
+
+// ClaimDueDeliveries returns up to limit pending deliveries of active
+// subscriptions whose next attempt is due and postpones them by lease, so
+// that other dispatchers leave them alone once the surrounding transaction
+// commits. Rows locked by another dispatcher are skipped.
+func (w webhookRepository) ClaimDueDeliveries(ctx context.Context, limit int, lease time.Duration) ([]DueWebhookDelivery, error) {
+	deliveries := []DueWebhookDelivery{}
+
+	err := sqlx.SelectContext(ctx, conn(ctx, w.db), &deliveries, `
+		SELECT `+w.deliveryColumns()+`, s.url, s.secret
+		FROM webhook_deliveries d
+		JOIN webhook_subscriptions s ON s.id = d.subscription_id
+		WHERE d.status = $1 AND d.next_attempt_at <= now() AND s.active
+		ORDER BY d.next_attempt_at, d.id
+		LIMIT $2`+rowLock(w.db, "FOR UPDATE OF d SKIP LOCKED"),
+		domain.WebhookDeliveryStatusPending, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, d := range deliveries {
+		err := conn(ctx, w.db).QueryRowxContext(ctx,
+			"UPDATE webhook_deliveries SET next_attempt_at = "+nowPlusSeconds(w.db, "$1")+", updated_at = now() WHERE id = $2 RETURNING next_attempt_at",
+			lease.Seconds(), d.ID).
+			Scan(&deliveries[i].NextAttemptAt)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return deliveries, nil
+}
+
+// MarkDelivered records a successful attempt. Like MarkRetry and MarkDead,
+// it leaves a delivery that is no longer pending alone, in case another
+// dispatcher settled it after the lease of this one ran out.
+func (w webhookRepository) MarkDelivered(ctx context.Context, id int64, statusCode int) error {
+	_, err := conn(ctx, w.db).ExecContext(ctx,
+		"UPDATE webhook_deliveries SET status = $1, attempts = attempts + 1, last_status_code = $2, last_error = NULL, delivered_at = now(), updated_at = now() WHERE id = $3 AND status = $4",
+		domain.WebhookDeliveryStatusSucceeded, statusCode, id, domain.WebhookDeliveryStatusPending)
+
+	return err
+}
+
+// MarkRetry records a failed attempt and schedules the next one after
+// retryAfter.
+func (w webhookRepository) MarkRetry(ctx context.Context, id int64, statusCode *int, lastError string, retryAfter time.Duration) error {
+	_, err := conn(ctx, w.db).ExecContext(ctx,
+		"UPDATE webhook_deliveries SET attempts = attempts + 1, last_status_code = $1, last_error = $2, next_attempt_at = "+nowPlusSeconds(w.db, "$3")+", updated_at = now() WHERE id = $4 AND status = $5",
+		statusCode, lastError, retryAfter.Seconds(), id, domain.WebhookDeliveryStatusPending)
+
+	return err
+}
+
+// MarkDead records a failed attempt and gives up on the delivery.
+func (w webhookRepository) MarkDead(ctx context.Context, id int64, statusCode *int, lastError string) error {
+	_, err := conn(ctx, w.db).ExecContext(ctx,
+		"UPDATE webhook_deliveries SET status = $1, attempts = attempts + 1, last_status_code = $2, last_error = $3, updated_at = now() WHERE id = $4 AND status = $5",
+		domain.WebhookDeliveryStatusDead, statusCode, lastError, id, domain.WebhookDeliveryStatusPending)
+
+	return err
+}
+
+func NewWebhookRepository(db *sqlx.DB) WebhookRepository {
+	return &webhookRepository{
+		db: db,
+	}
+}
@@ -0,0 +1,178 @@
+package router
+
+import (
+	"crypto/rsa"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/vcnt72/go-boilerplate/internal/config"
+	"github.com/vcnt72/go-boilerplate/internal/handler"
+	"github.com/vcnt72/go-boilerplate/internal/utils/response"
+)
+
+const (
+	AuthModeJWT    = "jwt"
+	AuthModeHeader = "header"
+)
+
+type AuthOptions struct {
+	Mode      string
+	Algorithm string
+	Secret    []byte
+	PublicKey *rsa.PublicKey
+	Issuer    string
+	Audience  string
+}
+
+// AuthOptionsFromConfig builds the auth options from config.Env, loading the
+// RS256 public key from disk when needed.
+func AuthOptionsFromConfig() (AuthOptions, error) {
+	opts := AuthOptions{
+		Mode:      config.Env.AuthMode,
+		Algorithm: config.Env.JWTAlgorithm,
+		Secret:    []byte(config.Env.JWTSecret),
+		Issuer:    config.Env.JWTIssuer,
+		Audience:  config.Env.JWTAudience,
+	}
+
+	if opts.Mode == AuthModeHeader && config.Env.AppEnv == "production" {
+		return opts, errors.New("header auth mode is not allowed in production")
+	}
+
+	if opts.Mode == AuthModeJWT && opts.Algorithm == jwt.SigningMethodRS256.Alg() {
+		pem, err := os.ReadFile(config.Env.JWTPublicKeyFile)
+		if err != nil {
+			return opts, fmt.Errorf("read jwt public key: %w", err)
+		}
+
+		opts.PublicKey, err = jwt.ParseRSAPublicKeyFromPEM(pem)
+		if err != nil {
+			return opts, fmt.Errorf("parse jwt public key: %w", err)
+		}
+	}
+
+	return opts, nil
+}
+
+type authClaims struct {
+	jwt.RegisteredClaims
+	Role string `json:"role,omitempty"`
+}
+
+// NewAuthMiddleware authenticates the caller and stores its user ID and role
+// on the gin context under handler.ContextUserIDKey and
+// handler.ContextUserRoleKey. In JWT mode they are the token subject and its
+// "role" claim; in header mode they are taken from X-User-ID and X-User-Role
+// as-is.
+func NewAuthMiddleware(opts AuthOptions) (gin.HandlerFunc, error) {
+	switch opts.Mode {
+	case AuthModeHeader:
+		return func(ctx *gin.Context) {
+			userID, err := parseUserID(ctx.GetHeader("X-User-ID"))
+			if err != nil {
+				abortUnauthorized(ctx)
+				return
+			}
+
+			ctx.Set(handler.ContextUserIDKey, userID)
+			ctx.Set(handler.ContextUserRoleKey, ctx.GetHeader("X-User-Role"))
+			ctx.Next()
+		}, nil
+
+	case AuthModeJWT:
+	default:
+		return nil, fmt.Errorf("unknown auth mode %q", opts.Mode)
+	}
+
+	var key any
+	switch opts.Algorithm {
+	case jwt.SigningMethodHS256.Alg():
+		if len(opts.Secret) == 0 {
+			return nil, errors.New("jwt secret is required for HS256")
+		}
+		key = opts.Secret
+
+	case jwt.SigningMethodRS256.Alg():
+		if opts.PublicKey == nil {
+			return nil, errors.New("jwt public key is required for RS256")
+		}
+		key = opts.PublicKey
+
+	default:
+		return nil, fmt.Errorf("unsupported jwt algorithm %q", opts.Algorithm)
+	}
+
+	parserOpts := []jwt.ParserOption{
+		jwt.WithValidMethods([]string{opts.Algorithm}),
+		jwt.WithExpirationRequired(),
+	}
+	if opts.Issuer != "" {
+		parserOpts = append(parserOpts, jwt.WithIssuer(opts.Issuer))
+	}
+	if opts.Audience != "" {
+		parserOpts = append(parserOpts, jwt.WithAudience(opts.Audience))
+	}
+	parser := jwt.NewParser(parserOpts...)
+
+	return func(ctx *gin.Context) {
+		raw, ok := strings.CutPrefix(ctx.GetHeader("Authorization"), "Bearer ")
+		if !ok || raw == "" {
+			abortUnauthorized(ctx)
+			return
+		}
+
+		var claims authClaims
+		_, err := parser.ParseWithClaims(raw, &claims, func(*jwt.Token) (any, error) {
+			return key, nil
+		})
+		if err != nil {
+			abortUnauthorized(ctx)
+			return
+		}
+
+		userID, err := parseUserID(claims.Subject)
+		if err != nil {
+			abortUnauthorized(ctx)
+			return
+		}
+
+		ctx.Set(handler.ContextUserIDKey, userID)
+		ctx.Set(handler.ContextUserRoleKey, claims.Role)
+		ctx.Next()
+	}, nil
+}
+
+// RequireRole only lets through callers the auth middleware tagged with role.
+func RequireRole(role string) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		if ctx.GetString(handler.ContextUserRoleKey) != role {
+			ctx.AbortWithStatusJSON(http.StatusForbidden, response.Error(ctx, "FORBIDDEN", "insufficient permissions"))
+			return
+		}
+
+		ctx.Next()
+	}
+}
+
+func parseUserID(s string) (int64, error) {
+	id, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return 0, err
+	}
+
+	if id <= 0 {
+		return 0, errors.New("user id must be positive")
+	}
+
+	return id, nil
+}
+
+func abortUnauthorized(ctx *gin.Context) {
+	ctx.AbortWithStatusJSON(http.StatusUnauthorized, response.Error(ctx, "UNAUTHORIZED", "missing or invalid credentials"))
+}
@@ -0,0 +1,172 @@
+package router
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/require"
+
+	"github.com/vcnt72/go-boilerplate/internal/handler"
+)
+
+func newAuthTestEngine(t *testing.T, opts AuthOptions) *gin.Engine {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	auth, err := NewAuthMiddleware(opts)
+	require.NoError(t, err)
+
+	engine := gin.New()
+	engine.GET("/me", auth, func(ctx *gin.Context) {
+		ctx.String(http.StatusOK, "%d", ctx.GetInt64(handler.ContextUserIDKey))
+	})
+
+	return engine
+}
+
+func doAuthRequest(engine *gin.Engine, header, value string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, "/me", nil)
+	if header != "" {
+		req.Header.Set(header, value)
+	}
+
+	rec := httptest.NewRecorder()
+	engine.ServeHTTP(rec, req)
+
+	return rec
+}
+
+func signToken(t *testing.T, method jwt.SigningMethod, key any, claims jwt.RegisteredClaims) string {
+	t.Helper()
+
+	token, err := jwt.NewWithClaims(method, claims).SignedString(key)
+	require.NoError(t, err)
+
+	return token
+}
+
+func validClaims(userID int64) jwt.RegisteredClaims {
+	return jwt.RegisteredClaims{
+		Subject:   strconv.FormatInt(userID, 10),
+		Issuer:    "wallet-test",
+		ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+	}
+}
+
+func TestAuthMiddleware_HS256(t *testing.T) {
+	secret := []byte("s3cret")
+	engine := newAuthTestEngine(t, AuthOptions{
+		Mode:      AuthModeJWT,
+		Algorithm: "HS256",
+		Secret:    secret,
+		Issuer:    "wallet-test",
+	})
+
+	rec := doAuthRequest(engine, "Authorization", "Bearer "+signToken(t, jwt.SigningMethodHS256, secret, validClaims(42)))
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Equal(t, "42", rec.Body.String())
+
+	expired := validClaims(42)
+	expired.ExpiresAt = jwt.NewNumericDate(time.Now().Add(-time.Minute))
+	noExpiry := validClaims(42)
+	noExpiry.ExpiresAt = nil
+	otherIssuer := validClaims(42)
+	otherIssuer.Issuer = "someone-else"
+	badSubject := validClaims(42)
+	badSubject.Subject = "alice"
+
+	cases := map[string]string{
+		"wrong secret": signToken(t, jwt.SigningMethodHS256, []byte("other"), validClaims(42)),
+		"expired":      signToken(t, jwt.SigningMethodHS256, secret, expired),
+		"no expiry":    signToken(t, jwt.SigningMethodHS256, secret, noExpiry),
+		"wrong issuer": signToken(t, jwt.SigningMethodHS256, secret, otherIssuer),
+		"bad subject":  signToken(t, jwt.SigningMethodHS256, secret, badSubject),
+		"alg none":     signToken(t, jwt.SigningMethodNone, jwt.UnsafeAllowNoneSignatureType, validClaims(42)),
+		"garbage":      "not-a-token",
+	}
+	for name, token := range cases {
+		t.Run(name, func(t *testing.T) {
+			rec := doAuthRequest(engine, "Authorization", "Bearer "+token)
+			require.Equal(t, http.StatusUnauthorized, rec.Code)
+		})
+	}
+
+	require.Equal(t, http.StatusUnauthorized, doAuthRequest(engine, "", "").Code)
+	require.Equal(t, http.StatusUnauthorized, doAuthRequest(engine, "X-User-ID", "42").Code)
+}
+
+func TestAuthMiddleware_RS256(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	engine := newAuthTestEngine(t, AuthOptions{
+		Mode:      AuthModeJWT,
+		Algorithm: "RS256",
+		PublicKey: &key.PublicKey,
+	})
+
+	rec := doAuthRequest(engine, "Authorization", "Bearer "+signToken(t, jwt.SigningMethodRS256, key, validClaims(7)))
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Equal(t, "7", rec.Body.String())
+
+	// A token signed with HS256 must not be accepted by an RS256 verifier.
+	hs := signToken(t, jwt.SigningMethodHS256, []byte("whatever"), validClaims(7))
+	require.Equal(t, http.StatusUnauthorized, doAuthRequest(engine, "Authorization", "Bearer "+hs).Code)
+}
+
+func TestAuthMiddleware_HeaderMode(t *testing.T) {
+	engine := newAuthTestEngine(t, AuthOptions{Mode: AuthModeHeader})
+
+	rec := doAuthRequest(engine, "X-User-ID", "5")
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Equal(t, "5", rec.Body.String())
+
+	require.Equal(t, http.StatusUnauthorized, doAuthRequest(engine, "X-User-ID", "abc").Code)
+	require.Equal(t, http.StatusUnauthorized, doAuthRequest(engine, "X-User-ID", "-1").Code)
+	require.Equal(t, http.StatusUnauthorized, doAuthRequest(engine, "", "").Code)
+}
+
+func TestRequireRole(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	secret := []byte("s3cret")
+
+	auth, err := NewAuthMiddleware(AuthOptions{Mode: AuthModeJWT, Algorithm: "HS256", Secret: secret})
+	require.NoError(t, err)
+
+	engine := gin.New()
+	engine.GET("/me", auth, RequireRole("admin"), func(ctx *gin.Context) {
+		ctx.Status(http.StatusNoContent)
+	})
+
+	sign := func(role string) string {
+		token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, authClaims{
+			RegisteredClaims: validClaims(1),
+			Role:             role,
+		}).SignedString(secret)
+		require.NoError(t, err)
+		return "Bearer " + token
+	}
+
+	require.Equal(t, http.StatusNoContent, doAuthRequest(engine, "Authorization", sign("admin")).Code)
+	require.Equal(t, http.StatusForbidden, doAuthRequest(engine, "Authorization", sign("")).Code)
+	require.Equal(t, http.StatusForbidden, doAuthRequest(engine, "Authorization", sign("partner")).Code)
+	require.Equal(t, http.StatusUnauthorized, doAuthRequest(engine, "", "").Code)
+}
+
+func TestNewAuthMiddleware_RejectsMisconfiguration(t *testing.T) {
+	_, err := NewAuthMiddleware(AuthOptions{Mode: AuthModeJWT, Algorithm: "HS256"})
+	require.Error(t, err)
+
+	_, err = NewAuthMiddleware(AuthOptions{Mode: AuthModeJWT, Algorithm: "RS256"})
+	require.Error(t, err)
+
+	_, err = NewAuthMiddleware(AuthOptions{Mode: "basic"})
+	require.Error(t, err)
+}
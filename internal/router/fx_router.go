@@ -0,0 +1,14 @@
+package router
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/vcnt72/go-boilerplate/internal/handler"
+)
+
+func NewFXRouter(router *gin.Engine, fxHandler *handler.FXHandler, auth, admin gin.HandlerFunc) {
+	v1 := router.Group("v1/fx", auth)
+
+	v1.POST("quotes", fxHandler.CreateQuote())
+	v1.POST("quotes/:id/execute", fxHandler.ExecuteQuote())
+	v1.PUT("rates", admin, fxHandler.SetRate())
+}
@@ -0,0 +1,61 @@
+package router
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/vcnt72/go-boilerplate/internal/config"
+	"github.com/vcnt72/go-boilerplate/internal/payout"
+	"github.com/vcnt72/go-boilerplate/internal/webhook"
+)
+
+// PayoutSignatureHeader carries the payout provider's signature in the same
+// "t=<unix seconds>,v1=<hex HMAC-SHA256>" format as outgoing webhooks.
+const PayoutSignatureHeader = payout.CallbackSignatureHeader
+
+type PayoutSignatureOptions struct {
+	Secret    string
+	Tolerance time.Duration
+	// Production refuses to run without a secret.
+	Production bool
+}
+
+func PayoutSignatureOptionsFromConfig() PayoutSignatureOptions {
+	return PayoutSignatureOptions{
+		Secret:     config.Env.PayoutCallbackSecret,
+		Tolerance:  config.Env.PayoutCallbackTolerance,
+		Production: config.Env.AppEnv == "production",
+	}
+}
+
+// NewPayoutSignatureMiddleware rejects requests whose PayoutSignatureHeader
+// does not match the body. Without a secret every request passes, which is
+// only allowed outside production.
+func NewPayoutSignatureMiddleware(opts PayoutSignatureOptions) (gin.HandlerFunc, error) {
+	if opts.Secret == "" {
+		if opts.Production {
+			return nil, errors.New("payout callback secret is required in production")
+		}
+
+		return func(ctx *gin.Context) { ctx.Next() }, nil
+	}
+
+	return func(ctx *gin.Context) {
+		body, err := io.ReadAll(ctx.Request.Body)
+		if err != nil {
+			abortUnauthorized(ctx)
+			return
+		}
+
+		if err := webhook.Verify(opts.Secret, ctx.GetHeader(PayoutSignatureHeader), body, opts.Tolerance, time.Now()); err != nil {
+			abortUnauthorized(ctx)
+			return
+		}
+
+		ctx.Request.Body = io.NopCloser(bytes.NewReader(body))
+		ctx.Next()
+	}, nil
+}
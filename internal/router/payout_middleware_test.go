@@ -0,0 +1,81 @@
+package router
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+
+	"github.com/vcnt72/go-boilerplate/internal/webhook"
+)
+
+func newPayoutSignatureTestEngine(t *testing.T, opts PayoutSignatureOptions) *gin.Engine {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	signature, err := NewPayoutSignatureMiddleware(opts)
+	require.NoError(t, err)
+
+	engine := gin.New()
+	engine.POST("/callback", signature, func(ctx *gin.Context) {
+		body, _ := io.ReadAll(ctx.Request.Body)
+		ctx.String(http.StatusOK, "%s", body)
+	})
+
+	return engine
+}
+
+func doPayoutCallback(engine *gin.Engine, signature, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/callback", strings.NewReader(body))
+	if signature != "" {
+		req.Header.Set(PayoutSignatureHeader, signature)
+	}
+
+	rec := httptest.NewRecorder()
+	engine.ServeHTTP(rec, req)
+
+	return rec
+}
+
+func TestPayoutSignature_Valid(t *testing.T) {
+	engine := newPayoutSignatureTestEngine(t, PayoutSignatureOptions{Secret: "s3cret", Tolerance: time.Minute})
+	body := `{"reference":"ref-1","status":"SUCCEEDED"}`
+
+	rec := doPayoutCallback(engine, webhook.Sign("s3cret", time.Now(), []byte(body)), body)
+	require.Equal(t, http.StatusOK, rec.Code)
+	// The handler still reads the body the middleware verified.
+	require.Equal(t, body, rec.Body.String())
+}
+
+func TestPayoutSignature_Rejected(t *testing.T) {
+	engine := newPayoutSignatureTestEngine(t, PayoutSignatureOptions{Secret: "s3cret", Tolerance: time.Minute})
+	body := `{"reference":"ref-1","status":"SUCCEEDED"}`
+
+	cases := map[string]string{
+		"missing":   "",
+		"wrong key": webhook.Sign("other", time.Now(), []byte(body)),
+		"tampered":  webhook.Sign("s3cret", time.Now(), []byte(`{"reference":"ref-1","status":"FAILED"}`)),
+		"stale":     webhook.Sign("s3cret", time.Now().Add(-time.Hour), []byte(body)),
+		"malformed": "v1=abc",
+	}
+
+	for name, signature := range cases {
+		rec := doPayoutCallback(engine, signature, body)
+		require.Equal(t, http.StatusUnauthorized, rec.Code, name)
+	}
+}
+
+func TestPayoutSignature_NoSecret(t *testing.T) {
+	engine := newPayoutSignatureTestEngine(t, PayoutSignatureOptions{})
+
+	rec := doPayoutCallback(engine, "", `{}`)
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	_, err := NewPayoutSignatureMiddleware(PayoutSignatureOptions{Production: true})
+	require.Error(t, err)
+}
@@ -0,0 +1,14 @@
+package router
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/vcnt72/go-boilerplate/internal/handler"
+)
+
+// NewPayoutRouter serves the payout provider, which authenticates by signing
+// its requests rather than as a user.
+func NewPayoutRouter(router *gin.Engine, payoutHandler *handler.PayoutHandler, signature gin.HandlerFunc) {
+	v1 := router.Group("v1/payouts", signature)
+
+	v1.POST("callback", payoutHandler.Callback())
+}
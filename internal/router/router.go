@@ -1,11 +1,36 @@
 package router
 
 import (
+	"log"
+
 	"github.com/gin-gonic/gin"
+	"github.com/vcnt72/go-boilerplate/internal/domain"
 	"github.com/vcnt72/go-boilerplate/internal/handler"
 )
 
 func New(router *gin.Engine, handlers handler.Handlers) {
+	authOpts, err := AuthOptionsFromConfig()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	auth, err := NewAuthMiddleware(authOpts)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	admin := RequireRole(domain.UserRoleAdmin)
+
+	payoutSignature, err := NewPayoutSignatureMiddleware(PayoutSignatureOptionsFromConfig())
+	if err != nil {
+		log.Fatal(err)
+	}
+
 	NewUserRouter(router, handlers.UserHandler)
-	NewWalletRouter(router, handlers.WalletHandler)
+	NewWalletRouter(router, handlers.WalletHandler, auth, admin)
+	NewWorkerRouter(router, handlers.WorkerHandler, auth, admin)
+	NewWebhookRouter(router, handlers.WebhookHandler, auth, admin)
+	NewFXRouter(router, handlers.FXHandler, auth, admin)
+	NewPayoutRouter(router, handlers.PayoutHandler, payoutSignature)
+	NewTxRouter(router, handlers.TxHandler, auth, admin)
 }
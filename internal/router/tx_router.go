@@ -0,0 +1,12 @@
+package router
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/vcnt72/go-boilerplate/internal/handler"
+)
+
+func NewTxRouter(router *gin.Engine, txHandler *handler.TxHandler, auth, admin gin.HandlerFunc) {
+	v1 := router.Group("v1/admin", auth, admin)
+
+	v1.GET("transactions", txHandler.Stats())
+}
@@ -6,9 +6,19 @@ import (
 	"github.com/vcnt72/go-boilerplate/internal/handler"
 )
 
-func NewWalletRouter(router *gin.Engine, walletHandler *handler.WalletHandler) {
-	v1 := router.Group("v1")
+func NewWalletRouter(router *gin.Engine, walletHandler *handler.WalletHandler, auth, admin gin.HandlerFunc) {
+	v1 := router.Group("v1", auth)
 
+	v1.GET("wallets", walletHandler.ListWallets())
+	v1.POST("wallets", walletHandler.OpenWallet())
 	v1.GET("wallets/balance", walletHandler.GetBalance())
+	v1.GET("wallets/transactions", walletHandler.ListTransactions())
 	v1.POST("wallets/withdraw", walletHandler.Withdraw())
+	v1.POST("wallets/withdraw/preview", walletHandler.PreviewWithdraw())
+	v1.POST("wallets/deposit", walletHandler.Deposit())
+	v1.POST("wallets/transfer", walletHandler.Transfer())
+	v1.POST("wallets/holds", walletHandler.Authorize())
+	v1.POST("wallets/holds/:id/capture", walletHandler.Capture())
+	v1.POST("wallets/holds/:id/void", walletHandler.Void())
+	v1.POST("wallets/withdrawals/:idempotencyKey/reverse", admin, walletHandler.ReverseWithdrawal())
 }
@@ -0,0 +1,17 @@
+package router
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/vcnt72/go-boilerplate/internal/handler"
+)
+
+func NewWebhookRouter(router *gin.Engine, webhookHandler *handler.WebhookHandler, auth, admin gin.HandlerFunc) {
+	v1 := router.Group("v1/webhooks", auth, admin)
+
+	v1.POST("", webhookHandler.Create())
+	v1.GET("", webhookHandler.List())
+	v1.GET(":id", webhookHandler.Get())
+	v1.PATCH(":id", webhookHandler.Update())
+	v1.DELETE(":id", webhookHandler.Delete())
+	v1.GET(":id/deliveries", webhookHandler.ListDeliveries())
+}
@@ -0,0 +1,15 @@
+// Package router
+package router
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/vcnt72/go-boilerplate/internal/handler"
+)
+
+func NewWorkerRouter(router *gin.Engine, workerHandler *handler.WorkerHandler, auth, admin gin.HandlerFunc) {
+	v1 := router.Group("v1/admin/workers", auth, admin)
+
+	v1.GET("sweeper", workerHandler.SweeperStats())
+	v1.GET("outbox-relay", workerHandler.OutboxRelayStats())
+	v1.GET("webhook-dispatcher", workerHandler.WebhookDispatcherStats())
+}
@@ -0,0 +1,117 @@
+package service_test
+
+import (
+	"context"
+	"errors"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/vcnt72/go-boilerplate/internal/domain"
+	"github.com/vcnt72/go-boilerplate/internal/service"
+)
+
+func TestIntegration_Currency_OneWalletPerCurrency(t *testing.T) {
+	cleanDB(t)
+
+	ctx := context.Background()
+	svc := newWalletService()
+
+	seedUser(t, 1)
+	seedWallet(t, 1, 100_000)
+
+	usd, err := svc.OpenWallet(ctx, service.OpenWalletSpec{UserID: 1, Currency: "usd"})
+	require.NoError(t, err)
+	require.Equal(t, "USD", usd.Currency)
+	require.Equal(t, 2, usd.Exponent)
+
+	_, err = svc.OpenWallet(ctx, service.OpenWalletSpec{UserID: 1, Currency: "USD"})
+	require.True(t, errors.Is(err, domain.ErrWalletExists))
+
+	_, err = svc.OpenWallet(ctx, service.OpenWalletSpec{UserID: 1, Currency: "XXX"})
+	require.True(t, errors.Is(err, domain.ErrUnsupportedCurrency))
+
+	deposit, err := svc.Deposit(ctx, service.DepositWalletSpec{UserID: 1, Amount: domain.NewMoney(2_500, "USD"), IdempotencyKey: "k-usd-deposit"})
+	require.NoError(t, err)
+	require.Equal(t, "USD", deposit.Amount.Currency)
+	require.Equal(t, domain.NewMoney(2_500, "USD"), deposit.Balance)
+
+	withdraw, err := svc.Withdraw(ctx, service.WithdrawWalletSpec{UserID: 1, Amount: domain.NewMoney(1_000, "USD"), IdempotencyKey: "k-usd-withdraw"})
+	require.NoError(t, err)
+	require.Equal(t, domain.NewMoney(1_500, "USD"), withdraw.Balance)
+
+	require.Equal(t, int64(1_500), getCurrencyBalance(t, 1, "USD"))
+	require.Equal(t, int64(100_000), getCurrencyBalance(t, 1, "IDR"))
+
+	wallets, err := svc.ListWallets(ctx, 1)
+	require.NoError(t, err)
+	require.Len(t, wallets, 2)
+
+	// Each currency books against its own system accounts.
+	var cashIn int64
+	err = testDB.QueryRowx(`
+		SELECT COALESCE(SUM(p.amount), 0) FROM postings p
+		JOIN accounts a ON a.id = p.account_id
+		WHERE a.code = $1
+	`, domain.SystemAccountCode(domain.SystemAccountCashIn, "USD")).Scan(&cashIn)
+	require.NoError(t, err)
+	require.Equal(t, int64(-2_500), cashIn)
+
+	summary, err := newReconcileService().Reconcile(ctx, service.ReconcileSpec{})
+	require.NoError(t, err)
+	require.Equal(t, int64(0), summary.Drifted)
+}
+
+func TestIntegration_Currency_Mismatch(t *testing.T) {
+	cleanDB(t)
+
+	ctx := context.Background()
+	svc := newWalletService()
+
+	seedUser(t, 1)
+	seedWallet(t, 1, 100_000)
+	seedUser(t, 2)
+	seedCurrencyWallet(t, 2, "USD", 10_000)
+
+	_, err := svc.Withdraw(ctx, service.WithdrawWalletSpec{UserID: 1, Amount: domain.NewMoney(1_000, "USD"), IdempotencyKey: "k-1"})
+	require.True(t, errors.Is(err, domain.ErrCurrencyMismatch))
+
+	_, err = svc.Deposit(ctx, service.DepositWalletSpec{UserID: 1, Amount: domain.NewMoney(1_000, "EUR"), IdempotencyKey: "k-2"})
+	require.True(t, errors.Is(err, domain.ErrCurrencyMismatch))
+
+	_, err = svc.Withdraw(ctx, service.WithdrawWalletSpec{UserID: 1, Amount: domain.Money{Amount: 1_000}, IdempotencyKey: "k-3"})
+	require.True(t, errors.Is(err, domain.ErrUnsupportedCurrency))
+
+	_, err = svc.Deposit(ctx, service.DepositWalletSpec{UserID: 1, Amount: domain.NewMoney(1_000, "ABC"), IdempotencyKey: "k-4"})
+	require.True(t, errors.Is(err, domain.ErrUnsupportedCurrency))
+
+	// A user without any wallet is still reported as such.
+	seedUser(t, 3)
+	_, err = svc.Deposit(ctx, service.DepositWalletSpec{UserID: 3, Amount: idr(1_000), IdempotencyKey: "k-5"})
+	require.True(t, errors.Is(err, domain.ErrWalletNotFound))
+
+	// Transfers need a wallet in the same currency on both sides.
+	_, err = svc.Transfer(ctx, service.TransferWalletSpec{FromUserID: 2, ToUserID: 1, Amount: domain.NewMoney(1_000, "USD"), IdempotencyKey: "k-6"})
+	require.True(t, errors.Is(err, domain.ErrCurrencyMismatch))
+
+	require.Equal(t, int64(100_000), getBalance(t, 1))
+	require.Equal(t, int64(10_000), getCurrencyBalance(t, 2, "USD"))
+	require.Equal(t, 0, countLedgers(t, "k-1"))
+}
+
+func TestIntegration_Currency_DepositOverflow(t *testing.T) {
+	cleanDB(t)
+
+	ctx := context.Background()
+	svc := newWalletService()
+
+	seedUser(t, 1)
+	seedWallet(t, 1, math.MaxInt64-1_000)
+
+	_, err := svc.Deposit(ctx, service.DepositWalletSpec{UserID: 1, Amount: idr(1_001), IdempotencyKey: "k-overflow"})
+	require.True(t, errors.Is(err, domain.ErrMoneyOverflow))
+
+	require.Equal(t, int64(math.MaxInt64-1_000), getBalance(t, 1))
+	require.Equal(t, 0, countLedgers(t, "k-overflow"))
+}
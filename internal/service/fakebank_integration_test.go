@@ -0,0 +1,303 @@
+package service_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/vcnt72/go-boilerplate/internal/domain"
+	"github.com/vcnt72/go-boilerplate/internal/fakebank"
+	"github.com/vcnt72/go-boilerplate/internal/payout"
+	"github.com/vcnt72/go-boilerplate/internal/service"
+)
+
+const bankClientTimeout = 300 * time.Millisecond
+
+type bankHarness struct {
+	bank    *fakebank.Bank
+	wallets *service.WalletService
+	payouts *service.PayoutService
+}
+
+// startBank wires WalletService to a fake bank over HTTP. With async set the
+// bank reports outcomes to a receiver that completes payouts like the
+// callback endpoint does.
+func startBank(t *testing.T, opts fakebank.Options, async bool) *bankHarness {
+	t.Helper()
+
+	var h bankHarness
+	if async {
+		receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var cb payout.Callback
+			if err := json.NewDecoder(r.Body).Decode(&cb); err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+
+			if _, err := h.payouts.Complete(r.Context(), service.CompletePayoutSpec{Reference: cb.Reference, Status: cb.Status}); err != nil {
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+		}))
+		t.Cleanup(receiver.Close)
+		opts.CallbackURL = receiver.URL
+	}
+
+	h.bank = fakebank.New(opts)
+	h.bank.Start()
+	t.Cleanup(h.bank.Close)
+
+	provider := payout.NewHTTPProvider(nil, h.bank.URL(), bankClientTimeout)
+	h.wallets = newWalletServiceWithProvider(domain.WithdrawalLimit{}, domain.FeeSchedule{Flat: 100}, provider)
+	h.payouts = newPayoutService(provider)
+
+	return &h
+}
+
+// settle heals the bank and resends pending payouts until none is left.
+func (h *bankHarness) settle(t *testing.T) {
+	t.Helper()
+
+	h.bank.Configure(fakebank.Options{})
+	for range 10 {
+		res, err := h.payouts.ResendStale(context.Background(), service.ResendStaleSpec{Limit: 100})
+		require.NoError(t, err)
+		if res.Found == 0 {
+			break
+		}
+	}
+	h.bank.Close()
+
+	require.Equal(t, 0, countPendingPayouts(t))
+}
+
+func countPendingPayouts(t *testing.T) int {
+	var c int
+	err := testDB.QueryRowx(`
+		SELECT COUNT(1) FROM ledgers WHERE status IN ($1, $2) AND payout_reference IS NOT NULL
+	`, domain.LedgerStatusProcessing, domain.LedgerStatusSent).Scan(&c)
+	require.NoError(t, err)
+	return c
+}
+
+// requireBankConsistent checks that every withdrawal ended as the bank says
+// its payout did and that the books balance.
+func requireBankConsistent(t *testing.T, bank *fakebank.Bank) {
+	t.Helper()
+
+	type row struct {
+		Reference string              `db:"payout_reference"`
+		Status    domain.LedgerStatus `db:"status"`
+		Amount    int64               `db:"amount"`
+	}
+	rows := []row{}
+	err := testDB.Select(&rows, `
+		SELECT payout_reference, status, amount FROM ledgers
+		WHERE type = $1 AND payout_reference IS NOT NULL AND error_code IS DISTINCT FROM $2 AND error_code IS DISTINCT FROM $3
+	`, domain.LedgerTypeWithdraw, domain.LedgerErrorCodeInsufficientFund, domain.LedgerErrorCodeLimitExceeded)
+	require.NoError(t, err)
+
+	payouts := bank.Payouts()
+	require.Len(t, payouts, len(rows))
+
+	for _, r := range rows {
+		p, ok := payouts[r.Reference]
+		require.True(t, ok, r.Reference)
+		require.Equal(t, r.Amount, p.Request.Amount)
+
+		switch p.Status {
+		case domain.PayoutStatusSucceeded:
+			require.Equal(t, domain.LedgerStatusSucceed, r.Status, r.Reference)
+		case domain.PayoutStatusFailed:
+			require.Equal(t, domain.LedgerStatusFailed, r.Status, r.Reference)
+		}
+	}
+
+	requireNoDrift(t)
+}
+
+func TestIntegration_FakeBank_Delay(t *testing.T) {
+	cleanDB(t)
+
+	ctx := context.Background()
+	h := startBank(t, fakebank.Options{Delay: bankClientTimeout / 3}, false)
+
+	seedUser(t, 1)
+	seedWallet(t, 1, 100_000)
+
+	res, err := h.wallets.Withdraw(ctx, service.WithdrawWalletSpec{UserID: 1, Amount: idr(10_000), IdempotencyKey: "k-delay"})
+	require.NoError(t, err)
+	require.Equal(t, domain.LedgerStatusSucceed, res.Status)
+	require.Equal(t, int64(89_900), getBalance(t, 1))
+
+	h.settle(t)
+	requireBankConsistent(t, h.bank)
+}
+
+func TestIntegration_FakeBank_TimeoutAfterPayoutTaken(t *testing.T) {
+	cleanDB(t)
+
+	ctx := context.Background()
+	h := startBank(t, fakebank.Options{TimeoutRate: 1}, false)
+
+	seedUser(t, 1)
+	seedWallet(t, 1, 100_000)
+
+	// The bank paid out but the answer was lost: the withdrawal stays
+	// accepted with its funds reserved.
+	res, err := h.wallets.Withdraw(ctx, service.WithdrawWalletSpec{UserID: 1, Amount: idr(10_000), IdempotencyKey: "k-timeout"})
+	require.NoError(t, err)
+	require.Equal(t, domain.LedgerStatusProcessing, res.Status)
+	require.Equal(t, int64(89_900), getBalance(t, 1))
+	requireNoDrift(t)
+
+	h.settle(t)
+
+	// Resending the reference did not pay twice.
+	p := h.bank.Payouts()[res.PayoutReference]
+	require.Equal(t, 2, p.Requests)
+	require.Len(t, h.bank.Payouts(), 1)
+
+	replay, err := h.wallets.Withdraw(ctx, service.WithdrawWalletSpec{UserID: 1, Amount: idr(10_000), IdempotencyKey: "k-timeout"})
+	require.NoError(t, err)
+	require.Equal(t, domain.LedgerStatusSucceed, replay.Status)
+	require.Equal(t, int64(89_900), getBalance(t, 1))
+	requireBankConsistent(t, h.bank)
+}
+
+func TestIntegration_FakeBank_Unavailable(t *testing.T) {
+	cleanDB(t)
+
+	ctx := context.Background()
+	h := startBank(t, fakebank.Options{FailRate: 1}, false)
+
+	seedUser(t, 1)
+	seedWallet(t, 1, 100_000)
+
+	res, err := h.wallets.Withdraw(ctx, service.WithdrawWalletSpec{UserID: 1, Amount: idr(10_000), IdempotencyKey: "k-unavailable"})
+	require.NoError(t, err)
+	require.Equal(t, domain.LedgerStatusProcessing, res.Status)
+	require.Empty(t, h.bank.Payouts())
+
+	// Still down: the resend fails and the payout stays pending.
+	resend, err := h.payouts.ResendStale(ctx, service.ResendStaleSpec{Limit: 10})
+	require.NoError(t, err)
+	require.Len(t, resend.Errors, 1)
+	require.Equal(t, 1, countPendingPayouts(t))
+
+	h.settle(t)
+	require.Equal(t, int64(89_900), getBalance(t, 1))
+	requireBankConsistent(t, h.bank)
+}
+
+func TestIntegration_FakeBank_Rejected(t *testing.T) {
+	cleanDB(t)
+
+	ctx := context.Background()
+	h := startBank(t, fakebank.Options{RejectRate: 1}, false)
+
+	seedUser(t, 1)
+	seedWallet(t, 1, 100_000)
+
+	_, err := h.wallets.Withdraw(ctx, service.WithdrawWalletSpec{UserID: 1, Amount: idr(10_000), IdempotencyKey: "k-rejected"})
+	require.True(t, errors.Is(err, domain.ErrPayoutFailed))
+	require.Equal(t, int64(100_000), getBalance(t, 1))
+
+	h.settle(t)
+	requireBankConsistent(t, h.bank)
+}
+
+func TestIntegration_FakeBank_DuplicateCallbacks(t *testing.T) {
+	cleanDB(t)
+
+	ctx := context.Background()
+	h := startBank(t, fakebank.Options{DuplicateRate: 1, RejectRate: 0.5, Seed: 7}, true)
+
+	seedUser(t, 1)
+	seedWallet(t, 1, 100_000)
+
+	for i := range 6 {
+		// A callback may beat the bank's SENT answer back to the service.
+		res, err := h.wallets.Withdraw(ctx, service.WithdrawWalletSpec{UserID: 1, Amount: idr(1_000), IdempotencyKey: fmt.Sprintf("k-dup-%d", i)})
+		if err != nil {
+			require.True(t, errors.Is(err, domain.ErrPayoutFailed))
+			continue
+		}
+		require.Contains(t, []domain.LedgerStatus{domain.LedgerStatusSent, domain.LedgerStatusSucceed}, res.Status)
+	}
+
+	// Closing waits for every callback, each delivered twice.
+	h.bank.Close()
+	stats := h.bank.Stats()
+	require.Equal(t, 12, stats.Callbacks)
+	require.Equal(t, 0, stats.CallbackErrors)
+	require.Equal(t, 0, countPendingPayouts(t))
+
+	var failed int
+	for _, p := range h.bank.Payouts() {
+		if p.Status == domain.PayoutStatusFailed {
+			failed++
+		}
+	}
+	require.Equal(t, int64(100_000-(6-int64(failed))*1_100), getBalance(t, 1))
+	requireBankConsistent(t, h.bank)
+}
+
+func TestIntegration_FakeBank_Chaos(t *testing.T) {
+	cleanDB(t)
+
+	ctx := context.Background()
+	h := startBank(t, fakebank.Options{
+		Delay:         10 * time.Millisecond,
+		FailRate:      0.2,
+		TimeoutRate:   0.2,
+		RejectRate:    0.2,
+		DuplicateRate: 0.5,
+		CallbackDelay: 50 * time.Millisecond,
+		Seed:          2026,
+	}, true)
+
+	const users = 4
+	for u := int64(1); u <= users; u++ {
+		seedUser(t, u)
+		seedWallet(t, u, 100_000)
+	}
+
+	var wg sync.WaitGroup
+	for u := int64(1); u <= users; u++ {
+		wg.Go(func() {
+			for i := range 8 {
+				_, err := h.wallets.Withdraw(ctx, service.WithdrawWalletSpec{UserID: u, Amount: idr(3_000), IdempotencyKey: fmt.Sprintf("k-chaos-%d-%d", u, i)})
+				if err != nil && !errors.Is(err, domain.ErrPayoutFailed) {
+					t.Errorf("withdraw: %v", err)
+				}
+			}
+		})
+	}
+	wg.Wait()
+
+	h.settle(t)
+	requireBankConsistent(t, h.bank)
+
+	// Each wallet lost exactly its paid out withdrawals and their fees.
+	paid := map[int64]int64{}
+	for _, p := range h.bank.Payouts() {
+		if p.Status == domain.PayoutStatusSucceeded {
+			paid[p.Request.WalletID] += p.Request.Amount + 100
+		}
+	}
+
+	for u := int64(1); u <= users; u++ {
+		wallet, err := h.wallets.GetByUserID(ctx, u, "IDR")
+		require.NoError(t, err)
+		require.Equal(t, 100_000-paid[wallet.ID], wallet.Balance.Amount)
+	}
+}
@@ -0,0 +1,119 @@
+package service_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/vcnt72/go-boilerplate/internal/domain"
+	"github.com/vcnt72/go-boilerplate/internal/service"
+)
+
+func getFeeRevenue(t *testing.T, currency string) int64 {
+	var revenue int64
+	err := testDB.QueryRowx(`
+		SELECT COALESCE(SUM(p.amount), 0) FROM postings p
+		JOIN accounts a ON a.id = p.account_id
+		WHERE a.code = $1
+	`, domain.SystemAccountCode(domain.SystemAccountFeeRevenue, currency)).Scan(&revenue)
+	require.NoError(t, err)
+	return revenue
+}
+
+func TestIntegration_Fee_DefaultSchedule(t *testing.T) {
+	cleanDB(t)
+
+	ctx := context.Background()
+	svc := newWalletServiceWithFee(domain.FeeSchedule{Flat: 1_000, PercentBps: 50, MaxFee: 5_000})
+
+	seedUser(t, 1)
+	seedWallet(t, 1, 1_000_000)
+
+	res, err := svc.Withdraw(ctx, service.WithdrawWalletSpec{UserID: 1, Amount: idr(100_000), IdempotencyKey: "k-fee"})
+	require.NoError(t, err)
+	require.Equal(t, idr(1_500), res.Fee)
+	require.Equal(t, idr(898_500), res.Balance)
+	require.Equal(t, int64(898_500), getBalance(t, 1))
+
+	// The fee is its own ledger, hanging off the withdrawal.
+	var fee domain.Ledger
+	err = testDB.QueryRowx(`
+		SELECT f.amount, f.status, f.result_balance FROM ledgers f
+		JOIN ledgers w ON w.id = f.parent_ledger_id
+		WHERE w.idempotency_key = $1 AND f.type = $2
+	`, "k-fee", domain.LedgerTypeFee).Scan(&fee.Amount, &fee.Status, &fee.ResultBalance)
+	require.NoError(t, err)
+	require.Equal(t, int64(1_500), fee.Amount.Amount)
+	require.Equal(t, domain.LedgerStatusSucceed, fee.Status)
+	require.Equal(t, int64(898_500), fee.ResultBalance.Amount)
+
+	require.Equal(t, int64(1_500), getFeeRevenue(t, "IDR"))
+
+	// Replays return the fee and the balance after it.
+	replay, err := svc.Withdraw(ctx, service.WithdrawWalletSpec{UserID: 1, Amount: idr(100_000), IdempotencyKey: "k-fee"})
+	require.NoError(t, err)
+	require.Equal(t, res, replay)
+
+	// The cap applies to large withdrawals.
+	res, err = svc.Withdraw(ctx, service.WithdrawWalletSpec{UserID: 1, Amount: idr(800_000), IdempotencyKey: "k-fee-max"})
+	require.NoError(t, err)
+	require.Equal(t, idr(5_000), res.Fee)
+	require.Equal(t, int64(93_500), getBalance(t, 1))
+
+	summary, err := newReconcileService().Reconcile(ctx, service.ReconcileSpec{})
+	require.NoError(t, err)
+	require.Equal(t, int64(0), summary.Drifted)
+}
+
+func TestIntegration_Fee_InsufficientForFee(t *testing.T) {
+	cleanDB(t)
+
+	ctx := context.Background()
+	svc := newWalletServiceWithFee(domain.FeeSchedule{Flat: 2_500})
+
+	seedUser(t, 1)
+	seedWallet(t, 1, 100_000)
+
+	// The amount fits the balance, but not together with the fee.
+	_, err := svc.Withdraw(ctx, service.WithdrawWalletSpec{UserID: 1, Amount: idr(99_000), IdempotencyKey: "k-fee-poor"})
+	require.True(t, errors.Is(err, domain.ErrInsufficientFund))
+
+	require.Equal(t, int64(100_000), getBalance(t, 1))
+	require.Equal(t, int64(0), getFeeRevenue(t, "IDR"))
+}
+
+func TestIntegration_Fee_TierAndUserSchedules(t *testing.T) {
+	cleanDB(t)
+
+	ctx := context.Background()
+	svc := newWalletServiceWithFee(domain.FeeSchedule{Flat: 9_999})
+
+	seedUser(t, 1)
+	seedWallet(t, 1, 1_000_000)
+	seedUser(t, 2)
+	seedWallet(t, 2, 1_000_000)
+
+	_, err := testDB.Exec(`
+		INSERT INTO fee_schedules (tier, currency, percent_bps, min_fee) VALUES ($1, 'IDR', 10, 2000);
+		INSERT INTO fee_schedules (tier, currency, flat) VALUES ($1, 'USD', 1);
+		INSERT INTO fee_schedules (user_id, currency) VALUES (2, 'IDR');
+	`, domain.UserTierStandard)
+	require.NoError(t, err)
+
+	res, err := svc.Withdraw(ctx, service.WithdrawWalletSpec{UserID: 1, Amount: idr(100_000), IdempotencyKey: "k-tier"})
+	require.NoError(t, err)
+	require.Equal(t, idr(2_000), res.Fee)
+
+	// A user row without any fee waives it; no FEE ledger is written.
+	res, err = svc.Withdraw(ctx, service.WithdrawWalletSpec{UserID: 2, Amount: idr(100_000), IdempotencyKey: "k-user"})
+	require.NoError(t, err)
+	require.Equal(t, idr(0), res.Fee)
+	require.Equal(t, int64(900_000), getBalance(t, 2))
+
+	var fees int
+	err = testDB.QueryRowx(`SELECT COUNT(1) FROM ledgers WHERE type = $1`, domain.LedgerTypeFee).Scan(&fees)
+	require.NoError(t, err)
+	require.Equal(t, 1, fees)
+}
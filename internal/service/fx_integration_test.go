@@ -0,0 +1,182 @@
+package service_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/vcnt72/go-boilerplate/internal/domain"
+	"github.com/vcnt72/go-boilerplate/internal/repository"
+	"github.com/vcnt72/go-boilerplate/internal/service"
+)
+
+func newFXService() *service.FXService {
+	rateRepo := repository.NewFXRateRepository(testDB)
+	return service.NewFXService(
+		repository.NewWalletRepository(testDB),
+		repository.NewLedgerRepository(testDB),
+		repository.NewAccountRepository(testDB),
+		repository.NewJournalRepository(testDB),
+		rateRepo,
+		repository.NewFXQuoteRepository(testDB),
+		repository.NewTxProvider(testDB),
+		rateRepo,
+		time.Minute,
+	)
+}
+
+// systemPostings sums the postings of one system account.
+func systemPostings(t *testing.T, account, currency string) int64 {
+	t.Helper()
+
+	var sum int64
+	err := testDB.QueryRowx(`
+		SELECT COALESCE(SUM(p.amount), 0) FROM postings p
+		JOIN accounts a ON a.id = p.account_id
+		WHERE a.code = $1
+	`, domain.SystemAccountCode(account, currency)).Scan(&sum)
+	require.NoError(t, err)
+	return sum
+}
+
+// seedFX gives user 1 an IDR wallet and a USD wallet and prices USD→IDR at
+// 16000 with a 1% fee.
+func seedFX(t *testing.T, svc *service.FXService, usdBalance int64) {
+	seedUser(t, 1)
+	seedWallet(t, 1, 0)
+	seedCurrencyWallet(t, 1, "USD", usdBalance)
+
+	_, err := svc.SetRate(context.Background(), domain.FXRate{BaseCurrency: "usd", QuoteCurrency: "IDR", Rate: "16000", FeeBps: 100})
+	require.NoError(t, err)
+}
+
+func TestIntegration_FX_QuoteAndExecute(t *testing.T) {
+	cleanDB(t)
+
+	ctx := context.Background()
+	svc := newFXService()
+	seedFX(t, svc, 10_000)
+
+	quote, err := svc.CreateQuote(ctx, service.CreateQuoteSpec{UserID: 1, Amount: domain.NewMoney(5_000, "USD"), ToCurrency: "IDR"})
+	require.NoError(t, err)
+	require.Equal(t, domain.NewMoney(50, "USD"), quote.Fee)
+	require.Equal(t, idr(800_000), quote.ToAmount)
+
+	res, err := svc.ExecuteQuote(ctx, service.ExecuteQuoteSpec{UserID: 1, QuoteID: quote.ID, IdempotencyKey: "k-fx"})
+	require.NoError(t, err)
+	require.Equal(t, domain.NewMoney(5_050, "USD"), res.Debited)
+	require.Equal(t, domain.NewMoney(4_950, "USD"), res.FromBalance)
+	require.Equal(t, idr(800_000), res.ToBalance)
+
+	require.Equal(t, int64(4_950), getCurrencyBalance(t, 1, "USD"))
+	require.Equal(t, int64(800_000), getBalance(t, 1))
+
+	// Replaying the key returns the same outcome without converting again.
+	replay, err := svc.ExecuteQuote(ctx, service.ExecuteQuoteSpec{UserID: 1, QuoteID: quote.ID, IdempotencyKey: "k-fx"})
+	require.NoError(t, err)
+	require.Equal(t, res, replay)
+
+	_, err = svc.ExecuteQuote(ctx, service.ExecuteQuoteSpec{UserID: 1, QuoteID: quote.ID, IdempotencyKey: "k-fx-2"})
+	require.True(t, errors.Is(err, domain.ErrQuoteNotOpen))
+	require.Equal(t, int64(4_950), getCurrencyBalance(t, 1, "USD"))
+
+	var legs int
+	err = testDB.QueryRowx(`SELECT COUNT(1) FROM ledgers WHERE transfer_id = $1 AND status = $2`, quote.ID, domain.LedgerStatusSucceed).Scan(&legs)
+	require.NoError(t, err)
+	require.Equal(t, 2, legs)
+
+	// The fee is booked apart from the converted amount.
+	require.Equal(t, int64(5_000), systemPostings(t, domain.SystemAccountFXSettlement, "USD"))
+	require.Equal(t, int64(50), systemPostings(t, domain.SystemAccountFeeRevenue, "USD"))
+	require.Equal(t, int64(-800_000), systemPostings(t, domain.SystemAccountFXSettlement, "IDR"))
+
+	summary, err := newReconcileService().Reconcile(ctx, service.ReconcileSpec{})
+	require.NoError(t, err)
+	require.Equal(t, int64(0), summary.Drifted)
+}
+
+func TestIntegration_FX_Rejections(t *testing.T) {
+	cleanDB(t)
+
+	ctx := context.Background()
+	svc := newFXService()
+	seedFX(t, svc, 1_000)
+	seedUser(t, 2)
+	seedWallet(t, 2, 0)
+
+	_, err := svc.CreateQuote(ctx, service.CreateQuoteSpec{UserID: 1, Amount: idr(1_000), ToCurrency: "USD"})
+	require.True(t, errors.Is(err, domain.ErrRateNotFound))
+
+	_, err = svc.CreateQuote(ctx, service.CreateQuoteSpec{UserID: 1, Amount: domain.NewMoney(100, "USD"), ToCurrency: "USD"})
+	require.True(t, errors.Is(err, domain.ErrSameCurrency))
+
+	_, err = svc.CreateQuote(ctx, service.CreateQuoteSpec{UserID: 2, Amount: domain.NewMoney(100, "USD"), ToCurrency: "IDR"})
+	require.True(t, errors.Is(err, domain.ErrCurrencyMismatch))
+
+	quote, err := svc.CreateQuote(ctx, service.CreateQuoteSpec{UserID: 1, Amount: domain.NewMoney(1_000, "USD"), ToCurrency: "IDR"})
+	require.NoError(t, err)
+
+	// Quotes belong to the user who asked for them.
+	_, err = svc.ExecuteQuote(ctx, service.ExecuteQuoteSpec{UserID: 2, QuoteID: quote.ID, IdempotencyKey: "k-other"})
+	require.True(t, errors.Is(err, domain.ErrQuoteNotFound))
+
+	// The fee does not fit in the balance.
+	_, err = svc.ExecuteQuote(ctx, service.ExecuteQuoteSpec{UserID: 1, QuoteID: quote.ID, IdempotencyKey: "k-poor"})
+	require.True(t, errors.Is(err, domain.ErrInsufficientFund))
+
+	_, err = svc.ExecuteQuote(ctx, service.ExecuteQuoteSpec{UserID: 1, QuoteID: quote.ID, IdempotencyKey: "k-poor"})
+	require.True(t, errors.Is(err, domain.ErrInsufficientFund))
+
+	quote, err = svc.CreateQuote(ctx, service.CreateQuoteSpec{UserID: 1, Amount: domain.NewMoney(500, "USD"), ToCurrency: "IDR"})
+	require.NoError(t, err)
+
+	_, err = testDB.Exec(`UPDATE fx_quotes SET expires_at = now() - interval '1 second' WHERE id = $1`, quote.ID)
+	require.NoError(t, err)
+
+	_, err = svc.ExecuteQuote(ctx, service.ExecuteQuoteSpec{UserID: 1, QuoteID: quote.ID, IdempotencyKey: "k-expired"})
+	require.True(t, errors.Is(err, domain.ErrQuoteExpired))
+
+	require.Equal(t, int64(1_000), getCurrencyBalance(t, 1, "USD"))
+	require.Equal(t, int64(0), getBalance(t, 1))
+}
+
+func TestIntegration_FX_ConcurrentExecute(t *testing.T) {
+	cleanDB(t)
+
+	ctx := context.Background()
+	svc := newFXService()
+	seedFX(t, svc, 100_000)
+
+	quote, err := svc.CreateQuote(ctx, service.CreateQuoteSpec{UserID: 1, Amount: domain.NewMoney(1_000, "USD"), ToCurrency: "IDR"})
+	require.NoError(t, err)
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 5)
+	for i := range 5 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := svc.ExecuteQuote(ctx, service.ExecuteQuoteSpec{UserID: 1, QuoteID: quote.ID, IdempotencyKey: "k-" + string(rune('a'+i))})
+			errs <- err
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	succeeded := 0
+	for err := range errs {
+		if err == nil {
+			succeeded++
+			continue
+		}
+		require.True(t, errors.Is(err, domain.ErrQuoteNotOpen))
+	}
+
+	require.Equal(t, 1, succeeded)
+	require.Equal(t, int64(100_000-1_010), getCurrencyBalance(t, 1, "USD"))
+	require.Equal(t, int64(160_000), getBalance(t, 1))
+}
@@ -0,0 +1,394 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/vcnt72/go-boilerplate/internal/domain"
+	"github.com/vcnt72/go-boilerplate/internal/repository"
+)
+
+// RateSource looks up the current rate of a currency pair. It is either the
+// admin-set fx_rates table or a rates file.
+type RateSource interface {
+	GetRate(ctx context.Context, base, quote string) (*domain.FXRate, error)
+}
+
+type FXService struct {
+	walletRepository  repository.WalletRepository
+	ledgerRepository  repository.LedgerRepository
+	accountRepository repository.AccountRepository
+	journalRepository repository.JournalRepository
+	rateRepository    repository.FXRateRepository
+	quoteRepository   repository.FXQuoteRepository
+	txProvider        repository.TxProvider
+	rates             RateSource
+	quoteTTL          time.Duration
+}
+
+// ParseAmount reads a decimal amount in major units of currency.
+func (f FXService) ParseAmount(amount, currency string) (domain.Money, error) {
+	return domain.ParseMoney(amount, currency)
+}
+
+// SetRate stores an admin-set rate. It has no effect on quotes while rates
+// are read from a file.
+func (f FXService) SetRate(ctx context.Context, rate domain.FXRate) (*domain.FXRate, error) {
+	base, err := domain.LookupCurrency(rate.BaseCurrency)
+	if err != nil {
+		return nil, err
+	}
+
+	quote, err := domain.LookupCurrency(rate.QuoteCurrency)
+	if err != nil {
+		return nil, err
+	}
+
+	rate.BaseCurrency = base.Code
+	rate.QuoteCurrency = quote.Code
+	if err := rate.Validate(); err != nil {
+		return nil, err
+	}
+
+	saved, err := f.rateRepository.Upsert(ctx, rate)
+	if err != nil {
+		return nil, errors.Join(errors.New("FXService.SetRate: error on fx rate repository upsert"), err)
+	}
+
+	return saved, nil
+}
+
+type CreateQuoteSpec struct {
+	UserID int64
+	// Amount is what gets converted, in the currency sold. The fee is
+	// charged on top of it.
+	Amount     domain.Money
+	ToCurrency string
+}
+
+// CreateQuote prices a conversion between two of the user's wallets and
+// locks the rate and fee for the quote TTL.
+func (f FXService) CreateQuote(ctx context.Context, spec CreateQuoteSpec) (*domain.FXQuote, error) {
+	if !spec.Amount.IsPositive() {
+		return nil, domain.ErrInvalidAmount
+	}
+
+	from, err := domain.LookupCurrency(spec.Amount.Currency)
+	if err != nil {
+		return nil, err
+	}
+
+	to, err := domain.LookupCurrency(spec.ToCurrency)
+	if err != nil {
+		return nil, err
+	}
+
+	if from.Code == to.Code {
+		return nil, domain.ErrSameCurrency
+	}
+	spec.Amount.Currency = from.Code
+
+	for _, currency := range []string{from.Code, to.Code} {
+		if _, err := getWallet(ctx, f.walletRepository, spec.UserID, currency); err != nil {
+			return nil, err
+		}
+	}
+
+	rate, err := f.rates.GetRate(ctx, from.Code, to.Code)
+	if err != nil {
+		return nil, err
+	}
+
+	converted, err := rate.Convert(spec.Amount)
+	if err != nil {
+		return nil, err
+	}
+
+	// An amount too small to buy a single minor unit is not worth quoting.
+	if !converted.IsPositive() {
+		return nil, domain.ErrInvalidAmount
+	}
+
+	fee := rate.Fee(spec.Amount)
+	if _, err := spec.Amount.Add(fee); err != nil {
+		return nil, err
+	}
+
+	quote, err := f.quoteRepository.Create(ctx, domain.FXQuote{
+		ID:           uuid.NewString(),
+		UserID:       spec.UserID,
+		FromCurrency: from.Code,
+		ToCurrency:   to.Code,
+		FromAmount:   spec.Amount,
+		Fee:          fee,
+		ToAmount:     converted,
+		Rate:         rate.Rate,
+		Status:       domain.FXQuoteStatusOpen,
+		ExpiresAt:    time.Now().Add(f.quoteTTL),
+	})
+	if err != nil {
+		return nil, errors.Join(errors.New("FXService.CreateQuote: error on fx quote repository create"), err)
+	}
+
+	return quote, nil
+}
+
+type ExecuteQuoteSpec struct {
+	UserID         int64
+	QuoteID        string
+	IdempotencyKey string
+}
+
+type FXResult struct {
+	UserID  int64
+	QuoteID string
+	// Debited is the quoted amount plus the fee, taken from the wallet of
+	// the currency sold.
+	Debited     domain.Money
+	Fee         domain.Money
+	Credited    domain.Money
+	FromBalance domain.Money
+	ToBalance   domain.Money
+}
+
+// ExecuteQuote converts at the quoted rate: it debits the wallet of the
+// currency sold and credits the one of the currency bought in a single
+// transaction, recording an FX_OUT and an FX_IN ledger linked by the quote ID.
+func (f FXService) ExecuteQuote(ctx context.Context, spec ExecuteQuoteSpec) (*FXResult, error) {
+	var fromWalletID int64
+	var result *FXResult
+	var appErr error
+	err := f.txProvider.Tx(ctx, func(ctx context.Context) error {
+		quote, err := f.quoteRepository.LockByID(ctx, spec.UserID, spec.QuoteID)
+		if err != nil {
+			return err
+		}
+
+		from, err := getWallet(ctx, f.walletRepository, spec.UserID, quote.FromCurrency)
+		if err != nil {
+			return err
+		}
+		fromWalletID = from.ID
+
+		to, err := getWallet(ctx, f.walletRepository, spec.UserID, quote.ToCurrency)
+		if err != nil {
+			return err
+		}
+
+		lockIDs := []int64{from.ID, to.ID}
+		slices.Sort(lockIDs)
+		for _, id := range lockIDs {
+			locked, err := f.walletRepository.LockByID(ctx, id)
+			if err != nil {
+				return err
+			}
+
+			if locked.ID == from.ID {
+				from = locked
+			} else {
+				to = locked
+			}
+		}
+
+		debit, err := quote.FromAmount.Add(quote.Fee)
+		if err != nil {
+			return err
+		}
+
+		if _, err := to.Balance.Add(quote.ToAmount); err != nil {
+			return err
+		}
+
+		out, err := f.ledgerRepository.Create(ctx, domain.Ledger{
+			IdempotencyKey: spec.IdempotencyKey,
+			Type:           domain.LedgerTypeFXOut,
+			WalletID:       from.ID,
+			Status:         domain.LedgerStatusProcessing,
+			Amount:         debit,
+			TransferID:     &quote.ID,
+		})
+		if err != nil {
+			return err
+		}
+
+		if err := quote.CheckExecute(time.Now()); err != nil {
+			errCode := domain.LedgerErrorCodeQuoteExpired
+			if errors.Is(err, domain.ErrQuoteNotOpen) {
+				errCode = domain.LedgerErrorCodeQuoteNotOpen
+			}
+
+			out.Status = domain.LedgerStatusFailed
+			out.ErrorCode = &errCode
+			out.ResultBalance = &from.Balance
+			appErr = err
+			return f.ledgerRepository.Update(ctx, *out)
+		}
+
+		fromBalance, err := f.walletRepository.DecreaseBalance(ctx, debit, from.ID)
+		if err != nil {
+			if errors.Is(err, domain.ErrInsufficientFund) {
+				errCode := domain.LedgerErrorCodeInsufficientFund
+				out.Status = domain.LedgerStatusFailed
+				out.ErrorCode = &errCode
+				out.ResultBalance = &from.Balance
+				appErr = err
+				return f.ledgerRepository.Update(ctx, *out)
+			}
+
+			return err
+		}
+
+		in, err := f.ledgerRepository.Create(ctx, domain.Ledger{
+			IdempotencyKey: uuid.NewString(),
+			Type:           domain.LedgerTypeFXIn,
+			WalletID:       to.ID,
+			Status:         domain.LedgerStatusProcessing,
+			Amount:         quote.ToAmount,
+			TransferID:     &quote.ID,
+		})
+		if err != nil {
+			return err
+		}
+
+		toBalance, err := f.walletRepository.IncreaseBalance(ctx, quote.ToAmount, to.ID)
+		if err != nil {
+			return err
+		}
+
+		if err := f.quoteRepository.MarkExecuted(ctx, quote.ID); err != nil {
+			return errors.Join(errors.New("FXService.ExecuteQuote: error on fx quote repository mark executed"), err)
+		}
+
+		out.Status = domain.LedgerStatusSucceed
+		out.ResultBalance = &fromBalance
+		if err := f.ledgerRepository.Update(ctx, *out); err != nil {
+			return err
+		}
+
+		in.Status = domain.LedgerStatusSucceed
+		in.ResultBalance = &toBalance
+		if err := f.ledgerRepository.Update(ctx, *in); err != nil {
+			return err
+		}
+
+		// The fee is booked to fee revenue, apart from the converted amount,
+		// so the settlement account only ever holds what was converted.
+		err = bookSplit(ctx, f.accountRepository, f.journalRepository, *out, walletAccount(from.ID),
+			split{to: systemAccount(domain.SystemAccountFXSettlement, from.Currency), amount: quote.FromAmount},
+			split{to: systemAccount(domain.SystemAccountFeeRevenue, from.Currency), amount: quote.Fee})
+		if err != nil {
+			return err
+		}
+
+		err = bookLedger(ctx, f.accountRepository, f.journalRepository, *in,
+			systemAccount(domain.SystemAccountFXSettlement, to.Currency), walletAccount(to.ID))
+		if err != nil {
+			return err
+		}
+
+		result = &FXResult{
+			UserID:      spec.UserID,
+			QuoteID:     quote.ID,
+			Debited:     debit,
+			Fee:         quote.Fee,
+			Credited:    quote.ToAmount,
+			FromBalance: fromBalance,
+			ToBalance:   toBalance,
+		}
+		return nil
+	})
+
+	if errors.Is(err, domain.ErrLedgerConflict) {
+		fxResult, err2 := f.handleConflictExecute(ctx, fromWalletID, spec)
+		if err2 != nil {
+			return nil, errors.Join(err, err2)
+		}
+
+		return fxResult, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	if appErr != nil {
+		return nil, appErr
+	}
+
+	return result, nil
+}
+
+func (f FXService) handleConflictExecute(ctx context.Context, walletID int64, spec ExecuteQuoteSpec) (*FXResult, error) {
+	l, err := getReplayLedger(ctx, f.ledgerRepository, walletID, spec.IdempotencyKey)
+	if err != nil {
+		return nil, err
+	}
+
+	if l.Type != domain.LedgerTypeFXOut || l.TransferID == nil || *l.TransferID != spec.QuoteID {
+		return nil, domain.ErrIdempotencyKeyReused
+	}
+
+	switch l.Status {
+	case domain.LedgerStatusSucceed:
+		if l.ResultBalance == nil {
+			return nil, domain.ErrRequestInProgress
+		}
+
+		in, err := f.ledgerRepository.GetByTransferID(ctx, spec.QuoteID, domain.LedgerTypeFXIn)
+		if err != nil {
+			return nil, err
+		}
+
+		if in.ResultBalance == nil {
+			return nil, domain.ErrRequestInProgress
+		}
+
+		quote, err := f.quoteRepository.GetByID(ctx, spec.UserID, spec.QuoteID)
+		if err != nil {
+			return nil, err
+		}
+
+		return &FXResult{
+			UserID:      spec.UserID,
+			QuoteID:     quote.ID,
+			Debited:     l.Amount,
+			Fee:         quote.Fee,
+			Credited:    in.Amount,
+			FromBalance: *l.ResultBalance,
+			ToBalance:   *in.ResultBalance,
+		}, nil
+
+	case domain.LedgerStatusFailed:
+		if l.ErrorCode != nil {
+			switch *l.ErrorCode {
+			case domain.LedgerErrorCodeInsufficientFund:
+				return nil, domain.ErrInsufficientFund
+			case domain.LedgerErrorCodeQuoteExpired:
+				return nil, domain.ErrQuoteExpired
+			case domain.LedgerErrorCodeQuoteNotOpen:
+				return nil, domain.ErrQuoteNotOpen
+			}
+		}
+		return nil, domain.ErrFXFailed
+
+	default:
+		return nil, domain.ErrRequestInProgress
+	}
+}
+
+func NewFXService(walletRepository repository.WalletRepository, ledgerRepository repository.LedgerRepository, accountRepository repository.AccountRepository, journalRepository repository.JournalRepository, rateRepository repository.FXRateRepository, quoteRepository repository.FXQuoteRepository, txProvider repository.TxProvider, rates RateSource, quoteTTL time.Duration) *FXService {
+	return &FXService{
+		walletRepository:  walletRepository,
+		ledgerRepository:  ledgerRepository,
+		accountRepository: accountRepository,
+		journalRepository: journalRepository,
+		rateRepository:    rateRepository,
+		quoteRepository:   quoteRepository,
+		txProvider:        txProvider,
+		rates:             rates,
+		quoteTTL:          quoteTTL,
+	}
+}
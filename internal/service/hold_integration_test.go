@@ -0,0 +1,136 @@
+package service_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/vcnt72/go-boilerplate/internal/domain"
+	"github.com/vcnt72/go-boilerplate/internal/service"
+)
+
+func getHeldBalance(t *testing.T, userID int64) int64 {
+	var b int64
+	err := testDB.QueryRowx(`
+		SELECT held_balance FROM wallets WHERE user_id = $1
+	`, userID).Scan(&b)
+	require.NoError(t, err)
+	return b
+}
+
+func TestIntegration_Hold_PartialCaptureReleasesRest(t *testing.T) {
+	cleanDB(t)
+
+	ctx := context.Background()
+	users := newUserService()
+	svc := newWalletService()
+
+	u, err := users.Create(ctx, service.CreateUserSpec{Name: "test", Balance: idr(100_000)})
+	require.NoError(t, err)
+
+	held, err := svc.Authorize(ctx, service.AuthorizeSpec{UserID: u.ID, Amount: idr(70_000), IdempotencyKey: "k-auth"})
+	require.NoError(t, err)
+	require.Equal(t, domain.HoldStatusActive, held.Hold.Status)
+	require.Equal(t, int64(100_000), getBalance(t, u.ID))
+	require.Equal(t, int64(70_000), getHeldBalance(t, u.ID))
+
+	wallet, err := svc.GetByUserID(ctx, u.ID, "")
+	require.NoError(t, err)
+	require.Equal(t, idr(30_000), wallet.AvailableBalance)
+
+	// Held funds cannot be withdrawn.
+	_, err = svc.Withdraw(ctx, service.WithdrawWalletSpec{UserID: u.ID, Amount: idr(40_000), IdempotencyKey: "k-w"})
+	require.True(t, errors.Is(err, domain.ErrInsufficientFund))
+
+	_, err = svc.Capture(ctx, service.CaptureSpec{UserID: u.ID, HoldID: held.Hold.ID, Amount: idr(80_000), IdempotencyKey: "k-cap-too-much"})
+	require.True(t, errors.Is(err, domain.ErrCaptureExceedsHold))
+
+	captured, err := svc.Capture(ctx, service.CaptureSpec{UserID: u.ID, HoldID: held.Hold.ID, Amount: idr(50_000), IdempotencyKey: "k-cap"})
+	require.NoError(t, err)
+	require.Equal(t, domain.HoldStatusCaptured, captured.Hold.Status)
+	require.Equal(t, idr(50_000), captured.Hold.CapturedAmount)
+	require.Equal(t, idr(50_000), captured.Balance)
+	require.Equal(t, int64(50_000), getBalance(t, u.ID))
+	require.Equal(t, int64(0), getHeldBalance(t, u.ID))
+
+	replay, err := svc.Capture(ctx, service.CaptureSpec{UserID: u.ID, HoldID: held.Hold.ID, Amount: idr(50_000), IdempotencyKey: "k-cap"})
+	require.NoError(t, err)
+	require.Equal(t, captured.Balance, replay.Balance)
+	require.Equal(t, 1, countLedgers(t, "k-cap"))
+
+	_, err = svc.Void(ctx, service.VoidSpec{UserID: u.ID, HoldID: held.Hold.ID, IdempotencyKey: "k-void"})
+	require.True(t, errors.Is(err, domain.ErrHoldNotActive))
+
+	summary, err := newReconcileService().Reconcile(ctx, service.ReconcileSpec{})
+	require.NoError(t, err)
+	require.Equal(t, int64(0), summary.Drifted)
+}
+
+func TestIntegration_Hold_VoidAndFullCapture(t *testing.T) {
+	cleanDB(t)
+
+	ctx := context.Background()
+	svc := newWalletService()
+
+	seedUser(t, 1)
+	seedWallet(t, 1, 100_000)
+
+	_, err := svc.Authorize(ctx, service.AuthorizeSpec{UserID: 1, Amount: idr(200_000), IdempotencyKey: "k-big"})
+	require.True(t, errors.Is(err, domain.ErrInsufficientFund))
+
+	first, err := svc.Authorize(ctx, service.AuthorizeSpec{UserID: 1, Amount: idr(60_000), IdempotencyKey: "k-1"})
+	require.NoError(t, err)
+	second, err := svc.Authorize(ctx, service.AuthorizeSpec{UserID: 1, Amount: idr(40_000), IdempotencyKey: "k-2"})
+	require.NoError(t, err)
+
+	_, err = svc.Authorize(ctx, service.AuthorizeSpec{UserID: 1, Amount: idr(1), IdempotencyKey: "k-3"})
+	require.True(t, errors.Is(err, domain.ErrInsufficientFund))
+
+	voided, err := svc.Void(ctx, service.VoidSpec{UserID: 1, HoldID: first.Hold.ID, IdempotencyKey: "k-void"})
+	require.NoError(t, err)
+	require.Equal(t, domain.HoldStatusVoided, voided.Hold.Status)
+	require.Equal(t, int64(40_000), getHeldBalance(t, 1))
+
+	captured, err := svc.Capture(ctx, service.CaptureSpec{UserID: 1, HoldID: second.Hold.ID, IdempotencyKey: "k-cap"})
+	require.NoError(t, err)
+	require.Equal(t, idr(40_000), captured.Hold.CapturedAmount)
+	require.Equal(t, int64(60_000), getBalance(t, 1))
+	require.Equal(t, int64(0), getHeldBalance(t, 1))
+
+	// Another user's hold is not visible.
+	seedUser(t, 2)
+	seedWallet(t, 2, 100_000)
+	_, err = svc.Void(ctx, service.VoidSpec{UserID: 2, HoldID: second.Hold.ID, IdempotencyKey: "k-other"})
+	require.True(t, errors.Is(err, domain.ErrHoldNotFound))
+}
+
+func TestIntegration_Hold_Expiry(t *testing.T) {
+	cleanDB(t)
+
+	ctx := context.Background()
+	svc := newWalletService()
+	recovery := newRecoveryService()
+
+	seedUser(t, 1)
+	seedWallet(t, 1, 100_000)
+
+	held, err := svc.Authorize(ctx, service.AuthorizeSpec{UserID: 1, Amount: idr(30_000), IdempotencyKey: "k-auth", ExpiresIn: time.Millisecond})
+	require.NoError(t, err)
+	time.Sleep(10 * time.Millisecond)
+
+	_, err = svc.Capture(ctx, service.CaptureSpec{UserID: 1, HoldID: held.Hold.ID, IdempotencyKey: "k-cap"})
+	require.True(t, errors.Is(err, domain.ErrHoldExpired))
+
+	res, err := recovery.ExpireHolds(ctx, service.ExpireHoldsSpec{Limit: 10})
+	require.NoError(t, err)
+	require.Equal(t, 1, res.Expired)
+	require.Equal(t, int64(0), getHeldBalance(t, 1))
+	require.Equal(t, int64(100_000), getBalance(t, 1))
+
+	res, err = recovery.ExpireHolds(ctx, service.ExpireHoldsSpec{Limit: 10})
+	require.NoError(t, err)
+	require.Equal(t, 0, res.Found)
+}
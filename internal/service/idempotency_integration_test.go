@@ -0,0 +1,122 @@
+package service_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/vcnt72/go-boilerplate/internal/domain"
+	"github.com/vcnt72/go-boilerplate/internal/service"
+)
+
+func TestIntegration_Idempotency_SameKeyDifferentWallets(t *testing.T) {
+	cleanDB(t)
+
+	svc := newWalletService()
+
+	seedUser(t, 1)
+	seedWallet(t, 1, 100_000)
+	seedUser(t, 2)
+	seedWallet(t, 2, 50_000)
+
+	key := "k-shared"
+
+	res1, err := svc.Withdraw(context.Background(), service.WithdrawWalletSpec{
+		UserID:         1,
+		Amount:         idr(30_000),
+		IdempotencyKey: key,
+	})
+	require.NoError(t, err)
+	require.Equal(t, idr(70_000), res1.Balance)
+
+	res2, err := svc.Withdraw(context.Background(), service.WithdrawWalletSpec{
+		UserID:         2,
+		Amount:         idr(30_000),
+		IdempotencyKey: key,
+	})
+	require.NoError(t, err)
+	require.Equal(t, idr(20_000), res2.Balance)
+
+	require.Equal(t, 2, countLedgers(t, key))
+	require.Equal(t, int64(70_000), getBalance(t, 1))
+	require.Equal(t, int64(20_000), getBalance(t, 2))
+}
+
+func TestIntegration_Idempotency_ReplayReturnsOwnOutcome(t *testing.T) {
+	cleanDB(t)
+
+	svc := newWalletService()
+
+	seedUser(t, 1)
+	seedWallet(t, 1, 100_000)
+	seedUser(t, 2)
+	seedWallet(t, 2, 10_000)
+
+	key := "k-replay-owner"
+
+	_, err := svc.Withdraw(context.Background(), service.WithdrawWalletSpec{
+		UserID:         1,
+		Amount:         idr(30_000),
+		IdempotencyKey: key,
+	})
+	require.NoError(t, err)
+
+	// User 2 cannot afford the same request; the outcome must be user 2's own
+	// failure, both on the first call and on replay, never user 1's success.
+	for range 2 {
+		res, err := svc.Withdraw(context.Background(), service.WithdrawWalletSpec{
+			UserID:         2,
+			Amount:         idr(30_000),
+			IdempotencyKey: key,
+		})
+		require.Nil(t, res)
+		require.True(t, errors.Is(err, domain.ErrInsufficientFund))
+	}
+
+	replay, err := svc.Withdraw(context.Background(), service.WithdrawWalletSpec{
+		UserID:         1,
+		Amount:         idr(30_000),
+		IdempotencyKey: key,
+	})
+	require.NoError(t, err)
+	require.Equal(t, idr(70_000), replay.Balance)
+
+	require.Equal(t, int64(70_000), getBalance(t, 1))
+	require.Equal(t, int64(10_000), getBalance(t, 2))
+}
+
+func TestIntegration_Idempotency_ReusedWithDifferentPayload(t *testing.T) {
+	cleanDB(t)
+
+	svc := newWalletService()
+
+	seedUser(t, 1)
+	seedWallet(t, 1, 100_000)
+
+	key := "k-reused"
+
+	_, err := svc.Withdraw(context.Background(), service.WithdrawWalletSpec{
+		UserID:         1,
+		Amount:         idr(30_000),
+		IdempotencyKey: key,
+	})
+	require.NoError(t, err)
+
+	_, err = svc.Withdraw(context.Background(), service.WithdrawWalletSpec{
+		UserID:         1,
+		Amount:         idr(10_000),
+		IdempotencyKey: key,
+	})
+	require.True(t, errors.Is(err, domain.ErrIdempotencyKeyReused))
+
+	_, err = svc.Deposit(context.Background(), service.DepositWalletSpec{
+		UserID:         1,
+		Amount:         idr(30_000),
+		IdempotencyKey: key,
+	})
+	require.True(t, errors.Is(err, domain.ErrIdempotencyKeyReused))
+
+	require.Equal(t, int64(70_000), getBalance(t, 1))
+}
@@ -0,0 +1,103 @@
+package service
+
+import (
+	"context"
+	"errors"
+
+	"github.com/vcnt72/go-boilerplate/internal/domain"
+	"github.com/vcnt72/go-boilerplate/internal/repository"
+)
+
+// accountRef names one side of a journal: either a wallet's account or a
+// system account code qualified by currency.
+type accountRef struct {
+	walletID int64
+	code     string
+}
+
+func walletAccount(walletID int64) accountRef {
+	return accountRef{walletID: walletID}
+}
+
+func systemAccount(code, currency string) accountRef {
+	return accountRef{code: domain.SystemAccountCode(code, currency)}
+}
+
+func (a accountRef) resolve(ctx context.Context, accountRepository repository.AccountRepository) (*domain.Account, error) {
+	if a.code != "" {
+		return accountRepository.GetOrCreateSystem(ctx, a.code)
+	}
+
+	return accountRepository.GetByWalletID(ctx, a.walletID)
+}
+
+// bookLedger writes the balanced journal for a succeeded ledger, moving
+// ledger.Amount from one account to the other. Both repositories must be
+// bound to the transaction that settles the ledger.
+func bookLedger(ctx context.Context, accountRepository repository.AccountRepository, journalRepository repository.JournalRepository, ledger domain.Ledger, from, to accountRef) error {
+	return bookJournal(ctx, accountRepository, journalRepository, ledger.Type, &ledger.ID, ledger.Amount, from, to)
+}
+
+// bookJournal is bookLedger for journals whose type or amount differ from the
+// ledger they belong to, such as the restore of a failed payout.
+func bookJournal(ctx context.Context, accountRepository repository.AccountRepository, journalRepository repository.JournalRepository, journalType string, ledgerID *int64, amount domain.Money, from, to accountRef) error {
+	fromAccount, err := from.resolve(ctx, accountRepository)
+	if err != nil {
+		return errors.Join(errors.New("bookJournal: error on resolve debit account"), err)
+	}
+
+	toAccount, err := to.resolve(ctx, accountRepository)
+	if err != nil {
+		return errors.Join(errors.New("bookJournal: error on resolve credit account"), err)
+	}
+
+	_, err = journalRepository.Create(ctx, domain.NewTransferJournal(journalType, ledgerID, fromAccount.ID, toAccount.ID, amount.Amount))
+	if err != nil {
+		return errors.Join(errors.New("bookJournal: error on journal repository create"), err)
+	}
+
+	return nil
+}
+
+// split is one credited side of a journal booked by bookSplit.
+type split struct {
+	to     accountRef
+	amount domain.Money
+}
+
+// bookSplit is bookLedger for a ledger whose amount is shared between several
+// accounts, such as a conversion and its fee. The splits must add up to
+// ledger.Amount.
+func bookSplit(ctx context.Context, accountRepository repository.AccountRepository, journalRepository repository.JournalRepository, ledger domain.Ledger, from accountRef, splits ...split) error {
+	fromAccount, err := from.resolve(ctx, accountRepository)
+	if err != nil {
+		return errors.Join(errors.New("bookSplit: error on resolve debit account"), err)
+	}
+
+	var total int64
+	credits := make([]domain.Posting, 0, len(splits))
+	for _, s := range splits {
+		if s.amount.Amount == 0 {
+			continue
+		}
+
+		toAccount, err := s.to.resolve(ctx, accountRepository)
+		if err != nil {
+			return errors.Join(errors.New("bookSplit: error on resolve credit account"), err)
+		}
+
+		total += s.amount.Amount
+		credits = append(credits, domain.Posting{AccountID: toAccount.ID, Amount: s.amount.Amount})
+	}
+
+	if total != ledger.Amount.Amount {
+		return errors.Join(errors.New("bookSplit: error on splits not matching the ledger amount"), domain.ErrUnbalancedJournal)
+	}
+
+	_, err = journalRepository.Create(ctx, domain.NewSplitJournal(ledger.Type, &ledger.ID, fromAccount.ID, credits))
+	if err != nil {
+		return errors.Join(errors.New("bookSplit: error on journal repository create"), err)
+	}
+
+	return nil
+}
@@ -0,0 +1,63 @@
+package service_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/vcnt72/go-boilerplate/internal/service"
+)
+
+func postingsBalance(t *testing.T, userID int64) int64 {
+	var b int64
+	err := testDB.QueryRowx(`
+		SELECT COALESCE(SUM(p.amount), 0)
+		FROM postings p
+		JOIN accounts a ON a.id = p.account_id
+		JOIN wallets w ON w.id = a.wallet_id
+		WHERE w.user_id = $1
+	`, userID).Scan(&b)
+	require.NoError(t, err)
+	return b
+}
+
+func TestIntegration_Journal_PostingsMatchBalances(t *testing.T) {
+	cleanDB(t)
+
+	svc := newWalletService()
+	ctx := context.Background()
+
+	seedUser(t, 1)
+	seedWallet(t, 1, 100_000)
+	seedUser(t, 2)
+	seedWallet(t, 2, 0)
+
+	_, err := svc.Deposit(ctx, service.DepositWalletSpec{UserID: 1, Amount: idr(20_000), IdempotencyKey: "k-j-deposit"})
+	require.NoError(t, err)
+
+	_, err = svc.Withdraw(ctx, service.WithdrawWalletSpec{UserID: 1, Amount: idr(50_000), IdempotencyKey: "k-j-withdraw"})
+	require.NoError(t, err)
+
+	_, err = svc.Transfer(ctx, service.TransferWalletSpec{FromUserID: 1, ToUserID: 2, Amount: idr(30_000), IdempotencyKey: "k-j-transfer"})
+	require.NoError(t, err)
+
+	// A failed withdraw must not book anything.
+	_, err = svc.Withdraw(ctx, service.WithdrawWalletSpec{UserID: 2, Amount: idr(90_000), IdempotencyKey: "k-j-failed"})
+	require.Error(t, err)
+
+	require.Equal(t, getBalance(t, 1), postingsBalance(t, 1))
+	require.Equal(t, getBalance(t, 2), postingsBalance(t, 2))
+
+	var total int64
+	err = testDB.QueryRowx(`SELECT COALESCE(SUM(amount), 0) FROM postings`).Scan(&total)
+	require.NoError(t, err)
+	require.Equal(t, int64(0), total)
+
+	_, err = testDB.Exec(`
+		WITH j AS (INSERT INTO journals(type) VALUES ('BROKEN') RETURNING id)
+		INSERT INTO postings(journal_id, account_id, amount)
+		SELECT j.id, a.id, 1 FROM j, accounts a WHERE a.wallet_id IS NOT NULL LIMIT 1
+	`)
+	require.Error(t, err, "unbalanced journal must be rejected by the database")
+}
@@ -0,0 +1,106 @@
+package service_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/vcnt72/go-boilerplate/internal/domain"
+	"github.com/vcnt72/go-boilerplate/internal/service"
+)
+
+func TestIntegration_Limit_DefaultsFromConfig(t *testing.T) {
+	cleanDB(t)
+
+	ctx := context.Background()
+	svc := newWalletServiceWithLimit(domain.WithdrawalLimit{
+		MaxPerTransaction: 50_000,
+		MaxDailyAmount:    60_000,
+		MaxDailyCount:     3,
+	})
+
+	seedUser(t, 1)
+	seedWallet(t, 1, 1_000_000)
+
+	_, err := svc.Withdraw(ctx, service.WithdrawWalletSpec{UserID: 1, Amount: idr(50_001), IdempotencyKey: "k-l-tx"})
+	require.True(t, errors.Is(err, domain.ErrLimitExceeded))
+
+	_, err = svc.Withdraw(ctx, service.WithdrawWalletSpec{UserID: 1, Amount: idr(50_000), IdempotencyKey: "k-l-1"})
+	require.NoError(t, err)
+
+	_, err = svc.Withdraw(ctx, service.WithdrawWalletSpec{UserID: 1, Amount: idr(20_000), IdempotencyKey: "k-l-daily"})
+	require.True(t, errors.Is(err, domain.ErrLimitExceeded))
+
+	// Replaying a rejected request returns the same rejection.
+	_, err = svc.Withdraw(ctx, service.WithdrawWalletSpec{UserID: 1, Amount: idr(20_000), IdempotencyKey: "k-l-daily"})
+	require.True(t, errors.Is(err, domain.ErrLimitExceeded))
+
+	_, err = svc.Withdraw(ctx, service.WithdrawWalletSpec{UserID: 1, Amount: idr(5_000), IdempotencyKey: "k-l-2"})
+	require.NoError(t, err)
+	_, err = svc.Withdraw(ctx, service.WithdrawWalletSpec{UserID: 1, Amount: idr(1_000), IdempotencyKey: "k-l-3"})
+	require.NoError(t, err)
+	_, err = svc.Withdraw(ctx, service.WithdrawWalletSpec{UserID: 1, Amount: idr(1_000), IdempotencyKey: "k-l-count"})
+	require.True(t, errors.Is(err, domain.ErrLimitExceeded))
+
+	require.Equal(t, int64(1_000_000-56_000), getBalance(t, 1))
+}
+
+func TestIntegration_Limit_UserOverridesTier(t *testing.T) {
+	cleanDB(t)
+
+	ctx := context.Background()
+	svc := newWalletService()
+
+	seedUser(t, 1)
+	seedWallet(t, 1, 1_000_000)
+	seedUser(t, 2)
+	seedWallet(t, 2, 1_000_000)
+
+	_, err := testDB.Exec(`
+		INSERT INTO withdrawal_limits (tier, currency, max_per_transaction) VALUES ($1, 'IDR', 10000);
+		INSERT INTO withdrawal_limits (tier, currency, max_per_transaction) VALUES ($1, 'USD', 1);
+		INSERT INTO withdrawal_limits (user_id, currency, max_per_transaction) VALUES (2, 'IDR', 100000);
+	`, domain.UserTierStandard)
+	require.NoError(t, err)
+
+	_, err = svc.Withdraw(ctx, service.WithdrawWalletSpec{UserID: 1, Amount: idr(20_000), IdempotencyKey: "k-tier"})
+	require.True(t, errors.Is(err, domain.ErrLimitExceeded))
+
+	_, err = svc.Withdraw(ctx, service.WithdrawWalletSpec{UserID: 2, Amount: idr(20_000), IdempotencyKey: "k-user"})
+	require.NoError(t, err)
+}
+
+func TestIntegration_Limit_ConcurrentWithdrawalsRespectDailyAmount(t *testing.T) {
+	cleanDB(t)
+
+	svc := newWalletServiceWithLimit(domain.WithdrawalLimit{MaxDailyAmount: 50_000})
+
+	seedUser(t, 1)
+	seedWallet(t, 1, 1_000_000)
+
+	errCh := make(chan error, 10)
+	for i := range 10 {
+		go func() {
+			_, err := svc.Withdraw(context.Background(), service.WithdrawWalletSpec{
+				UserID: 1, Amount: idr(10_000), IdempotencyKey: fmt.Sprintf("k-lc-%d", i),
+			})
+			errCh <- err
+		}()
+	}
+
+	succeeded := 0
+	for range 10 {
+		err := <-errCh
+		if err == nil {
+			succeeded++
+			continue
+		}
+		require.True(t, errors.Is(err, domain.ErrLimitExceeded), err)
+	}
+
+	require.Equal(t, 5, succeeded)
+	require.Equal(t, int64(950_000), getBalance(t, 1))
+}
@@ -0,0 +1,484 @@
+package service_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/vcnt72/go-boilerplate/internal/domain"
+	"github.com/vcnt72/go-boilerplate/internal/payout"
+	"github.com/vcnt72/go-boilerplate/internal/repository"
+	"github.com/vcnt72/go-boilerplate/internal/repository/memory"
+	"github.com/vcnt72/go-boilerplate/internal/service"
+	"github.com/vcnt72/go-boilerplate/internal/webhook"
+)
+
+// harness runs the services on one set of repositories, without Postgres.
+type harness struct {
+	users     *service.UserService
+	wallets   *service.WalletService
+	payouts   *service.PayoutService
+	reconcile *service.ReconcileService
+	tx        repository.TxProvider
+}
+
+func newHarness(r repository.Repositories, fee domain.FeeSchedule, provider service.PayoutProvider) *harness {
+	return newHarnessWithLimit(r, domain.WithdrawalLimit{}, fee, provider)
+}
+
+func newHarnessWithLimit(r repository.Repositories, limit domain.WithdrawalLimit, fee domain.FeeSchedule, provider service.PayoutProvider) *harness {
+	payouts := service.NewPayoutService(r.WalletRepository, r.LedgerRepository, r.AccountRepository, r.JournalRepository, r.OutboxRepository, r.WebhookRepository, r.TxProvider, provider)
+
+	return &harness{
+		users: service.NewUserService(r.UserRepository, r.WalletRepository, r.LedgerRepository, r.AccountRepository, r.JournalRepository, r.OutboxRepository, r.WebhookRepository, r.TxProvider, "IDR"),
+		wallets: service.NewWalletService(r.WalletRepository, r.LedgerRepository, r.AccountRepository, r.JournalRepository, r.LimitRepository, r.FeeRepository,
+			r.OutboxRepository, r.WebhookRepository, r.HoldRepository, r.TxProvider, payouts, limit, fee, repository.TxOptions{}, time.Hour, "IDR"),
+		payouts:   payouts,
+		reconcile: service.NewReconcileService(r.WalletRepository, r.LedgerRepository, r.JournalRepository),
+		tx:        r.TxProvider,
+	}
+}
+
+// memoryHarness runs the services on an in-memory store.
+type memoryHarness struct {
+	*harness
+	store *memory.Store
+}
+
+func newMemoryHarness(t *testing.T, fee domain.FeeSchedule, provider service.PayoutProvider) *memoryHarness {
+	t.Helper()
+
+	store := memory.New()
+	return &memoryHarness{harness: newHarness(store.Repositories(), fee, provider), store: store}
+}
+
+func (h *harness) createUser(t *testing.T, balance int64) int64 {
+	t.Helper()
+
+	user, err := h.users.Create(context.Background(), service.CreateUserSpec{Name: "test", Balance: idr(balance)})
+	require.NoError(t, err)
+	return user.ID
+}
+
+func (h *harness) balance(t *testing.T, userID int64) int64 {
+	t.Helper()
+
+	wallet, err := h.wallets.GetByUserID(context.Background(), userID, "IDR")
+	require.NoError(t, err)
+	return wallet.Balance.Amount
+}
+
+func (h *harness) requireNoDrift(t *testing.T) {
+	t.Helper()
+
+	summary, err := h.reconcile.Reconcile(context.Background(), service.ReconcileSpec{})
+	require.NoError(t, err)
+	require.Equal(t, int64(0), summary.Drifted)
+}
+
+func TestMemory_Withdraw_Success(t *testing.T) {
+	ctx := context.Background()
+	h := newMemoryHarness(t, domain.FeeSchedule{Flat: 100}, newSimulator(payout.OutcomeSucceed))
+	userID := h.createUser(t, 100_000)
+
+	res, err := h.wallets.Withdraw(ctx, service.WithdrawWalletSpec{UserID: userID, Amount: idr(10_000), IdempotencyKey: "k-1"})
+	require.NoError(t, err)
+	require.Equal(t, domain.LedgerStatusSucceed, res.Status)
+	require.Equal(t, int64(89_900), h.balance(t, userID))
+	h.requireNoDrift(t)
+}
+
+func TestMemory_Withdraw_InsufficientFundsRollsBack(t *testing.T) {
+	ctx := context.Background()
+	h := newMemoryHarness(t, domain.FeeSchedule{}, newSimulator(payout.OutcomeSucceed))
+	userID := h.createUser(t, 50_000)
+
+	res, err := h.wallets.Withdraw(ctx, service.WithdrawWalletSpec{UserID: userID, Amount: idr(60_000), IdempotencyKey: "k-1"})
+	require.Nil(t, res)
+	require.True(t, errors.Is(err, domain.ErrInsufficientFund))
+	require.Equal(t, int64(50_000), h.balance(t, userID))
+	h.requireNoDrift(t)
+}
+
+func TestMemory_Withdraw_Idempotency(t *testing.T) {
+	ctx := context.Background()
+	h := newMemoryHarness(t, domain.FeeSchedule{}, newSimulator(payout.OutcomeSucceed))
+	userID := h.createUser(t, 100_000)
+
+	spec := service.WithdrawWalletSpec{UserID: userID, Amount: idr(10_000), IdempotencyKey: "k-1"}
+	first, err := h.wallets.Withdraw(ctx, spec)
+	require.NoError(t, err)
+
+	replay, err := h.wallets.Withdraw(ctx, spec)
+	require.NoError(t, err)
+	require.Equal(t, first.PayoutReference, replay.PayoutReference)
+	require.Equal(t, int64(90_000), h.balance(t, userID))
+
+	spec.Amount = idr(20_000)
+	_, err = h.wallets.Withdraw(ctx, spec)
+	require.True(t, errors.Is(err, domain.ErrIdempotencyKeyReused))
+}
+
+func TestMemory_Withdraw_Concurrent(t *testing.T) {
+	h := newMemoryHarness(t, domain.FeeSchedule{}, newSimulator(payout.OutcomeSucceed))
+	userID := h.createUser(t, 100_000)
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 20)
+	for i := range 20 {
+		wg.Go(func() {
+			_, err := h.wallets.Withdraw(context.Background(), service.WithdrawWalletSpec{UserID: userID, Amount: idr(10_000), IdempotencyKey: fmt.Sprintf("k-%d", i)})
+			errs <- err
+		})
+	}
+	wg.Wait()
+	close(errs)
+
+	var succeeded int
+	for err := range errs {
+		if err == nil {
+			succeeded++
+			continue
+		}
+		require.True(t, errors.Is(err, domain.ErrInsufficientFund), err)
+	}
+
+	require.Equal(t, 10, succeeded)
+	require.Equal(t, int64(0), h.balance(t, userID))
+	h.requireNoDrift(t)
+}
+
+func TestMemory_Withdraw_PayoutFailedRestoresFunds(t *testing.T) {
+	ctx := context.Background()
+	h := newMemoryHarness(t, domain.FeeSchedule{Flat: 100}, newSimulator(payout.OutcomeFail))
+	userID := h.createUser(t, 100_000)
+
+	_, err := h.wallets.Withdraw(ctx, service.WithdrawWalletSpec{UserID: userID, Amount: idr(10_000), IdempotencyKey: "k-1"})
+	require.True(t, errors.Is(err, domain.ErrPayoutFailed))
+	require.Equal(t, int64(100_000), h.balance(t, userID))
+	h.requireNoDrift(t)
+}
+
+// commitUnknownTx loses the answer to every commit of a TxWithOptions, after
+// the transaction committed.
+type commitUnknownTx struct {
+	repository.TxProvider
+}
+
+func (c commitUnknownTx) TxWithOptions(ctx context.Context, opts repository.TxOptions, txFunc func(ctx context.Context) error) error {
+	if err := c.TxProvider.TxWithOptions(ctx, opts, txFunc); err != nil {
+		return err
+	}
+
+	return errors.Join(repository.ErrCommitUnknown, errors.New("connection reset"))
+}
+
+func TestMemory_Withdraw_CommitUnknownIsInProgress(t *testing.T) {
+	ctx := context.Background()
+	r := memory.New().Repositories()
+	r.TxProvider = commitUnknownTx{r.TxProvider}
+	h := newHarness(r, domain.FeeSchedule{}, newSimulator(payout.OutcomeSucceed))
+	userID := h.createUser(t, 100_000)
+
+	spec := service.WithdrawWalletSpec{UserID: userID, Amount: idr(10_000), IdempotencyKey: "k-1"}
+	_, err := h.wallets.Withdraw(ctx, spec)
+	require.ErrorIs(t, err, domain.ErrRequestInProgress)
+	require.ErrorIs(t, err, repository.ErrCommitUnknown)
+
+	// It did commit: a retry replays the withdrawal, whose payout is left to
+	// the sweeper.
+	res, err := h.wallets.Withdraw(ctx, spec)
+	require.NoError(t, err)
+	require.Equal(t, domain.LedgerStatusProcessing, res.Status)
+	require.Equal(t, int64(90_000), h.balance(t, userID))
+	h.requireNoDrift(t)
+}
+
+func TestMemory_Transfer(t *testing.T) {
+	ctx := context.Background()
+	h := newMemoryHarness(t, domain.FeeSchedule{}, newSimulator(payout.OutcomeSucceed))
+	from := h.createUser(t, 100_000)
+	to := h.createUser(t, 0)
+
+	_, err := h.wallets.Transfer(ctx, service.TransferWalletSpec{FromUserID: from, ToUserID: to, Amount: idr(40_000), IdempotencyKey: "k-1"})
+	require.NoError(t, err)
+
+	_, err = h.wallets.Transfer(ctx, service.TransferWalletSpec{FromUserID: from, ToUserID: to, Amount: idr(70_000), IdempotencyKey: "k-2"})
+	require.True(t, errors.Is(err, domain.ErrInsufficientFund))
+
+	require.Equal(t, int64(60_000), h.balance(t, from))
+	require.Equal(t, int64(40_000), h.balance(t, to))
+	h.requireNoDrift(t)
+}
+
+func TestMemory_ComposesInTransaction(t *testing.T) {
+	ctx := context.Background()
+	h := newMemoryHarness(t, domain.FeeSchedule{}, newSimulator(payout.OutcomeSucceed))
+	from := h.createUser(t, 100_000)
+	to := h.createUser(t, 0)
+
+	boom := errors.New("boom")
+	err := h.tx.Tx(ctx, func(ctx context.Context) error {
+		_, err := h.wallets.Transfer(ctx, service.TransferWalletSpec{FromUserID: from, ToUserID: to, Amount: idr(40_000), IdempotencyKey: "k-1"})
+		require.NoError(t, err)
+
+		_, err = h.wallets.OpenWallet(ctx, service.OpenWalletSpec{UserID: to, Currency: "USD"})
+		require.NoError(t, err)
+		return boom
+	})
+	require.ErrorIs(t, err, boom)
+	require.Equal(t, int64(100_000), h.balance(t, from))
+	require.Equal(t, int64(0), h.balance(t, to))
+
+	err = h.tx.Tx(ctx, func(ctx context.Context) error {
+		_, err := h.wallets.OpenWallet(ctx, service.OpenWalletSpec{UserID: to, Currency: "USD"})
+		require.NoError(t, err)
+
+		_, err = h.wallets.OpenWallet(ctx, service.OpenWalletSpec{UserID: to, Currency: "USD"})
+		require.ErrorIs(t, err, domain.ErrWalletExists)
+		return nil
+	})
+	require.NoError(t, err)
+
+	wallets, err := h.wallets.ListWallets(ctx, to)
+	require.NoError(t, err)
+	require.Len(t, wallets, 2)
+	h.requireNoDrift(t)
+}
+
+func TestMemory_Withdraw_EnforcesLimits(t *testing.T) {
+	ctx := context.Background()
+	h := newMemoryHarness(t, domain.FeeSchedule{}, newSimulator(payout.OutcomeSucceed))
+	userID := h.createUser(t, 100_000)
+	require.NoError(t, h.store.SetWithdrawalLimit(domain.WithdrawalLimit{UserID: &userID, Currency: "IDR", MaxDailyAmount: 10_000, MaxDailyCount: 2}))
+
+	// A withdrawal does not count against itself.
+	_, err := h.wallets.Withdraw(ctx, service.WithdrawWalletSpec{UserID: userID, Amount: idr(10_000), IdempotencyKey: "k-1"})
+	require.NoError(t, err)
+
+	_, err = h.wallets.Withdraw(ctx, service.WithdrawWalletSpec{UserID: userID, Amount: idr(1), IdempotencyKey: "k-2"})
+	require.ErrorIs(t, err, domain.ErrLimitExceeded)
+	require.Equal(t, int64(90_000), h.balance(t, userID))
+
+	other := h.createUser(t, 100_000)
+	require.NoError(t, h.store.SetWithdrawalLimit(domain.WithdrawalLimit{UserID: &other, Currency: "IDR", MaxDailyCount: 1}))
+
+	_, err = h.wallets.Withdraw(ctx, service.WithdrawWalletSpec{UserID: other, Amount: idr(5_000), IdempotencyKey: "k-1"})
+	require.NoError(t, err)
+
+	_, err = h.wallets.Withdraw(ctx, service.WithdrawWalletSpec{UserID: other, Amount: idr(5_000), IdempotencyKey: "k-2"})
+	require.ErrorIs(t, err, domain.ErrLimitExceeded)
+	h.requireNoDrift(t)
+}
+
+func TestMemory_Withdraw_LimitIsPerCurrency(t *testing.T) {
+	ctx := context.Background()
+	store := memory.New()
+	h := newHarnessWithLimit(store.Repositories(), domain.WithdrawalLimit{MaxPerTransaction: 5_000}, domain.FeeSchedule{}, newSimulator(payout.OutcomeSucceed))
+	userID := h.createUser(t, 100_000)
+
+	_, err := h.wallets.OpenWallet(ctx, service.OpenWalletSpec{UserID: userID, Currency: "USD"})
+	require.NoError(t, err)
+	_, err = h.wallets.Deposit(ctx, service.DepositWalletSpec{UserID: userID, Amount: domain.NewMoney(100_000, "USD"), IdempotencyKey: "k-deposit"})
+	require.NoError(t, err)
+
+	// The default is in IDR, so it limits IDR withdrawals only.
+	_, err = h.wallets.Withdraw(ctx, service.WithdrawWalletSpec{UserID: userID, Amount: idr(10_000), IdempotencyKey: "k-1"})
+	require.ErrorIs(t, err, domain.ErrLimitExceeded)
+	_, err = h.wallets.Withdraw(ctx, service.WithdrawWalletSpec{UserID: userID, Amount: domain.NewMoney(10_000, "USD"), IdempotencyKey: "k-2"})
+	require.NoError(t, err)
+
+	// A row applies to its own currency only.
+	require.NoError(t, store.SetWithdrawalLimit(domain.WithdrawalLimit{UserID: &userID, Currency: "USD", MaxPerTransaction: 1_000}))
+	_, err = h.wallets.Withdraw(ctx, service.WithdrawWalletSpec{UserID: userID, Amount: domain.NewMoney(1_001, "USD"), IdempotencyKey: "k-3"})
+	require.ErrorIs(t, err, domain.ErrLimitExceeded)
+	_, err = h.wallets.Withdraw(ctx, service.WithdrawWalletSpec{UserID: userID, Amount: idr(4_000), IdempotencyKey: "k-4"})
+	require.NoError(t, err)
+
+	require.NoError(t, store.SetWithdrawalLimit(domain.WithdrawalLimit{UserID: &userID, Currency: "IDR", MaxPerTransaction: 20_000}))
+	_, err = h.wallets.Withdraw(ctx, service.WithdrawWalletSpec{UserID: userID, Amount: idr(20_000), IdempotencyKey: "k-5"})
+	require.NoError(t, err)
+	h.requireNoDrift(t)
+}
+
+func TestMemory_PreviewWithdraw_MatchesWithdraw(t *testing.T) {
+	ctx := context.Background()
+	h := newMemoryHarness(t, domain.FeeSchedule{Flat: 100}, newSimulator(payout.OutcomeSucceed))
+	userID := h.createUser(t, 100_000)
+	require.NoError(t, h.store.SetWithdrawalLimit(domain.WithdrawalLimit{UserID: &userID, Currency: "IDR", MaxDailyAmount: 10_000, MaxDailyCount: 1}))
+
+	_, err := h.wallets.PreviewWithdraw(ctx, service.PreviewWithdrawSpec{UserID: userID, Amount: idr(0)})
+	require.ErrorIs(t, err, domain.ErrInvalidAmount)
+
+	preview, err := h.wallets.PreviewWithdraw(ctx, service.PreviewWithdrawSpec{UserID: userID, Amount: idr(10_000)})
+	require.NoError(t, err)
+	require.Equal(t, idr(10_100), preview.Debit)
+	require.Equal(t, int64(0), preview.RemainingDailyAmount.Amount)
+	require.Equal(t, int64(0), *preview.RemainingDailyCount)
+
+	res, err := h.wallets.Withdraw(ctx, service.WithdrawWalletSpec{UserID: userID, Amount: idr(10_000), IdempotencyKey: "k-1"})
+	require.NoError(t, err)
+	require.Equal(t, preview.Balance, res.Balance)
+
+	_, err = h.wallets.PreviewWithdraw(ctx, service.PreviewWithdrawSpec{UserID: userID, Amount: idr(1)})
+	require.ErrorIs(t, err, domain.ErrLimitExceeded)
+
+	_, err = h.wallets.Withdraw(ctx, service.WithdrawWalletSpec{UserID: userID, Amount: idr(1), IdempotencyKey: "k-2"})
+	require.ErrorIs(t, err, domain.ErrLimitExceeded)
+}
+
+func TestMemory_Withdraw_UsesUserFeeSchedule(t *testing.T) {
+	ctx := context.Background()
+	h := newMemoryHarness(t, domain.FeeSchedule{Flat: 100}, newSimulator(payout.OutcomeSucceed))
+	userID := h.createUser(t, 100_000)
+	require.NoError(t, h.store.SetFeeSchedule(domain.FeeSchedule{UserID: &userID, Currency: "IDR", Flat: 500}))
+
+	_, err := h.wallets.Withdraw(ctx, service.WithdrawWalletSpec{UserID: userID, Amount: idr(10_000), IdempotencyKey: "k-1"})
+	require.NoError(t, err)
+	require.Equal(t, int64(89_500), h.balance(t, userID))
+}
+
+func TestMemory_Withdraw_FeeScheduleIsPerCurrency(t *testing.T) {
+	ctx := context.Background()
+	h := newMemoryHarness(t, domain.FeeSchedule{Flat: 100}, newSimulator(payout.OutcomeSucceed))
+	userID := h.createUser(t, 100_000)
+	require.NoError(t, h.store.SetFeeSchedule(domain.FeeSchedule{UserID: &userID, Currency: "IDR", Flat: 500}))
+
+	_, err := h.wallets.OpenWallet(ctx, service.OpenWalletSpec{UserID: userID, Currency: "USD"})
+	require.NoError(t, err)
+	_, err = h.wallets.Deposit(ctx, service.DepositWalletSpec{UserID: userID, Amount: domain.NewMoney(10_000, "USD"), IdempotencyKey: "k-deposit"})
+	require.NoError(t, err)
+
+	// Neither the IDR schedule nor the default, which is in IDR, prices USD.
+	res, err := h.wallets.Withdraw(ctx, service.WithdrawWalletSpec{UserID: userID, Amount: domain.NewMoney(1_000, "USD"), IdempotencyKey: "k-1"})
+	require.NoError(t, err)
+	require.Equal(t, domain.NewMoney(0, "USD"), res.Fee)
+
+	require.NoError(t, h.store.SetFeeSchedule(domain.FeeSchedule{UserID: &userID, Currency: "USD", Flat: 50}))
+	res, err = h.wallets.Withdraw(ctx, service.WithdrawWalletSpec{UserID: userID, Amount: domain.NewMoney(1_000, "USD"), IdempotencyKey: "k-2"})
+	require.NoError(t, err)
+	require.Equal(t, domain.NewMoney(50, "USD"), res.Fee)
+
+	res, err = h.wallets.Withdraw(ctx, service.WithdrawWalletSpec{UserID: userID, Amount: idr(10_000), IdempotencyKey: "k-3"})
+	require.NoError(t, err)
+	require.Equal(t, idr(500), res.Fee)
+	h.requireNoDrift(t)
+}
+
+// downProvider cannot reach the provider for the amounts it holds.
+type downProvider struct {
+	down map[int64]bool
+	service.PayoutProvider
+}
+
+func (d downProvider) Send(ctx context.Context, req domain.PayoutRequest) (domain.PayoutResponse, error) {
+	if d.down[req.Amount.Amount] {
+		return domain.PayoutResponse{}, errors.New("provider unavailable")
+	}
+
+	return d.PayoutProvider.Send(ctx, req)
+}
+
+func TestMemory_ResendStale_SkipsPastFailingPayouts(t *testing.T) {
+	ctx := context.Background()
+	provider := downProvider{down: map[int64]bool{10_000: true, 20_000: true}, PayoutProvider: newSimulator(payout.OutcomeSucceed)}
+	h := newMemoryHarness(t, domain.FeeSchedule{}, provider)
+	userID := h.createUser(t, 100_000)
+
+	for i, amount := range []int64{10_000, 20_000} {
+		res, err := h.wallets.Withdraw(ctx, service.WithdrawWalletSpec{UserID: userID, Amount: idr(amount), IdempotencyKey: fmt.Sprintf("k-%d", i)})
+		require.NoError(t, err)
+		require.Equal(t, domain.LedgerStatusProcessing, res.Status)
+	}
+
+	// The first payout is still unreachable; it is recorded and moved to the
+	// back of the queue, so the next batch reaches the second one.
+	delete(provider.down, 20_000)
+	res, err := h.payouts.ResendStale(ctx, service.ResendStaleSpec{Limit: 1})
+	require.NoError(t, err)
+	require.Equal(t, 1, res.Found)
+	require.Equal(t, 0, res.Settled)
+	require.Len(t, res.Errors, 1)
+
+	res, err = h.payouts.ResendStale(ctx, service.ResendStaleSpec{Limit: 2})
+	require.NoError(t, err)
+	require.Equal(t, 2, res.Found)
+	require.Equal(t, 1, res.Settled)
+	require.Len(t, res.Errors, 1)
+	require.Equal(t, int64(70_000), h.balance(t, userID))
+	h.requireNoDrift(t)
+}
+
+func TestMemory_WebhookDispatch_SendsOutsideTransaction(t *testing.T) {
+	ctx := context.Background()
+	store := memory.New()
+	r := store.Repositories()
+	h := newHarness(r, domain.FeeSchedule{}, newSimulator(payout.OutcomeSucceed))
+	webhooks := service.NewWebhookService(r.WebhookRepository, r.TxProvider)
+	spec := service.DispatchSpec{Limit: 10, MaxAttempts: 3, Lease: time.Hour, Sender: webhook.NewSender(nil, time.Second)}
+
+	// While the batch is sent, the store is free and the claimed delivery is
+	// not handed to another dispatcher.
+	var concurrent *service.DispatchResult
+	var concurrentErr error
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		concurrent, concurrentErr = webhooks.Dispatch(req.Context(), spec)
+		w.WriteHeader(http.StatusOK)
+	}))
+	t.Cleanup(receiver.Close)
+
+	_, err := webhooks.CreateSubscription(ctx, service.CreateWebhookSpec{URL: receiver.URL, EventTypes: []string{domain.OutboxEventWithdrawSucceeded}})
+	require.NoError(t, err)
+
+	userID := h.createUser(t, 100_000)
+	_, err = h.wallets.Withdraw(ctx, service.WithdrawWalletSpec{UserID: userID, Amount: idr(10_000), IdempotencyKey: "k-1"})
+	require.NoError(t, err)
+
+	res, err := webhooks.Dispatch(ctx, spec)
+	require.NoError(t, err)
+	require.Equal(t, 1, res.Found)
+	require.Equal(t, 1, res.Delivered)
+
+	require.NoError(t, concurrentErr)
+	require.Equal(t, 0, concurrent.Found)
+}
+
+// relaySink publishes by calling publish.
+type relaySink struct {
+	publish func(ctx context.Context, event domain.OutboxEvent) error
+}
+
+func (s relaySink) Publish(ctx context.Context, event domain.OutboxEvent) error {
+	return s.publish(ctx, event)
+}
+
+func TestMemory_OutboxRelay_PublishesOutsideTransaction(t *testing.T) {
+	ctx := context.Background()
+	store := memory.New()
+	r := store.Repositories()
+	h := newHarness(r, domain.FeeSchedule{}, newSimulator(payout.OutcomeSucceed))
+	relay := service.NewOutboxService(r.OutboxRepository, r.TxProvider)
+	h.createUser(t, 100_000)
+
+	// While the batch is published, the store is free and the claimed event
+	// is not handed to another relay.
+	var concurrent *service.RelayResult
+	sink := relaySink{publish: func(ctx context.Context, event domain.OutboxEvent) error {
+		var err error
+		concurrent, err = relay.Relay(ctx, service.RelaySpec{Limit: 10, Lease: time.Hour, Sink: relaySink{publish: func(context.Context, domain.OutboxEvent) error { return nil }}})
+		return err
+	}}
+
+	res, err := relay.Relay(ctx, service.RelaySpec{Limit: 10, Lease: time.Hour, Sink: sink})
+	require.NoError(t, err)
+	require.Equal(t, 1, res.Found)
+	require.Equal(t, 1, res.Published)
+	require.Equal(t, 0, concurrent.Found)
+}
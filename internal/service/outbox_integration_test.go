@@ -0,0 +1,142 @@
+package service_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/vcnt72/go-boilerplate/internal/domain"
+	"github.com/vcnt72/go-boilerplate/internal/repository"
+	"github.com/vcnt72/go-boilerplate/internal/service"
+)
+
+type recordingSink struct {
+	mu     sync.Mutex
+	failOn map[int64]bool
+	events []domain.OutboxEvent
+}
+
+func (s *recordingSink) Publish(_ context.Context, event domain.OutboxEvent) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.failOn[event.ID] {
+		return errors.New("sink unavailable")
+	}
+
+	s.events = append(s.events, event)
+	return nil
+}
+
+func newOutboxService() *service.OutboxService {
+	return service.NewOutboxService(repository.NewOutboxRepository(testDB), repository.NewTxProvider(testDB))
+}
+
+func listOutbox(t *testing.T) []domain.OutboxEvent {
+	events := []domain.OutboxEvent{}
+	err := testDB.Select(&events, `
+		SELECT id, event_type, partition_key, payload::text AS payload, attempts, last_error, next_attempt_at, published_at, created_at
+		FROM outbox ORDER BY id
+	`)
+	require.NoError(t, err)
+	return events
+}
+
+func TestIntegration_Outbox_WrittenWithStateChange(t *testing.T) {
+	cleanDB(t)
+
+	ctx := context.Background()
+	users := newUserService()
+	wallets := newWalletService()
+
+	u, err := users.Create(ctx, service.CreateUserSpec{Name: "test", Balance: idr(100_000)})
+	require.NoError(t, err)
+
+	_, err = wallets.Withdraw(ctx, service.WithdrawWalletSpec{UserID: u.ID, Amount: idr(30_000), IdempotencyKey: "k-o-1"})
+	require.NoError(t, err)
+	_, err = wallets.Withdraw(ctx, service.WithdrawWalletSpec{UserID: u.ID, Amount: idr(500_000), IdempotencyKey: "k-o-2"})
+	require.True(t, errors.Is(err, domain.ErrInsufficientFund))
+
+	// Replays do not write events again.
+	_, err = wallets.Withdraw(ctx, service.WithdrawWalletSpec{UserID: u.ID, Amount: idr(30_000), IdempotencyKey: "k-o-1"})
+	require.NoError(t, err)
+
+	events := listOutbox(t)
+	require.Len(t, events, 3)
+	require.Equal(t, domain.OutboxEventUserCreated, events[0].Type)
+	require.Equal(t, domain.OutboxEventWithdrawSucceeded, events[1].Type)
+	require.Equal(t, domain.OutboxEventWithdrawFailed, events[2].Type)
+	require.Equal(t, events[0].PartitionKey, events[1].PartitionKey)
+	require.Equal(t, events[0].PartitionKey, events[2].PartitionKey)
+
+	// Amounts are published as decimal strings.
+	var failed struct {
+		UserID    int64  `json:"userId"`
+		Currency  string `json:"currency"`
+		Amount    string `json:"amount"`
+		ErrorCode string `json:"errorCode"`
+	}
+	require.NoError(t, json.Unmarshal(events[2].Payload, &failed))
+	require.Equal(t, u.ID, failed.UserID)
+	require.Equal(t, "IDR", failed.Currency)
+	require.Equal(t, "500000", failed.Amount)
+	require.Equal(t, domain.LedgerErrorCodeInsufficientFund, failed.ErrorCode)
+}
+
+func TestIntegration_Outbox_RelayKeepsPartitionOrder(t *testing.T) {
+	cleanDB(t)
+
+	ctx := context.Background()
+	users := newUserService()
+	wallets := newWalletService()
+
+	a, err := users.Create(ctx, service.CreateUserSpec{Name: "a", Balance: idr(100_000)})
+	require.NoError(t, err)
+	b, err := users.Create(ctx, service.CreateUserSpec{Name: "b", Balance: idr(100_000)})
+	require.NoError(t, err)
+
+	_, err = wallets.Withdraw(ctx, service.WithdrawWalletSpec{UserID: a.ID, Amount: idr(1_000), IdempotencyKey: "k-a"})
+	require.NoError(t, err)
+	_, err = wallets.Withdraw(ctx, service.WithdrawWalletSpec{UserID: b.ID, Amount: idr(1_000), IdempotencyKey: "k-b"})
+	require.NoError(t, err)
+
+	events := listOutbox(t)
+	require.Len(t, events, 4)
+	createdA := events[0]
+
+	sink := &recordingSink{failOn: map[int64]bool{createdA.ID: true}}
+	res, err := newOutboxService().Relay(ctx, service.RelaySpec{Limit: 10, Sink: sink})
+	require.NoError(t, err)
+	require.Equal(t, 1, res.Failed)
+	require.Equal(t, 2, res.Published)
+
+	// Only wallet b went out; wallet a waits behind its failed event.
+	for _, e := range sink.events {
+		require.NotEqual(t, createdA.PartitionKey, e.PartitionKey)
+	}
+
+	// Nothing of wallet a is due until the failed event's retry time.
+	res, err = newOutboxService().Relay(ctx, service.RelaySpec{Limit: 10, Sink: sink})
+	require.NoError(t, err)
+	require.Equal(t, 0, res.Found)
+
+	_, err = testDB.Exec(`UPDATE outbox SET next_attempt_at = now() WHERE published_at IS NULL`)
+	require.NoError(t, err)
+
+	sink.failOn = nil
+	res, err = newOutboxService().Relay(ctx, service.RelaySpec{Limit: 10, Sink: sink})
+	require.NoError(t, err)
+	require.Equal(t, 2, res.Published)
+
+	require.Len(t, sink.events, 4)
+	require.Equal(t, createdA.ID, sink.events[2].ID)
+	require.Equal(t, domain.OutboxEventWithdrawSucceeded, sink.events[3].Type)
+
+	for _, e := range listOutbox(t) {
+		require.NotNil(t, e.PublishedAt)
+	}
+}
@@ -0,0 +1,127 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/vcnt72/go-boilerplate/internal/domain"
+	"github.com/vcnt72/go-boilerplate/internal/outbox"
+	"github.com/vcnt72/go-boilerplate/internal/repository"
+)
+
+const (
+	outboxRetryBase = time.Second
+	outboxRetryMax  = 5 * time.Minute
+)
+
+type OutboxService struct {
+	outboxRepository repository.OutboxRepository
+	txProvider       repository.TxProvider
+}
+
+type RelaySpec struct {
+	Limit int
+	// Lease keeps a claimed batch from other relays while it is published, so
+	// it should outlast publishing a whole batch.
+	Lease time.Duration
+	Sink  outbox.Sink
+}
+
+type RelayResult struct {
+	Found     int
+	Published int
+	Failed    int
+}
+
+// Relay publishes one batch of pending events to spec.Sink.
+//
+// The batch is claimed in a short transaction that postpones it by
+// spec.Lease, then published with no transaction open, so a slow sink holds
+// no locks. A claimed event holds back the later events of its partition, so
+// concurrent relays never publish a partition out of order while the lease
+// lasts. Each event is marked published by a statement of its own once the
+// sink accepted it. Delivery is at-least-once: an event that is not marked,
+// because the relay stopped or the lease ran out first, is published again.
+// When an event fails, the rest of its partition is released and held back
+// until the event is retried.
+func (o OutboxService) Relay(ctx context.Context, spec RelaySpec) (*RelayResult, error) {
+	var events []domain.OutboxEvent
+	err := o.txProvider.Tx(ctx, func(ctx context.Context) error {
+		var err error
+		events, err = o.outboxRepository.ClaimPending(ctx, spec.Limit, spec.Lease)
+		return err
+	})
+	if err != nil {
+		return nil, errors.Join(errors.New("OutboxService.Relay: error on outbox repository claim"), err)
+	}
+
+	result := &RelayResult{Found: len(events)}
+	blocked := map[string]bool{}
+	for _, event := range events {
+		if blocked[event.PartitionKey] {
+			if err := o.outboxRepository.Release(ctx, event.ID); err != nil {
+				return nil, errors.Join(errors.New("OutboxService.Relay: error on outbox repository release"), err)
+			}
+			continue
+		}
+
+		if err := spec.Sink.Publish(ctx, event); err != nil {
+			blocked[event.PartitionKey] = true
+			result.Failed++
+
+			if err := o.outboxRepository.MarkFailed(ctx, event.ID, err.Error(), retryDelay(outboxRetryBase, outboxRetryMax, event.Attempts)); err != nil {
+				return nil, errors.Join(errors.New("OutboxService.Relay: error on outbox repository mark failed"), err)
+			}
+			continue
+		}
+
+		if err := o.outboxRepository.MarkPublished(ctx, event.ID); err != nil {
+			return nil, errors.Join(errors.New("OutboxService.Relay: error on outbox repository mark published"), err)
+		}
+		result.Published++
+	}
+
+	return result, nil
+}
+
+// retryDelay doubles base with every failed attempt, up to maxDelay.
+func retryDelay(base, maxDelay time.Duration, attempts int) time.Duration {
+	if attempts >= 30 {
+		return maxDelay
+	}
+
+	return min(base<<attempts, maxDelay)
+}
+
+// writeEvent records event in the outbox and queues it for the webhook
+// subscriptions to its type. It must run in the transaction that made the
+// change the event describes.
+func writeEvent(ctx context.Context, outboxRepository repository.OutboxRepository, webhookRepository repository.WebhookRepository, event domain.OutboxEvent) error {
+	created, err := outboxRepository.Create(ctx, event)
+	if err != nil {
+		return err
+	}
+
+	_, err = webhookRepository.CreateDeliveries(ctx, *created)
+
+	return err
+}
+
+// writeWithdrawEvent records the final outcome of a withdraw ledger and of
+// the fee charged on it, if any.
+func writeWithdrawEvent(ctx context.Context, outboxRepository repository.OutboxRepository, webhookRepository repository.WebhookRepository, userID int64, ledger domain.Ledger, fee *domain.Ledger) error {
+	event, err := domain.NewWithdrawEvent(userID, ledger, fee)
+	if err != nil {
+		return err
+	}
+
+	return writeEvent(ctx, outboxRepository, webhookRepository, event)
+}
+
+func NewOutboxService(outboxRepository repository.OutboxRepository, txProvider repository.TxProvider) *OutboxService {
+	return &OutboxService{
+		outboxRepository: outboxRepository,
+		txProvider:       txProvider,
+	}
+}
@@ -0,0 +1,282 @@
+package service_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/vcnt72/go-boilerplate/internal/domain"
+	"github.com/vcnt72/go-boilerplate/internal/payout"
+	"github.com/vcnt72/go-boilerplate/internal/repository"
+	"github.com/vcnt72/go-boilerplate/internal/service"
+)
+
+func newSimulator(outcome string) *payout.Simulator {
+	sim, err := payout.NewSimulator(outcome)
+	if err != nil {
+		panic(err)
+	}
+
+	return sim
+}
+
+func newPayoutService(provider service.PayoutProvider) *service.PayoutService {
+	return service.NewPayoutService(
+		repository.NewWalletRepository(testDB),
+		repository.NewLedgerRepository(testDB),
+		repository.NewAccountRepository(testDB),
+		repository.NewJournalRepository(testDB),
+		repository.NewOutboxRepository(testDB),
+		repository.NewWebhookRepository(testDB),
+		repository.NewTxProvider(testDB),
+		provider,
+	)
+}
+
+// unreachableProvider fails every Send until it is switched on.
+type unreachableProvider struct {
+	mu   sync.Mutex
+	up   bool
+	sent int
+}
+
+func (u *unreachableProvider) Send(_ context.Context, _ domain.PayoutRequest) (domain.PayoutResponse, error) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	u.sent++
+	if !u.up {
+		return domain.PayoutResponse{}, errors.New("connection refused")
+	}
+
+	return domain.PayoutResponse{Status: domain.PayoutStatusSucceeded}, nil
+}
+
+func getPayoutLedgers(t *testing.T, reference string) []domain.Ledger {
+	ledgers, err := repository.NewLedgerRepository(testDB).ListByPayoutReference(context.Background(), reference)
+	require.NoError(t, err)
+	return ledgers
+}
+
+func requireNoDrift(t *testing.T) {
+	summary, err := newReconcileService().Reconcile(context.Background(), service.ReconcileSpec{})
+	require.NoError(t, err)
+	require.Equal(t, int64(0), summary.Drifted)
+}
+
+func TestIntegration_Payout_Succeeded(t *testing.T) {
+	cleanDB(t)
+
+	ctx := context.Background()
+	svc := newWalletServiceWithFee(domain.FeeSchedule{Flat: 1_000})
+
+	seedUser(t, 1)
+	seedWallet(t, 1, 100_000)
+
+	res, err := svc.Withdraw(ctx, service.WithdrawWalletSpec{UserID: 1, Amount: idr(30_000), IdempotencyKey: "k-payout"})
+	require.NoError(t, err)
+	require.Equal(t, domain.LedgerStatusSucceed, res.Status)
+	require.NotEmpty(t, res.PayoutReference)
+	require.Equal(t, idr(69_000), res.Balance)
+
+	ledgers := getPayoutLedgers(t, res.PayoutReference)
+	require.Len(t, ledgers, 2)
+	for _, l := range ledgers {
+		require.Equal(t, domain.LedgerStatusSucceed, l.Status)
+	}
+
+	requireNoDrift(t)
+}
+
+func TestIntegration_Payout_FailedRestoresBalance(t *testing.T) {
+	cleanDB(t)
+
+	ctx := context.Background()
+	svc := newWalletServiceWithProvider(domain.WithdrawalLimit{}, domain.FeeSchedule{Flat: 1_000}, newSimulator(payout.OutcomeFail))
+
+	seedUser(t, 1)
+	seedWallet(t, 1, 100_000)
+
+	spec := service.WithdrawWalletSpec{UserID: 1, Amount: idr(30_000), IdempotencyKey: "k-payout-fail"}
+	_, err := svc.Withdraw(ctx, spec)
+	require.True(t, errors.Is(err, domain.ErrPayoutFailed))
+
+	// Both the amount and the fee come back.
+	require.Equal(t, int64(100_000), getBalance(t, 1))
+	require.Equal(t, int64(0), getFeeRevenue(t, "IDR"))
+
+	var reference string
+	err = testDB.QueryRowx(`SELECT payout_reference FROM ledgers WHERE idempotency_key = $1`, "k-payout-fail").Scan(&reference)
+	require.NoError(t, err)
+
+	ledgers := getPayoutLedgers(t, reference)
+	require.Len(t, ledgers, 2)
+	for _, l := range ledgers {
+		require.Equal(t, domain.LedgerStatusFailed, l.Status)
+		require.Equal(t, domain.LedgerErrorCodePayoutFailed, *l.ErrorCode)
+		require.Equal(t, int64(100_000), l.ResultBalance.Amount)
+	}
+
+	_, err = svc.Withdraw(ctx, spec)
+	require.True(t, errors.Is(err, domain.ErrPayoutFailed))
+
+	events := listOutbox(t)
+	require.Equal(t, domain.OutboxEventWithdrawFailed, events[len(events)-1].Type)
+
+	requireNoDrift(t)
+}
+
+func TestIntegration_Payout_PendingThenCallback(t *testing.T) {
+	cleanDB(t)
+
+	ctx := context.Background()
+	sim := newSimulator(payout.OutcomePending)
+	svc := newWalletServiceWithProvider(domain.WithdrawalLimit{MaxDailyCount: 2}, domain.FeeSchedule{Flat: 1_000}, sim)
+	payouts := newPayoutService(sim)
+
+	seedUser(t, 1)
+	seedWallet(t, 1, 100_000)
+
+	spec := service.WithdrawWalletSpec{UserID: 1, Amount: idr(30_000), IdempotencyKey: "k-pending"}
+	res, err := svc.Withdraw(ctx, spec)
+	require.NoError(t, err)
+	require.Equal(t, domain.LedgerStatusSent, res.Status)
+	require.Equal(t, idr(69_000), res.Balance)
+
+	// Funds stay reserved while the payout is pending, and it counts
+	// toward the limits.
+	require.Equal(t, int64(69_000), getBalance(t, 1))
+	requireNoDrift(t)
+
+	replay, err := svc.Withdraw(ctx, spec)
+	require.NoError(t, err)
+	require.Equal(t, res, replay)
+
+	_, err = svc.Withdraw(ctx, service.WithdrawWalletSpec{UserID: 1, Amount: idr(1_000), IdempotencyKey: "k-pending-2"})
+	require.NoError(t, err)
+	_, err = svc.Withdraw(ctx, service.WithdrawWalletSpec{UserID: 1, Amount: idr(1_000), IdempotencyKey: "k-pending-3"})
+	require.True(t, errors.Is(err, domain.ErrLimitExceeded))
+
+	done, err := payouts.Complete(ctx, service.CompletePayoutSpec{Reference: res.PayoutReference, Status: domain.PayoutStatusSucceeded})
+	require.NoError(t, err)
+	require.Equal(t, domain.LedgerStatusSucceed, done.Withdrawal.Status)
+	require.Equal(t, domain.LedgerStatusSucceed, done.Fee.Status)
+
+	// Callbacks may be delivered again; the opposite outcome is refused.
+	_, err = payouts.Complete(ctx, service.CompletePayoutSpec{Reference: res.PayoutReference, Status: domain.PayoutStatusSucceeded})
+	require.NoError(t, err)
+	_, err = payouts.Complete(ctx, service.CompletePayoutSpec{Reference: res.PayoutReference, Status: domain.PayoutStatusSent})
+	require.NoError(t, err)
+	_, err = payouts.Complete(ctx, service.CompletePayoutSpec{Reference: res.PayoutReference, Status: domain.PayoutStatusFailed})
+	require.True(t, errors.Is(err, domain.ErrPayoutConflict))
+
+	replay, err = svc.Withdraw(ctx, spec)
+	require.NoError(t, err)
+	require.Equal(t, domain.LedgerStatusSucceed, replay.Status)
+
+	_, err = payouts.Complete(ctx, service.CompletePayoutSpec{Reference: "unknown", Status: domain.PayoutStatusSucceeded})
+	require.True(t, errors.Is(err, domain.ErrPayoutNotFound))
+	_, err = payouts.Complete(ctx, service.CompletePayoutSpec{Reference: res.PayoutReference, Status: "PAID"})
+	require.True(t, errors.Is(err, domain.ErrInvalidPayoutStatus))
+
+	require.Equal(t, int64(68_000-1_000), getBalance(t, 1))
+	requireNoDrift(t)
+}
+
+func TestIntegration_Payout_CallbackFailure(t *testing.T) {
+	cleanDB(t)
+
+	ctx := context.Background()
+	sim := newSimulator(payout.OutcomePending)
+	svc := newWalletServiceWithProvider(domain.WithdrawalLimit{}, domain.FeeSchedule{Flat: 1_000}, sim)
+	payouts := newPayoutService(sim)
+
+	seedUser(t, 1)
+	seedWallet(t, 1, 100_000)
+
+	res, err := svc.Withdraw(ctx, service.WithdrawWalletSpec{UserID: 1, Amount: idr(30_000), IdempotencyKey: "k-callback-fail"})
+	require.NoError(t, err)
+
+	_, err = payouts.Complete(ctx, service.CompletePayoutSpec{Reference: res.PayoutReference, Status: domain.PayoutStatusFailed})
+	require.NoError(t, err)
+	_, err = payouts.Complete(ctx, service.CompletePayoutSpec{Reference: res.PayoutReference, Status: domain.PayoutStatusFailed})
+	require.NoError(t, err)
+
+	// Restored exactly once despite the repeated callback.
+	require.Equal(t, int64(100_000), getBalance(t, 1))
+	require.Equal(t, int64(0), getFeeRevenue(t, "IDR"))
+	requireNoDrift(t)
+
+	_, err = payouts.Complete(ctx, service.CompletePayoutSpec{Reference: res.PayoutReference, Status: domain.PayoutStatusSucceeded})
+	require.True(t, errors.Is(err, domain.ErrPayoutConflict))
+}
+
+func TestIntegration_Payout_ResendStale(t *testing.T) {
+	cleanDB(t)
+
+	ctx := context.Background()
+	provider := &unreachableProvider{}
+	svc := newWalletServiceWithProvider(domain.WithdrawalLimit{}, domain.FeeSchedule{}, provider)
+	payouts := newPayoutService(provider)
+
+	seedUser(t, 1)
+	seedWallet(t, 1, 100_000)
+
+	// An unreachable provider leaves the accepted withdrawal PROCESSING.
+	res, err := svc.Withdraw(ctx, service.WithdrawWalletSpec{UserID: 1, Amount: idr(30_000), IdempotencyKey: "k-resend"})
+	require.NoError(t, err)
+	require.Equal(t, domain.LedgerStatusProcessing, res.Status)
+	require.Equal(t, int64(70_000), getBalance(t, 1))
+
+	// The stale-ledger recovery leaves payouts alone.
+	resolved, err := newRecoveryService().ResolveStaleProcessing(ctx, service.ResolveStaleSpec{Limit: 10})
+	require.NoError(t, err)
+	require.Equal(t, 0, resolved.Found)
+
+	_, err = payouts.ResendStale(ctx, service.ResendStaleSpec{Limit: 10})
+	require.Error(t, err)
+
+	provider.up = true
+	resent, err := payouts.ResendStale(ctx, service.ResendStaleSpec{Limit: 10})
+	require.NoError(t, err)
+	require.Equal(t, 1, resent.Settled)
+	require.Equal(t, 3, provider.sent)
+
+	ledgers := getPayoutLedgers(t, res.PayoutReference)
+	require.Equal(t, domain.LedgerStatusSucceed, ledgers[0].Status)
+	require.Equal(t, int64(70_000), getBalance(t, 1))
+	requireNoDrift(t)
+}
+
+func TestIntegration_Payout_ResendPollsMissedCallback(t *testing.T) {
+	cleanDB(t)
+
+	ctx := context.Background()
+	sim := newSimulator(payout.OutcomePending)
+	svc := newWalletServiceWithProvider(domain.WithdrawalLimit{}, domain.FeeSchedule{}, sim)
+	payouts := newPayoutService(sim)
+
+	seedUser(t, 1)
+	seedWallet(t, 1, 100_000)
+
+	res, err := svc.Withdraw(ctx, service.WithdrawWalletSpec{UserID: 1, Amount: idr(30_000), IdempotencyKey: "k-missed"})
+	require.NoError(t, err)
+	require.Equal(t, domain.LedgerStatusSent, res.Status)
+
+	// Not stale yet.
+	resent, err := payouts.ResendStale(ctx, service.ResendStaleSpec{StaleAfter: time.Hour, Limit: 10})
+	require.NoError(t, err)
+	require.Equal(t, 0, resent.Found)
+
+	sim.Settle(res.PayoutReference, domain.PayoutResponse{Status: domain.PayoutStatusFailed})
+
+	resent, err = payouts.ResendStale(ctx, service.ResendStaleSpec{Limit: 10})
+	require.NoError(t, err)
+	require.Equal(t, 1, resent.Settled)
+	require.Equal(t, int64(100_000), getBalance(t, 1))
+	requireNoDrift(t)
+}
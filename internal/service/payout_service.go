@@ -0,0 +1,319 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/vcnt72/go-boilerplate/internal/domain"
+	"github.com/vcnt72/go-boilerplate/internal/repository"
+)
+
+// PayoutProvider sends withdrawn funds out of the system. Send must be
+// idempotent on the request reference: sending a reference again returns the
+// payout's current status instead of paying twice.
+type PayoutProvider interface {
+	Send(ctx context.Context, req domain.PayoutRequest) (domain.PayoutResponse, error)
+}
+
+// PayoutService moves withdrawals through the payout provider. A withdrawal
+// debits the wallet and books to payout clearing while PROCESSING; the
+// provider then reports it SENT and finally SUCCEEDED or FAILED. A failed
+// payout gives the withdrawn amount and its fee back to the wallet.
+type PayoutService struct {
+	walletRepository  repository.WalletRepository
+	ledgerRepository  repository.LedgerRepository
+	accountRepository repository.AccountRepository
+	journalRepository repository.JournalRepository
+	outboxRepository  repository.OutboxRepository
+	webhookRepository repository.WebhookRepository
+	txProvider        repository.TxProvider
+	provider          PayoutProvider
+}
+
+type PayoutResult struct {
+	Reference string
+	// Withdrawal and Fee are the payout's ledgers after the update. Fee is
+	// nil when the withdrawal was free.
+	Withdrawal domain.Ledger
+	Fee        *domain.Ledger
+}
+
+// Balance is the wallet balance after the payout's last ledger.
+func (p PayoutResult) Balance() *domain.Money {
+	if p.Fee != nil {
+		return p.Fee.ResultBalance
+	}
+
+	return p.Withdrawal.ResultBalance
+}
+
+// Dispatch sends the payout to the provider and applies the status it
+// answers with. Payouts already settled are returned as they are. When the
+// provider cannot be reached the payout stays PROCESSING and the error is
+// returned; ResendStale retries it later.
+func (p PayoutService) Dispatch(ctx context.Context, reference string) (*PayoutResult, error) {
+	ledger, err := p.ledgerRepository.GetByPayoutReference(ctx, reference)
+	if err != nil {
+		if errors.Is(err, domain.ErrLedgerNotFound) {
+			return nil, domain.ErrPayoutNotFound
+		}
+
+		return nil, errors.Join(errors.New("PayoutService.Dispatch: error on ledger repository get"), err)
+	}
+
+	if ledger.Status == domain.LedgerStatusSucceed || ledger.Status == domain.LedgerStatusFailed {
+		return p.result(ctx, p.ledgerRepository, *ledger)
+	}
+
+	wallet, err := p.walletRepository.GetByID(ctx, ledger.WalletID)
+	if err != nil {
+		return nil, errors.Join(errors.New("PayoutService.Dispatch: error on wallet repository get"), err)
+	}
+
+	res, err := p.provider.Send(ctx, domain.PayoutRequest{
+		Reference: reference,
+		UserID:    wallet.UserID,
+		WalletID:  wallet.ID,
+		Amount:    ledger.Amount,
+	})
+	if err != nil {
+		return nil, errors.Join(errors.New("PayoutService.Dispatch: error on payout provider send"), err)
+	}
+
+	return p.apply(ctx, reference, res.Status)
+}
+
+type CompletePayoutSpec struct {
+	Reference string
+	Status    domain.PayoutStatus
+}
+
+// Complete applies a status reported by the provider's callback. Reporting
+// the status a payout already has is a no-op, so callbacks may be delivered
+// more than once; reporting the opposite final status fails with
+// ErrPayoutConflict.
+func (p PayoutService) Complete(ctx context.Context, spec CompletePayoutSpec) (*PayoutResult, error) {
+	if !domain.ValidPayoutStatus(spec.Status) {
+		return nil, domain.ErrInvalidPayoutStatus
+	}
+
+	return p.apply(ctx, spec.Reference, spec.Status)
+}
+
+func (p PayoutService) apply(ctx context.Context, reference string, status domain.PayoutStatus) (*PayoutResult, error) {
+	ledger, err := p.ledgerRepository.GetByPayoutReference(ctx, reference)
+	if err != nil {
+		if errors.Is(err, domain.ErrLedgerNotFound) {
+			return nil, domain.ErrPayoutNotFound
+		}
+
+		return nil, errors.Join(errors.New("PayoutService.apply: error on ledger repository get"), err)
+	}
+
+	var result *PayoutResult
+	err = p.txProvider.Tx(ctx, func(ctx context.Context) error {
+		// Lock the wallet before the ledger, in the same order as Withdraw.
+		wallet, err := p.walletRepository.LockByID(ctx, ledger.WalletID)
+		if err != nil {
+			return errors.Join(errors.New("PayoutService.apply: error on wallet repository lock"), err)
+		}
+
+		ledger, err := p.ledgerRepository.LockPayout(ctx, reference)
+		if err != nil {
+			return errors.Join(errors.New("PayoutService.apply: error on ledger repository lock"), err)
+		}
+
+		result, err = p.result(ctx, p.ledgerRepository, *ledger)
+		if err != nil {
+			return err
+		}
+
+		switch status {
+		case domain.PayoutStatusSent:
+			// A late SENT never moves a payout back from a final status.
+			if ledger.Status != domain.LedgerStatusProcessing {
+				return nil
+			}
+
+			return p.update(ctx, result, domain.LedgerStatusSent, nil)
+
+		case domain.PayoutStatusSucceeded:
+			switch ledger.Status {
+			case domain.LedgerStatusSucceed:
+				return nil
+			case domain.LedgerStatusFailed:
+				return domain.ErrPayoutConflict
+			}
+
+			if err := p.update(ctx, result, domain.LedgerStatusSucceed, nil); err != nil {
+				return err
+			}
+
+			return writeWithdrawEvent(ctx, p.outboxRepository, p.webhookRepository, wallet.UserID, result.Withdrawal, result.Fee)
+
+		default:
+			switch ledger.Status {
+			case domain.LedgerStatusFailed:
+				return nil
+			case domain.LedgerStatusSucceed:
+				return domain.ErrPayoutConflict
+			}
+
+			return p.restore(ctx, wallet, result)
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// restore gives a failed payout's amount and fee back to the wallet, undoing
+// the journals booked when the withdrawal was accepted.
+func (p PayoutService) restore(ctx context.Context, wallet *domain.Wallet, result *PayoutResult) error {
+	withdrawal := result.Withdrawal
+	refund := withdrawal.Amount
+	if result.Fee != nil {
+		var err error
+		refund, err = refund.Add(result.Fee.Amount)
+		if err != nil {
+			return err
+		}
+	}
+
+	balance, err := p.walletRepository.IncreaseBalance(ctx, refund, wallet.ID)
+	if err != nil {
+		return errors.Join(errors.New("PayoutService.restore: error on wallet repository increase"), err)
+	}
+
+	err = bookJournal(ctx, p.accountRepository, p.journalRepository, domain.JournalTypePayoutRestore, &withdrawal.ID, withdrawal.Amount,
+		systemAccount(domain.SystemAccountPayoutClearing, withdrawal.Currency), walletAccount(wallet.ID))
+	if err != nil {
+		return err
+	}
+
+	if result.Fee != nil {
+		err = bookJournal(ctx, p.accountRepository, p.journalRepository, domain.JournalTypePayoutRestore, &result.Fee.ID, result.Fee.Amount,
+			systemAccount(domain.SystemAccountFeeRevenue, result.Fee.Currency), walletAccount(wallet.ID))
+		if err != nil {
+			return err
+		}
+	}
+
+	// The failed ledgers report the balance after the restore.
+	result.Withdrawal.ResultBalance = &balance
+	if result.Fee != nil {
+		result.Fee.ResultBalance = &balance
+	}
+
+	errCode := domain.LedgerErrorCodePayoutFailed
+	if err := p.update(ctx, result, domain.LedgerStatusFailed, &errCode); err != nil {
+		return err
+	}
+
+	return writeWithdrawEvent(ctx, p.outboxRepository, p.webhookRepository, wallet.UserID, result.Withdrawal, result.Fee)
+}
+
+// update moves both ledgers of the payout to status.
+func (p PayoutService) update(ctx context.Context, result *PayoutResult, status domain.LedgerStatus, errorCode *string) error {
+	result.Withdrawal.Status = status
+	result.Withdrawal.ErrorCode = errorCode
+	if err := p.ledgerRepository.Update(ctx, result.Withdrawal); err != nil {
+		return errors.Join(errors.New("PayoutService.update: error on ledger repository update"), err)
+	}
+
+	if result.Fee == nil {
+		return nil
+	}
+
+	result.Fee.Status = status
+	result.Fee.ErrorCode = errorCode
+	if err := p.ledgerRepository.Update(ctx, *result.Fee); err != nil {
+		return errors.Join(errors.New("PayoutService.update: error on ledger repository update"), err)
+	}
+
+	return nil
+}
+
+// result loads the FEE ledger sharing the withdrawal's payout reference.
+func (p PayoutService) result(ctx context.Context, ledgerRepository repository.LedgerRepository, withdrawal domain.Ledger) (*PayoutResult, error) {
+	result := &PayoutResult{
+		Reference:  *withdrawal.PayoutReference,
+		Withdrawal: withdrawal,
+	}
+
+	ledgers, err := ledgerRepository.ListByPayoutReference(ctx, result.Reference)
+	if err != nil {
+		return nil, errors.Join(errors.New("PayoutService.result: error on ledger repository list"), err)
+	}
+
+	for _, l := range ledgers {
+		if l.Type == domain.LedgerTypeFee {
+			result.Fee = &l
+		}
+	}
+
+	return result, nil
+}
+
+type ResendStaleSpec struct {
+	StaleAfter time.Duration
+	Limit      int
+}
+
+type ResendStaleResult struct {
+	Found   int
+	Settled int
+	// Errors holds why payouts could not be resent, one per payout.
+	Errors []error
+}
+
+// ResendStale dispatches payouts left PROCESSING or SENT for longer than
+// spec.StaleAfter again, covering both a provider that could not be reached
+// and a callback that never arrived. Each payout is touched before it is
+// sent, so that payouts the provider keeps failing or holding as pending
+// move to the back of the queue instead of taking every batch. A payout that
+// cannot be resent is recorded in the result and the rest still are.
+func (p PayoutService) ResendStale(ctx context.Context, spec ResendStaleSpec) (*ResendStaleResult, error) {
+	ledgers, err := p.ledgerRepository.ListStalePayouts(ctx, time.Now().Add(-spec.StaleAfter), spec.Limit)
+	if err != nil {
+		return nil, errors.Join(errors.New("PayoutService.ResendStale: error on ledger repository list"), err)
+	}
+
+	result := &ResendStaleResult{Found: len(ledgers)}
+	for _, l := range ledgers {
+		reference := *l.PayoutReference
+		if err := p.ledgerRepository.TouchPayout(ctx, reference); err != nil {
+			result.Errors = append(result.Errors, fmt.Errorf("PayoutService.ResendStale: error on ledger repository touch of payout %s: %w", reference, err))
+			continue
+		}
+
+		res, err := p.Dispatch(ctx, reference)
+		if err != nil {
+			result.Errors = append(result.Errors, fmt.Errorf("PayoutService.ResendStale: error on dispatch of payout %s: %w", reference, err))
+			continue
+		}
+
+		if res.Withdrawal.Status == domain.LedgerStatusSucceed || res.Withdrawal.Status == domain.LedgerStatusFailed {
+			result.Settled++
+		}
+	}
+
+	return result, nil
+}
+
+func NewPayoutService(walletRepository repository.WalletRepository, ledgerRepository repository.LedgerRepository, accountRepository repository.AccountRepository, journalRepository repository.JournalRepository, outboxRepository repository.OutboxRepository, webhookRepository repository.WebhookRepository, txProvider repository.TxProvider, provider PayoutProvider) *PayoutService {
+	return &PayoutService{
+		walletRepository:  walletRepository,
+		ledgerRepository:  ledgerRepository,
+		accountRepository: accountRepository,
+		journalRepository: journalRepository,
+		outboxRepository:  outboxRepository,
+		webhookRepository: webhookRepository,
+		txProvider:        txProvider,
+		provider:          provider,
+	}
+}
@@ -0,0 +1,80 @@
+package service_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/vcnt72/go-boilerplate/internal/domain"
+	"github.com/vcnt72/go-boilerplate/internal/service"
+)
+
+func countAllLedgers(t *testing.T) int {
+	var c int
+	err := testDB.QueryRowx(`SELECT COUNT(1) FROM ledgers`).Scan(&c)
+	require.NoError(t, err)
+	return c
+}
+
+func TestIntegration_PreviewWithdraw_MatchesWithdraw(t *testing.T) {
+	cleanDB(t)
+
+	ctx := context.Background()
+	svc := newWalletServiceWith(
+		domain.WithdrawalLimit{MaxPerTransaction: 200_000, MaxDailyAmount: 300_000, MaxDailyCount: 5},
+		domain.FeeSchedule{Flat: 1_000, PercentBps: 50},
+	)
+
+	seedUser(t, 1)
+	seedWallet(t, 1, 1_000_000)
+
+	_, err := svc.Withdraw(ctx, service.WithdrawWalletSpec{UserID: 1, Amount: idr(50_000), IdempotencyKey: "k-1"})
+	require.NoError(t, err)
+	ledgers := countAllLedgers(t)
+
+	preview, err := svc.PreviewWithdraw(ctx, service.PreviewWithdrawSpec{UserID: 1, Amount: idr(100_000)})
+	require.NoError(t, err)
+	require.Equal(t, idr(100_000), preview.Amount)
+	require.Equal(t, idr(1_500), preview.Fee)
+	require.Equal(t, idr(101_500), preview.Debit)
+	require.Equal(t, idr(847_250), preview.Balance)
+	require.Equal(t, idr(200_000), *preview.MaxPerTransaction)
+	require.Equal(t, idr(150_000), *preview.RemainingDailyAmount)
+	require.Equal(t, int64(3), *preview.RemainingDailyCount)
+
+	// Nothing was written.
+	require.Equal(t, ledgers, countAllLedgers(t))
+	require.Equal(t, int64(948_750), getBalance(t, 1))
+
+	res, err := svc.Withdraw(ctx, service.WithdrawWalletSpec{UserID: 1, Amount: idr(100_000), IdempotencyKey: "k-2"})
+	require.NoError(t, err)
+	require.Equal(t, preview.Fee, res.Fee)
+	require.Equal(t, preview.Balance, res.Balance)
+}
+
+func TestIntegration_PreviewWithdraw_Rejections(t *testing.T) {
+	cleanDB(t)
+
+	ctx := context.Background()
+	svc := newWalletServiceWith(domain.WithdrawalLimit{MaxPerTransaction: 50_000}, domain.FeeSchedule{Flat: 2_500})
+
+	seedUser(t, 1)
+	seedWallet(t, 1, 40_000)
+
+	_, err := svc.PreviewWithdraw(ctx, service.PreviewWithdrawSpec{UserID: 1, Amount: idr(50_001)})
+	require.True(t, errors.Is(err, domain.ErrLimitExceeded))
+
+	// The fee pushes the debit over the balance.
+	_, err = svc.PreviewWithdraw(ctx, service.PreviewWithdrawSpec{UserID: 1, Amount: idr(39_000)})
+	require.True(t, errors.Is(err, domain.ErrInsufficientFund))
+
+	_, err = svc.PreviewWithdraw(ctx, service.PreviewWithdrawSpec{UserID: 1, Amount: idr(0)})
+	require.True(t, errors.Is(err, domain.ErrInvalidAmount))
+
+	_, err = svc.PreviewWithdraw(ctx, service.PreviewWithdrawSpec{UserID: 1, Amount: domain.NewMoney(100, "USD")})
+	require.True(t, errors.Is(err, domain.ErrCurrencyMismatch))
+
+	require.Equal(t, 0, countAllLedgers(t))
+}
@@ -0,0 +1,74 @@
+package service_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/vcnt72/go-boilerplate/internal/domain"
+	"github.com/vcnt72/go-boilerplate/internal/repository"
+	"github.com/vcnt72/go-boilerplate/internal/service"
+)
+
+func newUserService() *service.UserService {
+	return service.NewUserService(
+		repository.NewUserRepository(testDB),
+		repository.NewWalletRepository(testDB),
+		repository.NewLedgerRepository(testDB),
+		repository.NewAccountRepository(testDB),
+		repository.NewJournalRepository(testDB),
+		repository.NewOutboxRepository(testDB),
+		repository.NewWebhookRepository(testDB),
+		repository.NewTxProvider(testDB),
+		"IDR",
+	)
+}
+
+func newReconcileService() *service.ReconcileService {
+	return service.NewReconcileService(
+		repository.NewWalletRepository(testDB),
+		repository.NewLedgerRepository(testDB),
+		repository.NewJournalRepository(testDB),
+	)
+}
+
+func TestIntegration_Reconcile_ReportsDriftOnly(t *testing.T) {
+	cleanDB(t)
+
+	ctx := context.Background()
+	users := newUserService()
+	wallets := newWalletService()
+
+	var userIDs []int64
+	for range 5 {
+		u, err := users.Create(ctx, service.CreateUserSpec{Name: "test", Balance: idr(100_000)})
+		require.NoError(t, err)
+		userIDs = append(userIDs, u.ID)
+	}
+
+	_, err := wallets.Withdraw(ctx, service.WithdrawWalletSpec{UserID: userIDs[0], Amount: idr(30_000), IdempotencyKey: "k-r-1"})
+	require.NoError(t, err)
+	_, err = wallets.Transfer(ctx, service.TransferWalletSpec{FromUserID: userIDs[1], ToUserID: userIDs[2], Amount: idr(5_000), IdempotencyKey: "k-r-2"})
+	require.NoError(t, err)
+
+	_, err = testDB.Exec(`UPDATE wallets SET balance = balance + 1 WHERE user_id = $1`, userIDs[3])
+	require.NoError(t, err)
+
+	var drifts []domain.WalletDrift
+	summary, err := newReconcileService().Reconcile(ctx, service.ReconcileSpec{
+		BatchSize: 2,
+		OnDrift: func(d domain.WalletDrift) error {
+			drifts = append(drifts, d)
+			return nil
+		},
+	})
+	require.NoError(t, err)
+	require.Equal(t, int64(5), summary.Checked)
+	require.Equal(t, int64(1), summary.Drifted)
+	require.Len(t, drifts, 1)
+	require.Equal(t, userIDs[3], drifts[0].UserID)
+	require.Equal(t, int64(100_001), drifts[0].Balance)
+	require.Equal(t, int64(100_000), drifts[0].LedgerBalance)
+	require.Equal(t, int64(100_000), drifts[0].PostingsBalance)
+}
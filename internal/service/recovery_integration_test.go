@@ -0,0 +1,63 @@
+package service_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/vcnt72/go-boilerplate/internal/domain"
+	"github.com/vcnt72/go-boilerplate/internal/repository"
+	"github.com/vcnt72/go-boilerplate/internal/service"
+)
+
+func seedProcessingLedger(t *testing.T, userID int64, key string, amount int64, age time.Duration) {
+	_, err := testDB.Exec(`
+		INSERT INTO ledgers (wallet_id, type, idempotency_key, amount, status, created_at, updated_at)
+		SELECT id, $2, $3, $4, $5, now() - make_interval(secs => $6), now() - make_interval(secs => $6)
+		FROM wallets WHERE user_id = $1
+	`, userID, domain.LedgerTypeWithdraw, key, amount, domain.LedgerStatusProcessing, age.Seconds())
+	require.NoError(t, err)
+}
+
+func newRecoveryService() *service.RecoveryService {
+	return service.NewRecoveryService(
+		repository.NewWalletRepository(testDB),
+		repository.NewLedgerRepository(testDB),
+		repository.NewHoldRepository(testDB),
+		repository.NewOutboxRepository(testDB),
+		repository.NewWebhookRepository(testDB),
+		repository.NewTxProvider(testDB),
+	)
+}
+
+func TestIntegration_Recovery_ResolvesStaleProcessing(t *testing.T) {
+	cleanDB(t)
+
+	ctx := context.Background()
+	svc := newWalletService()
+	recovery := newRecoveryService()
+
+	seedUser(t, 1)
+	seedWallet(t, 1, 100_000)
+	seedProcessingLedger(t, 1, "k-stale", 30_000, time.Hour)
+	seedProcessingLedger(t, 1, "k-fresh", 30_000, 0)
+
+	_, err := svc.Withdraw(ctx, service.WithdrawWalletSpec{UserID: 1, Amount: idr(30_000), IdempotencyKey: "k-stale"})
+	require.True(t, errors.Is(err, domain.ErrRequestInProgress))
+
+	res, err := recovery.ResolveStaleProcessing(ctx, service.ResolveStaleSpec{StaleAfter: 5 * time.Minute, Limit: 10})
+	require.NoError(t, err)
+	require.Equal(t, 1, res.Found)
+	require.Equal(t, 1, res.Resolved)
+
+	_, err = svc.Withdraw(ctx, service.WithdrawWalletSpec{UserID: 1, Amount: idr(30_000), IdempotencyKey: "k-stale"})
+	require.True(t, errors.Is(err, domain.ErrWithdrawFailed))
+
+	_, err = svc.Withdraw(ctx, service.WithdrawWalletSpec{UserID: 1, Amount: idr(30_000), IdempotencyKey: "k-fresh"})
+	require.True(t, errors.Is(err, domain.ErrRequestInProgress))
+
+	require.Equal(t, int64(100_000), getBalance(t, 1))
+}
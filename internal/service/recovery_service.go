@@ -0,0 +1,186 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/vcnt72/go-boilerplate/internal/domain"
+	"github.com/vcnt72/go-boilerplate/internal/repository"
+)
+
+type RecoveryService struct {
+	walletRepository  repository.WalletRepository
+	ledgerRepository  repository.LedgerRepository
+	holdRepository    repository.HoldRepository
+	outboxRepository  repository.OutboxRepository
+	webhookRepository repository.WebhookRepository
+	txProvider        repository.TxProvider
+}
+
+type ResolveStaleSpec struct {
+	StaleAfter time.Duration
+	Limit      int
+}
+
+type ResolveStaleResult struct {
+	Found    int
+	Resolved int
+}
+
+// ResolveStaleProcessing fails ledgers stuck in PROCESSING for longer than
+// spec.StaleAfter so that replays stop answering REQUEST_IN_PROGRESS.
+//
+// Balances move in the same transaction that marks a ledger SUCCEED, so a
+// committed PROCESSING ledger never moved money and FAILED is the consistent
+// outcome. Withdrawals pending at the payout provider did move money and are
+// left to PayoutService.ResendStale.
+func (r RecoveryService) ResolveStaleProcessing(ctx context.Context, spec ResolveStaleSpec) (*ResolveStaleResult, error) {
+	ledgers, err := r.ledgerRepository.ListStaleProcessing(ctx, time.Now().Add(-spec.StaleAfter), spec.Limit)
+	if err != nil {
+		return nil, errors.Join(errors.New("RecoveryService.ResolveStaleProcessing: error on ledger repository list"), err)
+	}
+
+	result := &ResolveStaleResult{Found: len(ledgers)}
+	for _, l := range ledgers {
+		ok, err := r.failProcessing(ctx, l)
+		if err != nil {
+			return result, err
+		}
+
+		if ok {
+			result.Resolved++
+		}
+	}
+
+	return result, nil
+}
+
+// failProcessing fails one ledger and, for withdrawals, records the failure in
+// the outbox within the same transaction.
+func (r RecoveryService) failProcessing(ctx context.Context, ledger domain.Ledger) (bool, error) {
+	var ok bool
+	err := r.txProvider.Tx(ctx, func(ctx context.Context) error {
+		var err error
+		ok, err = r.ledgerRepository.FailProcessing(ctx, ledger.ID, domain.LedgerErrorCodeProcessingTimeout)
+		if err != nil {
+			return errors.Join(errors.New("RecoveryService.ResolveStaleProcessing: error on ledger repository update"), err)
+		}
+
+		if !ok || ledger.Type != domain.LedgerTypeWithdraw {
+			return nil
+		}
+
+		wallet, err := r.walletRepository.GetByID(ctx, ledger.WalletID)
+		if err != nil {
+			return errors.Join(errors.New("RecoveryService.ResolveStaleProcessing: error on wallet repository get"), err)
+		}
+
+		errCode := domain.LedgerErrorCodeProcessingTimeout
+		ledger.Status = domain.LedgerStatusFailed
+		ledger.ErrorCode = &errCode
+		if err := writeWithdrawEvent(ctx, r.outboxRepository, r.webhookRepository, wallet.UserID, ledger, nil); err != nil {
+			return errors.Join(errors.New("RecoveryService.ResolveStaleProcessing: error on outbox repository create"), err)
+		}
+
+		return nil
+	})
+
+	return ok, err
+}
+
+type ExpireHoldsSpec struct {
+	Limit int
+}
+
+type ExpireHoldsResult struct {
+	Found   int
+	Expired int
+}
+
+// ExpireHolds releases active holds whose expiry passed, recording a
+// HOLD_EXPIRE ledger for each.
+func (r RecoveryService) ExpireHolds(ctx context.Context, spec ExpireHoldsSpec) (*ExpireHoldsResult, error) {
+	holds, err := r.holdRepository.ListExpired(ctx, time.Now(), spec.Limit)
+	if err != nil {
+		return nil, errors.Join(errors.New("RecoveryService.ExpireHolds: error on hold repository list"), err)
+	}
+
+	result := &ExpireHoldsResult{Found: len(holds)}
+	for _, h := range holds {
+		ok, err := r.expireHold(ctx, h)
+		if err != nil {
+			return result, err
+		}
+
+		if ok {
+			result.Expired++
+		}
+	}
+
+	return result, nil
+}
+
+// expireHold reports false when the hold was settled concurrently.
+func (r RecoveryService) expireHold(ctx context.Context, h domain.Hold) (bool, error) {
+	var ok bool
+	err := r.txProvider.Tx(ctx, func(ctx context.Context) error {
+		if _, err := r.walletRepository.LockByID(ctx, h.WalletID); err != nil {
+			return errors.Join(errors.New("RecoveryService.ExpireHolds: error on wallet repository lock"), err)
+		}
+
+		hold, err := r.holdRepository.GetByWalletID(ctx, h.WalletID, h.ID)
+		if err != nil {
+			return errors.Join(errors.New("RecoveryService.ExpireHolds: error on hold repository get"), err)
+		}
+
+		if !errors.Is(hold.CheckSettle(time.Now()), domain.ErrHoldExpired) {
+			return nil
+		}
+
+		ledger, err := r.ledgerRepository.Create(ctx, domain.Ledger{
+			IdempotencyKey: fmt.Sprintf("hold-expire:%d", hold.ID),
+			Type:           domain.LedgerTypeHoldExpire,
+			WalletID:       hold.WalletID,
+			Status:         domain.LedgerStatusProcessing,
+			Amount:         hold.Amount,
+			HoldID:         &hold.ID,
+		})
+		if err != nil {
+			return errors.Join(errors.New("RecoveryService.ExpireHolds: error on ledger repository create"), err)
+		}
+
+		released := domain.NewMoney(0, hold.Currency)
+		wallet, err := r.walletRepository.SettleHold(ctx, hold.WalletID, hold.Amount, released)
+		if err != nil {
+			return errors.Join(errors.New("RecoveryService.ExpireHolds: error on wallet repository settle"), err)
+		}
+
+		if err := r.holdRepository.Settle(ctx, hold.ID, domain.HoldStatusExpired, released); err != nil {
+			return errors.Join(errors.New("RecoveryService.ExpireHolds: error on hold repository settle"), err)
+		}
+
+		ledger.Status = domain.LedgerStatusSucceed
+		ledger.ResultBalance = &wallet.Balance
+		if err := r.ledgerRepository.Update(ctx, *ledger); err != nil {
+			return errors.Join(errors.New("RecoveryService.ExpireHolds: error on ledger repository update"), err)
+		}
+
+		ok = true
+		return nil
+	})
+
+	return ok, err
+}
+
+func NewRecoveryService(walletRepository repository.WalletRepository, ledgerRepository repository.LedgerRepository, holdRepository repository.HoldRepository, outboxRepository repository.OutboxRepository, webhookRepository repository.WebhookRepository, txProvider repository.TxProvider) *RecoveryService {
+	return &RecoveryService{
+		walletRepository:  walletRepository,
+		ledgerRepository:  ledgerRepository,
+		holdRepository:    holdRepository,
+		outboxRepository:  outboxRepository,
+		webhookRepository: webhookRepository,
+		txProvider:        txProvider,
+	}
+}
@@ -0,0 +1,136 @@
+package service_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/vcnt72/go-boilerplate/internal/domain"
+	"github.com/vcnt72/go-boilerplate/internal/service"
+)
+
+func TestIntegration_Reversal_PartialThenRest(t *testing.T) {
+	cleanDB(t)
+
+	ctx := context.Background()
+	svc := newWalletService()
+
+	seedUser(t, 1)
+	seedWallet(t, 1, 100_000)
+
+	_, err := svc.Withdraw(ctx, service.WithdrawWalletSpec{UserID: 1, Amount: idr(50_000), IdempotencyKey: "k-w"})
+	require.NoError(t, err)
+
+	partial, err := svc.ReverseWithdrawal(ctx, service.ReverseWithdrawalSpec{UserID: 1, WithdrawalKey: "k-w", IdempotencyKey: "k-r1", Amount: idr(20_000)})
+	require.NoError(t, err)
+	require.Equal(t, idr(20_000), partial.Amount)
+	require.Equal(t, int64(20_000), partial.Reversed)
+	require.Equal(t, idr(70_000), partial.Balance)
+
+	replay, err := svc.ReverseWithdrawal(ctx, service.ReverseWithdrawalSpec{UserID: 1, WithdrawalKey: "k-w", IdempotencyKey: "k-r1", Amount: idr(20_000)})
+	require.NoError(t, err)
+	require.Equal(t, partial.LedgerID, replay.LedgerID)
+	require.Equal(t, int64(70_000), getBalance(t, 1))
+
+	_, err = svc.ReverseWithdrawal(ctx, service.ReverseWithdrawalSpec{UserID: 1, WithdrawalKey: "k-w", IdempotencyKey: "k-r2", Amount: idr(40_000)})
+	require.True(t, errors.Is(err, domain.ErrReversalExceeds))
+
+	rest, err := svc.ReverseWithdrawal(ctx, service.ReverseWithdrawalSpec{UserID: 1, WithdrawalKey: "k-w", IdempotencyKey: "k-r3"})
+	require.NoError(t, err)
+	require.Equal(t, idr(30_000), rest.Amount)
+	require.Equal(t, int64(50_000), rest.Reversed)
+	require.Equal(t, int64(100_000), getBalance(t, 1))
+
+	_, err = svc.ReverseWithdrawal(ctx, service.ReverseWithdrawalSpec{UserID: 1, WithdrawalKey: "k-w", IdempotencyKey: "k-r4"})
+	require.True(t, errors.Is(err, domain.ErrReversalExceeds))
+
+	require.Equal(t, getBalance(t, 1), postingsBalance(t, 1))
+
+	var reversed int
+	for _, e := range listOutbox(t) {
+		if e.Type == domain.OutboxEventWithdrawReversed {
+			reversed++
+		}
+	}
+	require.Equal(t, 2, reversed)
+
+	summary, err := newReconcileService().Reconcile(ctx, service.ReconcileSpec{})
+	require.NoError(t, err)
+	require.Equal(t, int64(0), summary.Drifted)
+}
+
+func TestIntegration_Reversal_OnlySucceededWithdrawals(t *testing.T) {
+	cleanDB(t)
+
+	ctx := context.Background()
+	svc := newWalletService()
+
+	seedUser(t, 1)
+	seedWallet(t, 1, 10_000)
+	seedUser(t, 2)
+	seedWallet(t, 2, 10_000)
+
+	_, err := svc.Withdraw(ctx, service.WithdrawWalletSpec{UserID: 1, Amount: idr(50_000), IdempotencyKey: "k-failed"})
+	require.True(t, errors.Is(err, domain.ErrInsufficientFund))
+
+	_, err = svc.ReverseWithdrawal(ctx, service.ReverseWithdrawalSpec{UserID: 1, WithdrawalKey: "k-failed", IdempotencyKey: "k-r"})
+	require.True(t, errors.Is(err, domain.ErrNotReversible))
+
+	_, err = svc.Deposit(ctx, service.DepositWalletSpec{UserID: 1, Amount: idr(1_000), IdempotencyKey: "k-deposit"})
+	require.NoError(t, err)
+
+	_, err = svc.ReverseWithdrawal(ctx, service.ReverseWithdrawalSpec{UserID: 1, WithdrawalKey: "k-deposit", IdempotencyKey: "k-r"})
+	require.True(t, errors.Is(err, domain.ErrNotReversible))
+
+	_, err = svc.ReverseWithdrawal(ctx, service.ReverseWithdrawalSpec{UserID: 1, WithdrawalKey: "k-missing", IdempotencyKey: "k-r"})
+	require.True(t, errors.Is(err, domain.ErrLedgerNotFound))
+
+	// Keys are scoped per wallet: user 2 cannot reverse user 1's withdrawal.
+	_, err = svc.Withdraw(ctx, service.WithdrawWalletSpec{UserID: 1, Amount: idr(5_000), IdempotencyKey: "k-w"})
+	require.NoError(t, err)
+
+	_, err = svc.ReverseWithdrawal(ctx, service.ReverseWithdrawalSpec{UserID: 2, WithdrawalKey: "k-w", IdempotencyKey: "k-r"})
+	require.True(t, errors.Is(err, domain.ErrLedgerNotFound))
+	require.Equal(t, int64(10_000), getBalance(t, 2))
+}
+
+func TestIntegration_Reversal_ConcurrentNeverExceedsOriginal(t *testing.T) {
+	cleanDB(t)
+
+	ctx := context.Background()
+	svc := newWalletService()
+
+	seedUser(t, 1)
+	seedWallet(t, 1, 100_000)
+
+	_, err := svc.Withdraw(ctx, service.WithdrawWalletSpec{UserID: 1, Amount: idr(30_000), IdempotencyKey: "k-w"})
+	require.NoError(t, err)
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	succeeded := 0
+	for i := range 5 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := svc.ReverseWithdrawal(ctx, service.ReverseWithdrawalSpec{
+				UserID:         1,
+				WithdrawalKey:  "k-w",
+				IdempotencyKey: "k-r" + string(rune('a'+i)),
+				Amount:         idr(10_000),
+			})
+			if err == nil {
+				mu.Lock()
+				succeeded++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	require.Equal(t, 3, succeeded)
+	require.Equal(t, int64(100_000), getBalance(t, 1))
+}
@@ -1,24 +1,146 @@
 package service
 
-import "github.com/vcnt72/go-boilerplate/internal/repository"
+import (
+	"errors"
+	"fmt"
+
+	"github.com/vcnt72/go-boilerplate/internal/config"
+	"github.com/vcnt72/go-boilerplate/internal/domain"
+	"github.com/vcnt72/go-boilerplate/internal/fx"
+	"github.com/vcnt72/go-boilerplate/internal/payout"
+	"github.com/vcnt72/go-boilerplate/internal/repository"
+)
 
 type Services struct {
-	UserService   *UserService
-	WalletService *WalletService
+	UserService      *UserService
+	WalletService    *WalletService
+	ReconcileService *ReconcileService
+	RecoveryService  *RecoveryService
+	OutboxService    *OutboxService
+	WebhookService   *WebhookService
+	FXService        *FXService
+	PayoutService    *PayoutService
 }
 
 func New(repositories repository.Repositories) Services {
+	var rates RateSource = repositories.FXRateRepository
+	if config.Env.FXRatesFile != "" {
+		rates = fx.NewFileSource(config.Env.FXRatesFile)
+	}
+
+	provider, err := newPayoutProvider()
+	if err != nil {
+		panic(err)
+	}
+
+	withdrawIsolation, err := repository.ParseIsolationLevel(config.Env.WithdrawIsolation)
+	if err != nil {
+		panic(err)
+	}
+
+	payoutService := NewPayoutService(
+		repositories.WalletRepository,
+		repositories.LedgerRepository,
+		repositories.AccountRepository,
+		repositories.JournalRepository,
+		repositories.OutboxRepository,
+		repositories.WebhookRepository,
+		repositories.TxProvider,
+		provider,
+	)
+
 	return Services{
 		UserService: NewUserService(
 			repositories.UserRepository,
 			repositories.WalletRepository,
 			repositories.LedgerRepository,
+			repositories.AccountRepository,
+			repositories.JournalRepository,
+			repositories.OutboxRepository,
+			repositories.WebhookRepository,
 			repositories.TxProvider,
+			config.Env.DefaultCurrency,
 		),
 		WalletService: NewWalletService(
 			repositories.WalletRepository,
 			repositories.LedgerRepository,
+			repositories.AccountRepository,
+			repositories.JournalRepository,
+			repositories.LimitRepository,
+			repositories.FeeRepository,
+			repositories.OutboxRepository,
+			repositories.WebhookRepository,
+			repositories.HoldRepository,
+			repositories.TxProvider,
+			payoutService,
+			domain.WithdrawalLimit{
+				MaxPerTransaction: config.Env.WithdrawMaxPerTransaction,
+				MaxDailyAmount:    config.Env.WithdrawMaxDailyAmount,
+				MaxDailyCount:     config.Env.WithdrawMaxDailyCount,
+			},
+			domain.FeeSchedule{
+				Flat:       config.Env.WithdrawFeeFlat,
+				PercentBps: config.Env.WithdrawFeePercentBps,
+				MinFee:     config.Env.WithdrawFeeMin,
+				MaxFee:     config.Env.WithdrawFeeMax,
+			},
+			repository.TxOptions{
+				Isolation:  withdrawIsolation,
+				MaxRetries: config.Env.TxMaxRetries,
+			},
+			config.Env.HoldDefaultTTL,
+			config.Env.DefaultCurrency,
+		),
+		ReconcileService: NewReconcileService(
+			repositories.WalletRepository,
+			repositories.LedgerRepository,
+			repositories.JournalRepository,
+		),
+		RecoveryService: NewRecoveryService(
+			repositories.WalletRepository,
+			repositories.LedgerRepository,
+			repositories.HoldRepository,
+			repositories.OutboxRepository,
+			repositories.WebhookRepository,
+			repositories.TxProvider,
+		),
+		OutboxService: NewOutboxService(
+			repositories.OutboxRepository,
 			repositories.TxProvider,
 		),
+		WebhookService: NewWebhookService(
+			repositories.WebhookRepository,
+			repositories.TxProvider,
+		),
+		FXService: NewFXService(
+			repositories.WalletRepository,
+			repositories.LedgerRepository,
+			repositories.AccountRepository,
+			repositories.JournalRepository,
+			repositories.FXRateRepository,
+			repositories.FXQuoteRepository,
+			repositories.TxProvider,
+			rates,
+			config.Env.FXQuoteTTL,
+		),
+		PayoutService: payoutService,
+	}
+}
+
+func newPayoutProvider() (PayoutProvider, error) {
+	switch config.Env.PayoutProvider {
+	case "simulator":
+		sim, err := payout.NewSimulator(config.Env.PayoutSimulatorOutcome)
+		if err != nil {
+			return nil, err
+		}
+		return sim, nil
+	case "http":
+		if config.Env.PayoutProviderURL == "" {
+			return nil, errors.New("PAYOUT_PROVIDER_URL is required for the http payout provider")
+		}
+		return payout.NewHTTPProvider(nil, config.Env.PayoutProviderURL, config.Env.PayoutProviderTimeout), nil
+	default:
+		return nil, fmt.Errorf("unknown payout provider %q", config.Env.PayoutProvider)
 	}
 }
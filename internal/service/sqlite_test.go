@@ -0,0 +1,202 @@
+package service_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/pressly/goose/v3"
+	"github.com/stretchr/testify/require"
+
+	"github.com/vcnt72/go-boilerplate/internal/database"
+	"github.com/vcnt72/go-boilerplate/internal/domain"
+	"github.com/vcnt72/go-boilerplate/internal/payout"
+	"github.com/vcnt72/go-boilerplate/internal/repository"
+	"github.com/vcnt72/go-boilerplate/internal/service"
+)
+
+// newSQLiteDB opens a migrated SQLite database in a temporary directory.
+func newSQLiteDB(t *testing.T) *sqlx.DB {
+	t.Helper()
+
+	db, err := sqlx.Open("sqlite3", database.SQLiteDSN(filepath.Join(t.TempDir(), "wallet.db")))
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+
+	provider, err := goose.NewProvider(goose.DialectSQLite3, db.DB, os.DirFS("../../migrations/sqlite"))
+	require.NoError(t, err)
+	_, err = provider.Up(context.Background())
+	require.NoError(t, err)
+
+	return db
+}
+
+func newSQLiteHarness(t *testing.T, fee domain.FeeSchedule, provider service.PayoutProvider) *harness {
+	t.Helper()
+
+	return newHarness(repository.New(newSQLiteDB(t)), fee, provider)
+}
+
+func TestSQLite_Withdraw_Success(t *testing.T) {
+	ctx := context.Background()
+	h := newSQLiteHarness(t, domain.FeeSchedule{Flat: 100}, newSimulator(payout.OutcomeSucceed))
+	userID := h.createUser(t, 100_000)
+
+	res, err := h.wallets.Withdraw(ctx, service.WithdrawWalletSpec{UserID: userID, Amount: idr(10_000), IdempotencyKey: "k-1"})
+	require.NoError(t, err)
+	require.Equal(t, domain.LedgerStatusSucceed, res.Status)
+	require.Equal(t, int64(89_900), h.balance(t, userID))
+	h.requireNoDrift(t)
+}
+
+func TestSQLite_Withdraw_InsufficientFundsRollsBack(t *testing.T) {
+	ctx := context.Background()
+	h := newSQLiteHarness(t, domain.FeeSchedule{}, newSimulator(payout.OutcomeSucceed))
+	userID := h.createUser(t, 50_000)
+
+	res, err := h.wallets.Withdraw(ctx, service.WithdrawWalletSpec{UserID: userID, Amount: idr(60_000), IdempotencyKey: "k-1"})
+	require.Nil(t, res)
+	require.True(t, errors.Is(err, domain.ErrInsufficientFund))
+	require.Equal(t, int64(50_000), h.balance(t, userID))
+	h.requireNoDrift(t)
+}
+
+func TestSQLite_Withdraw_Idempotency(t *testing.T) {
+	ctx := context.Background()
+	h := newSQLiteHarness(t, domain.FeeSchedule{}, newSimulator(payout.OutcomeSucceed))
+	userID := h.createUser(t, 100_000)
+
+	spec := service.WithdrawWalletSpec{UserID: userID, Amount: idr(10_000), IdempotencyKey: "k-1"}
+	first, err := h.wallets.Withdraw(ctx, spec)
+	require.NoError(t, err)
+
+	replay, err := h.wallets.Withdraw(ctx, spec)
+	require.NoError(t, err)
+	require.Equal(t, first.PayoutReference, replay.PayoutReference)
+	require.Equal(t, int64(90_000), h.balance(t, userID))
+
+	spec.Amount = idr(20_000)
+	_, err = h.wallets.Withdraw(ctx, spec)
+	require.True(t, errors.Is(err, domain.ErrIdempotencyKeyReused))
+}
+
+func TestSQLite_Withdraw_Concurrent(t *testing.T) {
+	h := newSQLiteHarness(t, domain.FeeSchedule{}, newSimulator(payout.OutcomeSucceed))
+	userID := h.createUser(t, 100_000)
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 20)
+	for i := range 20 {
+		wg.Go(func() {
+			_, err := h.wallets.Withdraw(context.Background(), service.WithdrawWalletSpec{UserID: userID, Amount: idr(10_000), IdempotencyKey: fmt.Sprintf("k-%d", i)})
+			errs <- err
+		})
+	}
+	wg.Wait()
+	close(errs)
+
+	var succeeded int
+	for err := range errs {
+		if err == nil {
+			succeeded++
+			continue
+		}
+		require.True(t, errors.Is(err, domain.ErrInsufficientFund), err)
+	}
+
+	require.Equal(t, 10, succeeded)
+	require.Equal(t, int64(0), h.balance(t, userID))
+	h.requireNoDrift(t)
+}
+
+func TestSQLite_Withdraw_PayoutFailedRestoresFunds(t *testing.T) {
+	ctx := context.Background()
+	h := newSQLiteHarness(t, domain.FeeSchedule{Flat: 100}, newSimulator(payout.OutcomeFail))
+	userID := h.createUser(t, 100_000)
+
+	_, err := h.wallets.Withdraw(ctx, service.WithdrawWalletSpec{UserID: userID, Amount: idr(10_000), IdempotencyKey: "k-1"})
+	require.True(t, errors.Is(err, domain.ErrPayoutFailed))
+	require.Equal(t, int64(100_000), h.balance(t, userID))
+	h.requireNoDrift(t)
+}
+
+func TestSQLite_Transfer(t *testing.T) {
+	ctx := context.Background()
+	h := newSQLiteHarness(t, domain.FeeSchedule{}, newSimulator(payout.OutcomeSucceed))
+	from := h.createUser(t, 100_000)
+	to := h.createUser(t, 0)
+
+	_, err := h.wallets.Transfer(ctx, service.TransferWalletSpec{FromUserID: from, ToUserID: to, Amount: idr(40_000), IdempotencyKey: "k-1"})
+	require.NoError(t, err)
+
+	_, err = h.wallets.Transfer(ctx, service.TransferWalletSpec{FromUserID: from, ToUserID: to, Amount: idr(70_000), IdempotencyKey: "k-2"})
+	require.True(t, errors.Is(err, domain.ErrInsufficientFund))
+
+	require.Equal(t, int64(60_000), h.balance(t, from))
+	require.Equal(t, int64(40_000), h.balance(t, to))
+	h.requireNoDrift(t)
+}
+
+func TestSQLite_ComposesInTransaction(t *testing.T) {
+	ctx := context.Background()
+	h := newSQLiteHarness(t, domain.FeeSchedule{}, newSimulator(payout.OutcomeSucceed))
+	from := h.createUser(t, 100_000)
+	to := h.createUser(t, 0)
+
+	boom := errors.New("boom")
+	err := h.tx.Tx(ctx, func(ctx context.Context) error {
+		_, err := h.wallets.Transfer(ctx, service.TransferWalletSpec{FromUserID: from, ToUserID: to, Amount: idr(40_000), IdempotencyKey: "k-1"})
+		require.NoError(t, err)
+
+		_, err = h.wallets.OpenWallet(ctx, service.OpenWalletSpec{UserID: to, Currency: "USD"})
+		require.NoError(t, err)
+		return boom
+	})
+	require.ErrorIs(t, err, boom)
+	require.Equal(t, int64(100_000), h.balance(t, from))
+	require.Equal(t, int64(0), h.balance(t, to))
+
+	err = h.tx.Tx(ctx, func(ctx context.Context) error {
+		_, err := h.wallets.OpenWallet(ctx, service.OpenWalletSpec{UserID: to, Currency: "USD"})
+		require.NoError(t, err)
+
+		_, err = h.wallets.OpenWallet(ctx, service.OpenWalletSpec{UserID: to, Currency: "USD"})
+		require.ErrorIs(t, err, domain.ErrWalletExists)
+		return nil
+	})
+	require.NoError(t, err)
+
+	wallets, err := h.wallets.ListWallets(ctx, to)
+	require.NoError(t, err)
+	require.Len(t, wallets, 2)
+	h.requireNoDrift(t)
+}
+
+func TestSQLite_Withdraw_FeeScheduleIsPerCurrency(t *testing.T) {
+	ctx := context.Background()
+	db := newSQLiteDB(t)
+	h := newHarness(repository.New(db), domain.FeeSchedule{Flat: 100}, newSimulator(payout.OutcomeSucceed))
+	userID := h.createUser(t, 100_000)
+
+	_, err := db.Exec(`INSERT INTO fee_schedules (user_id, currency, flat) VALUES ($1, 'IDR', 500), ($1, 'USD', 50)`, userID)
+	require.NoError(t, err)
+
+	_, err = h.wallets.OpenWallet(ctx, service.OpenWalletSpec{UserID: userID, Currency: "USD"})
+	require.NoError(t, err)
+	_, err = h.wallets.Deposit(ctx, service.DepositWalletSpec{UserID: userID, Amount: domain.NewMoney(10_000, "USD"), IdempotencyKey: "k-deposit"})
+	require.NoError(t, err)
+
+	res, err := h.wallets.Withdraw(ctx, service.WithdrawWalletSpec{UserID: userID, Amount: domain.NewMoney(1_000, "USD"), IdempotencyKey: "k-1"})
+	require.NoError(t, err)
+	require.Equal(t, domain.NewMoney(50, "USD"), res.Fee)
+
+	res, err = h.wallets.Withdraw(ctx, service.WithdrawWalletSpec{UserID: userID, Amount: idr(10_000), IdempotencyKey: "k-2"})
+	require.NoError(t, err)
+	require.Equal(t, idr(500), res.Fee)
+	h.requireNoDrift(t)
+}
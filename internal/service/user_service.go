@@ -6,27 +6,54 @@ import (
 	"errors"
 
 	"github.com/google/uuid"
-	"github.com/jmoiron/sqlx"
 	"github.com/vcnt72/go-boilerplate/internal/domain"
 	"github.com/vcnt72/go-boilerplate/internal/repository"
 )
 
 type UserService struct {
-	userRepository   *repository.UserRepository
-	walletRepository *repository.WalletRepository
-	ledgerRepository *repository.LedgerRepository
-	txProvider       *repository.TxProvider
+	userRepository    repository.UserRepository
+	walletRepository  repository.WalletRepository
+	ledgerRepository  repository.LedgerRepository
+	accountRepository repository.AccountRepository
+	journalRepository repository.JournalRepository
+	outboxRepository  repository.OutboxRepository
+	webhookRepository repository.WebhookRepository
+	txProvider        repository.TxProvider
+	defaultCurrency   string
 }
 
 type CreateUserSpec struct {
-	Name    string
-	Balance int64
+	Name string
+	// Balance opens the user's first wallet in its currency, or in the
+	// default currency when it names none.
+	Balance domain.Money
+}
+
+// ParseAmount reads a decimal amount in major units of currency, or of the
+// default currency when currency is empty.
+func (t UserService) ParseAmount(amount, currency string) (domain.Money, error) {
+	if currency == "" {
+		currency = t.defaultCurrency
+	}
+
+	return domain.ParseMoney(amount, currency)
 }
 
 func (t UserService) Create(ctx context.Context, spec CreateUserSpec) (*domain.User, error) {
+	code := spec.Balance.Currency
+	if code == "" {
+		code = t.defaultCurrency
+	}
+
+	currency, err := domain.LookupCurrency(code)
+	if err != nil {
+		return nil, err
+	}
+	spec.Balance.Currency = currency.Code
+
 	var userObj *domain.User
-	err := t.txProvider.Tx(ctx, func(tx sqlx.ExtContext) error {
-		user, err := t.userRepository.WithTx(tx).Create(ctx, domain.User{
+	err = t.txProvider.Tx(ctx, func(ctx context.Context) error {
+		user, err := t.userRepository.Create(ctx, domain.User{
 			Name: spec.Name,
 		})
 		if err != nil {
@@ -35,15 +62,22 @@ func (t UserService) Create(ctx context.Context, spec CreateUserSpec) (*domain.U
 
 		userObj = user
 
-		wallet, err := t.walletRepository.WithTx(tx).Create(ctx, domain.Wallet{
-			Balance: spec.Balance,
-			UserID:  user.ID,
+		wallet, err := t.walletRepository.Create(ctx, domain.Wallet{
+			Balance:  spec.Balance,
+			UserID:   user.ID,
+			Currency: currency.Code,
+			Exponent: currency.Exponent,
 		})
 		if err != nil {
 			return errors.Join(errors.New("UserService.Create: error on wallet repository create"), err)
 		}
 
-		_, err = t.ledgerRepository.WithTx(tx).Create(ctx, domain.Ledger{
+		_, err = t.accountRepository.CreateForWallet(ctx, wallet.ID)
+		if err != nil {
+			return errors.Join(errors.New("UserService.Create: error on account repository create"), err)
+		}
+
+		ledger, err := t.ledgerRepository.Create(ctx, domain.Ledger{
 			WalletID:       wallet.ID,
 			Amount:         spec.Balance,
 			IdempotencyKey: uuid.NewString(),
@@ -54,17 +88,39 @@ func (t UserService) Create(ctx context.Context, spec CreateUserSpec) (*domain.U
 			return errors.Join(errors.New("UserService.Create: error on ledger repository create"), err)
 		}
 
+		if spec.Balance.IsPositive() {
+			err = bookLedger(ctx, t.accountRepository, t.journalRepository, *ledger,
+				systemAccount(domain.SystemAccountCashIn, wallet.Currency), walletAccount(wallet.ID))
+			if err != nil {
+				return err
+			}
+		}
+
+		event, err := domain.NewUserCreatedEvent(*user, *wallet)
+		if err != nil {
+			return errors.Join(errors.New("UserService.Create: error on build user created event"), err)
+		}
+
+		if err := writeEvent(ctx, t.outboxRepository, t.webhookRepository, event); err != nil {
+			return errors.Join(errors.New("UserService.Create: error on outbox repository create"), err)
+		}
+
 		return nil
 	})
 
 	return userObj, err
 }
 
-func NewUserService(userRepository *repository.UserRepository, walletRepository *repository.WalletRepository, ledgerRepository *repository.LedgerRepository, txProvider *repository.TxProvider) *UserService {
+func NewUserService(userRepository repository.UserRepository, walletRepository repository.WalletRepository, ledgerRepository repository.LedgerRepository, accountRepository repository.AccountRepository, journalRepository repository.JournalRepository, outboxRepository repository.OutboxRepository, webhookRepository repository.WebhookRepository, txProvider repository.TxProvider, defaultCurrency string) *UserService {
 	return &UserService{
-		userRepository:   userRepository,
-		walletRepository: walletRepository,
-		ledgerRepository: ledgerRepository,
-		txProvider:       txProvider,
+		userRepository:    userRepository,
+		walletRepository:  walletRepository,
+		ledgerRepository:  ledgerRepository,
+		accountRepository: accountRepository,
+		journalRepository: journalRepository,
+		outboxRepository:  outboxRepository,
+		webhookRepository: webhookRepository,
+		txProvider:        txProvider,
+		defaultCurrency:   defaultCurrency,
 	}
 }
@@ -0,0 +1,360 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/vcnt72/go-boilerplate/internal/domain"
+)
+
+type AuthorizeSpec struct {
+	UserID         int64
+	IdempotencyKey string
+	// Amount picks the wallet by its currency, defaulting to the default
+	// currency.
+	Amount domain.Money
+	// ExpiresIn defaults to the service's hold TTL when zero.
+	ExpiresIn time.Duration
+}
+
+type HoldResult struct {
+	UserID  int64
+	Hold    domain.Hold
+	Balance domain.Money
+}
+
+// Authorize reserves spec.Amount of the caller's available balance. The
+// balance itself only moves on Capture.
+func (w WalletService) Authorize(ctx context.Context, spec AuthorizeSpec) (*HoldResult, error) {
+	ttl := spec.ExpiresIn
+	if ttl <= 0 {
+		ttl = w.holdTTL
+	}
+
+	currency, err := w.resolveCurrency(spec.Amount.Currency)
+	if err != nil {
+		return nil, err
+	}
+	spec.Amount.Currency = currency
+
+	var walletID int64
+	var result *HoldResult
+	var appErr error
+	err = w.txProvider.Tx(ctx, func(ctx context.Context) error {
+		wallet, err := getWallet(ctx, w.walletRepository, spec.UserID, currency)
+		if err != nil {
+			return err
+		}
+		walletID = wallet.ID
+
+		wallet, err = w.walletRepository.LockByID(ctx, wallet.ID)
+		if err != nil {
+			return err
+		}
+
+		ledger, err := w.ledgerRepository.Create(ctx, domain.Ledger{
+			IdempotencyKey: spec.IdempotencyKey,
+			Type:           domain.LedgerTypeAuthorize,
+			WalletID:       wallet.ID,
+			Status:         domain.LedgerStatusProcessing,
+			Amount:         spec.Amount,
+		})
+		if err != nil {
+			return err
+		}
+
+		held, err := w.walletRepository.Hold(ctx, wallet.ID, spec.Amount)
+		if err != nil {
+			if errors.Is(err, domain.ErrInsufficientFund) {
+				errCode := domain.LedgerErrorCodeInsufficientFund
+				ledger.Status = domain.LedgerStatusFailed
+				ledger.ErrorCode = &errCode
+				ledger.ResultBalance = &wallet.Balance
+				appErr = err
+				return w.ledgerRepository.Update(ctx, *ledger)
+			}
+
+			return err
+		}
+
+		hold, err := w.holdRepository.Create(ctx, domain.Hold{
+			WalletID:  wallet.ID,
+			Amount:    spec.Amount,
+			Status:    domain.HoldStatusActive,
+			ExpiresAt: time.Now().Add(ttl),
+		})
+		if err != nil {
+			return errors.Join(errors.New("WalletService.Authorize: error on hold repository create"), err)
+		}
+
+		ledger.Status = domain.LedgerStatusSucceed
+		ledger.ResultBalance = &held.Balance
+		ledger.HoldID = &hold.ID
+		if err := w.ledgerRepository.Update(ctx, *ledger); err != nil {
+			return err
+		}
+
+		result = &HoldResult{UserID: spec.UserID, Hold: *hold, Balance: held.Balance}
+		return nil
+	})
+
+	if errors.Is(err, domain.ErrLedgerConflict) {
+		holdResult, err2 := w.handleConflictHold(ctx, walletID, spec.UserID, spec.IdempotencyKey, func(l *domain.Ledger) bool {
+			return l.Type == domain.LedgerTypeAuthorize && l.Amount == spec.Amount
+		})
+		if err2 != nil {
+			return nil, errors.Join(err, err2)
+		}
+
+		return holdResult, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	if appErr != nil {
+		return nil, appErr
+	}
+
+	return result, nil
+}
+
+type CaptureSpec struct {
+	UserID         int64
+	HoldID         int64
+	IdempotencyKey string
+	// Amount captures part of the hold; zero captures all of it. Whatever is
+	// not captured is released. Its currency, when set, must be the hold's.
+	Amount domain.Money
+}
+
+// Capture debits the caller's balance by the captured amount and releases
+// the hold.
+func (w WalletService) Capture(ctx context.Context, spec CaptureSpec) (*HoldResult, error) {
+	if spec.Amount.IsNegative() {
+		return nil, domain.ErrInvalidAmount
+	}
+
+	return w.settleHold(ctx, settleHoldSpec{
+		userID:         spec.UserID,
+		holdID:         spec.HoldID,
+		idempotencyKey: spec.IdempotencyKey,
+		ledgerType:     domain.LedgerTypeCapture,
+		status:         domain.HoldStatusCaptured,
+		amount:         spec.Amount,
+	})
+}
+
+type VoidSpec struct {
+	UserID         int64
+	HoldID         int64
+	IdempotencyKey string
+}
+
+// Void releases the hold without moving any money.
+func (w WalletService) Void(ctx context.Context, spec VoidSpec) (*HoldResult, error) {
+	return w.settleHold(ctx, settleHoldSpec{
+		userID:         spec.UserID,
+		holdID:         spec.HoldID,
+		idempotencyKey: spec.IdempotencyKey,
+		ledgerType:     domain.LedgerTypeVoid,
+		status:         domain.HoldStatusVoided,
+	})
+}
+
+type settleHoldSpec struct {
+	userID         int64
+	holdID         int64
+	idempotencyKey string
+	ledgerType     domain.LedgerType
+	status         domain.HoldStatus
+	// amount is the amount to capture, zero meaning the whole hold. Voids
+	// always record the whole hold.
+	amount domain.Money
+}
+
+func (w WalletService) settleHold(ctx context.Context, spec settleHoldSpec) (*HoldResult, error) {
+	var walletID int64
+	var result *HoldResult
+	var appErr error
+	err := w.txProvider.Tx(ctx, func(ctx context.Context) error {
+		// Holds are addressed by ID alone, so find the wallet through the
+		// hold and make sure it belongs to the caller.
+		hold, err := w.holdRepository.GetByID(ctx, spec.holdID)
+		if err != nil {
+			return err
+		}
+
+		wallet, err := w.walletRepository.GetByID(ctx, hold.WalletID)
+		if err != nil {
+			return err
+		}
+
+		if wallet.UserID != spec.userID {
+			return domain.ErrHoldNotFound
+		}
+		walletID = wallet.ID
+
+		// Every change to a hold happens under its wallet's lock.
+		wallet, err = w.walletRepository.LockByID(ctx, wallet.ID)
+		if err != nil {
+			return err
+		}
+
+		hold, err = w.holdRepository.GetByWalletID(ctx, wallet.ID, spec.holdID)
+		if err != nil {
+			return err
+		}
+
+		amount := hold.Amount
+		if spec.ledgerType == domain.LedgerTypeCapture && !spec.amount.IsZero() {
+			amount = spec.amount
+			if amount.Currency == "" {
+				amount.Currency = hold.Currency
+			}
+
+			if amount.Currency != hold.Currency {
+				return domain.ErrCurrencyMismatch
+			}
+		}
+
+		ledger, err := w.ledgerRepository.Create(ctx, domain.Ledger{
+			IdempotencyKey: spec.idempotencyKey,
+			Type:           spec.ledgerType,
+			WalletID:       wallet.ID,
+			Status:         domain.LedgerStatusProcessing,
+			Amount:         amount,
+			HoldID:         &hold.ID,
+		})
+		if err != nil {
+			return err
+		}
+
+		checkErr := hold.CheckSettle(time.Now())
+		if checkErr == nil && amount.Amount > hold.Amount.Amount {
+			checkErr = domain.ErrCaptureExceedsHold
+		}
+		if checkErr != nil {
+			errCode := holdErrorCode(checkErr)
+			ledger.Status = domain.LedgerStatusFailed
+			ledger.ErrorCode = &errCode
+			ledger.ResultBalance = &wallet.Balance
+			appErr = checkErr
+			return w.ledgerRepository.Update(ctx, *ledger)
+		}
+
+		spent := domain.NewMoney(0, hold.Currency)
+		if spec.ledgerType == domain.LedgerTypeCapture {
+			spent = amount
+		}
+
+		settled, err := w.walletRepository.SettleHold(ctx, wallet.ID, hold.Amount, spent)
+		if err != nil {
+			return err
+		}
+
+		if err := w.holdRepository.Settle(ctx, hold.ID, spec.status, spent); err != nil {
+			return err
+		}
+		hold.Status = spec.status
+		hold.CapturedAmount = spent
+
+		ledger.Status = domain.LedgerStatusSucceed
+		ledger.ResultBalance = &settled.Balance
+		if err := w.ledgerRepository.Update(ctx, *ledger); err != nil {
+			return err
+		}
+
+		result = &HoldResult{UserID: spec.userID, Hold: *hold, Balance: settled.Balance}
+
+		if spent.IsZero() {
+			return nil
+		}
+
+		return bookLedger(ctx, w.accountRepository, w.journalRepository, *ledger,
+			walletAccount(wallet.ID), systemAccount(domain.SystemAccountHoldSettlement, wallet.Currency))
+	})
+
+	if errors.Is(err, domain.ErrLedgerConflict) {
+		holdResult, err2 := w.handleConflictHold(ctx, walletID, spec.userID, spec.idempotencyKey, func(l *domain.Ledger) bool {
+			return l.Type == spec.ledgerType && l.HoldID != nil && *l.HoldID == spec.holdID &&
+				(spec.amount.IsZero() || l.Amount.Amount == spec.amount.Amount)
+		})
+		if err2 != nil {
+			return nil, errors.Join(err, err2)
+		}
+
+		return holdResult, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	if appErr != nil {
+		return nil, appErr
+	}
+
+	return result, nil
+}
+
+// handleConflictHold replays an authorize, capture or void. matches tells
+// whether the stored ledger was created by the same request.
+func (w WalletService) handleConflictHold(ctx context.Context, walletID, userID int64, idempotencyKey string, matches func(*domain.Ledger) bool) (*HoldResult, error) {
+	l, err := getReplayLedger(ctx, w.ledgerRepository, walletID, idempotencyKey)
+	if err != nil {
+		return nil, err
+	}
+
+	if !matches(l) {
+		return nil, domain.ErrIdempotencyKeyReused
+	}
+
+	switch l.Status {
+	case domain.LedgerStatusSucceed:
+		if l.ResultBalance == nil || l.HoldID == nil {
+			return nil, domain.ErrRequestInProgress
+		}
+
+		hold, err := w.holdRepository.GetByWalletID(ctx, walletID, *l.HoldID)
+		if err != nil {
+			return nil, err
+		}
+
+		return &HoldResult{UserID: userID, Hold: *hold, Balance: *l.ResultBalance}, nil
+
+	case domain.LedgerStatusFailed:
+		if l.ErrorCode == nil {
+			return nil, domain.ErrHoldFailed
+		}
+
+		switch *l.ErrorCode {
+		case domain.LedgerErrorCodeInsufficientFund:
+			return nil, domain.ErrInsufficientFund
+		case domain.LedgerErrorCodeHoldNotActive:
+			return nil, domain.ErrHoldNotActive
+		case domain.LedgerErrorCodeHoldExpired:
+			return nil, domain.ErrHoldExpired
+		case domain.LedgerErrorCodeCaptureExceeds:
+			return nil, domain.ErrCaptureExceedsHold
+		default:
+			return nil, domain.ErrHoldFailed
+		}
+
+	default:
+		return nil, domain.ErrRequestInProgress
+	}
+}
+
+func holdErrorCode(err error) string {
+	switch {
+	case errors.Is(err, domain.ErrHoldExpired):
+		return domain.LedgerErrorCodeHoldExpired
+	case errors.Is(err, domain.ErrCaptureExceedsHold):
+		return domain.LedgerErrorCodeCaptureExceeds
+	default:
+		return domain.LedgerErrorCodeHoldNotActive
+	}
+}
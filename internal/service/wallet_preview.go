@@ -0,0 +1,102 @@
+package service
+
+import (
+	"context"
+
+	"github.com/vcnt72/go-boilerplate/internal/domain"
+	"github.com/vcnt72/go-boilerplate/internal/repository"
+)
+
+type PreviewWithdrawSpec struct {
+	UserID int64
+	// Amount must name its currency, which picks the user's wallet.
+	Amount domain.Money
+}
+
+// WithdrawalPreview is what a withdrawal would do if it were made now.
+type WithdrawalPreview struct {
+	UserID int64
+	// Amount is what the user receives; Debit is Amount plus Fee.
+	Amount domain.Money
+	Fee    domain.Money
+	Debit  domain.Money
+	// Balance and AvailableBalance are the wallet's after the withdrawal.
+	Balance          domain.Money
+	AvailableBalance domain.Money
+	// Limits left once this withdrawal is counted; nil where no limit
+	// applies.
+	MaxPerTransaction    *domain.Money
+	RemainingDailyAmount *domain.Money
+	RemainingDailyCount  *int64
+}
+
+// PreviewWithdraw runs the checks of Withdraw, in the same order and with
+// the same errors, in a read-only transaction. The wallet is not locked, so a
+// later Withdraw can still be rejected.
+func (w WalletService) PreviewWithdraw(ctx context.Context, spec PreviewWithdrawSpec) (*WithdrawalPreview, error) {
+	currency, err := w.requireCurrency(spec.Amount.Currency)
+	if err != nil {
+		return nil, err
+	}
+	spec.Amount.Currency = currency
+
+	var preview *WithdrawalPreview
+	err = w.txProvider.TxWithOptions(ctx, repository.TxOptions{ReadOnly: true}, func(ctx context.Context) error {
+		wallet, err := getWallet(ctx, w.walletRepository, spec.UserID, currency)
+		if err != nil {
+			return err
+		}
+
+		quote, err := w.quoteWithdrawal(ctx, wallet, spec.Amount)
+		if err != nil {
+			return err
+		}
+		debit := quote.debit
+
+		if wallet.AvailableBalance.Amount < debit.Amount {
+			return domain.ErrInsufficientFund
+		}
+
+		balance, err := wallet.Balance.Sub(debit)
+		if err != nil {
+			return err
+		}
+
+		available, err := wallet.AvailableBalance.Sub(debit)
+		if err != nil {
+			return err
+		}
+
+		usage := quote.usage
+		usage.Amount += spec.Amount.Amount
+		usage.Count++
+		remaining := quote.limit.Remaining(usage)
+
+		preview = &WithdrawalPreview{
+			UserID:               spec.UserID,
+			Amount:               spec.Amount,
+			Fee:                  quote.fee,
+			Debit:                debit,
+			Balance:              balance,
+			AvailableBalance:     available,
+			MaxPerTransaction:    optionalMoney(remaining.MaxPerTransaction, currency),
+			RemainingDailyAmount: optionalMoney(remaining.DailyAmount, currency),
+			RemainingDailyCount:  remaining.DailyCount,
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return preview, nil
+}
+
+func optionalMoney(amount *int64, currency string) *domain.Money {
+	if amount == nil {
+		return nil
+	}
+
+	m := domain.NewMoney(*amount, currency)
+	return &m
+}
@@ -0,0 +1,219 @@
+package service
+
+import (
+	"context"
+	"errors"
+
+	"github.com/vcnt72/go-boilerplate/internal/domain"
+)
+
+type ReverseWithdrawalSpec struct {
+	UserID int64
+	// WithdrawalKey is the idempotency key of the withdrawal to reverse.
+	WithdrawalKey  string
+	IdempotencyKey string
+	// Amount reverses part of the withdrawal; zero reverses whatever has not
+	// been reversed yet. Its currency picks the wallet, defaulting to the
+	// default currency.
+	Amount domain.Money
+}
+
+type ReversalResult struct {
+	UserID        int64
+	LedgerID      int64
+	WithdrawalKey string
+	Amount        domain.Money
+	// Reversed is the total reversed from the withdrawal so far.
+	Reversed domain.Money
+	Balance  domain.Money
+}
+
+// ReverseWithdrawal credits a succeeded withdrawal back to the wallet. A
+// withdrawal can be reversed in several parts, but never by more than its
+// original amount.
+func (w WalletService) ReverseWithdrawal(ctx context.Context, spec ReverseWithdrawalSpec) (*ReversalResult, error) {
+	if spec.Amount.IsNegative() {
+		return nil, domain.ErrInvalidAmount
+	}
+
+	currency, err := w.resolveCurrency(spec.Amount.Currency)
+	if err != nil {
+		return nil, err
+	}
+	spec.Amount.Currency = currency
+
+	var walletID int64
+	var result *ReversalResult
+	var appErr error
+	err = w.txProvider.Tx(ctx, func(ctx context.Context) error {
+		wallet, err := getWallet(ctx, w.walletRepository, spec.UserID, currency)
+		if err != nil {
+			return err
+		}
+		walletID = wallet.ID
+
+		// Serialize reversals of this wallet so that two partial reversals
+		// cannot both fit under the original amount.
+		wallet, err = w.walletRepository.LockByID(ctx, wallet.ID)
+		if err != nil {
+			return err
+		}
+
+		parent, err := w.ledgerRepository.GetByIdempotencyKey(ctx, wallet.ID, spec.WithdrawalKey)
+		if err != nil {
+			return err
+		}
+
+		if parent.Type != domain.LedgerTypeWithdraw || parent.Status != domain.LedgerStatusSucceed {
+			return domain.ErrNotReversible
+		}
+
+		sum, err := w.ledgerRepository.SumReversed(ctx, parent.ID)
+		if err != nil {
+			return errors.Join(errors.New("WalletService.ReverseWithdrawal: error on ledger repository sum"), err)
+		}
+		reversed := domain.NewMoney(sum, parent.Currency)
+
+		remaining, err := parent.Amount.Sub(reversed)
+		if err != nil {
+			return err
+		}
+
+		amount := spec.Amount
+		if amount.IsZero() {
+			amount = remaining
+		}
+
+		ledger, err := w.ledgerRepository.Create(ctx, domain.Ledger{
+			IdempotencyKey: spec.IdempotencyKey,
+			Type:           domain.LedgerTypeReversal,
+			WalletID:       wallet.ID,
+			Status:         domain.LedgerStatusProcessing,
+			Amount:         amount,
+			ParentLedgerID: &parent.ID,
+		})
+		if err != nil {
+			return err
+		}
+
+		if amount.IsZero() || amount.Amount > remaining.Amount {
+			errCode := domain.LedgerErrorCodeReversalExceeds
+			ledger.Status = domain.LedgerStatusFailed
+			ledger.ErrorCode = &errCode
+			ledger.ResultBalance = &wallet.Balance
+			appErr = domain.ErrReversalExceeds
+			return w.ledgerRepository.Update(ctx, *ledger)
+		}
+
+		if _, err := wallet.Balance.Add(amount); err != nil {
+			return err
+		}
+
+		balance, err := w.walletRepository.IncreaseBalance(ctx, amount, wallet.ID)
+		if err != nil {
+			return err
+		}
+
+		ledger.Status = domain.LedgerStatusSucceed
+		ledger.ResultBalance = &balance
+		if err := w.ledgerRepository.Update(ctx, *ledger); err != nil {
+			return err
+		}
+
+		err = bookLedger(ctx, w.accountRepository, w.journalRepository, *ledger,
+			systemAccount(domain.SystemAccountPayoutClearing, wallet.Currency), walletAccount(wallet.ID))
+		if err != nil {
+			return err
+		}
+
+		event, err := domain.NewReversalEvent(spec.UserID, *parent, *ledger)
+		if err != nil {
+			return err
+		}
+
+		if err := writeEvent(ctx, w.outboxRepository, w.webhookRepository, event); err != nil {
+			return errors.Join(errors.New("WalletService.ReverseWithdrawal: error on outbox repository create"), err)
+		}
+
+		total, err := reversed.Add(amount)
+		if err != nil {
+			return err
+		}
+
+		result = &ReversalResult{
+			UserID:        spec.UserID,
+			LedgerID:      ledger.ID,
+			WithdrawalKey: spec.WithdrawalKey,
+			Amount:        amount,
+			Reversed:      total,
+			Balance:       balance,
+		}
+		return nil
+	})
+
+	if errors.Is(err, domain.ErrLedgerConflict) {
+		reversalResult, err2 := w.handleConflictReversal(ctx, walletID, spec)
+		if err2 != nil {
+			return nil, errors.Join(err, err2)
+		}
+
+		return reversalResult, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	if appErr != nil {
+		return nil, appErr
+	}
+
+	return result, nil
+}
+
+func (w WalletService) handleConflictReversal(ctx context.Context, walletID int64, spec ReverseWithdrawalSpec) (*ReversalResult, error) {
+	l, err := getReplayLedger(ctx, w.ledgerRepository, walletID, spec.IdempotencyKey)
+	if err != nil {
+		return nil, err
+	}
+
+	parent, err := w.ledgerRepository.GetByIdempotencyKey(ctx, walletID, spec.WithdrawalKey)
+	if err != nil {
+		return nil, err
+	}
+
+	if l.Type != domain.LedgerTypeReversal || l.ParentLedgerID == nil || *l.ParentLedgerID != parent.ID ||
+		(!spec.Amount.IsZero() && l.Amount != spec.Amount) {
+		return nil, domain.ErrIdempotencyKeyReused
+	}
+
+	switch l.Status {
+	case domain.LedgerStatusSucceed:
+		if l.ResultBalance == nil {
+			return nil, domain.ErrRequestInProgress
+		}
+
+		reversed, err := w.ledgerRepository.SumReversed(ctx, parent.ID)
+		if err != nil {
+			return nil, err
+		}
+
+		return &ReversalResult{
+			UserID:        spec.UserID,
+			LedgerID:      l.ID,
+			WithdrawalKey: spec.WithdrawalKey,
+			Amount:        l.Amount,
+			Reversed:      domain.NewMoney(reversed, l.Currency),
+			Balance:       *l.ResultBalance,
+		}, nil
+
+	case domain.LedgerStatusFailed:
+		if l.ErrorCode != nil && *l.ErrorCode == domain.LedgerErrorCodeReversalExceeds {
+			return nil, domain.ErrReversalExceeds
+		}
+		return nil, domain.ErrReversalFailed
+
+	default:
+		return nil, domain.ErrRequestInProgress
+	}
+}
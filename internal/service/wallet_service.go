@@ -2,84 +2,288 @@ package service
 
 import (
 	"context"
+	"encoding/base64"
+	"encoding/json"
 	"errors"
+	"slices"
+	"time"
 
-	"github.com/jmoiron/sqlx"
+	"github.com/google/uuid"
 	"github.com/vcnt72/go-boilerplate/internal/domain"
 	"github.com/vcnt72/go-boilerplate/internal/repository"
 )
 
 type WalletService struct {
-	walletRepository *repository.WalletRepository
-	ledgerRepository *repository.LedgerRepository
-	txProvider       *repository.TxProvider
+	walletRepository  repository.WalletRepository
+	ledgerRepository  repository.LedgerRepository
+	accountRepository repository.AccountRepository
+	journalRepository repository.JournalRepository
+	limitRepository   repository.LimitRepository
+	feeRepository     repository.FeeRepository
+	outboxRepository  repository.OutboxRepository
+	webhookRepository repository.WebhookRepository
+	holdRepository    repository.HoldRepository
+	txProvider        repository.TxProvider
+	payoutService     *PayoutService
+	defaultLimit      domain.WithdrawalLimit
+	defaultFee        domain.FeeSchedule
+	// withdrawTx runs the withdrawal transaction, which is safe to retry.
+	withdrawTx repository.TxOptions
+	holdTTL    time.Duration
+	// defaultCurrency is used by requests that do not name a currency.
+	defaultCurrency string
 }
 
-func (w WalletService) GetByUserID(ctx context.Context, userID int64) (*domain.Wallet, error) {
-	wallet, err := w.walletRepository.GetByUserID(ctx, userID)
+// GetByUserID returns the user's wallet in currency, or in the default
+// currency when currency is empty.
+func (w WalletService) GetByUserID(ctx context.Context, userID int64, currency string) (*domain.Wallet, error) {
+	currency, err := w.resolveCurrency(currency)
+	if err != nil {
+		return nil, err
+	}
+
+	return getWallet(ctx, w.walletRepository, userID, currency)
+}
+
+func (w WalletService) ListWallets(ctx context.Context, userID int64) ([]domain.Wallet, error) {
+	wallets, err := w.walletRepository.ListByUserID(ctx, userID)
+	if err != nil {
+		return nil, errors.Join(errors.New("WalletService.ListWallets: error on wallet repository list"), err)
+	}
+
+	return wallets, nil
+}
+
+type OpenWalletSpec struct {
+	UserID   int64
+	Currency string
+}
+
+// OpenWallet creates an empty wallet for the user in another currency.
+func (w WalletService) OpenWallet(ctx context.Context, spec OpenWalletSpec) (*domain.Wallet, error) {
+	currency, err := domain.LookupCurrency(spec.Currency)
+	if err != nil {
+		return nil, err
+	}
+
+	var wallet *domain.Wallet
+	err = w.txProvider.Tx(ctx, func(ctx context.Context) error {
+		wallets, err := w.walletRepository.ListByUserID(ctx, spec.UserID)
+		if err != nil {
+			return errors.Join(errors.New("WalletService.OpenWallet: error on wallet repository list"), err)
+		}
+
+		if len(wallets) == 0 {
+			return domain.ErrWalletNotFound
+		}
+
+		wallet, err = w.walletRepository.Create(ctx, domain.Wallet{
+			UserID:   spec.UserID,
+			Currency: currency.Code,
+			Exponent: currency.Exponent,
+		})
+		if err != nil {
+			return err
+		}
+
+		if _, err := w.accountRepository.CreateForWallet(ctx, wallet.ID); err != nil {
+			return errors.Join(errors.New("WalletService.OpenWallet: error on account repository create"), err)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return wallet, nil
+}
+
+// ParseAmount reads a decimal amount in major units of currency, or of the
+// default currency when currency is empty.
+func (w WalletService) ParseAmount(amount, currency string) (domain.Money, error) {
+	if currency == "" {
+		currency = w.defaultCurrency
+	}
+
+	return domain.ParseMoney(amount, currency)
+}
+
+// resolveCurrency normalizes a requested currency code, falling back to the
+// default currency when it is empty.
+func (w WalletService) resolveCurrency(code string) (string, error) {
+	if code == "" {
+		code = w.defaultCurrency
+	}
+
+	currency, err := domain.LookupCurrency(code)
+	if err != nil {
+		return "", err
+	}
+
+	return currency.Code, nil
+}
+
+// requireCurrency is resolveCurrency for requests that must name their
+// currency.
+func (w WalletService) requireCurrency(code string) (string, error) {
+	if code == "" {
+		return "", domain.ErrUnsupportedCurrency
+	}
+
+	return w.resolveCurrency(code)
+}
+
+// getWallet finds the user's wallet in currency. A user who has wallets, but
+// none in that currency, gets domain.ErrCurrencyMismatch rather than
+// domain.ErrWalletNotFound.
+func getWallet(ctx context.Context, walletRepository repository.WalletRepository, userID int64, currency string) (*domain.Wallet, error) {
+	wallet, err := walletRepository.GetByUserID(ctx, userID, currency)
+	if !errors.Is(err, domain.ErrWalletNotFound) {
+		return wallet, err
+	}
 
-	return wallet, err
+	wallets, lerr := walletRepository.ListByUserID(ctx, userID)
+	if lerr != nil {
+		return nil, errors.Join(err, lerr)
+	}
+
+	if len(wallets) > 0 {
+		return nil, domain.ErrCurrencyMismatch
+	}
+
+	return nil, err
 }
 
 type WithdrawWalletSpec struct {
 	UserID         int64
 	IdempotencyKey string
-	Amount         int64
+	// Amount must name its currency, which picks the user's wallet.
+	Amount domain.Money
 }
 
 type WithdrawalResult struct {
 	UserID  int64
-	Balance int64
-	Amount  int64
+	Balance domain.Money
+	Amount  domain.Money
+	// Fee is charged on top of Amount and booked as a separate FEE ledger.
+	Fee domain.Money
+	// Status is SUCCEED once the payout provider paid out, PROCESSING or SENT
+	// while it is still pending there.
+	Status          domain.LedgerStatus
+	PayoutReference string
 }
 
+// Withdraw debits the amount and its fee and hands the payout to the payout
+// provider. Funds stay debited while the payout is pending and are given back
+// if the provider fails it, in which case Withdraw returns ErrPayoutFailed.
 func (w WalletService) Withdraw(ctx context.Context, spec WithdrawWalletSpec) (*WithdrawalResult, error) {
-	var balance int64
+	currency, err := w.requireCurrency(spec.Amount.Currency)
+	if err != nil {
+		return nil, err
+	}
+	spec.Amount.Currency = currency
+
+	var walletID int64
+	var balance domain.Money
+	var fee domain.Money
 	var appErr error
-	err := w.txProvider.Tx(ctx, func(tx sqlx.ExtContext) error {
-		wallet, err := w.walletRepository.WithTx(tx).GetByUserID(ctx, spec.UserID)
+	reference := uuid.NewString()
+	err = w.txProvider.TxWithOptions(ctx, w.withdrawTx, func(ctx context.Context) error {
+		appErr = nil
+
+		wallet, err := getWallet(ctx, w.walletRepository, spec.UserID, currency)
 		if err != nil {
 			return err
 		}
+		walletID = wallet.ID
 
-		ledger, err := w.ledgerRepository.WithTx(tx).Create(ctx, domain.Ledger{
-			IdempotencyKey: spec.IdempotencyKey,
-			Type:           domain.LedgerTypeWithdraw,
-			WalletID:       wallet.ID,
-			Status:         domain.LedgerStatusProcessing,
-			Amount:         spec.Amount,
+		// Serialize withdrawals of this wallet so that concurrent requests
+		// cannot both pass the limit check on the same usage.
+		wallet, err = w.walletRepository.LockByID(ctx, wallet.ID)
+		if err != nil {
+			return err
+		}
+
+		// The limit is checked before this withdrawal's ledger is created:
+		// a PROCESSING ledger with a payout reference counts as usage.
+		quote, limitErr := w.quoteWithdrawal(ctx, wallet, spec.Amount)
+		if limitErr != nil && !errors.Is(limitErr, domain.ErrLimitExceeded) {
+			return limitErr
+		}
+
+		ledger, err := w.ledgerRepository.Create(ctx, domain.Ledger{
+			IdempotencyKey:  spec.IdempotencyKey,
+			Type:            domain.LedgerTypeWithdraw,
+			WalletID:        wallet.ID,
+			Status:          domain.LedgerStatusProcessing,
+			Amount:          spec.Amount,
+			PayoutReference: &reference,
 		})
 		if err != nil {
 			return err
 		}
 
-		balance, err = w.walletRepository.WithTx(tx).DecreaseBalance(ctx, spec.Amount, spec.UserID)
+		if limitErr != nil {
+			errCode := domain.LedgerErrorCodeLimitExceeded
+			ledger.Status = domain.LedgerStatusFailed
+			ledger.ErrorCode = &errCode
+			ledger.ResultBalance = &wallet.Balance
+			appErr = limitErr
+			if uerr := w.ledgerRepository.Update(ctx, *ledger); uerr != nil {
+				return uerr
+			}
+
+			return writeWithdrawEvent(ctx, w.outboxRepository, w.webhookRepository, wallet.UserID, *ledger, nil)
+		}
+
+		fee = quote.fee
+		balance, err = w.walletRepository.DecreaseBalance(ctx, quote.debit, wallet.ID)
 		if err != nil {
 			if errors.Is(err, domain.ErrInsufficientFund) {
-				errCode := "INSUFFICIENT_FUND"
+				errCode := domain.LedgerErrorCodeInsufficientFund
 				ledger.Status = domain.LedgerStatusFailed
 				ledger.ErrorCode = &errCode
 				ledger.ResultBalance = &wallet.Balance
-				uerr := w.ledgerRepository.WithTx(tx).Update(ctx, *ledger)
 				appErr = err
-				return uerr
+				if uerr := w.ledgerRepository.Update(ctx, *ledger); uerr != nil {
+					return uerr
+				}
+
+				return writeWithdrawEvent(ctx, w.outboxRepository, w.webhookRepository, wallet.UserID, *ledger, nil)
 			}
 
 			return err
 		}
 
+		// The withdraw ledger records the balance before the fee, so that
+		// each ledger's result follows from the previous one.
+		beforeFee, err := balance.Add(fee)
+		if err != nil {
+			return err
+		}
+
+		// The ledger stays PROCESSING until the payout provider settles it,
+		// but its funds are debited and booked to payout clearing now. The
+		// withdrawal event is written when the payout settles.
 		wallet.Balance = balance
-		ledger.Status = domain.LedgerStatusSucceed
-		ledger.ResultBalance = &balance
-		if err := w.ledgerRepository.WithTx(tx).Update(ctx, *ledger); err != nil {
+		ledger.ResultBalance = &beforeFee
+		if err := w.ledgerRepository.Update(ctx, *ledger); err != nil {
 			return err
 		}
 
-		return nil
+		err = bookLedger(ctx, w.accountRepository, w.journalRepository, *ledger,
+			walletAccount(wallet.ID), systemAccount(domain.SystemAccountPayoutClearing, wallet.Currency))
+		if err != nil {
+			return err
+		}
+
+		_, err = w.chargeFee(ctx, *ledger, fee, balance)
+		return err
 	})
 
 	if errors.Is(err, domain.ErrLedgerConflict) {
-		withdrawResult, err2 := w.handleConflictWithdraw(ctx, spec)
+		withdrawResult, err2 := w.handleConflictWithdraw(ctx, walletID, spec)
 
 		if err2 != nil {
 			return nil, errors.Join(err, err2)
@@ -88,6 +292,13 @@ func (w WalletService) Withdraw(ctx context.Context, spec WithdrawWalletSpec) (*
 		return withdrawResult, nil
 	}
 
+	// The withdrawal may have committed, leaving it PROCESSING for the
+	// sweeper to pay out, so it is not reported as failed. A retry with the
+	// same idempotency key finds out which.
+	if errors.Is(err, repository.ErrCommitUnknown) {
+		return nil, errors.Join(domain.ErrRequestInProgress, err)
+	}
+
 	if err != nil {
 		return nil, err
 	}
@@ -96,20 +307,332 @@ func (w WalletService) Withdraw(ctx context.Context, spec WithdrawWalletSpec) (*
 		return nil, appErr
 	}
 
-	return &WithdrawalResult{
+	result := &WithdrawalResult{
+		UserID:          spec.UserID,
+		Amount:          spec.Amount,
+		Fee:             fee,
+		Balance:         balance,
+		Status:          domain.LedgerStatusProcessing,
+		PayoutReference: reference,
+	}
+
+	// The withdrawal is accepted once committed. When the provider cannot
+	// be reached it stays PROCESSING and the sweeper sends it again.
+	payout, err := w.payoutService.Dispatch(ctx, reference)
+	if err != nil {
+		return result, nil
+	}
+
+	return withdrawalResult(spec.UserID, payout)
+}
+
+// withdrawalResult describes a withdrawal from the current state of its
+// payout.
+func withdrawalResult(userID int64, payout *PayoutResult) (*WithdrawalResult, error) {
+	l := payout.Withdrawal
+	if l.Status == domain.LedgerStatusFailed {
+		return nil, domain.ErrPayoutFailed
+	}
+
+	result := &WithdrawalResult{
+		UserID:          userID,
+		Amount:          l.Amount,
+		Fee:             domain.NewMoney(0, l.Currency),
+		Status:          l.Status,
+		PayoutReference: payout.Reference,
+	}
+
+	if payout.Fee != nil {
+		result.Fee = payout.Fee.Amount
+	}
+
+	balance := payout.Balance()
+	if balance == nil {
+		return nil, domain.ErrRequestInProgress
+	}
+	result.Balance = *balance
+
+	return result, nil
+}
+
+func (w WalletService) handleConflictWithdraw(ctx context.Context, walletID int64, spec WithdrawWalletSpec) (*WithdrawalResult, error) {
+	l, err := getReplayLedger(ctx, w.ledgerRepository, walletID, spec.IdempotencyKey)
+	if err != nil {
+		return nil, err
+	}
+
+	if l.Amount != spec.Amount || l.Type != domain.LedgerTypeWithdraw {
+		return nil, domain.ErrIdempotencyKeyReused
+	}
+
+	if l.PayoutReference != nil && l.ErrorCode == nil {
+		payout, err := w.payoutService.result(ctx, w.ledgerRepository, *l)
+		if err != nil {
+			return nil, err
+		}
+
+		return withdrawalResult(spec.UserID, payout)
+	}
+
+	switch l.Status {
+	case domain.LedgerStatusSucceed:
+		if l.ResultBalance == nil {
+			return nil, domain.ErrRequestInProgress
+		}
+
+		result := &WithdrawalResult{
+			UserID:  spec.UserID,
+			Amount:  spec.Amount,
+			Fee:     domain.NewMoney(0, l.Currency),
+			Balance: *l.ResultBalance,
+			Status:  l.Status,
+		}
+
+		fee, err := w.ledgerRepository.GetByParentID(ctx, l.ID, domain.LedgerTypeFee)
+		if err != nil {
+			if errors.Is(err, domain.ErrLedgerNotFound) {
+				return result, nil
+			}
+
+			return nil, err
+		}
+
+		if fee.ResultBalance == nil {
+			return nil, domain.ErrRequestInProgress
+		}
+
+		result.Fee = fee.Amount
+		result.Balance = *fee.ResultBalance
+		return result, nil
+
+	case domain.LedgerStatusFailed:
+		if l.ErrorCode != nil && *l.ErrorCode == domain.LedgerErrorCodeInsufficientFund {
+			return nil, domain.ErrInsufficientFund
+		}
+		if l.ErrorCode != nil && *l.ErrorCode == domain.LedgerErrorCodeLimitExceeded {
+			return nil, domain.ErrLimitExceeded
+		}
+		if l.ErrorCode != nil && *l.ErrorCode == domain.LedgerErrorCodePayoutFailed {
+			return nil, domain.ErrPayoutFailed
+		}
+		return nil, domain.ErrWithdrawFailed
+
+	default:
+		return nil, domain.ErrRequestInProgress
+	}
+}
+
+// withdrawalQuote is what withdrawing an amount from a wallet comes to.
+type withdrawalQuote struct {
+	limit *domain.WithdrawalLimit
+	// usage is what the wallet withdrew inside the limit window before
+	// this withdrawal.
+	usage domain.WithdrawalUsage
+	fee   domain.Money
+	// debit is the amount plus the fee.
+	debit domain.Money
+}
+
+// quoteWithdrawal runs the checks Withdraw and PreviewWithdraw share, in
+// order: the amount, the limit that applies to the wallet owner, counting the
+// withdrawals inside the limit window that succeeded or are still paying
+// out, and then the fee. Funds are left to the caller.
+func (w WalletService) quoteWithdrawal(ctx context.Context, wallet *domain.Wallet, amount domain.Money) (*withdrawalQuote, error) {
+	if !amount.IsPositive() {
+		return nil, domain.ErrInvalidAmount
+	}
+
+	limit, usage, err := w.withdrawalLimit(ctx, wallet)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := limit.Check(amount.Amount, usage); err != nil {
+		return nil, err
+	}
+
+	fee, err := w.withdrawalFee(ctx, wallet, amount)
+	if err != nil {
+		return nil, err
+	}
+
+	debit, err := amount.Add(fee)
+	if err != nil {
+		return nil, err
+	}
+
+	return &withdrawalQuote{limit: limit, usage: usage, fee: fee, debit: debit}, nil
+}
+
+// withdrawalLimit returns the limit that applies to the wallet owner in the
+// wallet's currency and what the wallet already withdrew inside the limit
+// window. The configured default is in minor units of the default currency,
+// so wallets in other currencies without a limit row of their own are not
+// limited.
+func (w WalletService) withdrawalLimit(ctx context.Context, wallet *domain.Wallet) (*domain.WithdrawalLimit, domain.WithdrawalUsage, error) {
+	limit, err := w.limitRepository.GetForUser(ctx, wallet.UserID, wallet.Currency)
+	if err != nil {
+		if !errors.Is(err, domain.ErrLimitNotFound) {
+			return nil, domain.WithdrawalUsage{}, errors.Join(errors.New("WalletService.withdrawalLimit: error on limit repository get"), err)
+		}
+
+		limit = &domain.WithdrawalLimit{}
+		if wallet.Currency == w.defaultCurrency {
+			limit = &w.defaultLimit
+		}
+	}
+
+	usage, err := w.ledgerRepository.SumWithdrawnSince(ctx, wallet.ID, time.Now().Add(-domain.WithdrawalLimitWindow))
+	if err != nil {
+		return nil, domain.WithdrawalUsage{}, errors.Join(errors.New("WalletService.withdrawalLimit: error on ledger repository sum"), err)
+	}
+
+	return limit, usage, nil
+}
+
+// withdrawalFee prices a withdrawal with the fee schedule that applies to the
+// wallet owner in the wallet's currency. The configured default is in minor
+// units of the default currency, so wallets in other currencies without a
+// schedule of their own pay no fee.
+func (w WalletService) withdrawalFee(ctx context.Context, wallet *domain.Wallet, amount domain.Money) (domain.Money, error) {
+	schedule, err := w.feeRepository.GetForUser(ctx, wallet.UserID, wallet.Currency)
+	if err != nil {
+		if !errors.Is(err, domain.ErrFeeScheduleNotFound) {
+			return domain.Money{}, errors.Join(errors.New("WalletService.withdrawalFee: error on fee repository get"), err)
+		}
+
+		if wallet.Currency != w.defaultCurrency {
+			return domain.NewMoney(0, amount.Currency), nil
+		}
+		schedule = &w.defaultFee
+	}
+
+	return schedule.Calculate(amount)
+}
+
+// chargeFee records the fee already debited with a withdrawal as a FEE ledger
+// and books it to the house revenue account. The FEE ledger shares the
+// withdrawal's status and payout reference, so it settles with the payout.
+// It records nothing for a zero fee.
+func (w WalletService) chargeFee(ctx context.Context, withdrawal domain.Ledger, fee, balance domain.Money) (*domain.Ledger, error) {
+	if !fee.IsPositive() {
+		return nil, nil
+	}
+
+	ledger, err := w.ledgerRepository.Create(ctx, domain.Ledger{
+		IdempotencyKey:  uuid.NewString(),
+		Type:            domain.LedgerTypeFee,
+		WalletID:        withdrawal.WalletID,
+		Status:          withdrawal.Status,
+		Amount:          fee,
+		PayoutReference: withdrawal.PayoutReference,
+		ParentLedgerID:  &withdrawal.ID,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	ledger.ResultBalance = &balance
+	if err := w.ledgerRepository.Update(ctx, *ledger); err != nil {
+		return nil, err
+	}
+
+	err = bookLedger(ctx, w.accountRepository, w.journalRepository, *ledger,
+		walletAccount(withdrawal.WalletID), systemAccount(domain.SystemAccountFeeRevenue, fee.Currency))
+	if err != nil {
+		return nil, err
+	}
+
+	return ledger, nil
+}
+
+type DepositWalletSpec struct {
+	UserID         int64
+	IdempotencyKey string
+	// Amount must name its currency, which picks the user's wallet.
+	Amount domain.Money
+}
+
+type DepositResult struct {
+	UserID  int64
+	Balance domain.Money
+	Amount  domain.Money
+}
+
+func (w WalletService) Deposit(ctx context.Context, spec DepositWalletSpec) (*DepositResult, error) {
+	currency, err := w.requireCurrency(spec.Amount.Currency)
+	if err != nil {
+		return nil, err
+	}
+	spec.Amount.Currency = currency
+
+	var walletID int64
+	var balance domain.Money
+	err = w.txProvider.Tx(ctx, func(ctx context.Context) error {
+		wallet, err := getWallet(ctx, w.walletRepository, spec.UserID, currency)
+		if err != nil {
+			return err
+		}
+		walletID = wallet.ID
+
+		if _, err := wallet.Balance.Add(spec.Amount); err != nil {
+			return err
+		}
+
+		ledger, err := w.ledgerRepository.Create(ctx, domain.Ledger{
+			IdempotencyKey: spec.IdempotencyKey,
+			Type:           domain.LedgerTypeDeposit,
+			WalletID:       wallet.ID,
+			Status:         domain.LedgerStatusProcessing,
+			Amount:         spec.Amount,
+		})
+		if err != nil {
+			return err
+		}
+
+		balance, err = w.walletRepository.IncreaseBalance(ctx, spec.Amount, wallet.ID)
+		if err != nil {
+			return err
+		}
+
+		ledger.Status = domain.LedgerStatusSucceed
+		ledger.ResultBalance = &balance
+		if err := w.ledgerRepository.Update(ctx, *ledger); err != nil {
+			return err
+		}
+
+		return bookLedger(ctx, w.accountRepository, w.journalRepository, *ledger,
+			systemAccount(domain.SystemAccountCashIn, wallet.Currency), walletAccount(wallet.ID))
+	})
+
+	if errors.Is(err, domain.ErrLedgerConflict) {
+		depositResult, err2 := w.handleConflictDeposit(ctx, walletID, spec)
+
+		if err2 != nil {
+			return nil, errors.Join(err, err2)
+		}
+
+		return depositResult, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &DepositResult{
 		UserID:  spec.UserID,
 		Amount:  spec.Amount,
 		Balance: balance,
-	}, err
+	}, nil
 }
 
-func (w WalletService) handleConflictWithdraw(ctx context.Context, spec WithdrawWalletSpec) (*WithdrawalResult, error) {
-	l, err := w.ledgerRepository.GetByIdempotencyKey(ctx, spec.IdempotencyKey)
+func (w WalletService) handleConflictDeposit(ctx context.Context, walletID int64, spec DepositWalletSpec) (*DepositResult, error) {
+	l, err := getReplayLedger(ctx, w.ledgerRepository, walletID, spec.IdempotencyKey)
 	if err != nil {
 		return nil, err
 	}
 
-	if l.Amount != spec.Amount || l.Type != domain.LedgerTypeWithdraw {
+	if l.Amount != spec.Amount || l.Type != domain.LedgerTypeDeposit {
 		return nil, domain.ErrIdempotencyKeyReused
 	}
 
@@ -118,27 +641,355 @@ func (w WalletService) handleConflictWithdraw(ctx context.Context, spec Withdraw
 		if l.ResultBalance == nil {
 			return nil, domain.ErrRequestInProgress
 		}
-		return &WithdrawalResult{
+		return &DepositResult{
 			UserID:  spec.UserID,
 			Amount:  spec.Amount,
 			Balance: *l.ResultBalance,
 		}, nil
 
 	case domain.LedgerStatusFailed:
-		if l.ErrorCode != nil && *l.ErrorCode == "INSUFFICIENT_FUND" {
+		return nil, domain.ErrDepositFailed
+
+	default:
+		return nil, domain.ErrRequestInProgress
+	}
+}
+
+type TransferWalletSpec struct {
+	FromUserID     int64
+	ToUserID       int64
+	IdempotencyKey string
+	// Amount is in the default currency when it names none. Both users need
+	// a wallet in its currency.
+	Amount domain.Money
+}
+
+type TransferResult struct {
+	TransferID string
+	FromUserID int64
+	ToUserID   int64
+	Balance    domain.Money
+	Amount     domain.Money
+}
+
+func (w WalletService) Transfer(ctx context.Context, spec TransferWalletSpec) (*TransferResult, error) {
+	if spec.FromUserID == spec.ToUserID {
+		return nil, domain.ErrSameWallet
+	}
+
+	currency, err := w.resolveCurrency(spec.Amount.Currency)
+	if err != nil {
+		return nil, err
+	}
+	spec.Amount.Currency = currency
+
+	transferID := uuid.NewString()
+
+	var fromWalletID int64
+	var balance domain.Money
+	var appErr error
+	err = w.txProvider.Tx(ctx, func(ctx context.Context) error {
+		from, err := getWallet(ctx, w.walletRepository, spec.FromUserID, currency)
+		if err != nil {
+			return err
+		}
+		fromWalletID = from.ID
+
+		to, err := getWallet(ctx, w.walletRepository, spec.ToUserID, currency)
+		if err != nil {
+			return err
+		}
+
+		// Lock both wallets in ID order so that two opposite transfers
+		// between the same pair cannot deadlock each other.
+		lockIDs := []int64{from.ID, to.ID}
+		slices.Sort(lockIDs)
+		for _, id := range lockIDs {
+			locked, err := w.walletRepository.LockByID(ctx, id)
+			if err != nil {
+				return err
+			}
+
+			if locked.ID == from.ID {
+				from = locked
+			} else {
+				to = locked
+			}
+		}
+
+		if _, err := to.Balance.Add(spec.Amount); err != nil {
+			return err
+		}
+
+		out, err := w.ledgerRepository.Create(ctx, domain.Ledger{
+			IdempotencyKey: spec.IdempotencyKey,
+			Type:           domain.LedgerTypeTransferOut,
+			WalletID:       from.ID,
+			Status:         domain.LedgerStatusProcessing,
+			Amount:         spec.Amount,
+			TransferID:     &transferID,
+		})
+		if err != nil {
+			return err
+		}
+
+		balance, err = w.walletRepository.DecreaseBalance(ctx, spec.Amount, from.ID)
+		if err != nil {
+			if errors.Is(err, domain.ErrInsufficientFund) {
+				errCode := domain.LedgerErrorCodeInsufficientFund
+				out.Status = domain.LedgerStatusFailed
+				out.ErrorCode = &errCode
+				out.ResultBalance = &from.Balance
+				uerr := w.ledgerRepository.Update(ctx, *out)
+				appErr = err
+				return uerr
+			}
+
+			return err
+		}
+
+		in, err := w.ledgerRepository.Create(ctx, domain.Ledger{
+			IdempotencyKey: uuid.NewString(),
+			Type:           domain.LedgerTypeTransferIn,
+			WalletID:       to.ID,
+			Status:         domain.LedgerStatusProcessing,
+			Amount:         spec.Amount,
+			TransferID:     &transferID,
+		})
+		if err != nil {
+			return err
+		}
+
+		toBalance, err := w.walletRepository.IncreaseBalance(ctx, spec.Amount, to.ID)
+		if err != nil {
+			return err
+		}
+
+		out.Status = domain.LedgerStatusSucceed
+		out.ResultBalance = &balance
+		if err := w.ledgerRepository.Update(ctx, *out); err != nil {
+			return err
+		}
+
+		in.Status = domain.LedgerStatusSucceed
+		in.ResultBalance = &toBalance
+		if err := w.ledgerRepository.Update(ctx, *in); err != nil {
+			return err
+		}
+
+		return bookLedger(ctx, w.accountRepository, w.journalRepository, *out,
+			walletAccount(from.ID), walletAccount(to.ID))
+	})
+
+	if errors.Is(err, domain.ErrLedgerConflict) {
+		transferResult, err2 := w.handleConflictTransfer(ctx, fromWalletID, spec)
+
+		if err2 != nil {
+			return nil, errors.Join(err, err2)
+		}
+
+		return transferResult, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	if appErr != nil {
+		return nil, appErr
+	}
+
+	return &TransferResult{
+		TransferID: transferID,
+		FromUserID: spec.FromUserID,
+		ToUserID:   spec.ToUserID,
+		Amount:     spec.Amount,
+		Balance:    balance,
+	}, nil
+}
+
+func (w WalletService) handleConflictTransfer(ctx context.Context, walletID int64, spec TransferWalletSpec) (*TransferResult, error) {
+	l, err := getReplayLedger(ctx, w.ledgerRepository, walletID, spec.IdempotencyKey)
+	if err != nil {
+		return nil, err
+	}
+
+	if l.Amount != spec.Amount || l.Type != domain.LedgerTypeTransferOut || l.TransferID == nil {
+		return nil, domain.ErrIdempotencyKeyReused
+	}
+
+	switch l.Status {
+	case domain.LedgerStatusSucceed:
+		if l.ResultBalance == nil {
+			return nil, domain.ErrRequestInProgress
+		}
+
+		in, err := w.ledgerRepository.GetByTransferID(ctx, *l.TransferID, domain.LedgerTypeTransferIn)
+		if err != nil {
+			return nil, err
+		}
+
+		to, err := w.walletRepository.GetByUserID(ctx, spec.ToUserID, spec.Amount.Currency)
+		if err != nil {
+			return nil, err
+		}
+
+		if in.WalletID != to.ID {
+			return nil, domain.ErrIdempotencyKeyReused
+		}
+
+		return &TransferResult{
+			TransferID: *l.TransferID,
+			FromUserID: spec.FromUserID,
+			ToUserID:   spec.ToUserID,
+			Amount:     spec.Amount,
+			Balance:    *l.ResultBalance,
+		}, nil
+
+	case domain.LedgerStatusFailed:
+		if l.ErrorCode != nil && *l.ErrorCode == domain.LedgerErrorCodeInsufficientFund {
 			return nil, domain.ErrInsufficientFund
 		}
-		return nil, domain.ErrWithdrawFailed
+		return nil, domain.ErrTransferFailed
 
 	default:
 		return nil, domain.ErrRequestInProgress
 	}
 }
 
-func NewWalletService(walletRepository *repository.WalletRepository, ledgerRepository *repository.LedgerRepository, txProvider *repository.TxProvider) *WalletService {
+// getReplayLedger loads the ledger that made an idempotent request conflict.
+// Keys are scoped per wallet, so only the caller's own ledger is ever replayed;
+// a conflict that cannot be traced back to the caller's wallet is reported as
+// a reused key instead of leaking another wallet's outcome.
+func getReplayLedger(ctx context.Context, ledgerRepository repository.LedgerRepository, walletID int64, idempotencyKey string) (*domain.Ledger, error) {
+	l, err := ledgerRepository.GetByIdempotencyKey(ctx, walletID, idempotencyKey)
+	if err != nil {
+		if errors.Is(err, domain.ErrLedgerNotFound) {
+			return nil, domain.ErrIdempotencyKeyReused
+		}
+
+		return nil, err
+	}
+
+	if l.WalletID != walletID {
+		return nil, domain.ErrIdempotencyKeyReused
+	}
+
+	return l, nil
+}
+
+const (
+	defaultTransactionsLimit = 20
+	maxTransactionsLimit     = 100
+)
+
+type ListTransactionsSpec struct {
+	UserID int64
+	// Currency picks the wallet, defaulting to the default currency.
+	Currency string
+	Type     string
+	Status   string
+	From     *time.Time
+	To       *time.Time
+	Limit    int
+	Cursor   string
+}
+
+type TransactionPage struct {
+	Ledgers    []domain.Ledger
+	NextCursor string
+}
+
+func (w WalletService) ListTransactions(ctx context.Context, spec ListTransactionsSpec) (*TransactionPage, error) {
+	limit := spec.Limit
+	if limit <= 0 {
+		limit = defaultTransactionsLimit
+	}
+	limit = min(limit, maxTransactionsLimit)
+
+	var after *repository.LedgerCursor
+	if spec.Cursor != "" {
+		c, err := decodeLedgerCursor(spec.Cursor)
+		if err != nil {
+			return nil, err
+		}
+		after = c
+	}
+
+	wallet, err := w.GetByUserID(ctx, spec.UserID, spec.Currency)
+	if err != nil {
+		return nil, err
+	}
+
+	// Fetch one extra row to know whether another page exists.
+	ledgers, err := w.ledgerRepository.List(ctx, repository.LedgerFilter{
+		WalletID: wallet.ID,
+		Type:     spec.Type,
+		Status:   spec.Status,
+		From:     spec.From,
+		To:       spec.To,
+		After:    after,
+		Limit:    limit + 1,
+	})
+	if err != nil {
+		return nil, errors.Join(errors.New("WalletService.ListTransactions: error on ledger repository list"), err)
+	}
+
+	page := &TransactionPage{Ledgers: ledgers}
+	if len(ledgers) > limit {
+		page.Ledgers = ledgers[:limit]
+		last := page.Ledgers[limit-1]
+		page.NextCursor = encodeLedgerCursor(repository.LedgerCursor{
+			CreatedAt: last.CreatedAt,
+			ID:        last.ID,
+		})
+	}
+
+	return page, nil
+}
+
+type ledgerCursorPayload struct {
+	CreatedAt time.Time `json:"t"`
+	ID        int64     `json:"id"`
+}
+
+func encodeLedgerCursor(c repository.LedgerCursor) string {
+	b, _ := json.Marshal(ledgerCursorPayload{CreatedAt: c.CreatedAt, ID: c.ID})
+
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func decodeLedgerCursor(s string) (*repository.LedgerCursor, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, domain.ErrInvalidCursor
+	}
+
+	var p ledgerCursorPayload
+	if err := json.Unmarshal(b, &p); err != nil || p.ID <= 0 {
+		return nil, domain.ErrInvalidCursor
+	}
+
+	return &repository.LedgerCursor{CreatedAt: p.CreatedAt, ID: p.ID}, nil
+}
+
+func NewWalletService(walletRepository repository.WalletRepository, ledgerRepository repository.LedgerRepository, accountRepository repository.AccountRepository, journalRepository repository.JournalRepository, limitRepository repository.LimitRepository, feeRepository repository.FeeRepository, outboxRepository repository.OutboxRepository, webhookRepository repository.WebhookRepository, holdRepository repository.HoldRepository, txProvider repository.TxProvider, payoutService *PayoutService, defaultLimit domain.WithdrawalLimit, defaultFee domain.FeeSchedule, withdrawTx repository.TxOptions, holdTTL time.Duration, defaultCurrency string) *WalletService {
 	return &WalletService{
-		walletRepository: walletRepository,
-		ledgerRepository: ledgerRepository,
-		txProvider:       txProvider,
+		walletRepository:  walletRepository,
+		ledgerRepository:  ledgerRepository,
+		accountRepository: accountRepository,
+		journalRepository: journalRepository,
+		limitRepository:   limitRepository,
+		feeRepository:     feeRepository,
+		outboxRepository:  outboxRepository,
+		webhookRepository: webhookRepository,
+		holdRepository:    holdRepository,
+		txProvider:        txProvider,
+		payoutService:     payoutService,
+		defaultLimit:      defaultLimit,
+		defaultFee:        defaultFee,
+		withdrawTx:        withdrawTx,
+		holdTTL:           holdTTL,
+		defaultCurrency:   defaultCurrency,
 	}
 }
@@ -0,0 +1,180 @@
+package service_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/vcnt72/go-boilerplate/internal/domain"
+	"github.com/vcnt72/go-boilerplate/internal/repository"
+	"github.com/vcnt72/go-boilerplate/internal/service"
+	"github.com/vcnt72/go-boilerplate/internal/webhook"
+)
+
+type webhookReceiver struct {
+	*httptest.Server
+
+	mu     sync.Mutex
+	status int
+	bodies [][]byte
+	errs   []error
+}
+
+func newWebhookReceiver(t *testing.T, secret string) *webhookReceiver {
+	r := &webhookReceiver{status: http.StatusOK}
+	r.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		b, _ := io.ReadAll(req.Body)
+
+		r.mu.Lock()
+		defer r.mu.Unlock()
+
+		r.errs = append(r.errs, webhook.Verify(secret, req.Header.Get(webhook.SignatureHeader), b, time.Minute, time.Now()))
+		r.bodies = append(r.bodies, b)
+		w.WriteHeader(r.status)
+	}))
+	t.Cleanup(r.Close)
+
+	return r
+}
+
+func (r *webhookReceiver) setStatus(status int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.status = status
+}
+
+func newWebhookService() *service.WebhookService {
+	return service.NewWebhookService(repository.NewWebhookRepository(testDB), repository.NewTxProvider(testDB))
+}
+
+func TestIntegration_Webhook_DeliversSignedWithdrawEvents(t *testing.T) {
+	cleanDB(t)
+
+	ctx := context.Background()
+	webhooks := newWebhookService()
+	wallets := newWalletService()
+
+	receiver := newWebhookReceiver(t, "whsec_test")
+	sub, err := webhooks.CreateSubscription(ctx, service.CreateWebhookSpec{
+		URL:        receiver.URL,
+		EventTypes: []string{domain.OutboxEventWithdrawSucceeded},
+		Secret:     "whsec_test",
+	})
+	require.NoError(t, err)
+
+	seedUser(t, 1)
+	seedWallet(t, 1, 100_000)
+
+	_, err = wallets.Withdraw(ctx, service.WithdrawWalletSpec{UserID: 1, Amount: idr(30_000), IdempotencyKey: "k-w-1"})
+	require.NoError(t, err)
+	// Not subscribed to failures.
+	_, err = wallets.Withdraw(ctx, service.WithdrawWalletSpec{UserID: 1, Amount: idr(500_000), IdempotencyKey: "k-w-2"})
+	require.True(t, errors.Is(err, domain.ErrInsufficientFund))
+
+	res, err := webhooks.Dispatch(ctx, service.DispatchSpec{Limit: 10, MaxAttempts: 3, Sender: webhook.NewSender(receiver.Client(), 0)})
+	require.NoError(t, err)
+	require.Equal(t, 1, res.Delivered)
+
+	require.Len(t, receiver.bodies, 1)
+	require.NoError(t, receiver.errs[0])
+
+	var body struct {
+		Type string `json:"type"`
+		Data struct {
+			IdempotencyKey string `json:"idempotencyKey"`
+			Amount         string `json:"amount"`
+		} `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(receiver.bodies[0], &body))
+	require.Equal(t, domain.OutboxEventWithdrawSucceeded, body.Type)
+	require.Equal(t, "30000", body.Data.Amount)
+	require.Equal(t, "k-w-1", body.Data.IdempotencyKey)
+
+	deliveries, err := webhooks.ListDeliveries(ctx, service.ListDeliveriesSpec{SubscriptionID: sub.ID})
+	require.NoError(t, err)
+	require.Len(t, deliveries, 1)
+	require.Equal(t, domain.WebhookDeliveryStatusSucceeded, deliveries[0].Status)
+	require.Equal(t, 1, deliveries[0].Attempts)
+}
+
+func TestIntegration_Webhook_RetriesThenDeadLetters(t *testing.T) {
+	cleanDB(t)
+
+	ctx := context.Background()
+	webhooks := newWebhookService()
+	wallets := newWalletService()
+
+	receiver := newWebhookReceiver(t, "whsec_test")
+	receiver.setStatus(http.StatusServiceUnavailable)
+	sub, err := webhooks.CreateSubscription(ctx, service.CreateWebhookSpec{
+		URL:        receiver.URL,
+		EventTypes: []string{domain.OutboxEventWithdrawSucceeded, domain.OutboxEventWithdrawFailed},
+		Secret:     "whsec_test",
+	})
+	require.NoError(t, err)
+
+	seedUser(t, 1)
+	seedWallet(t, 1, 100_000)
+
+	_, err = wallets.Withdraw(ctx, service.WithdrawWalletSpec{UserID: 1, Amount: idr(30_000), IdempotencyKey: "k-w-1"})
+	require.NoError(t, err)
+
+	spec := service.DispatchSpec{Limit: 10, MaxAttempts: 2, Sender: webhook.NewSender(receiver.Client(), 0)}
+
+	res, err := webhooks.Dispatch(ctx, spec)
+	require.NoError(t, err)
+	require.Equal(t, 1, res.Retried)
+
+	// Backoff keeps the delivery out of the next run.
+	res, err = webhooks.Dispatch(ctx, spec)
+	require.NoError(t, err)
+	require.Equal(t, 0, res.Found)
+
+	_, err = testDB.Exec(`UPDATE webhook_deliveries SET next_attempt_at = now()`)
+	require.NoError(t, err)
+
+	res, err = webhooks.Dispatch(ctx, spec)
+	require.NoError(t, err)
+	require.Equal(t, 1, res.Dead)
+
+	deliveries, err := webhooks.ListDeliveries(ctx, service.ListDeliveriesSpec{SubscriptionID: sub.ID, Status: domain.WebhookDeliveryStatusDead})
+	require.NoError(t, err)
+	require.Len(t, deliveries, 1)
+	require.Equal(t, 2, deliveries[0].Attempts)
+	require.Equal(t, http.StatusServiceUnavailable, *deliveries[0].LastStatusCode)
+}
+
+func TestIntegration_Webhook_SubscriptionCRUD(t *testing.T) {
+	cleanDB(t)
+
+	ctx := context.Background()
+	webhooks := newWebhookService()
+
+	_, err := webhooks.CreateSubscription(ctx, service.CreateWebhookSpec{URL: "ftp://example.com", EventTypes: []string{domain.OutboxEventWithdrawFailed}})
+	require.True(t, errors.Is(err, domain.ErrInvalidWebhookURL))
+	_, err = webhooks.CreateSubscription(ctx, service.CreateWebhookSpec{URL: "https://example.com", EventTypes: []string{"wallet.unknown"}})
+	require.True(t, errors.Is(err, domain.ErrInvalidWebhookEvent))
+
+	sub, err := webhooks.CreateSubscription(ctx, service.CreateWebhookSpec{URL: "https://example.com/hook", EventTypes: []string{domain.OutboxEventWithdrawFailed}})
+	require.NoError(t, err)
+	require.NotEmpty(t, sub.Secret)
+
+	active := false
+	updated, err := webhooks.UpdateSubscription(ctx, service.UpdateWebhookSpec{ID: sub.ID, Active: &active})
+	require.NoError(t, err)
+	require.False(t, updated.Active)
+	require.Equal(t, sub.Secret, updated.Secret)
+
+	require.NoError(t, webhooks.DeleteSubscription(ctx, sub.ID))
+	_, err = webhooks.GetSubscription(ctx, sub.ID)
+	require.True(t, errors.Is(err, domain.ErrWebhookNotFound))
+	require.True(t, errors.Is(webhooks.DeleteSubscription(ctx, sub.ID), domain.ErrWebhookNotFound))
+}
@@ -0,0 +1,232 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	"github.com/vcnt72/go-boilerplate/internal/domain"
+	"github.com/vcnt72/go-boilerplate/internal/repository"
+	"github.com/vcnt72/go-boilerplate/internal/webhook"
+)
+
+const (
+	webhookRetryBase = 10 * time.Second
+	webhookRetryMax  = time.Hour
+
+	defaultDeliveriesLimit = 20
+	maxDeliveriesLimit     = 100
+)
+
+type WebhookService struct {
+	webhookRepository repository.WebhookRepository
+	txProvider        repository.TxProvider
+}
+
+type CreateWebhookSpec struct {
+	URL        string
+	EventTypes []string
+	// Secret signs the deliveries. One is generated when empty.
+	Secret string
+}
+
+func (w WebhookService) CreateSubscription(ctx context.Context, spec CreateWebhookSpec) (*domain.WebhookSubscription, error) {
+	sub := domain.WebhookSubscription{
+		URL:        spec.URL,
+		EventTypes: spec.EventTypes,
+		Secret:     spec.Secret,
+		Active:     true,
+	}
+	if err := sub.Validate(); err != nil {
+		return nil, err
+	}
+
+	if sub.Secret == "" {
+		secret, err := newWebhookSecret()
+		if err != nil {
+			return nil, errors.Join(errors.New("WebhookService.CreateSubscription: error on generate secret"), err)
+		}
+		sub.Secret = secret
+	}
+
+	created, err := w.webhookRepository.CreateSubscription(ctx, sub)
+	if err != nil {
+		return nil, errors.Join(errors.New("WebhookService.CreateSubscription: error on webhook repository create"), err)
+	}
+
+	return created, nil
+}
+
+func (w WebhookService) GetSubscription(ctx context.Context, id int64) (*domain.WebhookSubscription, error) {
+	return w.webhookRepository.GetSubscription(ctx, id)
+}
+
+func (w WebhookService) ListSubscriptions(ctx context.Context) ([]domain.WebhookSubscription, error) {
+	return w.webhookRepository.ListSubscriptions(ctx)
+}
+
+// UpdateWebhookSpec changes only the fields that are set.
+type UpdateWebhookSpec struct {
+	ID         int64
+	URL        *string
+	EventTypes []string
+	Secret     *string
+	Active     *bool
+}
+
+func (w WebhookService) UpdateSubscription(ctx context.Context, spec UpdateWebhookSpec) (*domain.WebhookSubscription, error) {
+	var updated *domain.WebhookSubscription
+	err := w.txProvider.Tx(ctx, func(ctx context.Context) error {
+		sub, err := w.webhookRepository.GetSubscription(ctx, spec.ID)
+		if err != nil {
+			return err
+		}
+
+		if spec.URL != nil {
+			sub.URL = *spec.URL
+		}
+		if spec.EventTypes != nil {
+			sub.EventTypes = spec.EventTypes
+		}
+		if spec.Secret != nil && *spec.Secret != "" {
+			sub.Secret = *spec.Secret
+		}
+		if spec.Active != nil {
+			sub.Active = *spec.Active
+		}
+
+		if err := sub.Validate(); err != nil {
+			return err
+		}
+
+		updated, err = w.webhookRepository.UpdateSubscription(ctx, *sub)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return updated, nil
+}
+
+func (w WebhookService) DeleteSubscription(ctx context.Context, id int64) error {
+	return w.webhookRepository.DeleteSubscription(ctx, id)
+}
+
+type ListDeliveriesSpec struct {
+	SubscriptionID int64
+	Status         string
+	BeforeID       int64
+	Limit          int
+}
+
+// ListDeliveries is the delivery log of a subscription, newest first.
+func (w WebhookService) ListDeliveries(ctx context.Context, spec ListDeliveriesSpec) ([]domain.WebhookDelivery, error) {
+	if _, err := w.webhookRepository.GetSubscription(ctx, spec.SubscriptionID); err != nil {
+		return nil, err
+	}
+
+	limit := spec.Limit
+	if limit <= 0 {
+		limit = defaultDeliveriesLimit
+	}
+
+	return w.webhookRepository.ListDeliveries(ctx, repository.WebhookDeliveryFilter{
+		SubscriptionID: spec.SubscriptionID,
+		Status:         spec.Status,
+		BeforeID:       spec.BeforeID,
+		Limit:          min(limit, maxDeliveriesLimit),
+	})
+}
+
+type DispatchSpec struct {
+	Limit       int
+	MaxAttempts int
+	// Lease keeps a claimed batch from other dispatchers while it is sent, so
+	// it should outlast sending a whole batch.
+	Lease  time.Duration
+	Sender *webhook.Sender
+}
+
+type DispatchResult struct {
+	Found     int
+	Delivered int
+	Retried   int
+	Dead      int
+}
+
+// Dispatch sends one batch of due deliveries. A failed delivery is retried
+// with exponential backoff until it used spec.MaxAttempts attempts, after
+// which it is moved to DEAD.
+//
+// The batch is claimed in a short transaction that postpones it by
+// spec.Lease, then sent with no transaction open, so a slow receiver holds no
+// locks. Each outcome is recorded by a statement of its own. A delivery whose
+// outcome is not recorded, because the dispatcher stopped or the lease ran
+// out first, is sent again, which makes delivery at-least-once.
+func (w WebhookService) Dispatch(ctx context.Context, spec DispatchSpec) (*DispatchResult, error) {
+	var deliveries []repository.DueWebhookDelivery
+	err := w.txProvider.Tx(ctx, func(ctx context.Context) error {
+		var err error
+		deliveries, err = w.webhookRepository.ClaimDueDeliveries(ctx, spec.Limit, spec.Lease)
+		return err
+	})
+	if err != nil {
+		return nil, errors.Join(errors.New("WebhookService.Dispatch: error on webhook repository claim"), err)
+	}
+
+	result := &DispatchResult{Found: len(deliveries)}
+	for _, d := range deliveries {
+		statusCode, sendErr := spec.Sender.Send(ctx, webhook.Request{
+			URL:        d.URL,
+			Secret:     d.Secret,
+			DeliveryID: d.ID,
+			EventID:    d.EventID,
+			EventType:  d.EventType,
+			CreatedAt:  d.CreatedAt,
+			Payload:    d.Payload,
+		})
+
+		var code *int
+		if statusCode != 0 {
+			code = &statusCode
+		}
+
+		switch {
+		case sendErr == nil:
+			err = w.webhookRepository.MarkDelivered(ctx, d.ID, statusCode)
+			result.Delivered++
+
+		case d.Attempts+1 >= spec.MaxAttempts:
+			err = w.webhookRepository.MarkDead(ctx, d.ID, code, sendErr.Error())
+			result.Dead++
+
+		default:
+			err = w.webhookRepository.MarkRetry(ctx, d.ID, code, sendErr.Error(), retryDelay(webhookRetryBase, webhookRetryMax, d.Attempts))
+			result.Retried++
+		}
+		if err != nil {
+			return nil, errors.Join(errors.New("WebhookService.Dispatch: error on webhook repository update"), err)
+		}
+	}
+
+	return result, nil
+}
+
+func newWebhookSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return "whsec_" + hex.EncodeToString(b), nil
+}
+
+func NewWebhookService(webhookRepository repository.WebhookRepository, txProvider repository.TxProvider) *WebhookService {
+	return &WebhookService{
+		webhookRepository: webhookRepository,
+		txProvider:        txProvider,
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"database/sql"
 	"errors"
+	"fmt"
 	"log"
 	"net/url"
 	"os"
@@ -19,6 +20,7 @@ import (
 	"github.com/stretchr/testify/require"
 
 	"github.com/vcnt72/go-boilerplate/internal/domain"
+	"github.com/vcnt72/go-boilerplate/internal/payout"
 	"github.com/vcnt72/go-boilerplate/internal/repository"
 	"github.com/vcnt72/go-boilerplate/internal/service"
 )
@@ -89,8 +91,23 @@ func ensureDatabase(dsn string, dbName string) {
 }
 
 func cleanDB(t *testing.T) {
+	if testDB == nil {
+		t.Skip("DB_TEST_URL is not set")
+	}
+
 	_, err := testDB.Exec(`
+		TRUNCATE TABLE fx_quotes RESTART IDENTITY CASCADE;
+		TRUNCATE TABLE fx_rates RESTART IDENTITY CASCADE;
+		TRUNCATE TABLE webhook_deliveries RESTART IDENTITY CASCADE;
+		TRUNCATE TABLE webhook_subscriptions RESTART IDENTITY CASCADE;
+		TRUNCATE TABLE outbox RESTART IDENTITY CASCADE;
+		TRUNCATE TABLE postings RESTART IDENTITY CASCADE;
+		TRUNCATE TABLE journals RESTART IDENTITY CASCADE;
+		TRUNCATE TABLE accounts RESTART IDENTITY CASCADE;
+		TRUNCATE TABLE withdrawal_limits RESTART IDENTITY CASCADE;
+		TRUNCATE TABLE fee_schedules RESTART IDENTITY CASCADE;
 		TRUNCATE TABLE ledgers RESTART IDENTITY CASCADE;
+		TRUNCATE TABLE holds RESTART IDENTITY CASCADE;
 		TRUNCATE TABLE wallets RESTART IDENTITY CASCADE;
 		TRUNCATE TABLE users RESTART IDENTITY CASCADE;
 	`)
@@ -106,22 +123,55 @@ func seedUser(t *testing.T, userID int64) {
 }
 
 func seedWallet(t *testing.T, userID int64, balance int64) {
-	_, err := testDB.Exec(`
-		INSERT INTO wallets (user_id, balance, created_at, updated_at)
-		VALUES ($1, $2, now(), now())
-	`, userID, balance)
+	seedCurrencyWallet(t, userID, "IDR", balance)
+}
+
+func seedCurrencyWallet(t *testing.T, userID int64, currency string, balance int64) {
+	c, err := domain.LookupCurrency(currency)
+	require.NoError(t, err)
+
+	var walletID int64
+	err = testDB.QueryRowx(`
+		INSERT INTO wallets (user_id, balance, currency, currency_exponent, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, now(), now())
+		RETURNING id
+	`, userID, balance, c.Code, c.Exponent).Scan(&walletID)
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	accountRepo := repository.NewAccountRepository(testDB)
+	account, err := accountRepo.CreateForWallet(ctx, walletID)
+	require.NoError(t, err)
+
+	if balance == 0 {
+		return
+	}
+
+	opening, err := accountRepo.GetOrCreateSystem(ctx, domain.SystemAccountCode(domain.SystemAccountOpeningBalance, c.Code))
+	require.NoError(t, err)
+
+	_, err = repository.NewJournalRepository(testDB).Create(ctx,
+		domain.NewTransferJournal(domain.JournalTypeOpening, nil, opening.ID, account.ID, balance))
 	require.NoError(t, err)
 }
 
 func getBalance(t *testing.T, userID int64) int64 {
+	return getCurrencyBalance(t, userID, "IDR")
+}
+
+func getCurrencyBalance(t *testing.T, userID int64, currency string) int64 {
 	var b int64
 	err := testDB.QueryRowx(`
-		SELECT balance FROM wallets WHERE user_id = $1
-	`, userID).Scan(&b)
+		SELECT balance FROM wallets WHERE user_id = $1 AND currency = $2
+	`, userID, currency).Scan(&b)
 	require.NoError(t, err)
 	return b
 }
 
+func idr(amount int64) domain.Money {
+	return domain.NewMoney(amount, "IDR")
+}
+
 func countLedgers(t *testing.T, key string) int {
 	var c int
 	err := testDB.QueryRowx(`
@@ -132,10 +182,33 @@ func countLedgers(t *testing.T, key string) int {
 }
 
 func newWalletService() *service.WalletService {
+	return newWalletServiceWithLimit(domain.WithdrawalLimit{})
+}
+
+func newWalletServiceWithLimit(defaultLimit domain.WithdrawalLimit) *service.WalletService {
+	return newWalletServiceWith(defaultLimit, domain.FeeSchedule{})
+}
+
+func newWalletServiceWithFee(defaultFee domain.FeeSchedule) *service.WalletService {
+	return newWalletServiceWith(domain.WithdrawalLimit{}, defaultFee)
+}
+
+func newWalletServiceWith(defaultLimit domain.WithdrawalLimit, defaultFee domain.FeeSchedule) *service.WalletService {
+	return newWalletServiceWithProvider(defaultLimit, defaultFee, newSimulator(payout.OutcomeSucceed))
+}
+
+func newWalletServiceWithProvider(defaultLimit domain.WithdrawalLimit, defaultFee domain.FeeSchedule, provider service.PayoutProvider) *service.WalletService {
 	walletRepo := repository.NewWalletRepository(testDB)
 	ledgerRepo := repository.NewLedgerRepository(testDB)
+	accountRepo := repository.NewAccountRepository(testDB)
+	journalRepo := repository.NewJournalRepository(testDB)
+	limitRepo := repository.NewLimitRepository(testDB)
+	feeRepo := repository.NewFeeRepository(testDB)
+	outboxRepo := repository.NewOutboxRepository(testDB)
+	webhookRepo := repository.NewWebhookRepository(testDB)
+	holdRepo := repository.NewHoldRepository(testDB)
 	txProvider := repository.NewTxProvider(testDB)
-	return service.NewWalletService(walletRepo, ledgerRepo, txProvider)
+	return service.NewWalletService(walletRepo, ledgerRepo, accountRepo, journalRepo, limitRepo, feeRepo, outboxRepo, webhookRepo, holdRepo, txProvider, newPayoutService(provider), defaultLimit, defaultFee, repository.TxOptions{}, time.Hour, "IDR")
 }
 
 func TestIntegration_Withdraw_Success(t *testing.T) {
@@ -149,11 +222,11 @@ func TestIntegration_Withdraw_Success(t *testing.T) {
 
 	res, err := svc.Withdraw(context.Background(), service.WithdrawWalletSpec{
 		UserID:         userID,
-		Amount:         30_000,
+		Amount:         idr(30_000),
 		IdempotencyKey: "k-success",
 	})
 	require.NoError(t, err)
-	require.Equal(t, int64(70_000), res.Balance)
+	require.Equal(t, idr(70_000), res.Balance)
 	require.Equal(t, int64(70_000), getBalance(t, userID))
 }
 
@@ -168,7 +241,7 @@ func TestIntegration_Withdraw_InsufficientFunds(t *testing.T) {
 
 	res, err := svc.Withdraw(context.Background(), service.WithdrawWalletSpec{
 		UserID:         userID,
-		Amount:         60_000,
+		Amount:         idr(60_000),
 		IdempotencyKey: "k-insufficient",
 	})
 	require.Nil(t, res)
@@ -195,7 +268,7 @@ func TestIntegration_Withdraw_Concurrent(t *testing.T) {
 
 		_, err := svc.Withdraw(ctx, service.WithdrawWalletSpec{
 			UserID:         userID,
-			Amount:         80_000,
+			Amount:         idr(80_000),
 			IdempotencyKey: key,
 		})
 		errCh <- err
@@ -238,20 +311,183 @@ func TestIntegration_Withdraw_Idempotency_DeductOnce(t *testing.T) {
 
 	res1, err := svc.Withdraw(context.Background(), service.WithdrawWalletSpec{
 		UserID:         userID,
-		Amount:         30_000,
+		Amount:         idr(30_000),
 		IdempotencyKey: key,
 	})
 	require.NoError(t, err)
-	require.Equal(t, int64(70_000), res1.Balance)
+	require.Equal(t, idr(70_000), res1.Balance)
 	require.Equal(t, 1, countLedgers(t, key))
 
 	res2, err := svc.Withdraw(context.Background(), service.WithdrawWalletSpec{
 		UserID:         userID,
-		Amount:         30_000,
+		Amount:         idr(30_000),
 		IdempotencyKey: key,
 	})
 	require.NoError(t, err)
-	require.Equal(t, int64(70_000), res2.Balance)
+	require.Equal(t, idr(70_000), res2.Balance)
 	require.Equal(t, 1, countLedgers(t, key))
 	require.Equal(t, int64(70_000), getBalance(t, userID))
 }
+
+func TestIntegration_Deposit_Idempotency_CreditOnce(t *testing.T) {
+	cleanDB(t)
+
+	svc := newWalletService()
+	userID := int64(1)
+
+	seedUser(t, userID)
+	seedWallet(t, userID, 100_000)
+
+	key := "k-deposit"
+
+	res1, err := svc.Deposit(context.Background(), service.DepositWalletSpec{
+		UserID:         userID,
+		Amount:         idr(25_000),
+		IdempotencyKey: key,
+	})
+	require.NoError(t, err)
+	require.Equal(t, idr(125_000), res1.Balance)
+
+	res2, err := svc.Deposit(context.Background(), service.DepositWalletSpec{
+		UserID:         userID,
+		Amount:         idr(25_000),
+		IdempotencyKey: key,
+	})
+	require.NoError(t, err)
+	require.Equal(t, idr(125_000), res2.Balance)
+	require.Equal(t, 1, countLedgers(t, key))
+	require.Equal(t, int64(125_000), getBalance(t, userID))
+
+	_, err = svc.Withdraw(context.Background(), service.WithdrawWalletSpec{
+		UserID:         userID,
+		Amount:         idr(25_000),
+		IdempotencyKey: key,
+	})
+	require.True(t, errors.Is(err, domain.ErrIdempotencyKeyReused))
+}
+
+func TestIntegration_Transfer_Success(t *testing.T) {
+	cleanDB(t)
+
+	svc := newWalletService()
+
+	seedUser(t, 1)
+	seedWallet(t, 1, 100_000)
+	seedUser(t, 2)
+	seedWallet(t, 2, 10_000)
+
+	res, err := svc.Transfer(context.Background(), service.TransferWalletSpec{
+		FromUserID:     1,
+		ToUserID:       2,
+		Amount:         idr(40_000),
+		IdempotencyKey: "k-transfer",
+	})
+	require.NoError(t, err)
+	require.Equal(t, idr(60_000), res.Balance)
+	require.Equal(t, int64(60_000), getBalance(t, 1))
+	require.Equal(t, int64(50_000), getBalance(t, 2))
+
+	replay, err := svc.Transfer(context.Background(), service.TransferWalletSpec{
+		FromUserID:     1,
+		ToUserID:       2,
+		Amount:         idr(40_000),
+		IdempotencyKey: "k-transfer",
+	})
+	require.NoError(t, err)
+	require.Equal(t, res.TransferID, replay.TransferID)
+	require.Equal(t, int64(60_000), getBalance(t, 1))
+	require.Equal(t, int64(50_000), getBalance(t, 2))
+}
+
+func TestIntegration_Transfer_OppositeDirectionsConcurrent(t *testing.T) {
+	cleanDB(t)
+
+	svc := newWalletService()
+
+	seedUser(t, 1)
+	seedWallet(t, 1, 100_000)
+	seedUser(t, 2)
+	seedWallet(t, 2, 100_000)
+
+	var wg sync.WaitGroup
+	errCh := make(chan error, 20)
+
+	for i := range 10 {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			_, err := svc.Transfer(context.Background(), service.TransferWalletSpec{
+				FromUserID: 1, ToUserID: 2, Amount: idr(1_000), IdempotencyKey: fmt.Sprintf("k-a-%d", i),
+			})
+			errCh <- err
+		}()
+		go func() {
+			defer wg.Done()
+			_, err := svc.Transfer(context.Background(), service.TransferWalletSpec{
+				FromUserID: 2, ToUserID: 1, Amount: idr(1_000), IdempotencyKey: fmt.Sprintf("k-b-%d", i),
+			})
+			errCh <- err
+		}()
+	}
+	wg.Wait()
+	close(errCh)
+
+	for err := range errCh {
+		require.NoError(t, err)
+	}
+
+	require.Equal(t, int64(100_000), getBalance(t, 1))
+	require.Equal(t, int64(100_000), getBalance(t, 2))
+}
+
+func TestIntegration_ListTransactions_Pagination(t *testing.T) {
+	cleanDB(t)
+
+	svc := newWalletService()
+	userID := int64(1)
+
+	seedUser(t, userID)
+	seedWallet(t, userID, 100_000)
+
+	for i := range 5 {
+		_, err := svc.Withdraw(context.Background(), service.WithdrawWalletSpec{
+			UserID:         userID,
+			Amount:         idr(1_000),
+			IdempotencyKey: fmt.Sprintf("k-history-%d", i),
+		})
+		require.NoError(t, err)
+	}
+
+	seen := map[int64]bool{}
+	cursor := ""
+	pages := 0
+	for {
+		page, err := svc.ListTransactions(context.Background(), service.ListTransactionsSpec{
+			UserID: userID,
+			Type:   domain.LedgerTypeWithdraw,
+			Limit:  2,
+			Cursor: cursor,
+		})
+		require.NoError(t, err)
+		pages++
+
+		for _, l := range page.Ledgers {
+			require.False(t, seen[l.ID])
+			seen[l.ID] = true
+		}
+
+		if page.NextCursor == "" {
+			break
+		}
+		cursor = page.NextCursor
+	}
+
+	require.Equal(t, 3, pages)
+	require.Len(t, seen, 5)
+
+	_, err := svc.ListTransactions(context.Background(), service.ListTransactionsSpec{
+		UserID: userID,
+		Cursor: "not-a-cursor",
+	})
+	require.True(t, errors.Is(err, domain.ErrInvalidCursor))
+}
@@ -0,0 +1,83 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+type Request struct {
+	URL        string
+	Secret     string
+	DeliveryID int64
+	EventID    int64
+	EventType  string
+	CreatedAt  time.Time
+	Payload    json.RawMessage
+}
+
+type body struct {
+	ID        int64           `json:"id"`
+	Type      string          `json:"type"`
+	CreatedAt time.Time       `json:"createdAt"`
+	Data      json.RawMessage `json:"data"`
+}
+
+// Sender POSTs signed deliveries.
+type Sender struct {
+	client *http.Client
+	now    func() time.Time
+}
+
+// Send signs and posts req. It returns the response status code, or 0 when no
+// response was received, and an error unless the receiver answered 2xx.
+func (s Sender) Send(ctx context.Context, req Request) (int, error) {
+	b, err := json.Marshal(body{
+		ID:        req.EventID,
+		Type:      req.EventType,
+		CreatedAt: req.CreatedAt,
+		Data:      req.Payload,
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, req.URL, bytes.NewReader(b))
+	if err != nil {
+		return 0, err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("X-Webhook-ID", strconv.FormatInt(req.DeliveryID, 10))
+	httpReq.Header.Set("X-Webhook-Event", req.EventType)
+	httpReq.Header.Set(SignatureHeader, Sign(req.Secret, s.now(), b))
+
+	res, err := s.client.Do(httpReq)
+	if err != nil {
+		return 0, err
+	}
+	defer res.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(res.Body, 64<<10))
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return res.StatusCode, fmt.Errorf("webhook receiver responded %d", res.StatusCode)
+	}
+
+	return res.StatusCode, nil
+}
+
+// NewSender uses client, or a client with the given timeout when nil.
+func NewSender(client *http.Client, timeout time.Duration) *Sender {
+	if client == nil {
+		client = &http.Client{Timeout: timeout}
+	}
+
+	return &Sender{
+		client: client,
+		now:    time.Now,
+	}
+}
@@ -0,0 +1,79 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSignVerify(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	body := []byte(`{"id":1}`)
+	header := Sign("whsec_test", now, body)
+
+	require.NoError(t, Verify("whsec_test", header, body, 5*time.Minute, now.Add(time.Minute)))
+
+	require.ErrorIs(t, Verify("whsec_other", header, body, 5*time.Minute, now), ErrInvalidSignature)
+	require.ErrorIs(t, Verify("whsec_test", header, []byte(`{"id":2}`), 5*time.Minute, now), ErrInvalidSignature)
+	require.ErrorIs(t, Verify("whsec_test", header, body, 5*time.Minute, now.Add(10*time.Minute)), ErrStaleSignature)
+	require.ErrorIs(t, Verify("whsec_test", "v1=abc", body, 5*time.Minute, now), ErrInvalidSignature)
+	require.ErrorIs(t, Verify("whsec_test", "", body, 5*time.Minute, now), ErrInvalidSignature)
+}
+
+func TestSender_Send(t *testing.T) {
+	type received struct {
+		header http.Header
+		body   []byte
+	}
+	got := make(chan received, 1)
+	status := http.StatusNoContent
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		got <- received{header: r.Header.Clone(), body: b}
+		w.WriteHeader(status)
+	}))
+	defer srv.Close()
+
+	sender := NewSender(srv.Client(), 0)
+	req := Request{
+		URL:        srv.URL,
+		Secret:     "whsec_test",
+		DeliveryID: 9,
+		EventID:    3,
+		EventType:  "wallet.withdraw.succeeded",
+		CreatedAt:  time.Unix(1_700_000_000, 0).UTC(),
+		Payload:    json.RawMessage(`{"amount":1000}`),
+	}
+
+	code, err := sender.Send(context.Background(), req)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusNoContent, code)
+
+	r := <-got
+	require.Equal(t, "9", r.header.Get("X-Webhook-ID"))
+	require.Equal(t, "wallet.withdraw.succeeded", r.header.Get("X-Webhook-Event"))
+	require.NoError(t, Verify("whsec_test", r.header.Get(SignatureHeader), r.body, time.Minute, time.Now()))
+
+	var b map[string]any
+	require.NoError(t, json.Unmarshal(r.body, &b))
+	require.Equal(t, float64(3), b["id"])
+	require.Equal(t, map[string]any{"amount": float64(1000)}, b["data"])
+
+	status = http.StatusInternalServerError
+	code, err = sender.Send(context.Background(), req)
+	require.Error(t, err)
+	require.Equal(t, http.StatusInternalServerError, code)
+	<-got
+
+	srv.Close()
+	code, err = sender.Send(context.Background(), req)
+	require.Error(t, err)
+	require.Equal(t, 0, code)
+}
@@ -0,0 +1,108 @@
+package worker
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/vcnt72/go-boilerplate/internal/outbox"
+	"github.com/vcnt72/go-boilerplate/internal/service"
+	"github.com/vcnt72/go-boilerplate/internal/utils/logger"
+	"go.uber.org/zap"
+)
+
+type OutboxRelayStats struct {
+	Runs      int64      `json:"runs"`
+	Published int64      `json:"published"`
+	Failed    int64      `json:"failed"`
+	Errors    int64      `json:"errors"`
+	LastRunAt *time.Time `json:"lastRunAt"`
+	LastError string     `json:"lastError,omitempty"`
+}
+
+// OutboxRelay periodically publishes pending outbox events to its sink.
+type OutboxRelay struct {
+	outboxService *service.OutboxService
+	sink          outbox.Sink
+	interval      time.Duration
+	batchSize     int
+	lease         time.Duration
+
+	mu    sync.Mutex
+	stats OutboxRelayStats
+}
+
+func (o *OutboxRelay) Run(ctx context.Context) {
+	ticker := time.NewTicker(o.interval)
+	defer ticker.Stop()
+
+	for {
+		o.Relay(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Relay publishes batch by batch until no due event is left.
+func (o *OutboxRelay) Relay(ctx context.Context) {
+	var published, failed int
+	var runErr error
+	for {
+		res, err := o.outboxService.Relay(ctx, service.RelaySpec{
+			Limit: o.batchSize,
+			Lease: o.lease,
+			Sink:  o.sink,
+		})
+		if err != nil {
+			runErr = err
+			break
+		}
+
+		published += res.Published
+		failed += res.Failed
+
+		if res.Found < o.batchSize {
+			break
+		}
+	}
+
+	now := time.Now()
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	o.stats.Runs++
+	o.stats.Published += int64(published)
+	o.stats.Failed += int64(failed)
+	o.stats.LastRunAt = &now
+	if runErr != nil {
+		o.stats.Errors++
+		o.stats.LastError = runErr.Error()
+		logger.Log.Error("error on relay outbox events", zap.Error(runErr))
+	}
+
+	if failed > 0 {
+		logger.Log.Warn("outbox events failed to publish", zap.Int("failed", failed))
+	}
+}
+
+func (o *OutboxRelay) Stats() OutboxRelayStats {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	return o.stats
+}
+
+func NewOutboxRelay(outboxService *service.OutboxService, sink outbox.Sink, interval time.Duration, batchSize int, lease time.Duration) *OutboxRelay {
+	return &OutboxRelay{
+		outboxService: outboxService,
+		sink:          sink,
+		interval:      interval,
+		batchSize:     batchSize,
+		lease:         lease,
+	}
+}
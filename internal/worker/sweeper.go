@@ -0,0 +1,157 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/vcnt72/go-boilerplate/internal/service"
+	"github.com/vcnt72/go-boilerplate/internal/utils/logger"
+	"go.uber.org/zap"
+)
+
+type SweeperStats struct {
+	Runs      int64      `json:"runs"`
+	Found     int64      `json:"found"`
+	Resolved  int64      `json:"resolved"`
+	Expired   int64      `json:"expiredHolds"`
+	Payouts   int64      `json:"settledPayouts"`
+	Errors    int64      `json:"errors"`
+	LastRunAt *time.Time `json:"lastRunAt"`
+	LastError string     `json:"lastError,omitempty"`
+}
+
+// Sweeper periodically resolves ledgers left in PROCESSING, so clients
+// replaying an idempotency key are never stuck on REQUEST_IN_PROGRESS, and
+// releases expired holds. It also sends payouts still pending at the payout
+// provider again.
+type Sweeper struct {
+	recoveryService *service.RecoveryService
+	payoutService   *service.PayoutService
+	interval        time.Duration
+	staleAfter      time.Duration
+	batchSize       int
+
+	mu    sync.Mutex
+	stats SweeperStats
+}
+
+func (s *Sweeper) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		s.Sweep(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Sweep resolves stale ledgers and expired holds batch by batch until none
+// are left.
+func (s *Sweeper) Sweep(ctx context.Context) {
+	var found, resolved, expired, payouts int
+	var runErr error
+	for {
+		res, err := s.recoveryService.ResolveStaleProcessing(ctx, service.ResolveStaleSpec{
+			StaleAfter: s.staleAfter,
+			Limit:      s.batchSize,
+		})
+		if res != nil {
+			found += res.Found
+			resolved += res.Resolved
+		}
+		if err != nil {
+			runErr = err
+			break
+		}
+
+		if res.Found < s.batchSize {
+			break
+		}
+	}
+
+	for runErr == nil {
+		res, err := s.recoveryService.ExpireHolds(ctx, service.ExpireHoldsSpec{
+			Limit: s.batchSize,
+		})
+		if res != nil {
+			expired += res.Expired
+		}
+		if err != nil {
+			runErr = err
+			break
+		}
+
+		if res.Found < s.batchSize {
+			break
+		}
+	}
+
+	// A payout the provider still reports as pending stays stale, so only
+	// one batch is resent per run.
+	if runErr == nil {
+		res, err := s.payoutService.ResendStale(ctx, service.ResendStaleSpec{
+			StaleAfter: s.staleAfter,
+			Limit:      s.batchSize,
+		})
+		if res != nil {
+			payouts += res.Settled
+			err = errors.Join(append([]error{err}, res.Errors...)...)
+		}
+		if err != nil {
+			runErr = err
+		}
+	}
+
+	now := time.Now()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.stats.Runs++
+	s.stats.Found += int64(found)
+	s.stats.Resolved += int64(resolved)
+	s.stats.Expired += int64(expired)
+	s.stats.Payouts += int64(payouts)
+	s.stats.LastRunAt = &now
+	if runErr != nil {
+		s.stats.Errors++
+		s.stats.LastError = runErr.Error()
+		logger.Log.Error("error on sweep processing ledgers", zap.Error(runErr))
+	}
+
+	if resolved > 0 {
+		logger.Log.Info("resolved stale processing ledgers", zap.Int("resolved", resolved))
+	}
+
+	if expired > 0 {
+		logger.Log.Info("released expired holds", zap.Int("expired", expired))
+	}
+
+	if payouts > 0 {
+		logger.Log.Info("settled stale payouts", zap.Int("settled", payouts))
+	}
+}
+
+func (s *Sweeper) Stats() SweeperStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.stats
+}
+
+func NewSweeper(recoveryService *service.RecoveryService, payoutService *service.PayoutService, interval, staleAfter time.Duration, batchSize int) *Sweeper {
+	return &Sweeper{
+		recoveryService: recoveryService,
+		payoutService:   payoutService,
+		interval:        interval,
+		staleAfter:      staleAfter,
+		batchSize:       batchSize,
+	}
+}
@@ -0,0 +1,114 @@
+package worker
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/vcnt72/go-boilerplate/internal/service"
+	"github.com/vcnt72/go-boilerplate/internal/utils/logger"
+	"github.com/vcnt72/go-boilerplate/internal/webhook"
+	"go.uber.org/zap"
+)
+
+type WebhookDispatcherStats struct {
+	Runs      int64      `json:"runs"`
+	Delivered int64      `json:"delivered"`
+	Retried   int64      `json:"retried"`
+	Dead      int64      `json:"dead"`
+	Errors    int64      `json:"errors"`
+	LastRunAt *time.Time `json:"lastRunAt"`
+	LastError string     `json:"lastError,omitempty"`
+}
+
+// WebhookDispatcher periodically sends due webhook deliveries.
+type WebhookDispatcher struct {
+	webhookService *service.WebhookService
+	sender         *webhook.Sender
+	interval       time.Duration
+	batchSize      int
+	maxAttempts    int
+	lease          time.Duration
+
+	mu    sync.Mutex
+	stats WebhookDispatcherStats
+}
+
+func (d *WebhookDispatcher) Run(ctx context.Context) {
+	ticker := time.NewTicker(d.interval)
+	defer ticker.Stop()
+
+	for {
+		d.Dispatch(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Dispatch sends batch by batch until no delivery is due.
+func (d *WebhookDispatcher) Dispatch(ctx context.Context) {
+	var delivered, retried, dead int
+	var runErr error
+	for {
+		res, err := d.webhookService.Dispatch(ctx, service.DispatchSpec{
+			Limit:       d.batchSize,
+			MaxAttempts: d.maxAttempts,
+			Lease:       d.lease,
+			Sender:      d.sender,
+		})
+		if err != nil {
+			runErr = err
+			break
+		}
+
+		delivered += res.Delivered
+		retried += res.Retried
+		dead += res.Dead
+
+		if res.Found < d.batchSize {
+			break
+		}
+	}
+
+	now := time.Now()
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.stats.Runs++
+	d.stats.Delivered += int64(delivered)
+	d.stats.Retried += int64(retried)
+	d.stats.Dead += int64(dead)
+	d.stats.LastRunAt = &now
+	if runErr != nil {
+		d.stats.Errors++
+		d.stats.LastError = runErr.Error()
+		logger.Log.Error("error on dispatch webhooks", zap.Error(runErr))
+	}
+
+	if dead > 0 {
+		logger.Log.Warn("webhook deliveries moved to dead letter", zap.Int("dead", dead))
+	}
+}
+
+func (d *WebhookDispatcher) Stats() WebhookDispatcherStats {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	return d.stats
+}
+
+func NewWebhookDispatcher(webhookService *service.WebhookService, sender *webhook.Sender, interval time.Duration, batchSize, maxAttempts int, lease time.Duration) *WebhookDispatcher {
+	return &WebhookDispatcher{
+		webhookService: webhookService,
+		sender:         sender,
+		interval:       interval,
+		batchSize:      batchSize,
+		maxAttempts:    maxAttempts,
+		lease:          lease,
+	}
+}
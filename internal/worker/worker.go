@@ -0,0 +1,65 @@
+// Package worker
+package worker
+
+import (
+	"context"
+	"sync"
+
+	"github.com/vcnt72/go-boilerplate/internal/config"
+	"github.com/vcnt72/go-boilerplate/internal/outbox"
+	"github.com/vcnt72/go-boilerplate/internal/service"
+	"github.com/vcnt72/go-boilerplate/internal/webhook"
+)
+
+type Workers struct {
+	Sweeper           *Sweeper
+	OutboxRelay       *OutboxRelay
+	WebhookDispatcher *WebhookDispatcher
+}
+
+func New(services service.Services) (Workers, error) {
+	sink, err := outbox.NewSink(config.Env.OutboxSinks, outbox.Options{
+		WebhookURL: config.Env.OutboxWebhookURL,
+		FilePath:   config.Env.OutboxFilePath,
+	})
+	if err != nil {
+		return Workers{}, err
+	}
+
+	return Workers{
+		Sweeper: NewSweeper(
+			services.RecoveryService,
+			services.PayoutService,
+			config.Env.SweeperInterval,
+			config.Env.SweeperStaleAfter,
+			config.Env.SweeperBatchSize,
+		),
+		OutboxRelay: NewOutboxRelay(
+			services.OutboxService,
+			sink,
+			config.Env.OutboxRelayInterval,
+			config.Env.OutboxBatchSize,
+			config.Env.OutboxLease,
+		),
+		WebhookDispatcher: NewWebhookDispatcher(
+			services.WebhookService,
+			webhook.NewSender(nil, config.Env.WebhookTimeout),
+			config.Env.WebhookDispatchInterval,
+			config.Env.WebhookBatchSize,
+			config.Env.WebhookMaxAttempts,
+			config.Env.WebhookLease,
+		),
+	}, nil
+}
+
+// Run starts every background worker and blocks until ctx is cancelled and
+// all of them have returned.
+func (w Workers) Run(ctx context.Context) {
+	var wg sync.WaitGroup
+
+	wg.Go(func() { w.Sweeper.Run(ctx) })
+	wg.Go(func() { w.OutboxRelay.Run(ctx) })
+	wg.Go(func() { w.WebhookDispatcher.Run(ctx) })
+
+	wg.Wait()
+}
@@ -0,0 +1,11 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE ledgers ADD COLUMN transfer_id VARCHAR;
+CREATE INDEX idx_ledgers_transfer_id ON ledgers(transfer_id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX idx_ledgers_transfer_id;
+ALTER TABLE ledgers DROP COLUMN transfer_id;
+-- +goose StatementEnd
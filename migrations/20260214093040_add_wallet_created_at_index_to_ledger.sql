@@ -0,0 +1,9 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE INDEX idx_ledgers_wallet_id_created_at_id ON ledgers(wallet_id, created_at DESC, id DESC);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX idx_ledgers_wallet_id_created_at_id;
+-- +goose StatementEnd
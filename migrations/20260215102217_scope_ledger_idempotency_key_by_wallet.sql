@@ -0,0 +1,11 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE ledgers DROP CONSTRAINT ledgers_idempotency_key_key;
+ALTER TABLE ledgers ADD CONSTRAINT ledgers_wallet_id_idempotency_key_key UNIQUE (wallet_id, idempotency_key);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE ledgers DROP CONSTRAINT ledgers_wallet_id_idempotency_key_key;
+ALTER TABLE ledgers ADD CONSTRAINT ledgers_idempotency_key_key UNIQUE (idempotency_key);
+-- +goose StatementEnd
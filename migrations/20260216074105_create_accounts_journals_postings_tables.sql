@@ -0,0 +1,86 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE accounts(
+  id BIGSERIAL PRIMARY KEY,
+  code varchar not null unique,
+  "type" varchar not null,
+  wallet_id bigint unique,
+  created_at timestamptz default current_timestamp,
+  updated_at timestamptz default current_timestamp,
+  CONSTRAINT fk_wallets FOREIGN KEY (wallet_id) REFERENCES wallets(id)
+);
+
+CREATE TABLE journals(
+  id BIGSERIAL PRIMARY KEY,
+  ledger_id bigint,
+  "type" varchar not null,
+  created_at timestamptz default current_timestamp,
+  CONSTRAINT fk_ledgers FOREIGN KEY (ledger_id) REFERENCES ledgers(id)
+);
+
+CREATE TABLE postings(
+  id BIGSERIAL PRIMARY KEY,
+  journal_id bigint not null,
+  account_id bigint not null,
+  amount bigint not null CHECK (amount <> 0),
+  created_at timestamptz default current_timestamp,
+  CONSTRAINT fk_journals FOREIGN KEY (journal_id) REFERENCES journals(id),
+  CONSTRAINT fk_accounts FOREIGN KEY (account_id) REFERENCES accounts(id)
+);
+
+CREATE INDEX idx_journals_ledger_id ON journals(ledger_id);
+CREATE INDEX idx_postings_journal_id ON postings(journal_id);
+CREATE INDEX idx_postings_account_id ON postings(account_id);
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+CREATE FUNCTION check_journal_balanced() RETURNS trigger AS $$
+DECLARE
+  jid bigint := COALESCE(NEW.journal_id, OLD.journal_id);
+BEGIN
+  IF (SELECT COALESCE(SUM(amount), 0) FROM postings WHERE journal_id = jid) <> 0 THEN
+    RAISE EXCEPTION 'journal % postings do not sum to zero', jid USING ERRCODE = 'check_violation';
+  END IF;
+  RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+CREATE CONSTRAINT TRIGGER trg_postings_balanced
+  AFTER INSERT OR UPDATE OR DELETE ON postings
+  DEFERRABLE INITIALLY DEFERRED
+  FOR EACH ROW EXECUTE FUNCTION check_journal_balanced();
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+DO $$
+DECLARE
+  w record;
+  jid bigint;
+  opening_id bigint;
+  wallet_account_id bigint;
+BEGIN
+  INSERT INTO accounts(code, "type") VALUES ('system:opening-balance', 'SYSTEM') RETURNING id INTO opening_id;
+
+  FOR w IN SELECT id, balance FROM wallets ORDER BY id LOOP
+    INSERT INTO accounts(code, "type", wallet_id) VALUES ('wallet:' || w.id, 'WALLET', w.id) RETURNING id INTO wallet_account_id;
+
+    IF w.balance > 0 THEN
+      INSERT INTO journals("type") VALUES ('OPENING') RETURNING id INTO jid;
+      INSERT INTO postings(journal_id, account_id, amount) VALUES (jid, wallet_account_id, w.balance);
+      INSERT INTO postings(journal_id, account_id, amount) VALUES (jid, opening_id, -w.balance);
+    END IF;
+  END LOOP;
+END;
+$$;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TRIGGER trg_postings_balanced ON postings;
+DROP FUNCTION check_journal_balanced();
+DROP TABLE postings;
+DROP TABLE journals;
+DROP TABLE accounts;
+-- +goose StatementEnd
@@ -0,0 +1,9 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE INDEX idx_ledgers_processing_updated_at ON ledgers(updated_at) WHERE status = 'PROCESSING';
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX idx_ledgers_processing_updated_at;
+-- +goose StatementEnd
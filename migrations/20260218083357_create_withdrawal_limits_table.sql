@@ -0,0 +1,26 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE users ADD COLUMN tier varchar not null default 'STANDARD';
+
+CREATE TABLE withdrawal_limits(
+  id BIGSERIAL PRIMARY KEY,
+  user_id bigint unique,
+  tier varchar unique,
+  max_per_transaction bigint not null default 0 CHECK (max_per_transaction >= 0),
+  max_daily_amount bigint not null default 0 CHECK (max_daily_amount >= 0),
+  max_daily_count bigint not null default 0 CHECK (max_daily_count >= 0),
+  created_at timestamptz default current_timestamp,
+  updated_at timestamptz default current_timestamp,
+  CONSTRAINT fk_users FOREIGN KEY (user_id) REFERENCES users(id),
+  CONSTRAINT chk_withdrawal_limits_scope CHECK ((user_id IS NULL) <> (tier IS NULL))
+);
+
+CREATE INDEX idx_ledgers_wallet_id_type_created_at ON ledgers(wallet_id, "type", created_at);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX idx_ledgers_wallet_id_type_created_at;
+DROP TABLE withdrawal_limits;
+ALTER TABLE users DROP COLUMN tier;
+-- +goose StatementEnd
@@ -0,0 +1,22 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE outbox(
+  id BIGSERIAL PRIMARY KEY,
+  event_type varchar not null,
+  partition_key varchar not null,
+  payload jsonb not null,
+  attempts int not null default 0,
+  last_error text,
+  next_attempt_at timestamptz not null default current_timestamp,
+  published_at timestamptz,
+  created_at timestamptz default current_timestamp
+);
+
+CREATE INDEX idx_outbox_pending ON outbox(id) WHERE published_at IS NULL;
+CREATE INDEX idx_outbox_pending_partition_key ON outbox(partition_key, id) WHERE published_at IS NULL;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE outbox;
+-- +goose StatementEnd
@@ -0,0 +1,40 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE webhook_subscriptions(
+  id BIGSERIAL PRIMARY KEY,
+  url text not null,
+  event_types jsonb not null,
+  secret varchar not null,
+  active boolean not null default true,
+  created_at timestamptz default current_timestamp,
+  updated_at timestamptz default current_timestamp
+);
+
+CREATE TABLE webhook_deliveries(
+  id BIGSERIAL PRIMARY KEY,
+  subscription_id bigint not null,
+  event_id bigint not null,
+  event_type varchar not null,
+  payload jsonb not null,
+  status varchar not null default 'PENDING',
+  attempts int not null default 0,
+  next_attempt_at timestamptz not null default current_timestamp,
+  last_status_code int,
+  last_error text,
+  delivered_at timestamptz,
+  created_at timestamptz default current_timestamp,
+  updated_at timestamptz default current_timestamp,
+  CONSTRAINT fk_webhook_subscriptions FOREIGN KEY (subscription_id) REFERENCES webhook_subscriptions(id) ON DELETE CASCADE,
+  CONSTRAINT fk_outbox FOREIGN KEY (event_id) REFERENCES outbox(id),
+  CONSTRAINT uq_webhook_deliveries_subscription_event UNIQUE (subscription_id, event_id)
+);
+
+CREATE INDEX idx_webhook_deliveries_pending ON webhook_deliveries(next_attempt_at) WHERE status = 'PENDING';
+CREATE INDEX idx_webhook_deliveries_subscription_id ON webhook_deliveries(subscription_id, id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE webhook_deliveries;
+DROP TABLE webhook_subscriptions;
+-- +goose StatementEnd
@@ -0,0 +1,30 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE wallets ADD COLUMN held_balance bigint not null default 0;
+ALTER TABLE wallets ADD CONSTRAINT chk_wallets_held_balance CHECK (held_balance >= 0 AND held_balance <= balance);
+
+CREATE TABLE holds(
+  id BIGSERIAL PRIMARY KEY,
+  wallet_id bigint not null,
+  amount bigint not null CHECK (amount > 0),
+  captured_amount bigint not null default 0 CHECK (captured_amount >= 0 AND captured_amount <= amount),
+  status varchar not null,
+  expires_at timestamptz not null,
+  created_at timestamptz default current_timestamp,
+  updated_at timestamptz default current_timestamp,
+  CONSTRAINT fk_wallets FOREIGN KEY (wallet_id) REFERENCES wallets(id)
+);
+
+CREATE INDEX idx_holds_active_expires_at ON holds(expires_at) WHERE status = 'ACTIVE';
+
+ALTER TABLE ledgers ADD COLUMN hold_id bigint;
+ALTER TABLE ledgers ADD CONSTRAINT fk_holds FOREIGN KEY (hold_id) REFERENCES holds(id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE ledgers DROP COLUMN hold_id;
+DROP TABLE holds;
+ALTER TABLE wallets DROP CONSTRAINT chk_wallets_held_balance;
+ALTER TABLE wallets DROP COLUMN held_balance;
+-- +goose StatementEnd
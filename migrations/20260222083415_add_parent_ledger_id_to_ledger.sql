@@ -0,0 +1,12 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE ledgers ADD COLUMN parent_ledger_id bigint;
+ALTER TABLE ledgers ADD CONSTRAINT fk_parent_ledgers FOREIGN KEY (parent_ledger_id) REFERENCES ledgers(id);
+CREATE INDEX idx_ledgers_parent_ledger_id ON ledgers(parent_ledger_id) WHERE parent_ledger_id IS NOT NULL;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX idx_ledgers_parent_ledger_id;
+ALTER TABLE ledgers DROP COLUMN parent_ledger_id;
+-- +goose StatementEnd
@@ -0,0 +1,19 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE wallets ADD COLUMN currency varchar(3) not null default 'IDR';
+ALTER TABLE wallets ADD COLUMN currency_exponent smallint not null default 0;
+ALTER TABLE wallets ALTER COLUMN currency DROP DEFAULT;
+ALTER TABLE wallets ALTER COLUMN currency_exponent DROP DEFAULT;
+CREATE UNIQUE INDEX uq_wallets_user_id_currency ON wallets(user_id, currency);
+
+-- Existing balances are all rupiah, so the system accounts become IDR ones.
+UPDATE accounts SET code = code || ':IDR' WHERE "type" = 'SYSTEM';
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+UPDATE accounts SET code = left(code, length(code) - 4) WHERE "type" = 'SYSTEM' AND code LIKE '%:IDR';
+DROP INDEX uq_wallets_user_id_currency;
+ALTER TABLE wallets DROP COLUMN currency_exponent;
+ALTER TABLE wallets DROP COLUMN currency;
+-- +goose StatementEnd
@@ -0,0 +1,16 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE ledgers ADD COLUMN currency varchar(3);
+UPDATE ledgers l SET currency = w.currency FROM wallets w WHERE w.id = l.wallet_id;
+ALTER TABLE ledgers ALTER COLUMN currency SET NOT NULL;
+
+ALTER TABLE holds ADD COLUMN currency varchar(3);
+UPDATE holds h SET currency = w.currency FROM wallets w WHERE w.id = h.wallet_id;
+ALTER TABLE holds ALTER COLUMN currency SET NOT NULL;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE holds DROP COLUMN currency;
+ALTER TABLE ledgers DROP COLUMN currency;
+-- +goose StatementEnd
@@ -0,0 +1,35 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE fx_rates(
+  base_currency varchar(3) not null,
+  quote_currency varchar(3) not null,
+  rate numeric(30,12) not null CHECK (rate > 0),
+  fee_bps int not null default 0 CHECK (fee_bps >= 0 AND fee_bps < 10000),
+  created_at timestamptz default current_timestamp,
+  updated_at timestamptz default current_timestamp,
+  PRIMARY KEY (base_currency, quote_currency)
+);
+
+CREATE TABLE fx_quotes(
+  id uuid PRIMARY KEY,
+  user_id bigint not null,
+  from_currency varchar(3) not null,
+  to_currency varchar(3) not null,
+  from_amount bigint not null CHECK (from_amount > 0),
+  fee bigint not null CHECK (fee >= 0),
+  to_amount bigint not null CHECK (to_amount > 0),
+  rate numeric(30,12) not null,
+  status varchar not null,
+  expires_at timestamptz not null,
+  executed_at timestamptz,
+  created_at timestamptz default current_timestamp,
+  updated_at timestamptz default current_timestamp,
+  CONSTRAINT fk_users FOREIGN KEY (user_id) REFERENCES users(id)
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE fx_quotes;
+DROP TABLE fx_rates;
+-- +goose StatementEnd
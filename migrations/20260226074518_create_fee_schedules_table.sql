@@ -0,0 +1,22 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE fee_schedules(
+  id BIGSERIAL PRIMARY KEY,
+  user_id bigint unique,
+  tier varchar unique,
+  flat bigint not null default 0 CHECK (flat >= 0),
+  percent_bps bigint not null default 0 CHECK (percent_bps >= 0 AND percent_bps <= 10000),
+  min_fee bigint not null default 0 CHECK (min_fee >= 0),
+  max_fee bigint not null default 0 CHECK (max_fee >= 0),
+  created_at timestamptz default current_timestamp,
+  updated_at timestamptz default current_timestamp,
+  CONSTRAINT fk_users FOREIGN KEY (user_id) REFERENCES users(id),
+  CONSTRAINT chk_fee_schedules_scope CHECK ((user_id IS NULL) <> (tier IS NULL)),
+  CONSTRAINT chk_fee_schedules_range CHECK (max_fee = 0 OR max_fee >= min_fee)
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE fee_schedules;
+-- +goose StatementEnd
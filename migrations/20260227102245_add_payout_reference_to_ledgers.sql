@@ -0,0 +1,17 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE ledgers ADD COLUMN payout_reference varchar(64);
+
+CREATE UNIQUE INDEX ledgers_withdraw_payout_reference_key ON ledgers (payout_reference)
+    WHERE type = 'WITHDRAW' AND payout_reference IS NOT NULL;
+
+CREATE INDEX ledgers_payout_pending_idx ON ledgers (updated_at)
+    WHERE status IN ('PROCESSING', 'SENT') AND payout_reference IS NOT NULL;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS ledgers_payout_pending_idx;
+DROP INDEX IF EXISTS ledgers_withdraw_payout_reference_key;
+ALTER TABLE ledgers DROP COLUMN payout_reference;
+-- +goose StatementEnd
@@ -0,0 +1,22 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE fee_schedules ADD COLUMN currency varchar(3) not null default 'IDR';
+ALTER TABLE fee_schedules ALTER COLUMN currency DROP DEFAULT;
+
+-- Existing schedules were all priced in rupiah; each scope now has one
+-- schedule per currency.
+ALTER TABLE fee_schedules DROP CONSTRAINT fee_schedules_user_id_key;
+ALTER TABLE fee_schedules DROP CONSTRAINT fee_schedules_tier_key;
+CREATE UNIQUE INDEX uq_fee_schedules_user_id_currency ON fee_schedules(user_id, currency);
+CREATE UNIQUE INDEX uq_fee_schedules_tier_currency ON fee_schedules(tier, currency);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DELETE FROM fee_schedules WHERE currency <> 'IDR';
+DROP INDEX uq_fee_schedules_tier_currency;
+DROP INDEX uq_fee_schedules_user_id_currency;
+ALTER TABLE fee_schedules ADD CONSTRAINT fee_schedules_tier_key UNIQUE (tier);
+ALTER TABLE fee_schedules ADD CONSTRAINT fee_schedules_user_id_key UNIQUE (user_id);
+ALTER TABLE fee_schedules DROP COLUMN currency;
+-- +goose StatementEnd
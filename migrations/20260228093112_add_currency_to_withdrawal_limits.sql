@@ -0,0 +1,22 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE withdrawal_limits ADD COLUMN currency varchar(3) not null default 'IDR';
+ALTER TABLE withdrawal_limits ALTER COLUMN currency DROP DEFAULT;
+
+-- Existing limits were all in rupiah; each scope now has one limit row per
+-- currency.
+ALTER TABLE withdrawal_limits DROP CONSTRAINT withdrawal_limits_user_id_key;
+ALTER TABLE withdrawal_limits DROP CONSTRAINT withdrawal_limits_tier_key;
+CREATE UNIQUE INDEX uq_withdrawal_limits_user_id_currency ON withdrawal_limits(user_id, currency);
+CREATE UNIQUE INDEX uq_withdrawal_limits_tier_currency ON withdrawal_limits(tier, currency);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DELETE FROM withdrawal_limits WHERE currency <> 'IDR';
+DROP INDEX uq_withdrawal_limits_tier_currency;
+DROP INDEX uq_withdrawal_limits_user_id_currency;
+ALTER TABLE withdrawal_limits ADD CONSTRAINT withdrawal_limits_tier_key UNIQUE (tier);
+ALTER TABLE withdrawal_limits ADD CONSTRAINT withdrawal_limits_user_id_key UNIQUE (user_id);
+ALTER TABLE withdrawal_limits DROP COLUMN currency;
+-- +goose StatementEnd
@@ -0,0 +1,231 @@
+-- The SQLite schema matches the Postgres one as of the migration of the same
+-- version. Times are text in the layout of strftime('%Y-%m-%d %H:%M:%f000+00:00'),
+-- which the repositories bind times in too, and rates are text so that they
+-- keep their digits. The balanced-journal check has no SQLite counterpart, as
+-- SQLite triggers cannot be deferred to commit; JournalRepository.Create
+-- validates journals before inserting them.
+
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE users(
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  name varchar not null,
+  tier varchar not null default 'STANDARD',
+  created_at timestamp default (strftime('%Y-%m-%d %H:%M:%f000+00:00', 'now')),
+  updated_at timestamp default (strftime('%Y-%m-%d %H:%M:%f000+00:00', 'now'))
+);
+
+CREATE TABLE wallets(
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  user_id bigint not null,
+  balance bigint not null check (balance >= 0),
+  held_balance bigint not null default 0,
+  currency varchar(3) not null,
+  currency_exponent smallint not null,
+  created_at timestamp default (strftime('%Y-%m-%d %H:%M:%f000+00:00', 'now')),
+  updated_at timestamp default (strftime('%Y-%m-%d %H:%M:%f000+00:00', 'now')),
+  CONSTRAINT fk_users FOREIGN KEY (user_id) REFERENCES users(id),
+  CONSTRAINT chk_wallets_held_balance CHECK (held_balance >= 0 AND held_balance <= balance)
+);
+
+CREATE UNIQUE INDEX uq_wallets_user_id_currency ON wallets(user_id, currency);
+
+CREATE TABLE holds(
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  wallet_id bigint not null,
+  currency varchar(3) not null,
+  amount bigint not null CHECK (amount > 0),
+  captured_amount bigint not null default 0 CHECK (captured_amount >= 0 AND captured_amount <= amount),
+  status varchar not null,
+  expires_at timestamp not null,
+  created_at timestamp default (strftime('%Y-%m-%d %H:%M:%f000+00:00', 'now')),
+  updated_at timestamp default (strftime('%Y-%m-%d %H:%M:%f000+00:00', 'now')),
+  CONSTRAINT fk_wallets FOREIGN KEY (wallet_id) REFERENCES wallets(id)
+);
+
+CREATE INDEX idx_holds_active_expires_at ON holds(expires_at) WHERE status = 'ACTIVE';
+
+CREATE TABLE ledgers(
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  wallet_id bigint not null,
+  "type" varchar not null,
+  idempotency_key varchar not null,
+  currency varchar(3) not null,
+  amount bigint not null CHECK (amount >= 0),
+  status varchar not null,
+  error_code varchar,
+  result_balance bigint,
+  transfer_id varchar,
+  hold_id bigint,
+  parent_ledger_id bigint,
+  payout_reference varchar(64),
+  created_at timestamp default (strftime('%Y-%m-%d %H:%M:%f000+00:00', 'now')),
+  updated_at timestamp default (strftime('%Y-%m-%d %H:%M:%f000+00:00', 'now')),
+  CONSTRAINT fk_wallets FOREIGN KEY (wallet_id) REFERENCES wallets(id),
+  CONSTRAINT fk_holds FOREIGN KEY (hold_id) REFERENCES holds(id),
+  CONSTRAINT fk_parent_ledgers FOREIGN KEY (parent_ledger_id) REFERENCES ledgers(id),
+  CONSTRAINT ledgers_wallet_id_idempotency_key_key UNIQUE (wallet_id, idempotency_key)
+);
+
+CREATE INDEX idx_ledgers_transfer_id ON ledgers(transfer_id);
+CREATE INDEX idx_ledgers_wallet_id_created_at_id ON ledgers(wallet_id, created_at DESC, id DESC);
+CREATE INDEX idx_ledgers_processing_updated_at ON ledgers(updated_at) WHERE status = 'PROCESSING';
+CREATE INDEX idx_ledgers_wallet_id_type_created_at ON ledgers(wallet_id, "type", created_at);
+CREATE INDEX idx_ledgers_parent_ledger_id ON ledgers(parent_ledger_id) WHERE parent_ledger_id IS NOT NULL;
+CREATE UNIQUE INDEX ledgers_withdraw_payout_reference_key ON ledgers(payout_reference)
+  WHERE "type" = 'WITHDRAW' AND payout_reference IS NOT NULL;
+CREATE INDEX ledgers_payout_pending_idx ON ledgers(updated_at)
+  WHERE status IN ('PROCESSING', 'SENT') AND payout_reference IS NOT NULL;
+
+CREATE TABLE accounts(
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  code varchar not null unique,
+  "type" varchar not null,
+  wallet_id bigint unique,
+  created_at timestamp default (strftime('%Y-%m-%d %H:%M:%f000+00:00', 'now')),
+  updated_at timestamp default (strftime('%Y-%m-%d %H:%M:%f000+00:00', 'now')),
+  CONSTRAINT fk_wallets FOREIGN KEY (wallet_id) REFERENCES wallets(id)
+);
+
+CREATE TABLE journals(
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  ledger_id bigint,
+  "type" varchar not null,
+  created_at timestamp default (strftime('%Y-%m-%d %H:%M:%f000+00:00', 'now')),
+  CONSTRAINT fk_ledgers FOREIGN KEY (ledger_id) REFERENCES ledgers(id)
+);
+
+CREATE TABLE postings(
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  journal_id bigint not null,
+  account_id bigint not null,
+  amount bigint not null CHECK (amount <> 0),
+  created_at timestamp default (strftime('%Y-%m-%d %H:%M:%f000+00:00', 'now')),
+  CONSTRAINT fk_journals FOREIGN KEY (journal_id) REFERENCES journals(id),
+  CONSTRAINT fk_accounts FOREIGN KEY (account_id) REFERENCES accounts(id)
+);
+
+CREATE INDEX idx_journals_ledger_id ON journals(ledger_id);
+CREATE INDEX idx_postings_journal_id ON postings(journal_id);
+CREATE INDEX idx_postings_account_id ON postings(account_id);
+
+CREATE TABLE withdrawal_limits(
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  user_id bigint unique,
+  tier varchar unique,
+  max_per_transaction bigint not null default 0 CHECK (max_per_transaction >= 0),
+  max_daily_amount bigint not null default 0 CHECK (max_daily_amount >= 0),
+  max_daily_count bigint not null default 0 CHECK (max_daily_count >= 0),
+  created_at timestamp default (strftime('%Y-%m-%d %H:%M:%f000+00:00', 'now')),
+  updated_at timestamp default (strftime('%Y-%m-%d %H:%M:%f000+00:00', 'now')),
+  CONSTRAINT fk_users FOREIGN KEY (user_id) REFERENCES users(id),
+  CONSTRAINT chk_withdrawal_limits_scope CHECK ((user_id IS NULL) <> (tier IS NULL))
+);
+
+CREATE TABLE fee_schedules(
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  user_id bigint unique,
+  tier varchar unique,
+  flat bigint not null default 0 CHECK (flat >= 0),
+  percent_bps bigint not null default 0 CHECK (percent_bps >= 0 AND percent_bps <= 10000),
+  min_fee bigint not null default 0 CHECK (min_fee >= 0),
+  max_fee bigint not null default 0 CHECK (max_fee >= 0),
+  created_at timestamp default (strftime('%Y-%m-%d %H:%M:%f000+00:00', 'now')),
+  updated_at timestamp default (strftime('%Y-%m-%d %H:%M:%f000+00:00', 'now')),
+  CONSTRAINT fk_users FOREIGN KEY (user_id) REFERENCES users(id),
+  CONSTRAINT chk_fee_schedules_scope CHECK ((user_id IS NULL) <> (tier IS NULL)),
+  CONSTRAINT chk_fee_schedules_range CHECK (max_fee = 0 OR max_fee >= min_fee)
+);
+
+CREATE TABLE outbox(
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  event_type varchar not null,
+  partition_key varchar not null,
+  payload text not null CHECK (json_valid(payload)),
+  attempts int not null default 0,
+  last_error text,
+  next_attempt_at timestamp not null default (strftime('%Y-%m-%d %H:%M:%f000+00:00', 'now')),
+  published_at timestamp,
+  created_at timestamp default (strftime('%Y-%m-%d %H:%M:%f000+00:00', 'now'))
+);
+
+CREATE INDEX idx_outbox_pending ON outbox(id) WHERE published_at IS NULL;
+CREATE INDEX idx_outbox_pending_partition_key ON outbox(partition_key, id) WHERE published_at IS NULL;
+
+CREATE TABLE webhook_subscriptions(
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  url text not null,
+  event_types text not null CHECK (json_valid(event_types)),
+  secret varchar not null,
+  active boolean not null default true,
+  created_at timestamp default (strftime('%Y-%m-%d %H:%M:%f000+00:00', 'now')),
+  updated_at timestamp default (strftime('%Y-%m-%d %H:%M:%f000+00:00', 'now'))
+);
+
+CREATE TABLE webhook_deliveries(
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  subscription_id bigint not null,
+  event_id bigint not null,
+  event_type varchar not null,
+  payload text not null CHECK (json_valid(payload)),
+  status varchar not null default 'PENDING',
+  attempts int not null default 0,
+  next_attempt_at timestamp not null default (strftime('%Y-%m-%d %H:%M:%f000+00:00', 'now')),
+  last_status_code int,
+  last_error text,
+  delivered_at timestamp,
+  created_at timestamp default (strftime('%Y-%m-%d %H:%M:%f000+00:00', 'now')),
+  updated_at timestamp default (strftime('%Y-%m-%d %H:%M:%f000+00:00', 'now')),
+  CONSTRAINT fk_webhook_subscriptions FOREIGN KEY (subscription_id) REFERENCES webhook_subscriptions(id) ON DELETE CASCADE,
+  CONSTRAINT fk_outbox FOREIGN KEY (event_id) REFERENCES outbox(id),
+  CONSTRAINT uq_webhook_deliveries_subscription_event UNIQUE (subscription_id, event_id)
+);
+
+CREATE INDEX idx_webhook_deliveries_pending ON webhook_deliveries(next_attempt_at) WHERE status = 'PENDING';
+CREATE INDEX idx_webhook_deliveries_subscription_id ON webhook_deliveries(subscription_id, id);
+
+CREATE TABLE fx_rates(
+  base_currency varchar(3) not null,
+  quote_currency varchar(3) not null,
+  rate text not null CHECK (CAST(rate AS REAL) > 0),
+  fee_bps int not null default 0 CHECK (fee_bps >= 0 AND fee_bps < 10000),
+  created_at timestamp default (strftime('%Y-%m-%d %H:%M:%f000+00:00', 'now')),
+  updated_at timestamp default (strftime('%Y-%m-%d %H:%M:%f000+00:00', 'now')),
+  PRIMARY KEY (base_currency, quote_currency)
+);
+
+CREATE TABLE fx_quotes(
+  id text PRIMARY KEY,
+  user_id bigint not null,
+  from_currency varchar(3) not null,
+  to_currency varchar(3) not null,
+  from_amount bigint not null CHECK (from_amount > 0),
+  fee bigint not null CHECK (fee >= 0),
+  to_amount bigint not null CHECK (to_amount > 0),
+  rate text not null,
+  status varchar not null,
+  expires_at timestamp not null,
+  executed_at timestamp,
+  created_at timestamp default (strftime('%Y-%m-%d %H:%M:%f000+00:00', 'now')),
+  updated_at timestamp default (strftime('%Y-%m-%d %H:%M:%f000+00:00', 'now')),
+  CONSTRAINT fk_users FOREIGN KEY (user_id) REFERENCES users(id)
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE fx_quotes;
+DROP TABLE fx_rates;
+DROP TABLE webhook_deliveries;
+DROP TABLE webhook_subscriptions;
+DROP TABLE outbox;
+DROP TABLE fee_schedules;
+DROP TABLE withdrawal_limits;
+DROP TABLE postings;
+DROP TABLE journals;
+DROP TABLE accounts;
+DROP TABLE ledgers;
+DROP TABLE holds;
+DROP TABLE wallets;
+DROP TABLE users;
+-- +goose StatementEnd
@@ -0,0 +1,52 @@
+-- SQLite cannot drop a column's unique constraint, so the table is rebuilt.
+
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE fee_schedules_new(
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  user_id bigint,
+  tier varchar,
+  currency varchar(3) not null,
+  flat bigint not null default 0 CHECK (flat >= 0),
+  percent_bps bigint not null default 0 CHECK (percent_bps >= 0 AND percent_bps <= 10000),
+  min_fee bigint not null default 0 CHECK (min_fee >= 0),
+  max_fee bigint not null default 0 CHECK (max_fee >= 0),
+  created_at timestamp default (strftime('%Y-%m-%d %H:%M:%f000+00:00', 'now')),
+  updated_at timestamp default (strftime('%Y-%m-%d %H:%M:%f000+00:00', 'now')),
+  CONSTRAINT fk_users FOREIGN KEY (user_id) REFERENCES users(id),
+  CONSTRAINT chk_fee_schedules_scope CHECK ((user_id IS NULL) <> (tier IS NULL)),
+  CONSTRAINT chk_fee_schedules_range CHECK (max_fee = 0 OR max_fee >= min_fee)
+);
+
+INSERT INTO fee_schedules_new (id, user_id, tier, currency, flat, percent_bps, min_fee, max_fee, created_at, updated_at)
+SELECT id, user_id, tier, 'IDR', flat, percent_bps, min_fee, max_fee, created_at, updated_at FROM fee_schedules;
+
+DROP TABLE fee_schedules;
+ALTER TABLE fee_schedules_new RENAME TO fee_schedules;
+CREATE UNIQUE INDEX uq_fee_schedules_user_id_currency ON fee_schedules(user_id, currency);
+CREATE UNIQUE INDEX uq_fee_schedules_tier_currency ON fee_schedules(tier, currency);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+CREATE TABLE fee_schedules_old(
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  user_id bigint unique,
+  tier varchar unique,
+  flat bigint not null default 0 CHECK (flat >= 0),
+  percent_bps bigint not null default 0 CHECK (percent_bps >= 0 AND percent_bps <= 10000),
+  min_fee bigint not null default 0 CHECK (min_fee >= 0),
+  max_fee bigint not null default 0 CHECK (max_fee >= 0),
+  created_at timestamp default (strftime('%Y-%m-%d %H:%M:%f000+00:00', 'now')),
+  updated_at timestamp default (strftime('%Y-%m-%d %H:%M:%f000+00:00', 'now')),
+  CONSTRAINT fk_users FOREIGN KEY (user_id) REFERENCES users(id),
+  CONSTRAINT chk_fee_schedules_scope CHECK ((user_id IS NULL) <> (tier IS NULL)),
+  CONSTRAINT chk_fee_schedules_range CHECK (max_fee = 0 OR max_fee >= min_fee)
+);
+
+INSERT INTO fee_schedules_old (id, user_id, tier, flat, percent_bps, min_fee, max_fee, created_at, updated_at)
+SELECT id, user_id, tier, flat, percent_bps, min_fee, max_fee, created_at, updated_at FROM fee_schedules WHERE currency = 'IDR';
+
+DROP TABLE fee_schedules;
+ALTER TABLE fee_schedules_old RENAME TO fee_schedules;
+-- +goose StatementEnd
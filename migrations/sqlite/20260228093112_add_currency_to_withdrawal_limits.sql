@@ -0,0 +1,48 @@
+-- SQLite cannot drop a column's unique constraint, so the table is rebuilt.
+
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE withdrawal_limits_new(
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  user_id bigint,
+  tier varchar,
+  currency varchar(3) not null,
+  max_per_transaction bigint not null default 0 CHECK (max_per_transaction >= 0),
+  max_daily_amount bigint not null default 0 CHECK (max_daily_amount >= 0),
+  max_daily_count bigint not null default 0 CHECK (max_daily_count >= 0),
+  created_at timestamp default (strftime('%Y-%m-%d %H:%M:%f000+00:00', 'now')),
+  updated_at timestamp default (strftime('%Y-%m-%d %H:%M:%f000+00:00', 'now')),
+  CONSTRAINT fk_users FOREIGN KEY (user_id) REFERENCES users(id),
+  CONSTRAINT chk_withdrawal_limits_scope CHECK ((user_id IS NULL) <> (tier IS NULL))
+);
+
+INSERT INTO withdrawal_limits_new (id, user_id, tier, currency, max_per_transaction, max_daily_amount, max_daily_count, created_at, updated_at)
+SELECT id, user_id, tier, 'IDR', max_per_transaction, max_daily_amount, max_daily_count, created_at, updated_at FROM withdrawal_limits;
+
+DROP TABLE withdrawal_limits;
+ALTER TABLE withdrawal_limits_new RENAME TO withdrawal_limits;
+CREATE UNIQUE INDEX uq_withdrawal_limits_user_id_currency ON withdrawal_limits(user_id, currency);
+CREATE UNIQUE INDEX uq_withdrawal_limits_tier_currency ON withdrawal_limits(tier, currency);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+CREATE TABLE withdrawal_limits_old(
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  user_id bigint unique,
+  tier varchar unique,
+  max_per_transaction bigint not null default 0 CHECK (max_per_transaction >= 0),
+  max_daily_amount bigint not null default 0 CHECK (max_daily_amount >= 0),
+  max_daily_count bigint not null default 0 CHECK (max_daily_count >= 0),
+  created_at timestamp default (strftime('%Y-%m-%d %H:%M:%f000+00:00', 'now')),
+  updated_at timestamp default (strftime('%Y-%m-%d %H:%M:%f000+00:00', 'now')),
+  CONSTRAINT fk_users FOREIGN KEY (user_id) REFERENCES users(id),
+  CONSTRAINT chk_withdrawal_limits_scope CHECK ((user_id IS NULL) <> (tier IS NULL))
+);
+
+INSERT INTO withdrawal_limits_old (id, user_id, tier, max_per_transaction, max_daily_amount, max_daily_count, created_at, updated_at)
+SELECT id, user_id, tier, max_per_transaction, max_daily_amount, max_daily_count, created_at, updated_at FROM withdrawal_limits WHERE currency = 'IDR';
+
+DROP TABLE withdrawal_limits;
+ALTER TABLE withdrawal_limits_old RENAME TO withdrawal_limits;
+-- +goose StatementEnd